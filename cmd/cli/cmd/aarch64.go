@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/keurnel/assembler/cmd/cli/cmd/aarch64"
+	"github.com/spf13/cobra"
+)
+
+var aarch64Cmd = &cobra.Command{
+	Use:     "aarch64",
+	GroupID: "arch",
+	Short:   "AArch64 architecture",
+	Long:    `Functions related to the AArch64 (ARMv8-A) architecture.`,
+}
+
+func init() {
+	aarch64Cmd.AddGroup(&cobra.Group{
+		ID:    "file-operations",
+		Title: "File Operations",
+	})
+
+	aarch64Cmd.AddCommand(aarch64.AssembleFileCmd)
+}
@@ -0,0 +1,6 @@
+package aarch64
+
+import "github.com/keurnel/assembler/cmd/cli/cmd/pipeline"
+
+// AssembleFileCmd assembles an AArch64 source file.
+var AssembleFileCmd = pipeline.NewAssembleFileCmd("aarch64")
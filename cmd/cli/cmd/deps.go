@@ -0,0 +1,5 @@
+package cmd
+
+import "github.com/keurnel/assembler/cmd/cli/cmd/pipeline"
+
+var depsCmd = pipeline.NewDepsCmd()
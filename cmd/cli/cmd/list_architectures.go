@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/keurnel/assembler/cmd/cli/cmd/pipeline"
+	"github.com/spf13/cobra"
+)
+
+var listArchitecturesCmd = &cobra.Command{
+	Use:   "list-architectures",
+	Short: "List the architectures available to --arch",
+	Long:  `List the registered architectures with their aliases, descriptions and instruction counts.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "NAME\tALIASES\tINSTRUCTIONS\tDESCRIPTION")
+		for _, target := range pipeline.Targets() {
+			// A mnemonic listed in several groups is one instruction.
+			count := len(pipeline.BuildInstructionTable(target))
+			aliases := strings.Join(target.Aliases, ", ")
+			if aliases == "" {
+				aliases = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", target.Name, aliases, count, target.Description)
+		}
+		w.Flush()
+	},
+}
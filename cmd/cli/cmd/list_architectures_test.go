@@ -0,0 +1,30 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+// TestListArchitectures_CountsUniqueMnemonics verifies that an instruction
+// listed in several groups is counted once: x86_64 lists MOV in both its
+// arithmetic and data-transfer groups.
+func TestListArchitectures_CountsUniqueMnemonics(t *testing.T) {
+	var stdout strings.Builder
+	listArchitecturesCmd.SetOut(&stdout)
+	defer listArchitecturesCmd.SetOut(nil)
+	listArchitecturesCmd.Run(listArchitecturesCmd, nil)
+
+	for _, line := range strings.Split(stdout.String(), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || fields[0] != "x86_64" {
+			continue
+		}
+		// NAME, three aliases, INSTRUCTIONS, DESCRIPTION…
+		if index := slices.Index(fields, "x64") + 1; index == 0 || fields[index] != "2" {
+			t.Errorf("expected 2 instructions for x86_64, got %q", line)
+		}
+		return
+	}
+	t.Fatalf("expected a row for x86_64, got:\n%s", stdout.String())
+}
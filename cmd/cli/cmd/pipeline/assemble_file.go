@@ -0,0 +1,802 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/keurnel/assembler/internal/debugcontext"
+	"github.com/keurnel/assembler/internal/lineMap"
+	"github.com/keurnel/assembler/v0/architecture"
+	"github.com/keurnel/assembler/v0/kasm"
+	"github.com/keurnel/assembler/v0/kasm/ast"
+	"github.com/keurnel/assembler/v0/kasm/dependency_graph"
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+	"github.com/keurnel/assembler/v0/kasm/profile"
+	"github.com/spf13/cobra"
+)
+
+// Target describes the architecture an assembly run is performed for. Targets
+// are resolved by name through TargetByName; the pipeline itself is
+// architecture-neutral.
+type Target struct {
+	// Name - the canonical architecture name (e.g. "x86_64").
+	Name string
+	// Aliases - alternative names accepted for the architecture.
+	Aliases []string
+	// Description - a short, human-readable description of the architecture.
+	Description string
+	// Bits - the word size of the architecture in bits.
+	Bits int
+	// Instructions - returns the architecture's instruction groups.
+	Instructions func() map[string][]architecture.Instruction
+	// Profile - returns the lexer vocabulary for the architecture.
+	Profile func() profile.ArchitectureProfile
+	// Backend - encodes instructions during code generation.
+	Backend kasm.Backend
+}
+
+// NewAssembleFileCmd returns the `assemble-file` command of an architecture
+// subcommand group. The architecture is resolved through the registry when
+// the command runs.
+func NewAssembleFileCmd(arch string) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:     "assemble-file <assembly-file>",
+		GroupID: "file-operations",
+		Short:   fmt.Sprintf("Assemble an %s assembly file into a binary file.", arch),
+		Long:    fmt.Sprintf(`Assemble an %s assembly file into a binary file.`, arch),
+		Run: func(cmd *cobra.Command, args []string) {
+			target, err := TargetByName(arch)
+			if err == nil {
+				err = runAssembleFile(cmd, args, target)
+			}
+			if err != nil {
+				cmd.PrintErrln("Error:", err)
+			}
+		},
+	}
+
+	addAssembleFlags(cmd)
+
+	return cmd
+}
+
+// NewAssembleCmd returns the architecture-neutral `assemble` command, which
+// selects the target with --arch.
+func NewAssembleCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "assemble <assembly-file>",
+		Short: "Assemble an assembly file into a binary file for the selected architecture.",
+		Long: `Assemble an assembly file into a binary file for the architecture selected
+with --arch. Run 'list-architectures' to see the available architectures.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			arch, _ := cmd.Flags().GetString("arch")
+			target, err := TargetByName(arch)
+			if err == nil {
+				err = runAssembleFile(cmd, args, target)
+			}
+			if err != nil {
+				cmd.PrintErrln("Error:", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringP("arch", "a", DefaultArchitecture, "Target architecture (name or alias)")
+	addAssembleFlags(cmd)
+
+	return cmd
+}
+
+// addAssembleFlags registers the flags shared by every assemble command.
+func addAssembleFlags(cmd *cobra.Command) {
+	cmd.Annotations = map[string]string{dependencyFlagsAnnotation: "true"}
+	cmd.Flags().BoolP("verbose", "v", false, "Show debug context logs (trace, info, warning) during assembly")
+	cmd.Flags().Bool("dependency-graph-dot", false, "Print the dependency graph in Graphviz DOT format and exit")
+	cmd.Flags().Int("macro-depth", preProcessing.DefaultMacroExpansionDepth, "Maximum nesting depth of macro expansion")
+	addSymbolFlags(cmd)
+	cmd.Flags().BoolP("preprocess-only", "E", false, "Write the pre-processed source with %line markers instead of assembling")
+	cmd.Flags().StringP("output", "o", "", "Output file (default: the input file with a .bin extension, or stdout with -E)")
+	cmd.Flags().Bool("MD", false, "Also write a Make dependency file listing the included and embedded files (-MD)")
+	cmd.Flags().String("MF", "", "Dependency file written with -MD (-MF; default: the output file with a .d extension)")
+}
+
+// addSymbolFlags registers the flags that decide which files are included:
+// the include search path and the command-line symbols.
+func addSymbolFlags(cmd *cobra.Command) {
+	cmd.Flags().StringArrayP("include", "I", nil, "Add a directory to the include search path (repeatable, searched in order)")
+	cmd.Flags().StringArrayP("define", "D", nil, "Define a symbol as NAME or NAME=VALUE before the source (repeatable)")
+	cmd.Flags().StringArrayP("undefine", "U", nil, "Undefine a symbol, overriding -D and the source (repeatable)")
+}
+
+// preProcessOptions holds the command-line settings that affect
+// pre-processing.
+type preProcessOptions struct {
+	// macroDepth - the maximum nesting depth of macro expansion.
+	macroDepth int
+	// searchPath - the ordered include directories: -I flags followed by
+	// KASM_INCLUDE, as absolute paths.
+	searchPath []string
+	// commandLine - the symbols defined (-D) and undefined (-U) on the
+	// command line.
+	commandLine *preProcessing.CommandLineSymbols
+}
+
+// graphOptions returns the dependency graph options matching the
+// pre-processing options, so that the graph follows the same includes.
+func (o preProcessOptions) graphOptions() dependency_graph.Options {
+	return dependency_graph.Options{SearchPath: o.searchPath, CommandLine: o.commandLine}
+}
+
+// preProcessOptionsFromFlags reads the pre-processing settings from the
+// flags registered by addAssembleFlags, and adds the predefined symbols
+// describing the assembly run for target (pre-processor FR-11.1). The build
+// time honours SOURCE_DATE_EPOCH.
+func preProcessOptionsFromFlags(cmd *cobra.Command, target Target) (preProcessOptions, error) {
+	macroDepth, _ := cmd.Flags().GetInt("macro-depth")
+	if macroDepth < 1 {
+		return preProcessOptions{}, fmt.Errorf("--macro-depth must be at least 1, got %d", macroDepth)
+	}
+
+	options, err := symbolOptionsFromFlags(cmd, target)
+	options.macroDepth = macroDepth
+	return options, err
+}
+
+// symbolOptionsFromFlags reads the search path and command-line symbols from
+// the flags registered by addSymbolFlags, and adds the predefined symbols
+// for target.
+func symbolOptionsFromFlags(cmd *cobra.Command, target Target) (preProcessOptions, error) {
+	includeDirs, _ := cmd.Flags().GetStringArray("include")
+	searchPath := preProcessing.IncludeSearchPath(includeDirs)
+	for i, directory := range searchPath {
+		absolute, err := filepath.Abs(directory)
+		if err != nil {
+			return preProcessOptions{}, fmt.Errorf("invalid include directory '%s': %w", directory, err)
+		}
+		searchPath[i] = absolute
+	}
+
+	defines, _ := cmd.Flags().GetStringArray("define")
+	undefines, _ := cmd.Flags().GetStringArray("undefine")
+	commandLine, err := preProcessing.CommandLineSymbolsNew(defines, undefines)
+	if err != nil {
+		return preProcessOptions{}, err
+	}
+
+	buildTime, err := preProcessing.BuildTime(os.Getenv("SOURCE_DATE_EPOCH"), time.Now())
+	if err != nil {
+		return preProcessOptions{}, err
+	}
+	commandLine = commandLine.WithPredefined(preProcessing.Predefined{
+		Arch:      target.Name,
+		Bits:      target.Bits,
+		Version:   kasm.Version,
+		BuildTime: buildTime,
+	})
+
+	return preProcessOptions{searchPath: searchPath, commandLine: commandLine}, nil
+}
+
+// runAssembleFile orchestrates the full assembly pipeline: resolve the file,
+// load architecture instructions, run pre-processing, and assemble for the
+// given target.
+func runAssembleFile(cmd *cobra.Command, args []string, target Target) error {
+	fullPath, err := resolveFilePath(args)
+	if err != nil {
+		return err
+	}
+
+	verbose, _ := cmd.Flags().GetBool("verbose")
+
+	options, err := preProcessOptionsFromFlags(cmd, target)
+	if err != nil {
+		return err
+	}
+
+	loadArchitectureInstructions(target)
+
+	source, err := readSourceFile(fullPath)
+	if err != nil {
+		return err
+	}
+
+	// FR-11.4.2: When --dependency-graph-dot is set, build the dependency
+	// graph, print its DOT representation to stdout, and exit early.
+	if dot, _ := cmd.Flags().GetBool("dependency-graph-dot"); dot {
+		cwd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("unable to get working directory: %w", err)
+		}
+		graph := dependency_graph.NewWithOptions(source, cwd, fullPath, options.graphOptions())
+		for _, e := range graph.Errors() {
+			cmd.PrintErrln(e.String())
+		}
+		fmt.Println(graph.ToDot())
+		return nil
+	}
+
+	// FR-9.4: The binary output defaults to the input file with the
+	// extension replaced by .bin; -E writes to stdout by default.
+	outputPath, _ := cmd.Flags().GetString("output")
+	preProcessOnly, _ := cmd.Flags().GetBool("preprocess-only")
+	if outputPath == "" && !preProcessOnly {
+		outputPath = strings.TrimSuffix(fullPath, filepath.Ext(fullPath)) + ".bin"
+	}
+
+	// With -MD, the dependencies are listed from the dependency graph of the
+	// source as read, before pre-processing replaces it.
+	dependencyPath, err := dependencyFilePath(cmd, outputPath)
+	if err != nil {
+		return err
+	}
+	var dependencies []string
+	if dependencyPath != "" {
+		if dependencies, err = sourceDependencies(source, fullPath, options); err != nil {
+			return err
+		}
+	}
+
+	// Create the debug context for this assembly invocation (FR-9.1). Every
+	// diagnostic is remapped through the %line markers of its file, so that
+	// pre-processed output assembles with the original locations.
+	markers := lineMarkerMapperNew(fullPath, source)
+	debugCtx := debugcontext.NewDebugContext(fullPath).WithLocationMapper(markers.mapLocation)
+
+	tracker, err := lineMap.Track(fullPath)
+	if err != nil {
+		return fmt.Errorf("failed to initialise line tracker: %w", err)
+	}
+
+	source = preProcess(source, fullPath, options, tracker, debugCtx)
+
+	// Print debug context entries when verbose mode is enabled. Otherwise,
+	// print warnings (e.g. from %warning directives), which do not abort.
+	if verbose {
+		for _, e := range debugCtx.Entries() {
+			cmd.PrintErrln(e.String())
+		}
+	} else {
+		for _, e := range debugCtx.Warnings() {
+			cmd.PrintErrln(e.String())
+		}
+	}
+
+	// Abort if pre-processing recorded any errors (FR-9.5).
+	if debugCtx.HasErrors() {
+		if !verbose {
+			// Errors were not yet printed; print them now.
+			for _, e := range debugCtx.Errors() {
+				cmd.PrintErrln(e.String())
+			}
+		}
+		return fmt.Errorf("assembly aborted: %d error(s) during pre-processing", len(debugCtx.Errors()))
+	}
+
+	// Pre-processor FR-12.1: with -E, write the pre-processed source and
+	// stop before lexing.
+	if preProcessOnly {
+		output := preProcessedOutput(source, tracker, debugCtx, markers)
+		if outputPath == "" {
+			_, err := fmt.Fprint(cmd.OutOrStdout(), output)
+			return err
+		}
+		if err := os.WriteFile(outputPath, []byte(output), 0644); err != nil {
+			return fmt.Errorf("failed to write output file: %w", err)
+		}
+		if dependencyPath != "" {
+			return writeDependencyFile(dependencyPath, outputPath, fullPath, dependencies, nil, nil)
+		}
+		return nil
+	}
+
+	// Lexer phase: tokenise the pre-processed source using the target's
+	// architecture profile. Because the profile is constructed once and is
+	// immutable (FR-1.1.5), it can be reused across invocations.
+	archProfile := target.Profile()
+	tokens := kasm.LexerNew(source, archProfile).WithDebugContext(debugCtx).WithLineMapper(tracker).Start()
+
+	// Abort if lexer recorded any errors.
+	if debugCtx.HasErrors() {
+		if !verbose {
+			for _, e := range debugCtx.Errors() {
+				cmd.PrintErrln(e.String())
+			}
+		}
+		return fmt.Errorf("assembly aborted: %d error(s) during lexing", len(debugCtx.Errors()))
+	}
+
+	// Parser phase: transform the token slice into an AST.
+	program, parseErrors := kasm.ParserNew(tokens).WithDebugContext(debugCtx).WithLineMapper(tracker).Parse()
+
+	// Print debug context entries when verbose mode is enabled (parser phase).
+	if verbose {
+		for _, e := range debugCtx.Entries() {
+			cmd.PrintErrln(e.String())
+		}
+	}
+
+	// Abort if parsing recorded any errors.
+	if len(parseErrors) > 0 {
+		if !verbose {
+			for _, e := range debugCtx.Errors() {
+				cmd.PrintErrln(e.String())
+			}
+		}
+		return fmt.Errorf("assembly aborted: %d error(s) during parsing", len(parseErrors))
+	}
+
+	// Semantic analysis phase: validate the AST against the architecture's
+	// instruction metadata. The instruction table is flattened from all
+	// architecture groups into a single map keyed by upper-case mnemonic.
+	instrTable := BuildInstructionTable(target)
+	semanticErrors := kasm.AnalyserNew(program, instrTable).
+		WithDebugContext(debugCtx).
+		WithLineMapper(tracker).
+		Analyse()
+
+	// Print debug context entries when verbose mode is enabled (semantic phase).
+	if verbose {
+		for _, e := range debugCtx.Entries() {
+			cmd.PrintErrln(e.String())
+		}
+	}
+
+	// Abort if semantic analysis recorded any errors.
+	if len(semanticErrors) > 0 {
+		if !verbose {
+			for _, e := range debugCtx.Errors() {
+				cmd.PrintErrln(e.String())
+			}
+		}
+		return fmt.Errorf("assembly aborted: %d error(s) during semantic analysis", len(semanticErrors))
+	}
+
+	// Read the files embedded by %incbin; the generator performs no file
+	// I/O itself.
+	binaries := readIncbinFiles(program, source, tracker, debugCtx)
+	if debugCtx.HasErrors() {
+		if verbose {
+			for _, e := range debugCtx.Entries() {
+				cmd.PrintErrln(e.String())
+			}
+		} else {
+			for _, e := range debugCtx.Errors() {
+				cmd.PrintErrln(e.String())
+			}
+		}
+		return fmt.Errorf("assembly aborted: %d error(s) while reading %%incbin files", len(debugCtx.Errors()))
+	}
+
+	// Code generation phase: encode the validated AST into machine code
+	// (FR-9.1, FR-9.2).
+	generator := kasm.GeneratorNew(program, instrTable).
+		WithBackend(target.Backend).
+		WithBinaries(binaries).
+		WithDebugContext(debugCtx).
+		WithLineMapper(tracker)
+	output, codegenErrors := generator.Generate()
+
+	// Print debug context entries when verbose mode is enabled (codegen phase).
+	if verbose {
+		for _, e := range debugCtx.Entries() {
+			cmd.PrintErrln(e.String())
+		}
+	}
+
+	// Abort if code generation recorded any errors (FR-9.3).
+	if len(codegenErrors) > 0 {
+		if !verbose {
+			for _, e := range debugCtx.Errors() {
+				cmd.PrintErrln(e.String())
+			}
+		}
+		return fmt.Errorf("assembly aborted: %d error(s) during code generation", len(codegenErrors))
+	}
+
+	// FR-9.4: Write the binary output, then the dependency file, so that
+	// the dependency file is never older than its target.
+	if err := os.WriteFile(outputPath, output, 0644); err != nil {
+		return fmt.Errorf("failed to write output file: %w", err)
+	}
+	if dependencyPath != "" {
+		modules := moduleFiles(program, source, options.searchPath, tracker, debugCtx)
+		return writeDependencyFile(dependencyPath, outputPath, fullPath, dependencies, modules, binaries)
+	}
+
+	return nil
+}
+
+// readIncbinFiles reads every file embedded by an %incbin directive in
+// program, keyed by the path in the directive. The include phase has already
+// resolved the paths (pre-processor FR-1.8.4). A file that cannot be read is
+// recorded as an error at the directive, traced back through source, the
+// pre-processed source the program was parsed from.
+func readIncbinFiles(program *ast.Program, source string, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) map[string][]byte {
+	debugCtx.SetPhase("incbin")
+
+	binaries := make(map[string][]byte)
+	for _, stmt := range program.Statements {
+		incbin, ok := stmt.(*ast.IncbinStmt)
+		if !ok {
+			continue
+		}
+		if _, done := binaries[incbin.Path]; done {
+			continue
+		}
+
+		content, err := os.ReadFile(incbin.Path)
+		if err != nil {
+			debugCtx.Error(
+				locatePreProcessedLine(source, incbin.Line, incbin.Column, tracker, debugCtx),
+				fmt.Sprintf("failed to read %%incbin file '%s': %v", incbin.Path, err),
+			)
+			continue
+		}
+		binaries[incbin.Path] = content
+	}
+	return binaries
+}
+
+// resolveFilePath validates the CLI arguments and returns the absolute path
+// to the assembly file.
+func resolveFilePath(args []string) (string, error) {
+	if len(args) < 1 {
+		return "", fmt.Errorf("no assembly file provided")
+	}
+	if args[0] == "" {
+		return "", fmt.Errorf("assembly file path is empty")
+	}
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("unable to get current working directory: %w", err)
+	}
+
+	fullPath := filepath.Join(cwd, args[0])
+	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
+		return "", fmt.Errorf("assembly file does not exist at path: %s", fullPath)
+	}
+
+	return fullPath, nil
+}
+
+// readSourceFile reads the assembly source file and returns its content.
+func readSourceFile(path string) (string, error) {
+	sourceBytes, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read assembly file: %w", err)
+	}
+	return string(sourceBytes), nil
+}
+
+// loadArchitectureInstructions loads and indexes the target's instruction set.
+func loadArchitectureInstructions(target Target) map[string]architecture.InstructionGroup {
+	groups := make(map[string]architecture.InstructionGroup)
+	for groupName, instructions := range target.Instructions() {
+		groups[groupName] = *architecture.FromSlice(groupName, instructions)
+	}
+	return groups
+}
+
+// BuildInstructionTable flattens all architecture instruction groups into a
+// single map keyed by upper-case mnemonic, suitable for the semantic analyser.
+// If two groups contain the same mnemonic, the last one wins (AR-3.2).
+func BuildInstructionTable(target Target) map[string]architecture.Instruction {
+	table := make(map[string]architecture.Instruction)
+	for _, instructions := range target.Instructions() {
+		for _, instr := range instructions {
+			table[instr.Mnemonic] = instr
+		}
+	}
+	return table
+}
+
+// preProcess runs the pre-processing phases (includes, line markers,
+// repetitions, macros, line symbols, conditionals, defines) and snapshots each transformation in
+// the tracker. Line symbols are substituted again after the defines, which
+// may introduce them (pre-processor FR-11.2).
+// Each phase sets its debug context phase and records errors instead of
+// panicking. Every phase runs even when an earlier one recorded errors, so
+// that all pre-processing errors are reported in one run (FR-5.2); the caller
+// aborts before lexing. A %fatal directive stops pre-processing after the
+// phase that reported it (FR-9.3).
+func preProcess(source string, rootFilePath string, options preProcessOptions, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) string {
+	source = preProcessIncludes(source, rootFilePath, options, tracker, debugCtx)
+
+	commandLine := options.commandLine
+	phases := []func(string) string{
+		func(source string) string { return preProcessLineMarkers(source, tracker, debugCtx) },
+		func(source string) string { return preProcessRepetitions(source, commandLine, tracker, debugCtx) },
+		func(source string) string {
+			return preProcessMacros(source, options.macroDepth, commandLine, tracker, debugCtx)
+		},
+		func(source string) string { return preProcessLineSymbols(source, tracker, debugCtx) },
+		func(source string) string { return preProcessConditionals(source, commandLine, tracker, debugCtx) },
+		func(source string) string { return preProcessDefines(source, commandLine, tracker, debugCtx) },
+		func(source string) string { return preProcessLineSymbols(source, tracker, debugCtx) },
+		func(source string) string { return preProcessFunctions(source, tracker, debugCtx) },
+	}
+	for _, phase := range phases {
+		if debugCtx.HasFatal() {
+			break
+		}
+		source = phase(source)
+	}
+	return source
+}
+
+// recordPreProcessingErrors records errors returned by a pre-processing
+// function in the debug context, at the severity they carry (FR-9.2). Their
+// line numbers refer to source, which must be the tracker's latest snapshot;
+// each is mapped back to its original file and line (FR-5.2.4). An error
+// that names its own phase is recorded in it, below "pre-processing/".
+func recordPreProcessingErrors(errs []preProcessing.PreProcessingError, source string, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) {
+	phase := debugCtx.Phase()
+	defer debugCtx.SetPhase(phase)
+
+	for _, e := range errs {
+		message := e.Message
+		if e.Previous > 0 {
+			message = fmt.Sprintf("%s (first defined at %s)", message,
+				locatePreProcessedLine(source, e.Previous, 0, tracker, debugCtx))
+		}
+		location := locatePreProcessedLine(source, e.Line, e.Column, tracker, debugCtx)
+		if e.Phase != "" {
+			debugCtx.SetPhase("pre-processing/" + e.Phase)
+		} else {
+			debugCtx.SetPhase(phase)
+		}
+		switch e.Severity {
+		case preProcessing.SeverityWarning:
+			debugCtx.Warning(location, message)
+		case preProcessing.SeverityFatal:
+			debugCtx.Fatal(location, message)
+		default:
+			debugCtx.Error(location, message)
+		}
+	}
+}
+
+// preProcessIncludes handles %include directives, detects circular inclusions,
+// and snapshots the result with source file annotations.
+//
+// The rootFilePath is added to the seen set before the first invocation of
+// PreProcessingHandleIncludes so that a file cannot include itself indirectly
+// through a chain that leads back to the root (FR-1.6.6). Include paths are
+// resolved relative to the including file and then through the search path,
+// in both the dependency graph and the include handler (FR-1.8), and only
+// directives in branches that are active given the command-line symbols are
+// followed (FR-1.9, FR-10).
+func preProcessIncludes(source string, rootFilePath string, options preProcessOptions, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) string {
+	debugCtx.SetPhase("pre-processing/includes")
+
+	cwd, err := os.Getwd()
+	if err != nil {
+		debugCtx.Error(debugCtx.Loc(0, 0), fmt.Sprintf("unable to get working directory: %v", err))
+		return source
+	}
+
+	dependencyGraph := dependency_graph.NewWithOptions(source, cwd, rootFilePath, options.graphOptions())
+
+	// FR-11.4.1: Log the text representation of the dependency graph via
+	// debugCtx.Trace so it appears in verbose mode output.
+	debugCtx.Trace(debugCtx.Loc(0, 0), fmt.Sprintf("dependency graph:\n%s", dependencyGraph.String()))
+
+	// FR-9: Unresolvable includes anywhere in the file tree are reported at
+	// the directive that references them. Inlining a partial tree would only
+	// produce follow-up errors, so includes are left unresolved; the later
+	// phases still run on the root source.
+	if graphErrors := dependencyGraph.Errors(); len(graphErrors) > 0 {
+		for _, e := range graphErrors {
+			debugCtx.Error(debugCtx.LocIn(e.FilePath, e.Line, 0), e.Message)
+		}
+		return source
+	}
+
+	if !dependencyGraph.Acyclic() {
+		// FR-11.3.3: Use CyclePath() to enrich the error message with the
+		// full chain of files involved in the cycle.
+		cyclePath := dependencyGraph.CyclePath()
+		if cyclePath != nil {
+			debugCtx.Error(debugCtx.Loc(0, 0),
+				fmt.Sprintf("circular inclusion detected in dependency graph: %s",
+					strings.Join(cyclePath, " → ")))
+		} else {
+			debugCtx.Error(debugCtx.Loc(0, 0), "circular inclusion detected in dependency graph")
+		}
+		return source
+	}
+
+	// FR-1.6.6: Seed the seen set with the root file path, so that a guarded
+	// root re-included by one of its files is stripped. FR-1.7 / FR-1.10: a
+	// guarded file is inlined at its first point of use; later directives
+	// for it are stripped. Unguarded files are inlined at every directive.
+	seen := map[string]bool{rootFilePath: true}
+	totalInclusions := 0
+
+	// Standard Library block: placeholder for future standard library content.
+	stdLibBlock := "; =======================================\n" +
+		"; Start Standard Library\n" +
+		"; =======================================\n" +
+		"; =======================================\n" +
+		"; End Standard Library\n" +
+		"; =======================================\n"
+
+	source = stdLibBlock + source
+
+	// Snapshot the prepended blocks so that errors reported against this
+	// source can be traced back to the root file.
+	tracker.Snapshot(source)
+
+	// FR-1.5: Recursively resolve includes. Each iteration inlines one level
+	// of %include directives. The loop continues until no new inclusions are
+	// found (the source is fully resolved) or a circular inclusion is detected.
+	for {
+		previous := source
+		var inclusions []preProcessing.Inclusion
+		var includeErrors []preProcessing.PreProcessingError
+		source, inclusions, includeErrors = preProcessing.HandleIncludesWithOptions(source, preProcessing.IncludeOptions{FilePath: rootFilePath, SearchPath: options.searchPath, CommandLine: options.commandLine}, seen)
+		recordPreProcessingErrors(includeErrors, previous, tracker, debugCtx)
+
+		if len(inclusions) == 0 {
+			break
+		}
+
+		// FR-1.6.5: Add all newly included file paths to the seen set before
+		// the next recursive invocation. A directive inside the file it
+		// includes is reported by the include handler (FR-1.6.4).
+		trackerInclusions := make([]lineMap.Inclusion, 0, len(inclusions))
+		for _, inc := range inclusions {
+			seen[inc.IncludedFilePath] = true
+			trackerInclusions = append(trackerInclusions, lineMap.Inclusion{
+				FilePath:   inc.IncludedFilePath,
+				LineNumber: inc.LineNumber,
+			})
+		}
+
+		tracker.SnapshotWithInclusions(source, trackerInclusions)
+		totalInclusions += len(inclusions)
+	}
+
+	debugCtx.Trace(debugCtx.Loc(0, 0), fmt.Sprintf("included %d file(s)", totalInclusions))
+	return source
+}
+
+// preProcessLineMarkers blanks %line directives and reports malformed ones
+// (pre-processor FR-12.2). The markers are applied to diagnostics by the
+// debug context's location mapper.
+func preProcessLineMarkers(source string, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) string {
+	debugCtx.SetPhase("pre-processing/line-markers")
+
+	resolved, errs := preProcessing.HandleLineMarkers(source)
+	recordPreProcessingErrors(errs, source, tracker, debugCtx)
+	source = resolved
+
+	// Directives are blanked in place; keep the origins of all lines.
+	tracker.SnapshotRewrite(source)
+	return source
+}
+
+// preProcessRepetitions unrolls %rep blocks, evaluates %assign variables,
+// and snapshots the result so that every repeated line traces back to its
+// template line. Macro names seed the set of defined symbols, as in
+// preProcessConditionals.
+func preProcessRepetitions(source string, commandLine *preProcessing.CommandLineSymbols, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) string {
+	debugCtx.SetPhase("pre-processing/repetitions")
+
+	// Unterminated macro definitions are reported by the macro phase.
+	macros, _ := preProcessing.MacroTableWithCommandLine(source, commandLine)
+	symbols := make(map[string]bool, len(macros))
+	for name := range macros {
+		symbols[name] = true
+	}
+	unrolled, origins, errs := preProcessing.HandleRepetitionsWithCommandLine(source, symbols, commandLine)
+	recordPreProcessingErrors(errs, source, tracker, debugCtx)
+	source = unrolled
+
+	tracker.SnapshotMapped(source, origins)
+	debugCtx.Trace(debugCtx.Loc(0, 0), "unrolled %rep blocks")
+	return source
+}
+
+// preProcessMacros builds the macro table from the definitions in active
+// conditional branches, given the command-line symbols, collects calls,
+// expands them, including calls in macro bodies up to maxDepth levels deep,
+// and snapshots the result so that every expanded line traces back to its
+// call.
+func preProcessMacros(source string, maxDepth int, commandLine *preProcessing.CommandLineSymbols, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) string {
+	debugCtx.SetPhase("pre-processing/macros")
+
+	macros, errs := preProcessing.MacroTableWithCommandLine(source, commandLine)
+	recordPreProcessingErrors(errs, source, tracker, debugCtx)
+	errs = preProcessing.CollectMacroCallsWithCommandLine(source, macros, commandLine)
+	recordPreProcessingErrors(errs, source, tracker, debugCtx)
+	expanded, origins, errs := preProcessing.ReplaceMacroCallsWithOrigins(source, macros, maxDepth)
+	recordPreProcessingErrors(errs, source, tracker, debugCtx)
+	source = expanded
+
+	// Every expanded line maps to its call site.
+	tracker.SnapshotMapped(source, origins)
+	debugCtx.Trace(debugCtx.Loc(0, 0), fmt.Sprintf("expanded %d macro(s)", len(macros)))
+	return source
+}
+
+// preProcessLineSymbols substitutes __FILE__ and __LINE__ with the file and
+// line each occurrence originates from, traced through the tracker, whose
+// latest snapshot must be source. Expanded macro bodies trace back to their
+// call, so a __LINE__ in a macro body names the call site. Files inside the
+// working directory are named relative to it.
+func preProcessLineSymbols(source string, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) string {
+	debugCtx.SetPhase("pre-processing/line-symbols")
+
+	source = preProcessing.HandleLineSymbols(source, func(lineNumber int) (string, int) {
+		location := locatePreProcessedLine(source, lineNumber, 0, tracker, debugCtx)
+		return displayPath(location.FilePath()), location.Line()
+	})
+
+	// Substitution rewrites lines in place; keep their origins.
+	tracker.SnapshotRewrite(source)
+	debugCtx.Trace(debugCtx.Loc(0, 0), "substituted __FILE__ and __LINE__")
+	return source
+}
+
+// preProcessConditionals evaluates %if / %ifdef / %ifndef / %elif / %else /
+// %endif blocks, and snapshots the result. Macro names seed the set of
+// defined symbols, and the command-line symbols take precedence over them;
+// %define directives are tracked in source order by HandleConditionals
+// itself.
+func preProcessConditionals(source string, commandLine *preProcessing.CommandLineSymbols, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) string {
+	debugCtx.SetPhase("pre-processing/conditionals")
+
+	// Macro definitions were removed by the macro phase; any that remain
+	// were unterminated and have already been reported there.
+	macros, _ := preProcessing.MacroTable(source)
+	symbols := make(map[string]bool, len(macros))
+	for name := range macros {
+		symbols[name] = true
+	}
+	resolved, errs := preProcessing.HandleConditionalsWithCommandLine(source, symbols, commandLine)
+	recordPreProcessingErrors(errs, source, tracker, debugCtx)
+	source = resolved
+
+	// Inactive lines and directives are blanked rather than removed, so
+	// every remaining line keeps its origin.
+	tracker.SnapshotRewrite(source)
+	debugCtx.Trace(debugCtx.Loc(0, 0), fmt.Sprintf("evaluated conditionals with %d macro symbol(s) and %d command-line symbol(s)",
+		len(symbols), len(commandLine.Defined())))
+	return source
+}
+
+// preProcessFunctions evaluates the pre-processor functions (%strlen,
+// %substr, %strcat, %str) and %+ token pasting left in the source, including
+// those from define and macro bodies, and snapshots the result.
+func preProcessFunctions(source string, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) string {
+	debugCtx.SetPhase("pre-processing/functions")
+
+	resolved, errs := preProcessing.HandleFunctions(source)
+	recordPreProcessingErrors(errs, source, tracker, debugCtx)
+	source = resolved
+
+	// Evaluation rewrites lines in place; keep their origins.
+	tracker.SnapshotRewrite(source)
+	debugCtx.Trace(debugCtx.Loc(0, 0), "evaluated pre-processor functions")
+	return source
+}
+
+// preProcessDefines substitutes value-carrying %define symbols in the active
+// source, strips %define / %undef directives, and snapshots the result.
+// Command-line symbols are substituted from the first line (FR-10.2).
+func preProcessDefines(source string, commandLine *preProcessing.CommandLineSymbols, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) string {
+	debugCtx.SetPhase("pre-processing/defines")
+
+	resolved, errs := preProcessing.HandleDefinesWithCommandLine(source, commandLine)
+	recordPreProcessingErrors(errs, source, tracker, debugCtx)
+	source = resolved
+
+	// Substitution rewrites lines in place; keep their origins.
+	tracker.SnapshotRewrite(source)
+	debugCtx.Trace(debugCtx.Loc(0, 0), "substituted %define symbols")
+	return source
+}
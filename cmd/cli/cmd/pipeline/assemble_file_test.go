@@ -0,0 +1,1158 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keurnel/assembler/internal/debugcontext"
+	"github.com/keurnel/assembler/internal/lineMap"
+	"github.com/keurnel/assembler/v0/kasm/ast"
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+)
+
+// ---------------------------------------------------------------------------
+// FR-1.6: Circular Include Detection
+// ---------------------------------------------------------------------------
+
+// TestPreProcessIncludes_CircularTwoFiles verifies the canonical circular
+// inclusion scenario (FR-1.6): file1.kasm includes file2.kasm, and
+// file2.kasm includes file1.kasm.
+func TestPreProcessIncludes_CircularTwoFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	file1 := filepath.Join(tmpDir, "file1.kasm")
+	file2 := filepath.Join(tmpDir, "file2.kasm")
+
+	// file1 includes file2, file2 includes file1 — a cycle.
+	os.WriteFile(file1, []byte(`%include "`+file2+`"`), 0644)
+	os.WriteFile(file2, []byte(`%include "`+file1+`"`), 0644)
+
+	source, _ := os.ReadFile(file1)
+	debugCtx := debugcontext.NewDebugContext(file1)
+	tracker, err := lineMap.Track(file1)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	_ = preProcessIncludes(string(source), file1, preProcessOptions{}, tracker, debugCtx)
+
+	if !debugCtx.HasErrors() {
+		t.Fatal("expected circular inclusion error, got none")
+	}
+
+	errors := debugCtx.Errors()
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got %d", len(errors))
+	}
+
+	msg := errors[0].String()
+	if !strings.Contains(msg, "circular inclusion") {
+		t.Errorf("expected error message to contain 'circular inclusion', got: %s", msg)
+	}
+	if !strings.Contains(msg, file1) {
+		t.Errorf("expected error message to contain the offending file path '%s', got: %s", file1, msg)
+	}
+}
+
+// TestPreProcessIncludes_CircularThreeFiles verifies circular inclusion
+// detection across a three-file chain: a → b → c → a.
+func TestPreProcessIncludes_CircularThreeFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	fileA := filepath.Join(tmpDir, "a.kasm")
+	fileB := filepath.Join(tmpDir, "b.kasm")
+	fileC := filepath.Join(tmpDir, "c.kasm")
+
+	os.WriteFile(fileA, []byte(`%include "`+fileB+`"`), 0644)
+	os.WriteFile(fileB, []byte(`%include "`+fileC+`"`), 0644)
+	os.WriteFile(fileC, []byte(`%include "`+fileA+`"`), 0644)
+
+	source, _ := os.ReadFile(fileA)
+	debugCtx := debugcontext.NewDebugContext(fileA)
+	tracker, err := lineMap.Track(fileA)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	_ = preProcessIncludes(string(source), fileA, preProcessOptions{}, tracker, debugCtx)
+
+	if !debugCtx.HasErrors() {
+		t.Fatal("expected circular inclusion error, got none")
+	}
+
+	msg := debugCtx.Errors()[0].String()
+	if !strings.Contains(msg, "circular inclusion") {
+		t.Errorf("expected error message to contain 'circular inclusion', got: %s", msg)
+	}
+}
+
+// TestPreProcessIncludes_SelfInclude verifies that a file including itself
+// is caught. The pre-processor function itself detects this within a single
+// invocation (FR-1.6.1 / FR-1.2.2), but the orchestrator's root-seeding
+// (FR-1.6.6) provides an additional layer.
+func TestPreProcessIncludes_SelfInclude(t *testing.T) {
+	tmpDir := t.TempDir()
+	file := filepath.Join(tmpDir, "self.kasm")
+	os.WriteFile(file, []byte(`%include "`+file+`"`), 0644)
+
+	source, _ := os.ReadFile(file)
+	debugCtx := debugcontext.NewDebugContext(file)
+	tracker, err := lineMap.Track(file)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	// The pre-processor's own include handling will read the file and inline
+	// it, then the orchestrator loop will detect that the file path is already
+	// in the seen set (seeded by FR-1.6.6).
+	_ = preProcessIncludes(string(source), file, preProcessOptions{}, tracker, debugCtx)
+
+	if !debugCtx.HasErrors() {
+		t.Fatal("expected circular inclusion error for self-include, got none")
+	}
+
+	msg := debugCtx.Errors()[0].String()
+	if !strings.Contains(msg, "circular inclusion") {
+		t.Errorf("expected 'circular inclusion' in error, got: %s", msg)
+	}
+}
+
+// TestPreProcessIncludes_NoCircular verifies that legitimate includes (no
+// cycle) do not trigger a circular inclusion error.
+func TestPreProcessIncludes_NoCircular(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	helper := filepath.Join(tmpDir, "helper.kasm")
+	os.WriteFile(helper, []byte("mov rax, 1"), 0644)
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte(`%include "`+helper+`"`+"\nmov rbx, 2"), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcessIncludes(string(source), root, preProcessOptions{}, tracker, debugCtx)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+	}
+
+	if !strings.Contains(result, "mov rax, 1") {
+		t.Error("expected included content in result")
+	}
+	if !strings.Contains(result, "mov rbx, 2") {
+		t.Error("expected original content in result")
+	}
+}
+
+// TestPreProcessIncludes_RecursiveNonCircular verifies that multi-level
+// includes (a includes b, b includes c) work without triggering circular
+// inclusion when there is no cycle.
+func TestPreProcessIncludes_RecursiveNonCircular(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	fileC := filepath.Join(tmpDir, "c.kasm")
+	os.WriteFile(fileC, []byte("nop"), 0644)
+
+	fileB := filepath.Join(tmpDir, "b.kasm")
+	os.WriteFile(fileB, []byte(`%include "`+fileC+`"`), 0644)
+
+	fileA := filepath.Join(tmpDir, "a.kasm")
+	os.WriteFile(fileA, []byte(`%include "`+fileB+`"`), 0644)
+
+	source, _ := os.ReadFile(fileA)
+	debugCtx := debugcontext.NewDebugContext(fileA)
+	tracker, err := lineMap.Track(fileA)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcessIncludes(string(source), fileA, preProcessOptions{}, tracker, debugCtx)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+	}
+
+	if !strings.Contains(result, "nop") {
+		t.Error("expected deeply included content 'nop' in result")
+	}
+}
+
+// TestPreProcessIncludes_RootFileSeeded verifies FR-1.6.6: the root file
+// path is in the seen set so that an included file that re-includes the
+// root is caught.
+func TestPreProcessIncludes_RootFileSeeded(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	root := filepath.Join(tmpDir, "root.kasm")
+	child := filepath.Join(tmpDir, "child.kasm")
+
+	// child includes the root — cycle through the root file.
+	os.WriteFile(root, []byte(`%include "`+child+`"`), 0644)
+	os.WriteFile(child, []byte(`%include "`+root+`"`), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	_ = preProcessIncludes(string(source), root, preProcessOptions{}, tracker, debugCtx)
+
+	if !debugCtx.HasErrors() {
+		t.Fatal("expected circular inclusion error when child re-includes root")
+	}
+
+	msg := debugCtx.Errors()[0].String()
+	if !strings.Contains(msg, "circular inclusion") {
+		t.Errorf("expected 'circular inclusion' in error, got: %s", msg)
+	}
+	// The offending path should be the root file (re-included by child).
+	if !strings.Contains(msg, root) {
+		t.Errorf("expected root path in error message, got: %s", msg)
+	}
+}
+
+// TestPreProcessIncludes_ErrorMessageFormat verifies FR-1.6.7: the error
+// message must use the phrase "circular inclusion" and include the file path.
+func TestPreProcessIncludes_ErrorMessageFormat(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	fileX := filepath.Join(tmpDir, "x.kasm")
+	fileY := filepath.Join(tmpDir, "y.kasm")
+
+	os.WriteFile(fileX, []byte(`%include "`+fileY+`"`), 0644)
+	os.WriteFile(fileY, []byte(`%include "`+fileX+`"`), 0644)
+
+	source, _ := os.ReadFile(fileX)
+	debugCtx := debugcontext.NewDebugContext(fileX)
+	tracker, err := lineMap.Track(fileX)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	_ = preProcessIncludes(string(source), fileX, preProcessOptions{}, tracker, debugCtx)
+
+	errors := debugCtx.Errors()
+	if len(errors) == 0 {
+		t.Fatal("expected at least one error")
+	}
+
+	msg := errors[0].String()
+
+	// FR-1.6.7: must contain "circular inclusion".
+	if !strings.Contains(msg, "circular inclusion") {
+		t.Errorf("error message must contain 'circular inclusion', got: %s", msg)
+	}
+
+	// FR-1.6.7: must contain the file path for grep-based log analysis.
+	if !strings.Contains(msg, fileX) {
+		t.Errorf("error message must contain the file path, got: %s", msg)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FR-1.7: Shared Dependency Deduplication
+// ---------------------------------------------------------------------------
+
+// TestPreProcessIncludes_SharedDependency verifies FR-1.7: when two files
+// include the same guarded shared dependency, the shared file is inlined once
+// and the second %include is silently stripped. No error is produced.
+//
+// Graph:  root → a → shared
+//
+//	root → b → shared
+//
+// Expected: shared.kasm content appears exactly once in the final source.
+func TestPreProcessIncludes_SharedDependency(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	shared := filepath.Join(tmpDir, "shared.kasm")
+	os.WriteFile(shared, []byte("%pragma once\nmov rax, 42"), 0644)
+
+	fileA := filepath.Join(tmpDir, "a.kasm")
+	os.WriteFile(fileA, []byte(`%include "`+shared+`"`+"\nmov rbx, 1"), 0644)
+
+	fileB := filepath.Join(tmpDir, "b.kasm")
+	os.WriteFile(fileB, []byte(`%include "`+shared+`"`+"\nmov rcx, 2"), 0644)
+
+	root := filepath.Join(tmpDir, "root.kasm")
+	os.WriteFile(root, []byte(`%include "`+fileA+`"`+"\n"+`%include "`+fileB+`"`), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcessIncludes(string(source), root, preProcessOptions{}, tracker, debugCtx)
+
+	// FR-1.7.4: No errors — shared dependencies are valid.
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors for shared dependency, got: %v", debugCtx.Errors())
+	}
+
+	// FR-1.7.2: shared.kasm content should be inlined exactly once.
+	count := strings.Count(result, "mov rax, 42")
+	if count != 1 {
+		t.Errorf("expected shared content to appear exactly once, got %d occurrences:\n%s", count, result)
+	}
+
+	// Both a.kasm and b.kasm own content should be present.
+	if !strings.Contains(result, "mov rbx, 1") {
+		t.Error("expected a.kasm content in result")
+	}
+	if !strings.Contains(result, "mov rcx, 2") {
+		t.Error("expected b.kasm content in result")
+	}
+}
+
+// TestPreProcessIncludes_UnguardedIncludedTwice verifies FR-1.7.7: an
+// unguarded file is inlined at every %include, while a guarded one is
+// inlined once.
+func TestPreProcessIncludes_UnguardedIncludedTwice(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	os.WriteFile(filepath.Join(tmpDir, "entry.kasm"), []byte("dq 0"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "guarded.kasm"), []byte("%ifndef GUARDED\n%define GUARDED\nnop\n%endif"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "table.kasm"), []byte("%include \"entry.kasm\"\n%include \"guarded.kasm\""), 0644)
+	root := filepath.Join(tmpDir, "root.kasm")
+	os.WriteFile(root, []byte("%include \"entry.kasm\"\n%include \"guarded.kasm\"\n%include \"table.kasm\""), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcessIncludes(string(source), root, preProcessOptions{}, tracker, debugCtx)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+	}
+	if count := strings.Count(result, "dq 0"); count != 2 {
+		t.Errorf("expected the unguarded file twice, got %d occurrences:\n%s", count, result)
+	}
+	if count := strings.Count(result, "nop"); count != 1 {
+		t.Errorf("expected the guarded file once, got %d occurrences:\n%s", count, result)
+	}
+}
+
+// TestPreProcessIncludes_SharedDependency_NoErrorOnSecond verifies FR-1.7.4:
+// the second inclusion of a shared dependency does not produce an error.
+func TestPreProcessIncludes_SharedDependency_NoErrorOnSecond(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	shared := filepath.Join(tmpDir, "constants.kasm")
+	os.WriteFile(shared, []byte("BUFFER_SIZE equ 4096"), 0644)
+
+	fileA := filepath.Join(tmpDir, "io.kasm")
+	os.WriteFile(fileA, []byte(`%include "`+shared+`"`), 0644)
+
+	fileB := filepath.Join(tmpDir, "math.kasm")
+	os.WriteFile(fileB, []byte(`%include "`+shared+`"`), 0644)
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte(`%include "`+fileA+`"`+"\n"+`%include "`+fileB+`"`), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	_ = preProcessIncludes(string(source), root, preProcessOptions{}, tracker, debugCtx)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("shared dependency should not produce errors, got: %v", debugCtx.Errors())
+	}
+}
+
+// TestPreProcessIncludes_SharedDependency_FirstPointOfUse verifies FR-1.7.6:
+// a guarded shared dependency is inlined where it is first included, not
+// hoisted to the top of the translation unit, so a macro it uses is defined
+// before it.
+func TestPreProcessIncludes_SharedDependency_FirstPointOfUse(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	os.WriteFile(filepath.Join(tmpDir, "macros.kasm"), []byte("%macro exit 0\nmov rax, 60\n%endmacro"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "a.kasm"), []byte("%include \"shared.kasm\"\nmov rbx, 1"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "shared.kasm"), []byte("%pragma once\nexit"), 0644)
+	root := filepath.Join(tmpDir, "root.kasm")
+	os.WriteFile(root, []byte("%include \"macros.kasm\"\n%include \"a.kasm\"\n%include \"shared.kasm\""), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcessIncludes(string(source), root, preProcessOptions{}, tracker, debugCtx)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+	}
+	macro := strings.Index(result, "%macro exit")
+	use := strings.Index(result, "\nexit\n")
+	own := strings.Index(result, "mov rbx, 1")
+	if strings.Count(result, "\nexit\n") != 1 || macro > use || use > own {
+		t.Errorf("expected shared.kasm once, inside a.kasm and after the macro, got:\n%s", result)
+	}
+	if strings.Contains(result, "shared inclusions") {
+		t.Errorf("expected no shared inclusions block, got:\n%s", result)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FR-1.10: Include Guards
+// ---------------------------------------------------------------------------
+
+// TestPreProcessIncludes_GuardedFilesIncludeEachOther verifies FR-1.10: guarded
+// files may include each other; the repeated include is a no-op, not a cycle.
+func TestPreProcessIncludes_GuardedFilesIncludeEachOther(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	os.WriteFile(filepath.Join(tmpDir, "a.kasm"), []byte("%ifndef A_KASM\n%define A_KASM\n%include \"b.kasm\"\nmov rax, 1\n%endif"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "b.kasm"), []byte("%pragma once\n%include \"a.kasm\"\nmov rbx, 2"), 0644)
+	root := filepath.Join(tmpDir, "root.kasm")
+	os.WriteFile(root, []byte("%include \"a.kasm\"\n%include \"b.kasm\""), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcessIncludes(string(source), root, preProcessOptions{}, tracker, debugCtx)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+	}
+	if strings.Count(result, "mov rax, 1") != 1 || strings.Count(result, "mov rbx, 2") != 1 || strings.Contains(result, "%pragma") {
+		t.Errorf("expected each file inlined once, got:\n%s", result)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FR-1.8: Include Search Path
+// ---------------------------------------------------------------------------
+
+// TestPreProcessIncludes_SearchPathIndependentOfCwd verifies FR-1.8: relative
+// includes resolve against the including file and the search path, so the
+// result does not depend on the working directory.
+func TestPreProcessIncludes_SearchPathIndependentOfCwd(t *testing.T) {
+	tmpDir := t.TempDir()
+	project := filepath.Join(tmpDir, "project")
+	lib := filepath.Join(tmpDir, "lib")
+	os.MkdirAll(filepath.Join(project, "src"), 0755)
+	os.MkdirAll(lib, 0755)
+
+	os.WriteFile(filepath.Join(project, "src", "util.kasm"), []byte(`%include "macros.kasm"`+"\nmov rbx, 1"), 0644)
+	os.WriteFile(filepath.Join(lib, "macros.kasm"), []byte("mov rax, 42"), 0644)
+	root := filepath.Join(project, "main.kasm")
+	os.WriteFile(root, []byte(`%include "src/util.kasm"`), 0644)
+
+	t.Chdir(t.TempDir())
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcessIncludes(string(source), root, preProcessOptions{searchPath: []string{lib}}, tracker, debugCtx)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+	}
+	if !strings.Contains(result, "mov rax, 42") || !strings.Contains(result, "mov rbx, 1") {
+		t.Errorf("expected both includes to be resolved, got:\n%s", result)
+	}
+}
+
+// TestPreProcess_InactiveIncludeNotRead verifies FR-1.9: an include in an
+// inactive conditional branch is neither read by the dependency graph nor
+// inlined, so a missing platform-specific file is not an error.
+func TestPreProcess_InactiveIncludeNotRead(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	os.WriteFile(filepath.Join(tmpDir, "config.kasm"), []byte("%define ARCH_X86"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "x86.kasm"), []byte("mov rax, 86"), 0644)
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%include \"config.kasm\"\n%ifdef ARCH_RISCV\n%include \"riscv.kasm\"\n%elifdef ARCH_X86\n%include \"x86.kasm\"\n%endif"), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcess(string(source), root, preProcessOptions{macroDepth: 1}, tracker, debugCtx)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+	}
+	if !strings.Contains(result, "mov rax, 86") || strings.Contains(result, "riscv.kasm") {
+		t.Errorf("expected only the active include to be resolved, got:\n%s", result)
+	}
+}
+
+// TestReadIncbinFiles verifies that the files embedded by %incbin are read
+// once per path and that unreadable files are reported at the directive.
+func TestReadIncbinFiles(t *testing.T) {
+	tmpDir := t.TempDir()
+	blob := filepath.Join(tmpDir, "blob.bin")
+	missing := filepath.Join(tmpDir, "missing.bin")
+	os.WriteFile(blob, []byte{1, 2, 3}, 0644)
+	root := filepath.Join(tmpDir, "root.kasm")
+	source := "%incbin \"" + blob + "\"\n%incbin \"" + blob + "\"\n%incbin \"" + missing + "\""
+	os.WriteFile(root, []byte(source), 0644)
+
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+	program := &ast.Program{Statements: []ast.Statement{
+		&ast.IncbinStmt{Path: blob, Line: 1, Column: 1},
+		&ast.IncbinStmt{Path: blob, Line: 2, Column: 1},
+		&ast.IncbinStmt{Path: missing, Line: 3, Column: 1},
+	}}
+
+	binaries := readIncbinFiles(program, source, tracker, debugCtx)
+
+	if len(binaries) != 1 || string(binaries[blob]) != "\x01\x02\x03" {
+		t.Errorf("expected the contents of blob.bin, got %v", binaries)
+	}
+	errors := debugCtx.Errors()
+	if len(errors) != 1 || !strings.Contains(errors[0].String(), root+":3:1") || !strings.Contains(errors[0].String(), "missing.bin") {
+		t.Errorf("expected an error for missing.bin at line 3, got %v", errors)
+	}
+}
+
+// TestAssembleFile_IncbinErrorLine verifies that a binary that cannot be
+// read, and an offset outside of a binary, are reported at the line of the
+// %incbin directive in the source, not in the pre-processed source.
+func TestAssembleFile_IncbinErrorLine(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	os.WriteFile(filepath.Join(tmpDir, "blob.bin"), []byte("ab"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "missing.kasm"), []byte("section .text: code\n%incbin FILE"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "offset.kasm"), []byte("section .text: code\n%incbin \"blob.bin\", 5"), 0644)
+
+	cases := map[string][]string{
+		"missing.kasm:2:1: failed to read %incbin file 'missing.bin'": {"missing.kasm", "-D", `FILE="missing.bin"`},
+		"offset.kasm:2:1: %incbin offset 5 is outside of":             {"offset.kasm"},
+	}
+	for message, args := range cases {
+		cmd := NewAssembleFileCmd("rv64")
+		cmd.SetArgs(args)
+		var stderr strings.Builder
+		cmd.SetErr(&stderr)
+		cmd.Execute()
+		if !strings.Contains(stderr.String(), message) {
+			t.Errorf("%v: expected %q, got %q", args, message, stderr.String())
+		}
+	}
+}
+
+// TestAssembleFile_ParserErrorLine verifies FR-12.2 for the parser: a parse
+// error is reported at its original line, both when assembling the source and
+// when assembling its pre-processed (-E) output.
+func TestAssembleFile_ParserErrorLine(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	os.WriteFile(filepath.Join(tmpDir, "q.kasm"), []byte("%define N 1\nsection .text: code\n    mov rax, N\n    mov [rax\n    ret"), 0644)
+
+	cmd := NewAssembleFileCmd("x86_64")
+	cmd.SetArgs([]string{"q.kasm", "-E", "-o", "q.pre.kasm"})
+	cmd.Execute()
+
+	for _, file := range []string{"q.kasm", "q.pre.kasm"} {
+		cmd := NewAssembleFileCmd("x86_64")
+		cmd.SetArgs([]string{file})
+		var stderr strings.Builder
+		cmd.SetErr(&stderr)
+		cmd.Execute()
+		if !strings.Contains(stderr.String(), "q.kasm:4:9: unterminated memory operand") {
+			t.Errorf("%s: expected the error at q.kasm:4:9, got %q", file, stderr.String())
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FR-7: Defines
+// ---------------------------------------------------------------------------
+
+// TestPreProcessDefines_KeepsLineOrigins verifies FR-7.4: lines rewritten by
+// %define substitution still trace back to their original line.
+func TestPreProcessDefines_KeepsLineOrigins(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%define SIZE 8\nmov rax, 1\nmov rbx, SIZE"), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcessDefines(string(source), nil, tracker, debugCtx)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+	}
+
+	lines := strings.Split(result, "\n")
+	for i, line := range lines {
+		if line != "mov rbx, 8" {
+			continue
+		}
+		if origin := tracker.Origin(i); origin != 2 {
+			t.Errorf("expected substituted line to trace to line index 2, got %d", origin)
+		}
+		return
+	}
+	t.Fatalf("expected 'mov rbx, 8' in the pre-processed source, got:\n%s", result)
+}
+
+// ---------------------------------------------------------------------------
+// FR-4: Conditionals
+// ---------------------------------------------------------------------------
+
+// TestPreProcessConditionals_ReportsExpressionErrorColumn verifies FR-4.8:
+// %if expression errors are recorded at the original line and the column of
+// the offending token, after earlier blocks have been blanked.
+func TestPreProcessConditionals_ReportsExpressionErrorColumn(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%ifdef DEBUG\nmov rax, 1\n%endif\n%define SIZE 8\n  %if SIZE / 0\n%endif"), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	preProcessConditionals(string(source), nil, tracker, debugCtx)
+
+	errs := debugCtx.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got: %v", errs)
+	}
+	loc := errs[0].Location()
+	if loc.Line() != 5 || loc.Column() != 12 {
+		t.Errorf("expected error at 5:12, got %d:%d", loc.Line(), loc.Column())
+	}
+	if !strings.Contains(errs[0].Message(), "division by zero") {
+		t.Errorf("expected a division by zero error, got: %s", errs[0].Message())
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FR-2.6: Nested Macro Expansion
+// ---------------------------------------------------------------------------
+
+// TestPreProcessMacros_DepthLimit verifies FR-2.6.4: runaway recursion is
+// recorded at the line of the outermost call, using the configured limit.
+func TestPreProcessMacros_DepthLimit(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%macro ping 0\n    pong\n%endmacro\n%macro pong 0\n    ping\n%endmacro\nmov rax, 1\nping"), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	preProcessMacros(string(source), 2, nil, tracker, debugCtx)
+
+	errs := debugCtx.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got: %v", errs)
+	}
+	if loc := errs[0].Location(); loc.Line() != 8 {
+		t.Errorf("expected error at line 8, got %d", loc.Line())
+	}
+	if !strings.Contains(errs[0].Message(), "depth limit of 2: ping → pong → ping") {
+		t.Errorf("expected the expansion chain in the error, got: %s", errs[0].Message())
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FR-8: Repetitions
+// ---------------------------------------------------------------------------
+
+// TestPreProcessRepetitions_KeepsTemplateOrigins verifies FR-8.2.3: every
+// repeated line traces back to its template line in the tracker.
+func TestPreProcessRepetitions_KeepsTemplateOrigins(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%assign i 0\n%rep 3\nmov rax, i\n%assign i i+1\n%endrep\nmov rbx, 1"), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcessRepetitions(string(source), nil, tracker, debugCtx)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+	}
+	expected := "%define i 0\nmov rax, 0\n%undef i\n%define i 1\nmov rax, 1\n%undef i\n%define i 2\nmov rax, 2\n%undef i\n%define i 3\nmov rbx, 1"
+	if result != expected {
+		t.Fatalf("unexpected unrolled source:\n%s", result)
+	}
+	for line, origin := range []int{0, 2, 3, 3, 2, 3, 3, 2, 3, 3, 5} {
+		if got := tracker.Origin(line); got != origin {
+			t.Errorf("expected line %d to trace to line index %d, got %d", line, origin, got)
+		}
+	}
+}
+
+// TestPreProcess_AssignIsDefined verifies FR-8.3.3: a name bound by %assign,
+// at the top level or in a macro body, is defined for %ifdef and defined() in
+// the conditional phase from the %assign on, and its last value is
+// substituted.
+func TestPreProcess_AssignIsDefined(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%ifdef N\nearly\n%endif\n%assign N 1\n%assign N N+1\n%ifdef N\nifdef_N\n%endif\n%if defined(N) && N == 2\ndefined_N\n%endif\n%macro count 0\n%assign M 7\n%endmacro\ncount\n%ifdef M\nifdef_M\n%endif\ndb N, M"), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcess(string(source), root, preProcessOptions{macroDepth: 1}, tracker, debugCtx)
+
+	if debugCtx.HasErrors() || len(debugCtx.Warnings()) > 0 {
+		t.Fatalf("expected no diagnostics, got: %v %v", debugCtx.Errors(), debugCtx.Warnings())
+	}
+	for _, expected := range []string{"ifdef_N", "defined_N", "ifdef_M", "db 2, 7"} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("expected %q in the result, got:\n%s", expected, result)
+		}
+	}
+	if strings.Contains(result, "early") || strings.Contains(result, "%define") || strings.Contains(result, "%undef") {
+		t.Errorf("expected N to be undefined before the %%assign and no directives left, got:\n%s", result)
+	}
+}
+
+// TestPreProcess_StringFunctionDirectives verifies that the names bound by
+// %strlen, %substr and %strcat directives are defined for the later phases.
+func TestPreProcess_StringFunctionDirectives(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%strlen L \"abc\"\n%substr V \"abc\" 1,1\n%strcat C \"ab\", \"cd\"\n%if defined(V) && L == 3\ndb L, V, C\n%endif"), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcess(string(source), root, preProcessOptions{macroDepth: 1}, tracker, debugCtx)
+
+	if debugCtx.HasErrors() || len(debugCtx.Warnings()) > 0 {
+		t.Fatalf("expected no diagnostics, got: %v %v", debugCtx.Errors(), debugCtx.Warnings())
+	}
+	if !strings.Contains(result, `db 3, "a", "abcd"`) || strings.Contains(result, "%strlen") || strings.Contains(result, "%substr") || strings.Contains(result, "%strcat") {
+		t.Errorf("expected the bound values and no directives left, got:\n%s", result)
+	}
+}
+
+// TestPreProcess_StringFunctionDirectiveErrorPhase verifies FR-14.6.5: errors
+// in string directives are recorded in their own phase, and the repetition
+// phase's own errors are not.
+func TestPreProcess_StringFunctionDirectiveErrorPhase(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%strcat S \"a\", 1\n%assign\n%strlen"), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	preProcess(string(source), root, preProcessOptions{macroDepth: 1}, tracker, debugCtx)
+
+	expected := map[int]string{
+		1: "pre-processing/string-directives",
+		2: "pre-processing/repetitions",
+		3: "pre-processing/string-directives",
+	}
+	errs := debugCtx.Errors()
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got: %v", len(expected), errs)
+	}
+	for _, e := range errs {
+		if phase := expected[e.Location().Line()]; e.Phase() != phase {
+			t.Errorf("%s: expected phase %q, got %q", e.String(), phase, e.Phase())
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FR-9: User Diagnostics
+// ---------------------------------------------------------------------------
+
+// TestPreProcess_UserDiagnostics verifies FR-9.2 and FR-9.3: %warning and
+// %fatal are recorded at their severity and original line, and %fatal stops
+// the remaining phases.
+func TestPreProcess_UserDiagnostics(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%rep 2\nnop\n%endrep\n%warning \"check\"\n%fatal \"stop\"\n%define BAD("), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	preProcess(string(source), root, preProcessOptions{macroDepth: 1}, tracker, debugCtx)
+
+	warnings := debugCtx.Warnings()
+	if len(warnings) != 1 || warnings[0].Message() != "check" || warnings[0].Location().Line() != 4 {
+		t.Errorf("expected the warning at line 4, got %v", warnings)
+	}
+	errs := debugCtx.Errors()
+	if len(errs) != 1 || errs[0].Severity() != debugcontext.SeverityFatal || errs[0].Location().Line() != 5 {
+		t.Errorf("expected only the fatal entry at line 5, got %v", errs)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FR-10: Command-Line Symbols
+// ---------------------------------------------------------------------------
+
+// TestPreProcess_CommandLineSymbols verifies FR-10.2: -D and -U symbols select
+// conditional branches and includes, are substituted, and take precedence over
+// the source, whose overridden %define is reported as a warning.
+func TestPreProcess_CommandLineSymbols(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	os.WriteFile(filepath.Join(tmpDir, "debug.kasm"), []byte("mov rcx, LOG_LEVEL"), 0644)
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%define FEATURE_X\n%ifdef DEBUG\n%include \"debug.kasm\"\n%endif\n%ifdef FEATURE_X\nmov rax, 1\n%endif\n%ifdef RELEASE\n%include \"release.kasm\"\n%endif"), 0644)
+
+	commandLine, err := preProcessing.CommandLineSymbolsNew([]string{"DEBUG", "LOG_LEVEL=3"}, []string{"FEATURE_X"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcess(string(source), root, preProcessOptions{macroDepth: 1, commandLine: commandLine}, tracker, debugCtx)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+	}
+	if !strings.Contains(result, "mov rcx, 3") || strings.Contains(result, "mov rax, 1") {
+		t.Errorf("expected the command-line symbols to take effect, got:\n%s", result)
+	}
+	warnings := debugCtx.Warnings()
+	if len(warnings) != 1 || warnings[0].Location().Line() != 1 || !strings.Contains(warnings[0].Message(), "FEATURE_X") {
+		t.Errorf("expected a warning for the %%define at line 1, got %v", warnings)
+	}
+}
+
+// TestPreProcess_ConditionalMacroDefinitions verifies FR-2.2.8: of a macro
+// defined under both branches of %ifdef DEBUG, the one selected by -D DEBUG
+// is expanded.
+func TestPreProcess_ConditionalMacroDefinitions(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%ifdef DEBUG\n%macro log 1\n    mov rdi, %1\n    call debug_log\n%endmacro\n%else\n%macro log 1\n%endmacro\n%endif\nlog 42"), 0644)
+
+	for _, defines := range [][]string{nil, {"DEBUG"}} {
+		commandLine, err := preProcessing.CommandLineSymbolsNew(defines, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		source, _ := os.ReadFile(root)
+		debugCtx := debugcontext.NewDebugContext(root)
+		tracker, err := lineMap.Track(root)
+		if err != nil {
+			t.Fatalf("failed to create tracker: %v", err)
+		}
+
+		result := preProcess(string(source), root, preProcessOptions{macroDepth: 1, commandLine: commandLine}, tracker, debugCtx)
+
+		if debugCtx.HasErrors() {
+			t.Fatalf("%v: expected no errors, got: %v", defines, debugCtx.Errors())
+		}
+		if expanded := strings.Contains(result, "call debug_log"); expanded != (defines != nil) {
+			t.Errorf("%v: expected debug_log to be called only with -D DEBUG, got:\n%s", defines, result)
+		}
+	}
+}
+
+// TestPreProcess_MacroCallInInactiveBranch verifies FR-2.3.7: a call with
+// the wrong number of arguments in a false %ifdef is not checked, unless the
+// branch is made active with -D.
+func TestPreProcess_MacroCallInInactiveBranch(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%macro m 1\n    mov rax, %1\n%endmacro\n%ifdef NOPE\nm 1, 2\n%endif\nm 3"), 0644)
+
+	for _, defines := range [][]string{nil, {"NOPE"}} {
+		commandLine, err := preProcessing.CommandLineSymbolsNew(defines, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		source, _ := os.ReadFile(root)
+		debugCtx := debugcontext.NewDebugContext(root)
+		tracker, err := lineMap.Track(root)
+		if err != nil {
+			t.Fatalf("failed to create tracker: %v", err)
+		}
+
+		result := preProcess(string(source), root, preProcessOptions{macroDepth: 1, commandLine: commandLine}, tracker, debugCtx)
+
+		if defines == nil {
+			if debugCtx.HasErrors() {
+				t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+			}
+			if !strings.Contains(result, "mov rax, 3") {
+				t.Errorf("expected the active call to be expanded, got:\n%s", result)
+			}
+			continue
+		}
+		errs := debugCtx.Errors()
+		if len(errs) != 1 || !strings.Contains(errs[0].Message(), "macro 'm' expects 1 arguments, but got 2") || errs[0].Location().Line() != 5 {
+			t.Errorf("-D NOPE: expected the argument count error at line 5, got: %v", errs)
+		}
+	}
+}
+
+// TestPreProcess_PredefinedSymbols verifies FR-11: the predefined symbols
+// describe the run, and __FILE__ and __LINE__ resolve to the original file and
+// line — the call site for a macro body, the use for a define body.
+func TestPreProcess_PredefinedSymbols(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	os.Mkdir(filepath.Join(tmpDir, "lib"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "lib", "where.kasm"), []byte("%pragma once\n%macro where 0\n    mov rax, __LINE__ ; __LINE__\n%endmacro\nmov rbx, __FILE__"), 0644)
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%include \"lib/where.kasm\"\n%define HERE __LINE__\n\nwhere\nmov rcx, HERE\n%if __BITS__ == 64\nmov rdx, __ARCH__\n%endif\nmov rsi, __FILE__, __DATE__, __TIME__, __KASM_VERSION__"), 0644)
+
+	commandLine := (*preProcessing.CommandLineSymbols)(nil).WithPredefined(preProcessing.Predefined{
+		Arch:      "x86_64",
+		Bits:      64,
+		Version:   "1.2.3",
+		BuildTime: time.Unix(1700000000, 0),
+	})
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcess(string(source), root, preProcessOptions{macroDepth: 1, commandLine: commandLine}, tracker, debugCtx)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+	}
+	for _, expected := range []string{
+		"mov rax, 4 ; __LINE__",
+		`mov rbx, "lib/where.kasm"`,
+		"mov rcx, 5",
+		`mov rdx, "x86_64"`,
+		`mov rsi, "main.kasm", "2023-11-14", "22:13:20", "1.2.3"`,
+	} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("expected %q in the result, got:\n%s", expected, result)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Pre-processor FR-14: Functions
+// ---------------------------------------------------------------------------
+
+// TestPreProcess_Functions verifies FR-14: functions and pasting operators in
+// macro and define bodies see the substituted arguments, functions in
+// constant expressions are evaluated with the expression, and errors are
+// reported at the original line.
+func TestPreProcess_Functions(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%define GREETING \"hello, world\"\n%define HANDLER(n) isr_ %+ n\n%macro name 1\nisr_ %+ %1:\n    db %strlen(%str(%1))\n%endmacro\n%if %strlen(GREETING) > 8\nname 14\n%endif\njmp HANDLER(14)\ndb %strlen(42)"), 0644)
+
+	source, _ := os.ReadFile(root)
+	debugCtx := debugcontext.NewDebugContext(root)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcess(string(source), root, preProcessOptions{macroDepth: 1}, tracker, debugCtx)
+
+	for _, expected := range []string{"isr_14:", "db 2", "jmp isr_14"} {
+		if !strings.Contains(result, expected) {
+			t.Errorf("expected %q in the result, got:\n%s", expected, result)
+		}
+	}
+	errs := debugCtx.Errors()
+	if len(errs) != 1 || errs[0].Location().Line() != 11 || !strings.Contains(errs[0].Message(), "%strlen expects a string") {
+		t.Errorf("expected the %%strlen error at line 11, got %v", errs)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Pre-processor FR-12: Pre-processed output
+// ---------------------------------------------------------------------------
+
+// preProcessFile pre-processes the file at root as runAssembleFile does and
+// returns the -E output together with the debug context.
+func preProcessFile(t *testing.T, root string) (string, *debugcontext.DebugContext) {
+	t.Helper()
+	source, _ := os.ReadFile(root)
+	markers := lineMarkerMapperNew(root, string(source))
+	debugCtx := debugcontext.NewDebugContext(root).WithLocationMapper(markers.mapLocation)
+	tracker, err := lineMap.Track(root)
+	if err != nil {
+		t.Fatalf("failed to create tracker: %v", err)
+	}
+
+	result := preProcess(string(source), root, preProcessOptions{macroDepth: 1}, tracker, debugCtx)
+	return preProcessedOutput(result, tracker, debugCtx, markers), debugCtx
+}
+
+// TestPreProcessedOutput_LineMarkers verifies FR-12.1: the output carries a
+// %line marker wherever the original numbering breaks, with an increment of 0
+// for a macro expansion, and does not write a file of its own.
+func TestPreProcessedOutput_LineMarkers(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	os.Mkdir(filepath.Join(tmpDir, "lib"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "lib", "io.kasm"), []byte("\n\nmov rax, 1\n%include \"b.kasm\"\nmov rbx, 2"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "lib", "b.kasm"), []byte("nop"), 0644)
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%include \"lib/io.kasm\"\n%macro twice 1\n    mov rcx, %1\n    mov rdx, %1\n%endmacro\nstart:\n    twice 5\n    ret"), 0644)
+
+	output, debugCtx := preProcessFile(t, root)
+
+	if debugCtx.HasErrors() {
+		t.Fatalf("expected no errors, got: %v", debugCtx.Errors())
+	}
+	expected := `%line 3+1 "lib/io.kasm"
+mov rax, 1
+%line 1+1 "lib/b.kasm"
+nop
+%line 4+1 "lib/io.kasm"
+
+mov rbx, 2
+%line 6+1 "main.kasm"
+start:
+
+%line 7+0 "main.kasm"
+; MACRO: twice
+mov rcx, 5
+mov rdx, 5
+
+%line 8+1 "main.kasm"
+    ret
+`
+	if output != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, output)
+	}
+	if _, err := os.Stat(filepath.Join(tmpDir, "preprocessed.kasm")); err == nil {
+		t.Error("expected no preprocessed.kasm to be written")
+	}
+}
+
+// TestPreProcessedOutput_RoundTrip verifies FR-12.2: pre-processing the output
+// again yields the same output, and diagnostics in it are reported at the
+// original file and line.
+func TestPreProcessedOutput_RoundTrip(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	os.WriteFile(filepath.Join(tmpDir, "io.kasm"), []byte("mov rax, 1\n%warning check io\nmov rbx, 2"), 0644)
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(root, []byte("%include \"io.kasm\"\nstart:\n%warning check main"), 0644)
+
+	first, _ := preProcessFile(t, root)
+	preProcessed := filepath.Join(tmpDir, "main.pre.kasm")
+	os.WriteFile(preProcessed, []byte(first), 0644)
+
+	second, debugCtx := preProcessFile(t, preProcessed)
+
+	if second != first {
+		t.Errorf("expected the output to be stable, got:\n%s\nthen:\n%s", first, second)
+	}
+	if warnings := debugCtx.Warnings(); len(warnings) != 0 {
+		t.Fatalf("expected the %%warning directives to have been evaluated away, got %v", warnings)
+	}
+
+	// A later stage reports a line of the pre-processed file, as the
+	// semantic analyser does.
+	line := slices.Index(strings.Split(first, "\n"), "mov rbx, 2") + 1
+	entry := debugCtx.Error(debugCtx.Loc(line, 1), "unknown instruction")
+	if got := entry.Location().String(); got != "io.kasm:3:1" {
+		t.Errorf("expected the diagnostic at io.kasm:3:1, got %s", got)
+	}
+}
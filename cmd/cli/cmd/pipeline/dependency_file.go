@@ -0,0 +1,171 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/keurnel/assembler/internal/debugcontext"
+	"github.com/keurnel/assembler/internal/lineMap"
+	"github.com/keurnel/assembler/v0/kasm/ast"
+	"github.com/keurnel/assembler/v0/kasm/dependency_graph"
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+	"github.com/spf13/cobra"
+)
+
+// dependencyFlagsAnnotation marks the commands that accept -MD and -MF, so
+// that DependencyArgs leaves the arguments of every other command alone.
+const dependencyFlagsAnnotation = "dependency-flags"
+
+// DependencyArgs returns args, the command line of root, with -MD and -MF
+// rewritten by MakeDependencyArgs if args run an assemble command, and
+// unchanged otherwise.
+func DependencyArgs(root *cobra.Command, args []string) []string {
+	command, _, err := root.Find(args)
+	if err != nil || command.Annotations[dependencyFlagsAnnotation] == "" {
+		return args
+	}
+	return MakeDependencyArgs(args)
+}
+
+// MakeDependencyArgs rewrites the compiler-style -MD and -MF flags in args to
+// the --MD and --MF flags registered by addAssembleFlags, which the flag
+// parser would otherwise read as the shorthands -M and -D. -MFpath and
+// -MF=path become --MF=path. Arguments after "--" are left untouched.
+func MakeDependencyArgs(args []string) []string {
+	rewritten := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "--" {
+			copy(rewritten[i:], args[i:])
+			break
+		}
+		switch {
+		case arg == "-MD" || arg == "-MF":
+			arg = "-" + arg
+		case strings.HasPrefix(arg, "-MF"):
+			arg = "--MF=" + strings.TrimPrefix(arg[len("-MF"):], "=")
+		}
+		rewritten[i] = arg
+	}
+	return rewritten
+}
+
+// dependencyFilePath returns the path of the dependency file requested with
+// -MD: the -MF path, or outputPath with its extension replaced by .d. It
+// returns an empty path without -MD. The dependency file names outputPath as
+// its target, so it cannot be written for output to stdout.
+func dependencyFilePath(cmd *cobra.Command, outputPath string) (string, error) {
+	enabled, _ := cmd.Flags().GetBool("MD")
+	path, _ := cmd.Flags().GetString("MF")
+	switch {
+	case !enabled && path != "":
+		return "", fmt.Errorf("-MF requires -MD")
+	case !enabled:
+		return "", nil
+	case outputPath == "":
+		return "", fmt.Errorf("-MD requires an output file with -E")
+	case path != "":
+		return path, nil
+	}
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + ".d", nil
+}
+
+// sourceDependencies returns the files the root file depends on: the files
+// it includes, transitively, and the binaries they embed with %incbin. The
+// dependency graph follows the same includes as the pre-processor.
+func sourceDependencies(source, rootFilePath string, options preProcessOptions) ([]string, error) {
+	cwd, err := os.Getwd()
+	if err != nil {
+		return nil, fmt.Errorf("unable to get working directory: %w", err)
+	}
+	return dependency_graph.NewWithOptions(source, cwd, rootFilePath, options.graphOptions()).Dependencies(), nil
+}
+
+// moduleFiles returns the source files of the modules program imports with
+// use. A module is the file NAME.kasm, looked up with the include rules
+// (pre-processor FR-1.8.1): next to the file containing the use statement,
+// then in the search path. Modules without such a file are not listed.
+func moduleFiles(program *ast.Program, source string, searchPath []string, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) []string {
+	files := make([]string, 0)
+	for _, stmt := range program.Statements {
+		use, ok := stmt.(*ast.UseStmt)
+		if !ok {
+			continue
+		}
+
+		location := locatePreProcessedLine(source, use.Line, use.Column, tracker, debugCtx)
+		path := preProcessing.ResolveInclude(use.ModuleName+".kasm", filepath.Dir(location.FilePath()), searchPath)
+		if info, err := os.Stat(path); err != nil || info.IsDir() {
+			continue
+		}
+		if absolute, err := filepath.Abs(path); err == nil {
+			path = absolute
+		}
+		if !slices.Contains(files, path) {
+			files = append(files, path)
+		}
+	}
+	return files
+}
+
+// writeDependencyFile writes a Make dependency file to path stating that
+// target depends on the root file, dependencies, modules and binaries.
+// modules holds the files of the modules imported with use. binaries holds
+// the files read for the %incbin directives of the program, which include
+// those produced by macros and defines that the dependency graph cannot see.
+// Their paths are relative to the working directory unless the include phase
+// resolved them.
+func writeDependencyFile(path, target, rootFilePath string, dependencies, modules []string, binaries map[string][]byte) error {
+	for _, module := range modules {
+		if !slices.Contains(dependencies, module) {
+			dependencies = append(dependencies, module)
+		}
+	}
+	for binary := range binaries {
+		if absolute, err := filepath.Abs(binary); err == nil {
+			binary = absolute
+		}
+		if !slices.Contains(dependencies, binary) {
+			dependencies = append(dependencies, binary)
+		}
+	}
+	slices.Sort(dependencies)
+
+	content := dependencyFile(target, rootFilePath, dependencies)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		return fmt.Errorf("failed to write dependency file: %w", err)
+	}
+	return nil
+}
+
+// dependencyFile returns a Make rule stating that target depends on the root
+// file and dependencies, followed by an empty rule for every dependency, so
+// that deleting an included file does not break the build:
+//
+//	main.bin: main.kasm \
+//	  lib/io.kasm
+//
+//	lib/io.kasm:
+//
+// Paths inside the working directory are written relative to it.
+func dependencyFile(target, rootFilePath string, dependencies []string) string {
+	var sb strings.Builder
+	sb.WriteString(makePath(displayPath(target)) + ": " + makePath(displayPath(rootFilePath)))
+	for _, dependency := range dependencies {
+		sb.WriteString(" \\\n  " + makePath(displayPath(dependency)))
+	}
+	sb.WriteString("\n")
+
+	for _, dependency := range dependencies {
+		sb.WriteString("\n" + makePath(displayPath(dependency)) + ":\n")
+	}
+	return sb.String()
+}
+
+// makePath escapes the characters of path that Make would otherwise read as
+// a separator, a comment or a variable reference.
+func makePath(path string) string {
+	return strings.NewReplacer(" ", `\ `, "#", `\#`, "$", "$$").Replace(path)
+}
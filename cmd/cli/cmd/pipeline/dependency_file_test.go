@@ -0,0 +1,165 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+)
+
+// ---------------------------------------------------------------------------
+// Dependency files (-MD / -MF)
+// ---------------------------------------------------------------------------
+
+// TestMakeDependencyArgs verifies that -MD and -MF are rewritten to their
+// long flags, in every form, and that arguments after "--" are not.
+func TestMakeDependencyArgs(t *testing.T) {
+	args := []string{"rv64", "assemble-file", "-MD", "-MF", "a.d", "-MFb.d", "-MF=c.d", "-D", "X", "-M", "--", "-MD"}
+
+	expected := []string{"rv64", "assemble-file", "--MD", "--MF", "a.d", "--MF=b.d", "--MF=c.d", "-D", "X", "-M", "--", "-MD"}
+	if rewritten := MakeDependencyArgs(args); !slices.Equal(rewritten, expected) {
+		t.Errorf("expected %v, got %v", expected, rewritten)
+	}
+}
+
+// TestDependencyArgs verifies that the arguments are only rewritten for the
+// assemble commands.
+func TestDependencyArgs(t *testing.T) {
+	root := &cobra.Command{Use: "keurnel-asm"}
+	arch := &cobra.Command{Use: "rv64"}
+	arch.AddGroup(&cobra.Group{ID: "file-operations", Title: "File Operations"})
+	arch.AddCommand(NewAssembleFileCmd("rv64"))
+	root.AddCommand(arch, NewAssembleCmd(), &cobra.Command{Use: "other", Run: func(*cobra.Command, []string) {}})
+
+	cases := map[string][]string{
+		"rv64 assemble-file main.kasm --MD": {"rv64", "assemble-file", "main.kasm", "-MD"},
+		"assemble main.kasm --MF=a.d":       {"assemble", "main.kasm", "-MFa.d"},
+		"other -MD":                         {"other", "-MD"},
+		"missing -MD":                       {"missing", "-MD"},
+	}
+	for expected, args := range cases {
+		if rewritten := strings.Join(DependencyArgs(root, args), " "); rewritten != expected {
+			t.Errorf("%v: expected %q, got %q", args, expected, rewritten)
+		}
+	}
+}
+
+// TestDependencyFile verifies the Make rule and the empty rule for every
+// dependency, with paths relative to the working directory and escaped.
+func TestDependencyFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	content := dependencyFile(filepath.Join(tmpDir, "out $1.bin"), filepath.Join(tmpDir, "main.kasm"),
+		[]string{filepath.Join(tmpDir, "lib", "io #2.kasm"), "/elsewhere/font.bin"})
+
+	expected := `out\ $$1.bin: main.kasm \
+  lib/io\ \#2.kasm \
+  /elsewhere/font.bin
+
+lib/io\ \#2.kasm:
+
+/elsewhere/font.bin:
+`
+	if content != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+}
+
+// TestAssembleFile_DependencyFile verifies that -MD -MF writes the included
+// files and the binaries embedded with %incbin, including one embedded by a
+// macro, after assembling.
+func TestAssembleFile_DependencyFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	os.Mkdir(filepath.Join(tmpDir, "lib"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "lib", "io.kasm"), []byte("%incbin \"logo.bin\"\n%macro blob 1\n%incbin %1\n%endmacro"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "lib", "logo.bin"), []byte("ab"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "data.bin"), []byte("cd"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "main.kasm"), []byte("section .text: code\n%include \"lib/io.kasm\"\nblob \"data.bin\"\n_start:\n    nop"), 0644)
+
+	cmd := NewAssembleFileCmd("rv64")
+	cmd.SetArgs(MakeDependencyArgs([]string{"main.kasm", "-MD", "-MF", "build/main.d", "-o", "main.bin"}))
+	os.Mkdir(filepath.Join(tmpDir, "build"), 0755)
+	var stderr strings.Builder
+	cmd.SetErr(&stderr)
+	if err := cmd.Execute(); err != nil || stderr.Len() > 0 {
+		t.Fatalf("unexpected error: %v %s", err, stderr.String())
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "build", "main.d"))
+	if err != nil {
+		t.Fatalf("expected the dependency file to be written: %v", err)
+	}
+	expected := "main.bin: main.kasm \\\n  data.bin \\\n  lib/io.kasm \\\n  lib/logo.bin\n\ndata.bin:\n\nlib/io.kasm:\n\nlib/logo.bin:\n"
+	if string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+}
+
+// TestAssembleFile_DependencyFileModules verifies that the files of the
+// modules imported with use are listed, looked up next to the importing file
+// and then in the search path, and that a module without a file is not.
+func TestAssembleFile_DependencyFileModules(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	os.Mkdir(filepath.Join(tmpDir, "lib"), 0755)
+	os.Mkdir(filepath.Join(tmpDir, "modules"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "lib", "io.kasm"), []byte("use strings"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "lib", "strings.kasm"), []byte(""), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "modules", "math.kasm"), []byte(""), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "main.kasm"), []byte("section .text: code\n%include \"lib/io.kasm\"\nuse math\nuse linker\n_start:\n    nop"), 0644)
+
+	cmd := NewAssembleFileCmd("rv64")
+	cmd.SetArgs(MakeDependencyArgs([]string{"main.kasm", "-I", "modules", "-MD", "-o", "main.bin"}))
+	var stderr strings.Builder
+	cmd.SetErr(&stderr)
+	if err := cmd.Execute(); err != nil || stderr.Len() > 0 {
+		t.Fatalf("unexpected error: %v %s", err, stderr.String())
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "main.d"))
+	if err != nil {
+		t.Fatalf("expected the dependency file to be written: %v", err)
+	}
+	expected := "main.bin: main.kasm \\\n  lib/io.kasm \\\n  lib/strings.kasm \\\n  modules/math.kasm\n\nlib/io.kasm:\n\nlib/strings.kasm:\n\nmodules/math.kasm:\n"
+	if string(content) != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, content)
+	}
+}
+
+// TestAssembleFile_DependencyFileDefaultPath verifies that -MD without -MF
+// writes next to the output, and that -MF without -MD, or -MD for -E output
+// to stdout, is rejected.
+func TestAssembleFile_DependencyFileDefaultPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	os.WriteFile(filepath.Join(tmpDir, "main.kasm"), []byte("section .text: code\n_start:\n    nop"), 0644)
+
+	cases := map[string][]string{
+		"":                            {"main.kasm", "-MD", "-E", "-o", "pre.kasm"},
+		"-MF requires -MD":            {"main.kasm", "-MF", "main.d"},
+		"-MD requires an output file": {"main.kasm", "-MD", "-E"},
+	}
+	for message, args := range cases {
+		cmd := NewAssembleFileCmd("rv64")
+		cmd.SetArgs(MakeDependencyArgs(args))
+		var stderr strings.Builder
+		cmd.SetErr(&stderr)
+		cmd.Execute()
+		if message == "" && stderr.Len() > 0 || !strings.Contains(stderr.String(), message) {
+			t.Errorf("%v: expected %q, got %q", args, message, stderr.String())
+		}
+	}
+
+	content, err := os.ReadFile(filepath.Join(tmpDir, "pre.d"))
+	if err != nil || string(content) != "pre.kasm: main.kasm\n" {
+		t.Errorf("expected pre.d next to the output, got %q (%v)", content, err)
+	}
+}
@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/keurnel/assembler/v0/kasm/dependency_graph"
+	"github.com/spf13/cobra"
+)
+
+// dependencyFormats lists the output formats of the deps command.
+var dependencyFormats = []string{"tree", "dot", "json", "mermaid", "order"}
+
+// NewDepsCmd returns the `deps` command, which prints the dependency graph of
+// a source file, or the files that depend on one of its dependencies. The
+// architecture selected with --arch decides the predefined symbols, and so
+// the conditional includes that are followed.
+func NewDepsCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "deps <assembly-file>",
+		Short: "Print the dependency graph of an assembly file.",
+		Long: `Print the files an assembly file includes and embeds, as a tree (the default),
+in Graphviz DOT, JSON or Mermaid format, or in topological order with the
+depth of each file. With --reverse, print the files that depend on the given
+file instead.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			arch, _ := cmd.Flags().GetString("arch")
+			target, err := TargetByName(arch)
+			if err == nil {
+				err = runDeps(cmd, args, target)
+			}
+			if err != nil {
+				cmd.PrintErrln("Error:", err)
+			}
+		},
+	}
+
+	cmd.Flags().StringP("arch", "a", DefaultArchitecture, "Target architecture (name or alias)")
+	cmd.Flags().StringP("format", "f", "tree", "Output format: "+strings.Join(dependencyFormats, ", "))
+	cmd.Flags().StringP("reverse", "r", "", "Print the files that depend on this file, directly or indirectly")
+	addSymbolFlags(cmd)
+
+	return cmd
+}
+
+// runDeps builds the dependency graph of the file in args, as the include
+// phase would for target, and prints it in the requested format. Unresolvable
+// includes are reported on stderr; the rest of the graph is still printed.
+func runDeps(cmd *cobra.Command, args []string, target Target) error {
+	format, _ := cmd.Flags().GetString("format")
+	reverse, _ := cmd.Flags().GetString("reverse")
+
+	fullPath, err := resolveFilePath(args)
+	if err != nil {
+		return err
+	}
+	options, err := symbolOptionsFromFlags(cmd, target)
+	if err != nil {
+		return err
+	}
+	source, err := readSourceFile(fullPath)
+	if err != nil {
+		return err
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("unable to get working directory: %w", err)
+	}
+
+	graph := dependency_graph.NewWithOptions(source, cwd, fullPath, options.graphOptions())
+	for _, e := range graph.Errors() {
+		cmd.PrintErrln(e.String())
+	}
+
+	var output string
+	if reverse != "" {
+		output, err = reverseDependencies(graph, reverse, format)
+	} else {
+		output, err = formatDependencies(graph, format)
+	}
+	if err != nil {
+		return err
+	}
+	if output != "" && !strings.HasSuffix(output, "\n") {
+		output += "\n"
+	}
+	_, err = fmt.Fprint(cmd.OutOrStdout(), output)
+	return err
+}
+
+// formatDependencies returns the dependency graph in format.
+func formatDependencies(graph *dependency_graph.Instance, format string) (string, error) {
+	switch format {
+	case "tree":
+		return graph.String(), nil
+	case "dot":
+		return graph.ToDot(), nil
+	case "json":
+		return graph.ToJSON(), nil
+	case "mermaid":
+		return graph.ToMermaid(), nil
+	case "order":
+		order := graph.TopologicalOrder()
+		if order == nil {
+			return "", fmt.Errorf("no topological order: circular inclusion detected in dependency graph: %s",
+				strings.Join(graph.CyclePath(), " → "))
+		}
+		depths := graph.Depths()
+		lines := make([]string, len(order))
+		for i, name := range order {
+			lines[i] = fmt.Sprintf("%d %s", depths[name], name)
+		}
+		return strings.Join(lines, "\n"), nil
+	}
+	return "", fmt.Errorf("unknown format '%s' (expected one of: %s)", format, strings.Join(dependencyFormats, ", "))
+}
+
+// reverseDependencies returns the files of graph that depend on file, one per
+// line, or as a JSON array with --format json. file is resolved relative to
+// the working directory, like the assembly file.
+func reverseDependencies(graph *dependency_graph.Instance, file, format string) (string, error) {
+	name, err := filepath.Abs(file)
+	if err != nil {
+		return "", fmt.Errorf("invalid file '%s': %w", file, err)
+	}
+	if _, ok := graph.Nodes()[name]; !ok {
+		return "", fmt.Errorf("'%s' is not in the dependency graph", file)
+	}
+
+	dependents := graph.ReverseDependencies(name)
+	switch format {
+	case "tree":
+		return strings.Join(dependents, "\n"), nil
+	case "json":
+		content, err := json.MarshalIndent(dependents, "", "  ")
+		return string(content), err
+	}
+	return "", fmt.Errorf("--reverse supports the tree and json formats, not '%s'", format)
+}
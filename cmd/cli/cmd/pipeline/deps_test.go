@@ -0,0 +1,116 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// ---------------------------------------------------------------------------
+// deps command
+// ---------------------------------------------------------------------------
+
+// runDepsCmd runs the deps command with args and returns its stdout
+// and stderr.
+func runDepsCmd(t *testing.T, args ...string) (string, string) {
+	t.Helper()
+	cmd := NewDepsCmd()
+	cmd.SetArgs(args)
+	var stdout, stderr strings.Builder
+	cmd.SetOut(&stdout)
+	cmd.SetErr(&stderr)
+	if err := cmd.Execute(); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return stdout.String(), stderr.String()
+}
+
+// depsProject writes a project whose main.kasm includes lib/a.kasm and,
+// when X is defined, lib/x.kasm; both include lib/shared.kasm.
+func depsProject(t *testing.T) string {
+	t.Helper()
+	tmpDir := t.TempDir()
+	t.Chdir(tmpDir)
+
+	os.Mkdir(filepath.Join(tmpDir, "lib"), 0755)
+	os.WriteFile(filepath.Join(tmpDir, "main.kasm"), []byte("%include \"lib/a.kasm\"\n%ifdef X\n%include \"lib/x.kasm\"\n%endif"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "lib", "a.kasm"), []byte("%include \"shared.kasm\""), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "lib", "x.kasm"), []byte("%include \"shared.kasm\""), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "lib", "shared.kasm"), []byte("nop"), 0644)
+	return tmpDir
+}
+
+// TestDeps_Formats verifies that every format prints the graph the include
+// phase would follow, with -D selecting conditional includes.
+func TestDeps_Formats(t *testing.T) {
+	tmpDir := depsProject(t)
+	main := filepath.Join(tmpDir, "main.kasm")
+	shared := filepath.Join(tmpDir, "lib", "shared.kasm")
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"main.kasm"}, main + "\n└── " + filepath.Join(tmpDir, "lib", "a.kasm") + "\n    └── " + shared + "\n"},
+		{[]string{"main.kasm", "-f", "order", "-D", "X"}, "0 " + main + "\n1 " + filepath.Join(tmpDir, "lib", "a.kasm") +
+			"\n1 " + filepath.Join(tmpDir, "lib", "x.kasm") + "\n2 " + shared + "\n"},
+		{[]string{"main.kasm", "-f", "json"}, `"name": "` + shared + `",` + "\n      \"depth\": 2"},
+		{[]string{"main.kasm", "-f", "mermaid"}, "flowchart TD\n"},
+		{[]string{"main.kasm", "-f", "dot"}, "digraph dependencies {\n"},
+	}
+	for _, tt := range tests {
+		stdout, stderr := runDepsCmd(t, tt.args...)
+		if stderr != "" || !strings.Contains(stdout, tt.expected) {
+			t.Errorf("%v: expected %q, got:\n%s%s", tt.args, tt.expected, stdout, stderr)
+		}
+	}
+}
+
+// TestDeps_Reverse verifies that --reverse lists the direct and indirect
+// dependents of a file, as lines or as a JSON array.
+func TestDeps_Reverse(t *testing.T) {
+	tmpDir := depsProject(t)
+	a := filepath.Join(tmpDir, "lib", "a.kasm")
+	main := filepath.Join(tmpDir, "main.kasm")
+	x := filepath.Join(tmpDir, "lib", "x.kasm")
+
+	stdout, _ := runDepsCmd(t, "main.kasm", "-D", "X", "--reverse", "lib/shared.kasm")
+	if stdout != a+"\n"+x+"\n"+main+"\n" {
+		t.Errorf("expected the dependents of shared.kasm, got:\n%s", stdout)
+	}
+
+	stdout, _ = runDepsCmd(t, "main.kasm", "-r", "lib/a.kasm", "-f", "json")
+	if stdout != "[\n  \""+main+"\"\n]\n" {
+		t.Errorf("expected a JSON array, got:\n%s", stdout)
+	}
+
+	stdout, _ = runDepsCmd(t, "main.kasm", "-r", "main.kasm")
+	if stdout != "" {
+		t.Errorf("expected no dependents of the root, got:\n%s", stdout)
+	}
+}
+
+// TestDeps_Errors verifies that unknown formats, files outside the graph and
+// cyclic graphs without a topological order are reported.
+func TestDeps_Errors(t *testing.T) {
+	tmpDir := depsProject(t)
+	os.WriteFile(filepath.Join(tmpDir, "cyclic.kasm"), []byte("%include \"cyclic.kasm\""), 0644)
+
+	tests := []struct {
+		args    []string
+		message string
+	}{
+		{[]string{"main.kasm", "-f", "yaml"}, "unknown format 'yaml'"},
+		{[]string{"main.kasm", "-r", "lib/x.kasm"}, "'lib/x.kasm' is not in the dependency graph"},
+		{[]string{"main.kasm", "-r", "lib/a.kasm", "-f", "dot"}, "--reverse supports the tree and json formats, not 'dot'"},
+		{[]string{"cyclic.kasm", "-f", "order"}, "no topological order: circular inclusion detected"},
+		{[]string{"main.kasm", "--arch", "z80"}, "z80"},
+	}
+	for _, tt := range tests {
+		stdout, stderr := runDepsCmd(t, tt.args...)
+		if stdout != "" || !strings.Contains(stderr, tt.message) {
+			t.Errorf("%v: expected %q, got %q%q", tt.args, tt.message, stdout, stderr)
+		}
+	}
+}
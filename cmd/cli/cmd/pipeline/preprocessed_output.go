@@ -0,0 +1,73 @@
+package pipeline
+
+import (
+	"strings"
+
+	"github.com/keurnel/assembler/internal/debugcontext"
+	"github.com/keurnel/assembler/internal/lineMap"
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+)
+
+// preProcessedOutput returns the fully pre-processed source as written by -E
+// (pre-processor FR-12.1), annotated with %line markers so that assembling it
+// again reports diagnostics at the original files and lines. source must be
+// the tracker's latest snapshot.
+//
+// A marker is emitted wherever a line does not continue the file and line
+// numbering of the previous one, with an increment of 0 for runs of lines
+// from the same original line, such as a macro expansion. The ; FILE: and
+// ; END FILE: markers of inlined files are replaced by %line markers, and
+// comments and blank lines the pre-processor inserted without an origin are
+// dropped. Locations are passed through mapper, and the %line directives of
+// the input are dropped, so that pre-processing the output again yields the
+// same markers.
+func preProcessedOutput(source string, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext, mapper *lineMarkerMapper) string {
+	lines := strings.Split(strings.TrimSuffix(source, "\n"), "\n")
+	origins := traceLines(lines, tracker, debugCtx)
+
+	// Select the lines to write and resolve their origins.
+	kept := make([]int, 0, len(lines))
+	for index, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		origin := &origins[index]
+		switch {
+		case strings.HasPrefix(trimmed, "; FILE: ") || strings.HasPrefix(trimmed, "; END FILE: "):
+			continue
+		case !origin.traced:
+			if trimmed == "" || strings.HasPrefix(trimmed, ";") {
+				continue
+			}
+		case mapper.isMarker(origin.location):
+			continue
+		default:
+			location := mapper.mapLocation(origin.location)
+			origin.location = debugcontext.Loc(displayPath(location.FilePath()), location.Line(), 0)
+		}
+		kept = append(kept, index)
+	}
+
+	var sb strings.Builder
+	var marker preProcessing.LineMarker
+	next, numbered := 0, false
+	for position, index := range kept {
+		origin := origins[index]
+		if !origin.traced {
+			sb.WriteString(lines[index] + "\n")
+			numbered = false
+			continue
+		}
+
+		file, line := origin.location.FilePath(), origin.location.Line()
+		if !numbered || file != marker.File || line != next {
+			marker = preProcessing.LineMarker{Line: line, Increment: 1, File: file}
+			if position+1 < len(kept) && origins[kept[position+1]].location == origin.location {
+				marker.Increment = 0
+			}
+			sb.WriteString(marker.String() + "\n")
+			next, numbered = line, true
+		}
+		sb.WriteString(lines[index] + "\n")
+		next += marker.Increment
+	}
+	return sb.String()
+}
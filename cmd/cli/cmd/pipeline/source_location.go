@@ -0,0 +1,202 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/keurnel/assembler/internal/debugcontext"
+	"github.com/keurnel/assembler/internal/lineMap"
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+)
+
+// locatePreProcessedLine maps a 1-based line of a pre-processed source back to
+// the file and line it originated from. column is carried over unchanged, as
+// every phase that reports columns is line-preserving; 0 means the whole line.
+//
+// Lines inside an inlined include (between ; FILE: and ; END FILE: markers)
+// are attributed to the included file: the line number is counted from the
+// enclosing marker, skipping nested inlined blocks (each of which replaced a
+// single %include line) and adding back the leading blank lines that were
+// trimmed on inlining (FR-1.3.2). All other lines belong to the root file and
+// are traced through the tracker, whose latest snapshot must be source.
+func locatePreProcessedLine(source string, line, column int, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) debugcontext.Location {
+	if location, ok := tracePreProcessedLine(source, line, column, tracker, debugCtx); ok {
+		return location
+	}
+	return debugCtx.Loc(line, column)
+}
+
+// tracePreProcessedLine is locatePreProcessedLine that reports whether the
+// line could be traced. ok is false for lines the pre-processor inserted
+// into the root file, which have no origin.
+func tracePreProcessedLine(source string, line, column int, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) (location debugcontext.Location, ok bool) {
+	lines := strings.Split(source, "\n")
+	index := line - 1
+	if index < 0 || index >= len(lines) {
+		return debugcontext.Location{}, false
+	}
+
+	origin := traceLines(lines[:index+1], tracker, debugCtx)[index]
+	if !origin.traced {
+		return debugcontext.Location{}, false
+	}
+	return debugCtx.LocIn(origin.location.FilePath(), origin.location.Line(), column), true
+}
+
+// lineOrigin is the original location of a pre-processed line. traced is
+// false if the line has no origin.
+type lineOrigin struct {
+	location debugcontext.Location
+	traced   bool
+}
+
+// traceLines returns the origin of each of lines, the first lines of a
+// pre-processed source, as described for locatePreProcessedLine. The lines
+// are traced in a single pass: a stack holds the inlined files enclosing the
+// current line, with the next line number in each. A ; FILE: marker belongs
+// to the enclosing file and a ; END FILE: marker to the file it closes, after
+// which the whole inlined block, including the blank line that follows the
+// marker, counts as a single line of the enclosing file.
+func traceLines(lines []string, tracker *lineMap.Tracker, debugCtx *debugcontext.DebugContext) []lineOrigin {
+	type inlinedFile struct {
+		path string
+		line int
+	}
+	stack := make([]inlinedFile, 0)
+	blankLines := make(map[string]int)
+
+	current := func(index int) lineOrigin {
+		if len(stack) > 0 {
+			top := stack[len(stack)-1]
+			return lineOrigin{location: debugCtx.LocIn(top.path, top.line, 0), traced: true}
+		}
+		if tracker != nil {
+			if origin := tracker.Origin(index); origin >= 0 {
+				return lineOrigin{location: debugCtx.Loc(origin+1, 0), traced: true}
+			}
+		}
+		return lineOrigin{}
+	}
+	advance := func() {
+		if len(stack) > 0 {
+			stack[len(stack)-1].line++
+		}
+	}
+
+	origins := make([]lineOrigin, len(lines))
+	closed := false
+	for index, line := range lines {
+		trimmed := strings.TrimSpace(line)
+
+		// The blank line that ends an inlined block belongs to the
+		// %include line the block replaced.
+		if closed && trimmed == "" && len(stack) > 0 {
+			top := stack[len(stack)-1]
+			origins[index] = lineOrigin{location: debugCtx.LocIn(top.path, top.line-1, 0), traced: true}
+			closed = false
+			continue
+		}
+		closed = false
+
+		origins[index] = current(index)
+		switch {
+		case strings.HasPrefix(trimmed, "; FILE: "):
+			path := strings.TrimPrefix(trimmed, "; FILE: ")
+			blank, ok := blankLines[path]
+			if !ok {
+				blank = leadingBlankLines(path)
+				blankLines[path] = blank
+			}
+			stack = append(stack, inlinedFile{path: path, line: blank + 1})
+		case strings.HasPrefix(trimmed, "; END FILE: ") && len(stack) > 0:
+			stack = stack[:len(stack)-1]
+			advance()
+			closed = true
+		default:
+			advance()
+		}
+	}
+	return origins
+}
+
+// leadingBlankLines returns the number of lines of leading whitespace in the
+// file at path, which are trimmed when the file is inlined. Returns 0 if the
+// file cannot be read.
+func leadingBlankLines(path string) int {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	text := string(content)
+	trimmed := strings.TrimLeft(text, " \t\r\n")
+	return strings.Count(text[:len(text)-len(trimmed)], "\n")
+}
+
+// displayPath returns path relative to the working directory if it lies
+// inside it, with forward slashes, for use in __FILE__ and %line markers.
+func displayPath(path string) string {
+	if cwd, err := os.Getwd(); err == nil {
+		if relative, err := filepath.Rel(cwd, path); err == nil && !strings.HasPrefix(relative, "..") {
+			path = relative
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// lineMarkerMapper applies the %line markers of source files to locations
+// (pre-processor FR-12.2). It is attached to the debug context, so that the
+// diagnostics of every stage for a pre-processed file that was re-assembled
+// name the file and line it was produced from. Files are read, and their
+// markers parsed, on first use.
+type lineMarkerMapper struct {
+	markers map[string][]preProcessing.LineMarker
+	mu      sync.Mutex
+}
+
+// lineMarkerMapperNew returns a mapper for a run whose root file is
+// rootPath, with content rootSource.
+func lineMarkerMapperNew(rootPath, rootSource string) *lineMarkerMapper {
+	markers, _ := preProcessing.LineMarkers(rootSource)
+	return &lineMarkerMapper{markers: map[string][]preProcessing.LineMarker{rootPath: markers}}
+}
+
+// mapLocation returns the location stated by the last %line marker before
+// location in its file, or location itself if there is none.
+func (m *lineMarkerMapper) mapLocation(location debugcontext.Location) debugcontext.Location {
+	file, line, ok := preProcessing.ResolveLineMarker(m.markersOf(location.FilePath()), location.Line())
+	if !ok {
+		return location
+	}
+	if file == "" {
+		file = location.FilePath()
+	}
+	return debugcontext.Loc(file, line, location.Column())
+}
+
+// isMarker returns true if location is the line of a %line directive.
+func (m *lineMarkerMapper) isMarker(location debugcontext.Location) bool {
+	for _, marker := range m.markersOf(location.FilePath()) {
+		if marker.Directive == location.Line() {
+			return true
+		}
+	}
+	return false
+}
+
+// markersOf returns the %line markers of the file at path; none if it cannot
+// be read.
+func (m *lineMarkerMapper) markersOf(path string) []preProcessing.LineMarker {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	markers, ok := m.markers[path]
+	if !ok {
+		if content, err := os.ReadFile(path); err == nil {
+			markers, _ = preProcessing.LineMarkers(string(content))
+		}
+		m.markers[path] = markers
+	}
+	return markers
+}
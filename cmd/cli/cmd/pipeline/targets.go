@@ -0,0 +1,67 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/keurnel/assembler/v0/architecture"
+	"github.com/keurnel/assembler/v0/kasm"
+	"github.com/keurnel/assembler/v0/kasm/profile"
+
+	// Architecture packages register their instruction tables on import.
+	_ "github.com/keurnel/assembler/v0/architecture/arm/aarch64"
+	_ "github.com/keurnel/assembler/v0/architecture/riscv/rv64"
+	_ "github.com/keurnel/assembler/v0/architecture/x86/_64"
+)
+
+// DefaultArchitecture is the architecture used when --arch is not given.
+const DefaultArchitecture = "x86_64"
+
+// TargetByName resolves an architecture name or alias into a Target by
+// combining the architecture, profile and backend registries.
+func TargetByName(name string) (Target, error) {
+	arch, exists := architecture.Lookup(name)
+	if !exists {
+		return Target{}, fmt.Errorf("unknown architecture '%s' (available: %s)", name, strings.Join(availableNames(), ", "))
+	}
+
+	newProfile, exists := profile.Lookup(arch.Name)
+	if !exists {
+		return Target{}, fmt.Errorf("architecture '%s' has no registered lexer profile", arch.Name)
+	}
+	backend, exists := kasm.BackendFor(arch.Name)
+	if !exists {
+		return Target{}, fmt.Errorf("architecture '%s' has no registered code generation backend", arch.Name)
+	}
+
+	return Target{
+		Name:         arch.Name,
+		Aliases:      arch.Aliases,
+		Description:  arch.Description,
+		Bits:         arch.Bits,
+		Instructions: arch.Instructions,
+		Profile:      newProfile,
+		Backend:      backend,
+	}, nil
+}
+
+// Targets returns every registered architecture that has a profile and a
+// backend, sorted by name.
+func Targets() []Target {
+	targets := make([]Target, 0)
+	for _, arch := range architecture.Registered() {
+		if target, err := TargetByName(arch.Name); err == nil {
+			targets = append(targets, target)
+		}
+	}
+	return targets
+}
+
+// availableNames returns the canonical names of all usable targets.
+func availableNames() []string {
+	names := make([]string, 0)
+	for _, target := range Targets() {
+		names = append(names, target.Name)
+	}
+	return names
+}
@@ -0,0 +1,85 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/keurnel/assembler/v0/architecture"
+	"github.com/keurnel/assembler/v0/kasm"
+	"github.com/keurnel/assembler/v0/kasm/profile"
+)
+
+// ---------------------------------------------------------------------------
+// Architecture registry
+// ---------------------------------------------------------------------------
+
+// TestTargetByName_CanonicalAndAliases verifies that every built-in
+// architecture resolves by canonical name and alias, case-insensitively, to a
+// complete Target whose backend matches the canonical name.
+func TestTargetByName_CanonicalAndAliases(t *testing.T) {
+	cases := map[string]string{
+		"x86_64":  "x86_64",
+		"AMD64":   "x86_64",
+		"_64":     "x86_64",
+		"rv64":    "rv64",
+		"riscv64": "rv64",
+		"aarch64": "aarch64",
+		"arm64":   "aarch64",
+	}
+	for name, canonical := range cases {
+		target, err := TargetByName(name)
+		if err != nil {
+			t.Fatalf("TargetByName(%q): unexpected error: %v", name, err)
+		}
+		if target.Name != canonical {
+			t.Errorf("TargetByName(%q): expected %q, got %q", name, canonical, target.Name)
+		}
+		if target.Backend.Name() != canonical {
+			t.Errorf("TargetByName(%q): expected backend %q, got %q", name, canonical, target.Backend.Name())
+		}
+		if target.Profile == nil || target.Instructions == nil {
+			t.Errorf("TargetByName(%q): incomplete target", name)
+		}
+	}
+}
+
+// TestTargetByName_Unknown verifies that an unknown architecture produces an
+// error listing the available architectures.
+func TestTargetByName_Unknown(t *testing.T) {
+	_, err := TargetByName("mips")
+	if err == nil {
+		t.Fatal("expected an error for an unknown architecture")
+	}
+	for _, name := range []string{"mips", "aarch64", "rv64", "x86_64"} {
+		if !strings.Contains(err.Error(), name) {
+			t.Errorf("expected error to mention %q, got %q", name, err.Error())
+		}
+	}
+}
+
+// TestTargets_Sorted verifies that Targets lists each architecture once, in
+// name order.
+func TestTargets_Sorted(t *testing.T) {
+	targets := Targets()
+	names := make([]string, len(targets))
+	for i, target := range targets {
+		names[i] = target.Name
+	}
+	if got := strings.Join(names, ","); got != "aarch64,rv64,x86_64" {
+		t.Errorf("expected aarch64,rv64,x86_64, got %s", got)
+	}
+}
+
+// TestTargets_EveryArchitectureComplete verifies that every registered
+// architecture has a lexer profile and a backend registered under its
+// canonical name, so that none is silently dropped from Targets.
+func TestTargets_EveryArchitectureComplete(t *testing.T) {
+	for _, arch := range architecture.Registered() {
+		if _, exists := profile.Lookup(arch.Name); !exists {
+			t.Errorf("architecture '%s' has no profile registered under its canonical name", arch.Name)
+		}
+		if backend, exists := kasm.BackendFor(arch.Name); !exists || backend.Name() != arch.Name {
+			t.Errorf("architecture '%s' has no backend registered under its canonical name", arch.Name)
+		}
+	}
+}
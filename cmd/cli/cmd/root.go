@@ -3,16 +3,20 @@ package cmd
 import (
 	"os"
 
+	"github.com/keurnel/assembler/cmd/cli/cmd/pipeline"
+	"github.com/keurnel/assembler/v0/kasm"
 	"github.com/spf13/cobra"
 )
 
 var rootCmd = &cobra.Command{
-	Use:   "keurnel-asm",
-	Short: "Keurnels assembler",
-	Long:  `Keurnels assembler is a tool for assembling code.`,
+	Use:     "keurnel-asm",
+	Short:   "Keurnels assembler",
+	Long:    `Keurnels assembler is a tool for assembling code.`,
+	Version: kasm.Version,
 }
 
 func Execute() {
+	rootCmd.SetArgs(pipeline.DependencyArgs(rootCmd, os.Args[1:]))
 	err := rootCmd.Execute()
 	if err != nil {
 		os.Exit(1)
@@ -27,6 +31,12 @@ func init() {
 	})
 
 	rootCmd.AddCommand(x8664Cmd)
+	rootCmd.AddCommand(rv64Cmd)
+	rootCmd.AddCommand(aarch64Cmd)
+
+	rootCmd.AddCommand(assembleCmd)
+	rootCmd.AddCommand(depsCmd)
+	rootCmd.AddCommand(listArchitecturesCmd)
 
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
@@ -0,0 +1,22 @@
+package cmd
+
+import (
+	"github.com/keurnel/assembler/cmd/cli/cmd/rv64"
+	"github.com/spf13/cobra"
+)
+
+var rv64Cmd = &cobra.Command{
+	Use:     "rv64",
+	GroupID: "arch",
+	Short:   "RISC-V RV64 architecture",
+	Long:    `Functions related to the RISC-V RV64 (RV64I/M/A) architecture.`,
+}
+
+func init() {
+	rv64Cmd.AddGroup(&cobra.Group{
+		ID:    "file-operations",
+		Title: "File Operations",
+	})
+
+	rv64Cmd.AddCommand(rv64.AssembleFileCmd)
+}
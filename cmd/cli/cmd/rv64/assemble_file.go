@@ -0,0 +1,6 @@
+package rv64
+
+import "github.com/keurnel/assembler/cmd/cli/cmd/pipeline"
+
+// AssembleFileCmd assembles a RISC-V RV64 source file.
+var AssembleFileCmd = pipeline.NewAssembleFileCmd("rv64")
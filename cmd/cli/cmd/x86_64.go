@@ -6,10 +6,11 @@ import (
 )
 
 var x8664Cmd = &cobra.Command{
-	Use:     "_64",
+	Use:     "x86_64",
+	Aliases: []string{"_64"},
 	GroupID: "arch",
-	Short:   "_64 architecture",
-	Long:    `Functions related to the _64 architecture.`,
+	Short:   "x86_64 architecture",
+	Long:    `Functions related to the x86_64 (AMD64) architecture.`,
 }
 
 func init() {
@@ -19,4 +20,5 @@ func init() {
 	})
 
 	x8664Cmd.AddCommand(x86_64.AssembleFileCmd)
+	x8664Cmd.AddCommand(x86_64.DisassembleFileCmd)
 }
@@ -0,0 +1,70 @@
+package x86_64
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/keurnel/assembler/cmd/cli/cmd/pipeline"
+	"github.com/keurnel/assembler/v0/kasm/decoder"
+	"github.com/spf13/cobra"
+)
+
+// bytesColumnWidth is the number of instruction bytes the hex column is
+// padded to; longer instructions simply push the text column to the right.
+const bytesColumnWidth = 8
+
+// DisassembleFileCmd disassembles a flat x86_64 binary file.
+var DisassembleFileCmd = &cobra.Command{
+	Use:     "disassemble-file <binary-file>",
+	GroupID: "file-operations",
+	Short:   "Disassemble an x86_64 binary file.",
+	Long: `Disassemble a flat x86_64 binary file and print one instruction per line
+with its address, raw bytes and kasm syntax. Undecodable bytes are printed as
+'db' and reported on stderr.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if err := runDisassembleFile(cmd, args[0]); err != nil {
+			cmd.PrintErrln("Error:", err)
+		}
+	},
+}
+
+func init() {
+	DisassembleFileCmd.Flags().Uint64("origin", 0, "Address of the first byte of the file")
+}
+
+// runDisassembleFile decodes the file and writes the listing to stdout.
+func runDisassembleFile(cmd *cobra.Command, path string) error {
+	code, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("unable to read file '%s': %w", path, err)
+	}
+	origin, _ := cmd.Flags().GetUint64("origin")
+
+	target, err := pipeline.TargetByName("x86_64")
+	if err != nil {
+		return err
+	}
+
+	instructions, decodeErrors := decoder.DecoderNew(target.Instructions()).Decode(code, origin)
+
+	out := cmd.OutOrStdout()
+	for _, instr := range instructions {
+		fmt.Fprintf(out, "%08x  %-*s  %s\n", instr.Address, bytesColumnWidth*3-1, formatBytes(instr.Bytes), instr)
+	}
+	for _, e := range decodeErrors {
+		cmd.PrintErrln(e.String())
+	}
+
+	return nil
+}
+
+// formatBytes renders bytes as space-separated lower-case hex pairs.
+func formatBytes(b []byte) string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = fmt.Sprintf("%02x", v)
+	}
+	return strings.Join(parts, " ")
+}
@@ -1,6 +1,9 @@
 package debugcontext
 
-import "sync"
+import (
+	"slices"
+	"sync"
+)
 
 // DebugContext is a passive, append-only data structure that accumulates
 // diagnostic entries as the assembler pipeline progresses. It is thread-safe
@@ -18,6 +21,7 @@ type DebugContext struct {
 	filePath string   // Primary source file path (FR-1.2).
 	phase    string   // Current pipeline phase (FR-2).
 	entries  []*Entry // Recorded entries in insertion order (FR-8.2).
+	mapper   func(Location) Location
 	mu       sync.Mutex
 }
 
@@ -62,6 +66,17 @@ func (c *DebugContext) LocIn(filePath string, line, column int) Location {
 	return Loc(filePath, line, column)
 }
 
+// WithLocationMapper sets a function that every recorded location is passed
+// through, and returns the context for chaining (FR-4.4). The caller uses it
+// for remapping that applies to every stage, such as %line markers; the
+// mapper must return its argument for locations it does not remap.
+func (c *DebugContext) WithLocationMapper(mapper func(Location) Location) *DebugContext {
+	c.mu.Lock()
+	c.mapper = mapper
+	c.mu.Unlock()
+	return c
+}
+
 // --- Recording methods (FR-3.3) ---
 
 // record is the internal method that creates an entry and appends it to
@@ -70,6 +85,10 @@ func (c *DebugContext) record(severity string, location Location, message string
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.mapper != nil {
+		location = c.mapper(location)
+	}
+
 	entry := &Entry{
 		severity: severity,
 		phase:    c.phase,
@@ -80,6 +99,13 @@ func (c *DebugContext) record(severity string, location Location, message string
 	return entry
 }
 
+// Fatal records an entry with severity "fatal" and returns the *Entry for
+// optional chaining. A fatal entry is an error after which the pipeline
+// stops immediately, without running the rest of the current stage.
+func (c *DebugContext) Fatal(location Location, message string) *Entry {
+	return c.record(SeverityFatal, location, message)
+}
+
 // Error records an entry with severity "error" and returns the *Entry
 // for optional chaining (WithSnippet, WithHint).
 func (c *DebugContext) Error(location Location, message string) *Entry {
@@ -116,9 +142,9 @@ func (c *DebugContext) Entries() []*Entry {
 	return result
 }
 
-// Errors returns only entries with severity "error".
+// Errors returns only entries with severity "error" or "fatal".
 func (c *DebugContext) Errors() []*Entry {
-	return c.filter(SeverityError)
+	return c.filter(SeverityError, SeverityFatal)
 }
 
 // Warnings returns only entries with severity "warning".
@@ -126,18 +152,16 @@ func (c *DebugContext) Warnings() []*Entry {
 	return c.filter(SeverityWarning)
 }
 
-// HasErrors returns true if at least one "error" entry exists. This is the
-// primary check used to decide whether the pipeline should abort.
+// HasErrors returns true if at least one "error" or "fatal" entry exists.
+// This is the primary check used to decide whether the pipeline should abort.
 func (c *DebugContext) HasErrors() bool {
-	c.mu.Lock()
-	defer c.mu.Unlock()
+	return c.has(SeverityError, SeverityFatal)
+}
 
-	for _, e := range c.entries {
-		if e.severity == SeverityError {
-			return true
-		}
-	}
-	return false
+// HasFatal returns true if at least one "fatal" entry exists. It is checked
+// between the steps of a stage to stop the pipeline immediately.
+func (c *DebugContext) HasFatal() bool {
+	return c.has(SeverityFatal)
 }
 
 // Count returns the total number of entries.
@@ -152,16 +176,29 @@ func (c *DebugContext) FilePath() string {
 	return c.filePath
 }
 
-// filter returns all entries matching the given severity.
-func (c *DebugContext) filter(severity string) []*Entry {
+// filter returns all entries matching one of the given severities.
+func (c *DebugContext) filter(severities ...string) []*Entry {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
 	var result []*Entry
 	for _, e := range c.entries {
-		if e.severity == severity {
+		if slices.Contains(severities, e.severity) {
 			result = append(result, e)
 		}
 	}
 	return result
 }
+
+// has returns true if at least one entry matches one of the given severities.
+func (c *DebugContext) has(severities ...string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, e := range c.entries {
+		if slices.Contains(severities, e.severity) {
+			return true
+		}
+	}
+	return false
+}
@@ -134,6 +134,15 @@ func TestDebugContext_Recording(t *testing.T) {
 		}
 	})
 
+	t.Run("Fatal records entry with severity fatal", func(t *testing.T) {
+		ctx := NewDebugContext("main.kasm")
+		entry := ctx.Fatal(ctx.Loc(3, 0), "unsupported configuration")
+
+		if entry.Severity() != SeverityFatal {
+			t.Errorf("Expected severity '%s', got '%s'", SeverityFatal, entry.Severity())
+		}
+	})
+
 	t.Run("Info records entry with severity info", func(t *testing.T) {
 		ctx := NewDebugContext("main.kasm")
 		entry := ctx.Info(ctx.Loc(1, 0), "macro expanded")
@@ -247,6 +256,27 @@ func TestDebugContext_Querying(t *testing.T) {
 		}
 	})
 
+	// ==============================================================
+	// FR-5.6: Fatal entries count as errors; HasFatal() reports them.
+	// ==============================================================
+	t.Run("fatal entries are errors", func(t *testing.T) {
+		fatal := NewDebugContext("fatal.kasm")
+		fatal.Warning(fatal.Loc(1, 0), "warning")
+
+		if fatal.HasFatal() {
+			t.Error("Expected HasFatal() to return false without fatal entries")
+		}
+
+		fatal.Fatal(fatal.Loc(2, 0), "stop")
+
+		if !fatal.HasErrors() || !fatal.HasFatal() {
+			t.Error("Expected HasErrors() and HasFatal() to return true")
+		}
+		if errors := fatal.Errors(); len(errors) != 1 || errors[0].Message() != "stop" {
+			t.Errorf("Expected Errors() to include the fatal entry, got %v", errors)
+		}
+	})
+
 	// ==============================================================
 	// FR-5.5: Count() returns total number of entries.
 	// ==============================================================
@@ -338,3 +368,26 @@ func TestDebugContext_IncludedFileLocation(t *testing.T) {
 		t.Errorf("Unexpected String(): %s", entry.String())
 	}
 }
+
+func TestDebugContext_LocationMapper(t *testing.T) {
+	// ==============================================================
+	// FR-4.4: Recorded locations are passed through the mapper.
+	// ==============================================================
+	ctx := NewDebugContext("main.pre.kasm").WithLocationMapper(func(loc Location) Location {
+		if loc.Line() < 3 {
+			return loc
+		}
+		return Loc("main.kasm", loc.Line()+10, loc.Column())
+	})
+
+	ctx.Error(ctx.Loc(1, 0), "before the marker")
+	ctx.Error(ctx.Loc(4, 2), "after the marker")
+
+	entries := ctx.Entries()
+	if got := entries[0].Location().String(); got != "main.pre.kasm:1" {
+		t.Errorf("Expected unmapped location 'main.pre.kasm:1', got '%s'", got)
+	}
+	if got := entries[1].Location().String(); got != "main.kasm:14:2" {
+		t.Errorf("Expected mapped location 'main.kasm:14:2', got '%s'", got)
+	}
+}
@@ -4,6 +4,7 @@ import "fmt"
 
 // Severity constants for entry classification.
 const (
+	SeverityFatal   = "fatal"
 	SeverityError   = "error"
 	SeverityWarning = "warning"
 	SeverityInfo    = "info"
@@ -20,7 +21,7 @@ const (
 //
 // See FR-6 in .requirements/assembler/debug-information.md.
 type Entry struct {
-	severity string   // "error" | "warning" | "info" | "trace"
+	severity string   // "fatal" | "error" | "warning" | "info" | "trace"
 	phase    string   // Pipeline phase at recording time.
 	message  string   // Human-readable description.
 	location Location // Source position the entry refers to.
@@ -13,7 +13,7 @@ const (
 )
 
 type LineChange struct {
-	_type      string // "expanding", "contracting", or "unchanged" — set by factory, never by callers.
+	_type      string // "expanding", "contracting", "unchanged", or "rewritten" — set by factory, never by callers.
 	origin     int    // 0-based line index in the previous (old) version.
 	newIndex   int    // 0-based line index in the new version. -1 for contracting (line no longer exists).
 	content    string // The actual text of the line.
@@ -22,7 +22,7 @@ type LineChange struct {
 
 // --- Accessor methods (FR-5.5) ---
 
-// Type returns the change type: "unchanged", "expanding", or "contracting".
+// Type returns the change type: "unchanged", "expanding", "contracting", or "rewritten".
 func (lc LineChange) Type() string { return lc._type }
 
 // Origin returns the 0-based line index in the previous (old) version.
@@ -64,6 +64,18 @@ func newExpandingChange(origin, newIndex int, content string) LineChange {
 	}
 }
 
+// newRewrittenChange creates a LineChange that records a line rewritten in
+// place. Captures the line it was rewritten from (origin), its position in the
+// new version, and the new line content.
+func newRewrittenChange(origin, newIndex int, content string) LineChange {
+	return LineChange{
+		_type:    "rewritten",
+		origin:   origin,
+		newIndex: newIndex,
+		content:  content,
+	}
+}
+
 // newContractingChange creates a LineChange that records a removed line.
 // Captures the position in the old version and the removed line content.
 // newIndex is -1 because the line no longer exists in the new version.
@@ -144,7 +156,7 @@ func (h *History) LineOrigin(lineNumber int) int {
 			return -1
 		}
 
-		// unchanged — trace through to the original position.
+		// unchanged or rewritten — trace through to the original position.
 		current = change.Origin()
 	}
 
@@ -179,7 +191,7 @@ func (h *History) snapshotNoChange(instance *Instance) {
 }
 
 // snapshotChange - records a diff between this update and the previous
-// snapshot. Called only from Update(). The changes map is keyed by new-version
+// snapshot. Called only from Update() and Rewrite(). The changes map is keyed by new-version
 // line index (unchanged + expanding). The removals slice contains contracting
 // entries. No _type parameter — the type is determined by which method is called.
 func (h *History) snapshotChange(instance *Instance, changes map[int]LineChange, removals []LineChange) {
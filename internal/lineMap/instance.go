@@ -59,6 +59,88 @@ func (i *Instance) Update(newValue string) {
 	i.history.snapshotChange(i, changes, removals)
 }
 
+// Rewrite - updates the value of `Instance.value` after a line-preserving
+// transformation: line i of the new value was produced from line i of the
+// latest snapshot. Lines whose content changed are recorded as rewritten, so
+// that they keep their origin (FR-4.6). If the number of lines differs, the
+// transformation was not line-preserving and Rewrite falls back to Update.
+func (i *Instance) Rewrite(newValue string) {
+	latestSnapshot := i.history.latest()
+
+	if latestSnapshot.SourceCompare(newValue) {
+		i.history.snapshotNoChange(i)
+		return
+	}
+
+	newLines := strings.Split(newValue, "\n")
+	if len(newLines) != len(latestSnapshot.lines) {
+		i.Update(newValue)
+		return
+	}
+
+	changes := make(map[int]LineChange)
+	for index, line := range newLines {
+		if line != latestSnapshot.lines[index] {
+			changes[index] = newRewrittenChange(index, index, line)
+		}
+	}
+
+	i.value = strings.Clone(newValue)
+
+	i.history.snapshotChange(i, changes, nil)
+}
+
+// Remap - updates the value of `Instance.value` after a transformation that
+// reports where each new line came from: origins[n] is the index of the line
+// in the latest snapshot that line n of the new value was produced from, or -1
+// if the line was inserted. A line may be produced from the same origin more
+// than once (e.g. a repeated block), and lines that are never referenced are
+// recorded as removed (FR-4.7). If origins does not have one entry per line,
+// Remap falls back to Update.
+func (i *Instance) Remap(newValue string, origins []int) {
+	latestSnapshot := i.history.latest()
+
+	if latestSnapshot.SourceCompare(newValue) {
+		i.history.snapshotNoChange(i)
+		return
+	}
+
+	newLines := strings.Split(newValue, "\n")
+	if len(newLines) != len(origins) {
+		i.Update(newValue)
+		return
+	}
+
+	changes := make(map[int]LineChange)
+	referenced := make([]bool, len(latestSnapshot.lines))
+	for index, line := range newLines {
+		origin := origins[index]
+		if origin < 0 || origin >= len(latestSnapshot.lines) {
+			changes[index] = newExpandingChange(0, index, line)
+			continue
+		}
+
+		referenced[origin] = true
+		switch {
+		case line != latestSnapshot.lines[origin]:
+			changes[index] = newRewrittenChange(origin, index, line)
+		case origin != index:
+			changes[index] = newUnchangedChange(origin, index, line)
+		}
+	}
+
+	var removals []LineChange
+	for index, ok := range referenced {
+		if !ok {
+			removals = append(removals, newContractingChange(index, latestSnapshot.lines[index]))
+		}
+	}
+
+	i.value = strings.Clone(newValue)
+
+	i.history.snapshotChange(i, changes, removals)
+}
+
 // changes - computes line-level changes between the current latest snapshot and a new value.
 // It uses a longest common subsequence (LCS) approach to identify which lines are unchanged,
 // which were expanded (added/replaced), and which were contracted (removed).
@@ -264,7 +346,7 @@ func (i *Instance) LineHistory(lineNumber int) []LineChange {
 			break
 		}
 
-		// unchanged — continue tracing with the origin index.
+		// unchanged or rewritten — continue tracing with the origin index.
 		currentLine = change.Origin()
 	}
 
@@ -304,6 +304,118 @@ func TestInstance_Update(t *testing.T) {
 	})
 }
 
+func TestInstance_Rewrite(t *testing.T) {
+	// ==============================================================
+	//
+	// FR-4.6: Rewritten lines keep their origin.
+	//
+	// ==============================================================
+	t.Run("rewritten lines keep their origin", func(t *testing.T) {
+		instance := newTestInstance(t, "header\nline1\nSIZE\nline3")
+
+		// Shift every line down by one, then rewrite one in place.
+		instance.Update("inserted\nheader\nline1\nSIZE\nline3")
+		instance.Rewrite("inserted\nheader\nline1\n8\nline3")
+
+		if origin := instance.LineOrigin(3); origin != 2 {
+			t.Errorf("Expected rewritten line to trace to origin 2, got %d", origin)
+		}
+		if origin := instance.LineOrigin(4); origin != 3 {
+			t.Errorf("Expected untouched line to trace to origin 3, got %d", origin)
+		}
+
+		history := instance.LineHistory(3)
+		if len(history) != 2 {
+			t.Fatalf("Expected 2 history entries, got %d", len(history))
+		}
+		if history[1].Type() != "rewritten" || history[1].Content() != "8" {
+			t.Errorf("Expected newest entry to be rewritten to '8', got %v", history[1])
+		}
+	})
+
+	// ==============================================================
+	//
+	// FR-4.6: A rewrite that changes the line count falls back to Update.
+	//
+	// ==============================================================
+	t.Run("different line count falls back to diff", func(t *testing.T) {
+		instance := newTestInstance(t, "line1\nline2")
+
+		instance.Rewrite("line1\nnew_line\nline2")
+
+		if origin := instance.LineOrigin(1); origin != -1 {
+			t.Errorf("Expected inserted line to have origin -1, got %d", origin)
+		}
+		if origin := instance.LineOrigin(2); origin != 1 {
+			t.Errorf("Expected line2 to trace to origin 1, got %d", origin)
+		}
+	})
+
+	t.Run("identical value creates NoChange snapshot", func(t *testing.T) {
+		instance := newTestInstance(t, "line1")
+
+		instance.Rewrite("line1")
+
+		if instance.history.latest()._type != LineSnapshotTypeNoChange {
+			t.Errorf("Expected a no-change snapshot, got %s", instance.history.latest()._type)
+		}
+	})
+}
+
+func TestInstance_Remap(t *testing.T) {
+	// ==============================================================
+	//
+	// FR-4.7: Lines produced from the same origin all trace back to it.
+	//
+	// ==============================================================
+	t.Run("repeated lines keep their origin", func(t *testing.T) {
+		instance := newTestInstance(t, "header\n%rep 2\ndb i\n%endrep\nfooter")
+
+		instance.Remap("header\ndb 0\ndb 1\nfooter", []int{0, 2, 2, 4})
+
+		expected := []int{0, 2, 2, 4}
+		for line, origin := range expected {
+			if got := instance.LineOrigin(line); got != origin {
+				t.Errorf("Expected line %d to trace to origin %d, got %d", line, origin, got)
+			}
+		}
+
+		snapshot := instance.history.latest()
+		if len(snapshot.removals) != 2 {
+			t.Errorf("Expected the 2 unreferenced lines to be removals, got %v", snapshot.removals)
+		}
+		if change := (*snapshot.changes)[1]; change.Type() != "rewritten" {
+			t.Errorf("Expected a changed line to be rewritten, got %v", change)
+		}
+		if change := (*snapshot.changes)[3]; change.Type() != "unchanged" || change.Origin() != 4 {
+			t.Errorf("Expected a moved line to be unchanged from origin 4, got %v", change)
+		}
+	})
+
+	t.Run("negative origin marks an inserted line", func(t *testing.T) {
+		instance := newTestInstance(t, "line1\nline2")
+
+		instance.Remap("line1\ninserted\nline2", []int{0, -1, 1})
+
+		if origin := instance.LineOrigin(1); origin != -1 {
+			t.Errorf("Expected inserted line to have origin -1, got %d", origin)
+		}
+		if origin := instance.LineOrigin(2); origin != 1 {
+			t.Errorf("Expected line2 to trace to origin 1, got %d", origin)
+		}
+	})
+
+	t.Run("mismatched origins fall back to diff", func(t *testing.T) {
+		instance := newTestInstance(t, "line1\nline2")
+
+		instance.Remap("line1\nnew_line\nline2", []int{0})
+
+		if origin := instance.LineOrigin(2); origin != 1 {
+			t.Errorf("Expected line2 to trace to origin 1, got %d", origin)
+		}
+	})
+}
+
 func TestInstance_LineHistory(t *testing.T) {
 	// ==============================================================
 	//
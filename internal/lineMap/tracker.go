@@ -48,6 +48,22 @@ func (t *Tracker) Snapshot(source string) {
 	t.instance.Update(source)
 }
 
+// SnapshotRewrite records a new version of the source after a line-preserving
+// pre-processing step, in which each line was rewritten in place. Rewritten
+// lines keep their origin. It delegates to Instance.Rewrite.
+func (t *Tracker) SnapshotRewrite(source string) {
+	t.instance.Rewrite(source)
+}
+
+// SnapshotMapped records a new version of the source after a pre-processing
+// step that knows which line each output line was produced from, such as
+// unrolling a repeated block. origins holds, per line of source, the index of
+// the line it was produced from in the previous version, or -1 for inserted
+// lines. It delegates to Instance.Remap.
+func (t *Tracker) SnapshotMapped(source string, origins []int) {
+	t.instance.Remap(source, origins)
+}
+
 // SnapshotWithInclusions records a new version of the source after handling
 // %include directives. After snapshotting, it annotates expanding entries in
 // the latest snapshot's changes map with the sourceFile they belong to, derived
@@ -102,6 +102,54 @@ func TestTracker_Snapshot(t *testing.T) {
 	})
 }
 
+func TestTracker_SnapshotRewrite(t *testing.T) {
+	// ==============================================================
+	//
+	// FR-11.2.4: SnapshotRewrite keeps the origin of rewritten lines.
+	//
+	// ==============================================================
+	t.Run("rewritten lines trace to their origin", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "test.kasm")
+		if err := os.WriteFile(path, []byte("%define SIZE 8\nmov rax, SIZE"), 0644); err != nil {
+			t.Fatalf("Failed to create temp file: %v", err)
+		}
+
+		tracker, _ := Track(path)
+
+		tracker.SnapshotRewrite("\nmov rax, 8")
+
+		if origin := tracker.Origin(1); origin != 1 {
+			t.Errorf("Expected origin 1, got %d", origin)
+		}
+	})
+}
+
+func TestTracker_SnapshotMapped(t *testing.T) {
+	// ==============================================================
+	//
+	// FR-11.2.5: SnapshotMapped traces lines to the origins it was given.
+	//
+	// ==============================================================
+	t.Run("mapped lines trace to their origin", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "test.kasm")
+		if err := os.WriteFile(path, []byte("%rep 2\nnop\n%endrep\nret"), 0644); err != nil {
+			t.Fatalf("Failed to create temp file: %v", err)
+		}
+
+		tracker, _ := Track(path)
+
+		tracker.SnapshotMapped("nop\nnop\nret", []int{1, 1, 3})
+
+		for line, origin := range []int{1, 1, 3} {
+			if got := tracker.Origin(line); got != origin {
+				t.Errorf("Expected line %d to trace to origin %d, got %d", line, origin, got)
+			}
+		}
+	})
+}
+
 func TestTracker_SnapshotWithInclusions(t *testing.T) {
 	// ==============================================================
 	//
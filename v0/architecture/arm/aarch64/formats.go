@@ -0,0 +1,73 @@
+package aarch64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+// Instruction formats. The encoding name selects how the code generator places
+// operands into the instruction word. Unless noted otherwise, the base word is
+// the 32-bit form (sf = 0); the code generator sets bit 31 when the operands
+// are 64-bit X registers.
+const (
+	// FormatImm12 - rd|sp, rn|sp, imm12 (add/sub immediate); rn|sp, imm12 for
+	// compare aliases whose rd is fixed to xzr in the base word.
+	FormatImm12 = "I12"
+	// FormatReg3 - rd, rn, rm (shifted register and two-source forms); rn, rm
+	// for compare/test aliases whose rd is fixed to xzr in the base word.
+	FormatReg3 = "R3"
+	// FormatReg2 - rd, rm with rn fixed to xzr in the base word (neg, mvn).
+	FormatReg2 = "R2"
+	// FormatReg4 - rd, rn, rm, ra (multiply-accumulate).
+	FormatReg4 = "R4"
+	// FormatLogicalImm - rd|sp, rn, bitmask immediate; rn, bitmask for tst.
+	FormatLogicalImm = "LI"
+	// FormatMoveWide - rd, imm16 [, shift] where shift is 0, 16, 32 or 48.
+	FormatMoveWide = "MW"
+	// FormatMove - rd, rm (orr, or add #0 when sp is involved) and rd, imm
+	// (movz, movn or orr with a bitmask immediate).
+	FormatMove = "MOV"
+	// FormatShiftLeft - rd, rn, shift (lsl alias of ubfm).
+	FormatShiftLeft = "SL"
+	// FormatShiftRight - rd, rn, shift (lsr/asr aliases of ubfm/sbfm).
+	FormatShiftRight = "SR"
+	// FormatLoadStore - rt, [rn|sp ...] with the access size taken from rt
+	// (ldr/str). The base word is the 32-bit unsigned-offset form.
+	FormatLoadStore = "LS"
+	// FormatLoadStoreFixed - rt, [rn|sp ...] with a fixed access size held
+	// in the base word (ldrb, strh, ldrsw, ...).
+	FormatLoadStoreFixed = "LSF"
+	// FormatLoadLiteral - rt, label or rt, =value (PC-relative, ±1 MiB).
+	FormatLoadLiteral = "LL"
+	// FormatPair - rt1, rt2, [rn|sp ...] (ldp/stp). The base word is the
+	// 32-bit signed-offset form.
+	FormatPair = "LP"
+	// FormatBranch - label (±128 MiB).
+	FormatBranch = "B26"
+	// FormatCondBranch - label (±1 MiB); the condition is in the base word.
+	FormatCondBranch = "B19"
+	// FormatCompareBranch - rt, label (±1 MiB).
+	FormatCompareBranch = "CB"
+	// FormatBranchReg - rn.
+	FormatBranchReg = "BR"
+	// FormatAdr - rd, label (±1 MiB).
+	FormatAdr = "ADR"
+	// FormatAdrp - rd, label (4 KiB page, ±4 GiB).
+	FormatAdrp = "ADRP"
+	// FormatException - imm16.
+	FormatException = "EXC"
+	// FormatNone - the base word is the complete instruction.
+	FormatNone = "N"
+)
+
+// variant - creates a 4-byte instruction variant whose base word already holds the opcode fields. Opcode carries
+// the top byte of the word, which identifies the instruction class.
+func variant(encoding string, base uint32, operands ...string) architecture.InstructionVariant {
+	if operands == nil {
+		operands = []string{}
+	}
+	return architecture.InstructionVariant{
+		Encoding: encoding,
+		Operands: operands,
+		Opcode:   uint8(base >> 24),
+		Size:     4,
+		Base:     base,
+	}
+}
@@ -0,0 +1,19 @@
+package aarch64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+var providers = []architecture.InstructionProvider{
+	dataProcessingProvider{},
+	loadStoreProvider{},
+	branchProvider{},
+	systemProvider{},
+}
+
+// Instructions - returns all AArch64 instructions across all providers.
+func Instructions() map[string][]architecture.Instruction {
+	var result = make(map[string][]architecture.Instruction)
+	for _, p := range providers {
+		result[p.Group()] = p.Provide()
+	}
+	return result
+}
@@ -0,0 +1,72 @@
+package aarch64
+
+import (
+	"strings"
+
+	"github.com/keurnel/assembler/v0/architecture"
+)
+
+// conditions maps the condition-code suffixes accepted by B.cond to their
+// 4-bit encodings.
+var conditions = []struct {
+	suffix string
+	code   uint32
+}{
+	{"EQ", 0x0}, {"NE", 0x1}, {"CS", 0x2}, {"HS", 0x2}, {"CC", 0x3}, {"LO", 0x3},
+	{"MI", 0x4}, {"PL", 0x5}, {"VS", 0x6}, {"VC", 0x7}, {"HI", 0x8}, {"LS", 0x9},
+	{"GE", 0xA}, {"LT", 0xB}, {"GT", 0xC}, {"LE", 0xD}, {"AL", 0xE},
+}
+
+type branchProvider struct {
+	architecture.InstructionProvider
+}
+
+func (p branchProvider) Group() string {
+	return "Branch"
+}
+
+func (p branchProvider) Provide() []architecture.Instruction {
+	instructions := []architecture.Instruction{
+		// Unconditional branches (±128 MiB).
+		{Mnemonic: "B", Description: "Branch", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatBranch, 0x14000000, "relative"),
+		}},
+		{Mnemonic: "BL", Description: "Branch with link", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatBranch, 0x94000000, "relative"),
+		}},
+
+		// Compare and branch (±1 MiB).
+		{Mnemonic: "CBZ", Description: "Compare and branch if zero", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatCompareBranch, 0x34000000, "register", "relative"),
+		}},
+		{Mnemonic: "CBNZ", Description: "Compare and branch if not zero", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatCompareBranch, 0x35000000, "register", "relative"),
+		}},
+
+		// Branches to register.
+		{Mnemonic: "BR", Description: "Branch to register", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatBranchReg, 0xD61F0000, "register"),
+		}},
+		{Mnemonic: "BLR", Description: "Branch with link to register", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatBranchReg, 0xD63F0000, "register"),
+		}},
+		{Mnemonic: "RET", Description: "Return from subroutine (defaults to x30)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatNone, 0xD65F03C0),
+			variant(FormatBranchReg, 0xD65F0000, "register"),
+		}},
+	}
+
+	// Conditional branches (±1 MiB), one mnemonic per condition code.
+	for _, c := range conditions {
+		instructions = append(instructions, architecture.Instruction{
+			Mnemonic:    "B." + c.suffix,
+			Description: "Branch if " + strings.ToLower(c.suffix),
+			Flags:       []string{},
+			Variants: []architecture.InstructionVariant{
+				variant(FormatCondBranch, 0x54000000|c.code, "relative"),
+			},
+		})
+	}
+
+	return instructions
+}
@@ -0,0 +1,132 @@
+package aarch64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+type dataProcessingProvider struct {
+	architecture.InstructionProvider
+}
+
+func (p dataProcessingProvider) Group() string {
+	return "Data Processing"
+}
+
+func (p dataProcessingProvider) Provide() []architecture.Instruction {
+	return []architecture.Instruction{
+		// Add and subtract.
+		{Mnemonic: "ADD", Description: "Add", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatImm12, 0x11000000, "register", "register", "immediate"),
+			variant(FormatReg3, 0x0B000000, "register", "register", "register"),
+		}},
+		{Mnemonic: "ADDS", Description: "Add, setting flags", Flags: []string{"N", "Z", "C", "V"}, Variants: []architecture.InstructionVariant{
+			variant(FormatImm12, 0x31000000, "register", "register", "immediate"),
+			variant(FormatReg3, 0x2B000000, "register", "register", "register"),
+		}},
+		{Mnemonic: "SUB", Description: "Subtract", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatImm12, 0x51000000, "register", "register", "immediate"),
+			variant(FormatReg3, 0x4B000000, "register", "register", "register"),
+		}},
+		{Mnemonic: "SUBS", Description: "Subtract, setting flags", Flags: []string{"N", "Z", "C", "V"}, Variants: []architecture.InstructionVariant{
+			variant(FormatImm12, 0x71000000, "register", "register", "immediate"),
+			variant(FormatReg3, 0x6B000000, "register", "register", "register"),
+		}},
+		{Mnemonic: "CMP", Description: "Compare (subs xzr, rn, op2)", Flags: []string{"N", "Z", "C", "V"}, Variants: []architecture.InstructionVariant{
+			variant(FormatImm12, 0x7100001F, "register", "immediate"),
+			variant(FormatReg3, 0x6B00001F, "register", "register"),
+		}},
+		{Mnemonic: "CMN", Description: "Compare negative (adds xzr, rn, op2)", Flags: []string{"N", "Z", "C", "V"}, Variants: []architecture.InstructionVariant{
+			variant(FormatImm12, 0x3100001F, "register", "immediate"),
+			variant(FormatReg3, 0x2B00001F, "register", "register"),
+		}},
+		{Mnemonic: "NEG", Description: "Negate (sub rd, xzr, rm)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg2, 0x4B0003E0, "register", "register"),
+		}},
+
+		// Logical.
+		{Mnemonic: "AND", Description: "Bitwise AND", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg3, 0x0A000000, "register", "register", "register"),
+			variant(FormatLogicalImm, 0x12000000, "register", "register", "immediate"),
+		}},
+		{Mnemonic: "ANDS", Description: "Bitwise AND, setting flags", Flags: []string{"N", "Z", "C", "V"}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg3, 0x6A000000, "register", "register", "register"),
+			variant(FormatLogicalImm, 0x72000000, "register", "register", "immediate"),
+		}},
+		{Mnemonic: "ORR", Description: "Bitwise inclusive OR", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg3, 0x2A000000, "register", "register", "register"),
+			variant(FormatLogicalImm, 0x32000000, "register", "register", "immediate"),
+		}},
+		{Mnemonic: "EOR", Description: "Bitwise exclusive OR", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg3, 0x4A000000, "register", "register", "register"),
+			variant(FormatLogicalImm, 0x52000000, "register", "register", "immediate"),
+		}},
+		{Mnemonic: "BIC", Description: "Bitwise bit clear", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg3, 0x0A200000, "register", "register", "register"),
+		}},
+		{Mnemonic: "ORN", Description: "Bitwise OR NOT", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg3, 0x2A200000, "register", "register", "register"),
+		}},
+		{Mnemonic: "TST", Description: "Test bits (ands xzr, rn, op2)", Flags: []string{"N", "Z", "C", "V"}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg3, 0x6A00001F, "register", "register"),
+			variant(FormatLogicalImm, 0x7200001F, "register", "immediate"),
+		}},
+		{Mnemonic: "MVN", Description: "Bitwise NOT (orn rd, xzr, rm)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg2, 0x2A2003E0, "register", "register"),
+		}},
+
+		// Move.
+		{Mnemonic: "MOV", Description: "Move register or immediate", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatMove, 0x2A0003E0, "register", "register"),
+			variant(FormatMove, 0x52800000, "register", "immediate"),
+		}},
+		{Mnemonic: "MOVZ", Description: "Move wide with zero", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatMoveWide, 0x52800000, "register", "immediate"),
+			variant(FormatMoveWide, 0x52800000, "register", "immediate", "immediate"),
+		}},
+		{Mnemonic: "MOVN", Description: "Move wide with NOT", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatMoveWide, 0x12800000, "register", "immediate"),
+			variant(FormatMoveWide, 0x12800000, "register", "immediate", "immediate"),
+		}},
+		{Mnemonic: "MOVK", Description: "Move wide with keep", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatMoveWide, 0x72800000, "register", "immediate"),
+			variant(FormatMoveWide, 0x72800000, "register", "immediate", "immediate"),
+		}},
+
+		// Shifts.
+		{Mnemonic: "LSL", Description: "Logical shift left", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatShiftLeft, 0x53000000, "register", "register", "immediate"),
+			variant(FormatReg3, 0x1AC02000, "register", "register", "register"),
+		}},
+		{Mnemonic: "LSR", Description: "Logical shift right", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatShiftRight, 0x53000000, "register", "register", "immediate"),
+			variant(FormatReg3, 0x1AC02400, "register", "register", "register"),
+		}},
+		{Mnemonic: "ASR", Description: "Arithmetic shift right", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatShiftRight, 0x13000000, "register", "register", "immediate"),
+			variant(FormatReg3, 0x1AC02800, "register", "register", "register"),
+		}},
+
+		// Multiply and divide.
+		{Mnemonic: "MUL", Description: "Multiply (madd rd, rn, rm, xzr)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg3, 0x1B007C00, "register", "register", "register"),
+		}},
+		{Mnemonic: "MADD", Description: "Multiply-add", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg4, 0x1B000000, "register", "register", "register", "register"),
+		}},
+		{Mnemonic: "MSUB", Description: "Multiply-subtract", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg4, 0x1B008000, "register", "register", "register", "register"),
+		}},
+		{Mnemonic: "UDIV", Description: "Unsigned divide", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg3, 0x1AC00800, "register", "register", "register"),
+		}},
+		{Mnemonic: "SDIV", Description: "Signed divide", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatReg3, 0x1AC00C00, "register", "register", "register"),
+		}},
+
+		// PC-relative addressing.
+		{Mnemonic: "ADR", Description: "Form PC-relative address", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatAdr, 0x10000000, "register", "relative"),
+		}},
+		{Mnemonic: "ADRP", Description: "Form PC-relative address to 4 KiB page", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatAdrp, 0x90000000, "register", "relative"),
+		}},
+	}
+}
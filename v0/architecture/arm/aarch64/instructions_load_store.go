@@ -0,0 +1,58 @@
+package aarch64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+type loadStoreProvider struct {
+	architecture.InstructionProvider
+}
+
+func (p loadStoreProvider) Group() string {
+	return "Load and Store"
+}
+
+func (p loadStoreProvider) Provide() []architecture.Instruction {
+	return []architecture.Instruction{
+		// Register loads and stores: [rn, imm] and [rn, imm]! share the
+		// memory variant; [rn], imm is the post-index variant.
+		{Mnemonic: "LDR", Description: "Load register", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatLoadStore, 0xB9400000, "register", "memory"),
+			variant(FormatLoadStore, 0xB9400000, "register", "memory", "immediate"),
+			variant(FormatLoadLiteral, 0x18000000, "register", "relative"),
+			variant(FormatLoadLiteral, 0x18000000, "register", "literal"),
+		}},
+		{Mnemonic: "STR", Description: "Store register", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatLoadStore, 0xB9000000, "register", "memory"),
+			variant(FormatLoadStore, 0xB9000000, "register", "memory", "immediate"),
+		}},
+		{Mnemonic: "LDRB", Description: "Load register byte", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatLoadStoreFixed, 0x39400000, "register", "memory"),
+			variant(FormatLoadStoreFixed, 0x39400000, "register", "memory", "immediate"),
+		}},
+		{Mnemonic: "STRB", Description: "Store register byte", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatLoadStoreFixed, 0x39000000, "register", "memory"),
+			variant(FormatLoadStoreFixed, 0x39000000, "register", "memory", "immediate"),
+		}},
+		{Mnemonic: "LDRH", Description: "Load register halfword", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatLoadStoreFixed, 0x79400000, "register", "memory"),
+			variant(FormatLoadStoreFixed, 0x79400000, "register", "memory", "immediate"),
+		}},
+		{Mnemonic: "STRH", Description: "Store register halfword", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatLoadStoreFixed, 0x79000000, "register", "memory"),
+			variant(FormatLoadStoreFixed, 0x79000000, "register", "memory", "immediate"),
+		}},
+		{Mnemonic: "LDRSW", Description: "Load register signed word", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatLoadStoreFixed, 0xB9800000, "register", "memory"),
+			variant(FormatLoadStoreFixed, 0xB9800000, "register", "memory", "immediate"),
+		}},
+
+		// Register pairs.
+		{Mnemonic: "LDP", Description: "Load pair of registers", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatPair, 0x29400000, "register", "register", "memory"),
+			variant(FormatPair, 0x29400000, "register", "register", "memory", "immediate"),
+		}},
+		{Mnemonic: "STP", Description: "Store pair of registers", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatPair, 0x29000000, "register", "register", "memory"),
+			variant(FormatPair, 0x29000000, "register", "register", "memory", "immediate"),
+		}},
+	}
+}
@@ -0,0 +1,25 @@
+package aarch64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+type systemProvider struct {
+	architecture.InstructionProvider
+}
+
+func (p systemProvider) Group() string {
+	return "System"
+}
+
+func (p systemProvider) Provide() []architecture.Instruction {
+	return []architecture.Instruction{
+		{Mnemonic: "SVC", Description: "Supervisor call", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatException, 0xD4000001, "immediate"),
+		}},
+		{Mnemonic: "BRK", Description: "Breakpoint", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatException, 0xD4200000, "immediate"),
+		}},
+		{Mnemonic: "NOP", Description: "No operation", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatNone, 0xD503201F),
+		}},
+	}
+}
@@ -0,0 +1,13 @@
+package aarch64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+func init() {
+	architecture.Register(architecture.Architecture{
+		Name:         "aarch64",
+		Aliases:      []string{"arm64"},
+		Description:  "ARMv8-A AArch64 (A64 instruction set)",
+		Bits:         64,
+		Instructions: Instructions,
+	})
+}
@@ -10,6 +10,9 @@ type InstructionVariant struct {
 	Opcode uint8
 	// Size - the size in bytes for this specific variant
 	Size uint8
+	// Base - the fixed-width instruction word with every operand field cleared, used by architectures whose
+	// instructions are a single 32-bit word (e.g., RISC-V, AArch64). Operand fields are OR-ed into it.
+	Base uint32
 }
 
 // InstructionVariantNew - creates a new instruction variant with the given properties
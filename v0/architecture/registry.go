@@ -0,0 +1,63 @@
+package architecture
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Architecture - describes a target architecture registered under a canonical name.
+type Architecture struct {
+	// Name - the canonical name of the architecture (e.g., "x86_64", "aarch64").
+	Name string
+	// Aliases - alternative names accepted by Lookup (e.g., "amd64", "arm64").
+	Aliases []string
+	// Description - a short, human-readable description of the architecture.
+	Description string
+	// Bits - the word size of the architecture in bits (e.g., 64).
+	Bits int
+	// Instructions - returns the instruction groups of the architecture.
+	Instructions func() map[string][]Instruction
+}
+
+// registry - maps lower-case canonical names and aliases to their architecture.
+var registry = make(map[string]*Architecture)
+
+// Register - adds an architecture to the registry under its name and aliases. Architecture packages call it from
+// their init function. Registering a name or alias twice is a programming error and panics.
+func Register(arch Architecture) {
+	if arch.Name == "" || arch.Instructions == nil {
+		panic("architecture: Register requires a name and an instruction table")
+	}
+
+	entry := &arch
+	for _, name := range append([]string{arch.Name}, arch.Aliases...) {
+		key := strings.ToLower(name)
+		if existing, exists := registry[key]; exists {
+			panic(fmt.Sprintf("architecture: '%s' is already registered by '%s'", name, existing.Name))
+		}
+		registry[key] = entry
+	}
+}
+
+// Lookup - returns the architecture registered under the given name or alias. The lookup is case-insensitive.
+func Lookup(name string) (*Architecture, bool) {
+	arch, exists := registry[strings.ToLower(name)]
+	return arch, exists
+}
+
+// Registered - returns every registered architecture once, sorted by canonical name.
+func Registered() []*Architecture {
+	seen := make(map[*Architecture]bool)
+	result := make([]*Architecture, 0)
+	for _, arch := range registry {
+		if !seen[arch] {
+			seen[arch] = true
+			result = append(result, arch)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result
+}
@@ -0,0 +1,65 @@
+package rv64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+// Major opcodes (bits 6:0) used by the RV64I, M and A encodings.
+const (
+	opLoad    = 0x03
+	opMiscMem = 0x0F
+	opImm     = 0x13
+	opAuipc   = 0x17
+	opImm32   = 0x1B
+	opStore   = 0x23
+	opAmo     = 0x2F
+	opOp      = 0x33
+	opLui     = 0x37
+	opOp32    = 0x3B
+	opBranch  = 0x63
+	opJalr    = 0x67
+	opJal     = 0x6F
+	opSystem  = 0x73
+)
+
+// Instruction formats. The encoding name selects how the code generator places
+// operands into the instruction word.
+const (
+	// FormatR - rd, rs1, rs2.
+	FormatR = "R"
+	// FormatI - rd, rs1, imm[11:0]; also rd, [rs1 + imm] for loads and jalr.
+	FormatI = "I"
+	// FormatShift - rd, rs1, shamt[5:0] (RV64 immediate shifts).
+	FormatShift = "SH"
+	// FormatShiftW - rd, rs1, shamt[4:0] (32-bit immediate shifts).
+	FormatShiftW = "SHW"
+	// FormatS - rs2, [rs1 + imm].
+	FormatS = "S"
+	// FormatB - rs1, rs2, label (±4 KiB).
+	FormatB = "B"
+	// FormatU - rd, imm[31:12].
+	FormatU = "U"
+	// FormatJ - rd, label (±1 MiB).
+	FormatJ = "J"
+	// FormatAtomic - rd, rs2, [rs1] (A extension, aq/rl cleared).
+	FormatAtomic = "A"
+	// FormatNone - the base word is the complete instruction.
+	FormatNone = "N"
+)
+
+// word - assembles the fixed fields of an instruction word.
+func word(funct7, funct3, opcode uint32) uint32 {
+	return funct7<<25 | funct3<<12 | opcode
+}
+
+// variant - creates a 4-byte instruction variant whose base word already holds the opcode and function fields.
+func variant(encoding string, base uint32, operands ...string) architecture.InstructionVariant {
+	if operands == nil {
+		operands = []string{}
+	}
+	return architecture.InstructionVariant{
+		Encoding: encoding,
+		Operands: operands,
+		Opcode:   uint8(base & 0x7F),
+		Size:     4,
+		Base:     base,
+	}
+}
@@ -0,0 +1,21 @@
+package rv64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+var providers = []architecture.InstructionProvider{
+	integerProvider{},
+	loadStoreProvider{},
+	controlFlowProvider{},
+	multiplyDivideProvider{},
+	atomicProvider{},
+	systemProvider{},
+}
+
+// Instructions - returns all RV64I/M/A instructions across all providers.
+func Instructions() map[string][]architecture.Instruction {
+	var result = make(map[string][]architecture.Instruction)
+	for _, p := range providers {
+		result[p.Group()] = p.Provide()
+	}
+	return result
+}
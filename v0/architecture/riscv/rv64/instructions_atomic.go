@@ -0,0 +1,50 @@
+package rv64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+type atomicProvider struct {
+	architecture.InstructionProvider
+}
+
+func (p atomicProvider) Group() string {
+	return "Atomic"
+}
+
+func (p atomicProvider) Provide() []architecture.Instruction {
+	return []architecture.Instruction{
+		atomic("LR.W", "Load reserved word", 0x02, 0x2, "register", "memory"),
+		atomic("SC.W", "Store conditional word", 0x03, 0x2, "register", "register", "memory"),
+		atomic("AMOSWAP.W", "Atomic swap word", 0x01, 0x2, "register", "register", "memory"),
+		atomic("AMOADD.W", "Atomic add word", 0x00, 0x2, "register", "register", "memory"),
+		atomic("AMOXOR.W", "Atomic exclusive or word", 0x04, 0x2, "register", "register", "memory"),
+		atomic("AMOAND.W", "Atomic and word", 0x0C, 0x2, "register", "register", "memory"),
+		atomic("AMOOR.W", "Atomic or word", 0x08, 0x2, "register", "register", "memory"),
+		atomic("AMOMIN.W", "Atomic signed minimum word", 0x10, 0x2, "register", "register", "memory"),
+		atomic("AMOMAX.W", "Atomic signed maximum word", 0x14, 0x2, "register", "register", "memory"),
+		atomic("AMOMINU.W", "Atomic unsigned minimum word", 0x18, 0x2, "register", "register", "memory"),
+		atomic("AMOMAXU.W", "Atomic unsigned maximum word", 0x1C, 0x2, "register", "register", "memory"),
+		atomic("LR.D", "Load reserved doubleword", 0x02, 0x3, "register", "memory"),
+		atomic("SC.D", "Store conditional doubleword", 0x03, 0x3, "register", "register", "memory"),
+		atomic("AMOSWAP.D", "Atomic swap doubleword", 0x01, 0x3, "register", "register", "memory"),
+		atomic("AMOADD.D", "Atomic add doubleword", 0x00, 0x3, "register", "register", "memory"),
+		atomic("AMOXOR.D", "Atomic exclusive or doubleword", 0x04, 0x3, "register", "register", "memory"),
+		atomic("AMOAND.D", "Atomic and doubleword", 0x0C, 0x3, "register", "register", "memory"),
+		atomic("AMOOR.D", "Atomic or doubleword", 0x08, 0x3, "register", "register", "memory"),
+		atomic("AMOMIN.D", "Atomic signed minimum doubleword", 0x10, 0x3, "register", "register", "memory"),
+		atomic("AMOMAX.D", "Atomic signed maximum doubleword", 0x14, 0x3, "register", "register", "memory"),
+		atomic("AMOMINU.D", "Atomic unsigned minimum doubleword", 0x18, 0x3, "register", "register", "memory"),
+		atomic("AMOMAXU.D", "Atomic unsigned maximum doubleword", 0x1C, 0x3, "register", "register", "memory"),
+	}
+}
+
+// atomic - builds an A-extension instruction. The aq and rl ordering bits are left cleared.
+func atomic(mnemonic, description string, funct5, funct3 uint32, operands ...string) architecture.Instruction {
+	return architecture.Instruction{
+		Mnemonic:    mnemonic,
+		Description: description,
+		Flags:       []string{},
+		Variants: []architecture.InstructionVariant{
+			variant(FormatAtomic, word(funct5<<2, funct3, opAmo), operands...),
+		},
+	}
+}
@@ -0,0 +1,60 @@
+package rv64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+type controlFlowProvider struct {
+	architecture.InstructionProvider
+}
+
+func (p controlFlowProvider) Group() string {
+	return "Control Flow"
+}
+
+func (p controlFlowProvider) Provide() []architecture.Instruction {
+	return []architecture.Instruction{
+		// Conditional branches (±4 KiB).
+		{Mnemonic: "BEQ", Description: "Branch if equal", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatB, word(0x00, 0x0, opBranch), "register", "register", "relative"),
+		}},
+		{Mnemonic: "BNE", Description: "Branch if not equal", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatB, word(0x00, 0x1, opBranch), "register", "register", "relative"),
+		}},
+		{Mnemonic: "BLT", Description: "Branch if less than (signed)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatB, word(0x00, 0x4, opBranch), "register", "register", "relative"),
+		}},
+		{Mnemonic: "BGE", Description: "Branch if greater than or equal (signed)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatB, word(0x00, 0x5, opBranch), "register", "register", "relative"),
+		}},
+		{Mnemonic: "BLTU", Description: "Branch if less than (unsigned)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatB, word(0x00, 0x6, opBranch), "register", "register", "relative"),
+		}},
+		{Mnemonic: "BGEU", Description: "Branch if greater than or equal (unsigned)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatB, word(0x00, 0x7, opBranch), "register", "register", "relative"),
+		}},
+		{Mnemonic: "BEQZ", Description: "Branch if zero (beq rs, x0, label)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatB, word(0x00, 0x0, opBranch), "register", "relative"),
+		}},
+		{Mnemonic: "BNEZ", Description: "Branch if not zero (bne rs, x0, label)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatB, word(0x00, 0x1, opBranch), "register", "relative"),
+		}},
+
+		// Unconditional jumps (±1 MiB).
+		{Mnemonic: "JAL", Description: "Jump and link", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatJ, opJal, "register", "relative"),
+			variant(FormatJ, 1<<7|opJal, "relative"),
+		}},
+		{Mnemonic: "JALR", Description: "Jump and link register", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x0, opJalr), "register", "register", "immediate"),
+			variant(FormatI, word(0x00, 0x0, opJalr), "register", "memory"),
+		}},
+		{Mnemonic: "J", Description: "Jump (jal x0, label)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatJ, opJal, "relative"),
+		}},
+		{Mnemonic: "CALL", Description: "Call a nearby routine (jal ra, label)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatJ, 1<<7|opJal, "relative"),
+		}},
+		{Mnemonic: "RET", Description: "Return from routine (jalr x0, 0(ra))", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatNone, 1<<15|word(0x00, 0x0, opJalr)),
+		}},
+	}
+}
@@ -0,0 +1,125 @@
+package rv64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+type integerProvider struct {
+	architecture.InstructionProvider
+}
+
+func (p integerProvider) Group() string {
+	return "Integer Computational"
+}
+
+func (p integerProvider) Provide() []architecture.Instruction {
+	return []architecture.Instruction{
+		// Register-register operations.
+		{Mnemonic: "ADD", Description: "Add rs2 to rs1", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x00, 0x0, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "SUB", Description: "Subtract rs2 from rs1", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x20, 0x0, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "SLL", Description: "Shift left logical by register", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x00, 0x1, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "SLT", Description: "Set if less than (signed)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x00, 0x2, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "SLTU", Description: "Set if less than (unsigned)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x00, 0x3, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "XOR", Description: "Bitwise exclusive or", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x00, 0x4, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "SRL", Description: "Shift right logical by register", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x00, 0x5, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "SRA", Description: "Shift right arithmetic by register", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x20, 0x5, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "OR", Description: "Bitwise or", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x00, 0x6, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "AND", Description: "Bitwise and", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x00, 0x7, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "ADDW", Description: "Add 32-bit and sign-extend", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x00, 0x0, opOp32), "register", "register", "register"),
+		}},
+		{Mnemonic: "SUBW", Description: "Subtract 32-bit and sign-extend", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x20, 0x0, opOp32), "register", "register", "register"),
+		}},
+		{Mnemonic: "SLLW", Description: "Shift left logical 32-bit by register", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x00, 0x1, opOp32), "register", "register", "register"),
+		}},
+		{Mnemonic: "SRLW", Description: "Shift right logical 32-bit by register", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x00, 0x5, opOp32), "register", "register", "register"),
+		}},
+		{Mnemonic: "SRAW", Description: "Shift right arithmetic 32-bit by register", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x20, 0x5, opOp32), "register", "register", "register"),
+		}},
+
+		// Register-immediate operations.
+		{Mnemonic: "ADDI", Description: "Add sign-extended 12-bit immediate", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x0, opImm), "register", "register", "immediate"),
+		}},
+		{Mnemonic: "SLTI", Description: "Set if less than immediate (signed)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x2, opImm), "register", "register", "immediate"),
+		}},
+		{Mnemonic: "SLTIU", Description: "Set if less than immediate (unsigned)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x3, opImm), "register", "register", "immediate"),
+		}},
+		{Mnemonic: "XORI", Description: "Bitwise exclusive or with immediate", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x4, opImm), "register", "register", "immediate"),
+		}},
+		{Mnemonic: "ORI", Description: "Bitwise or with immediate", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x6, opImm), "register", "register", "immediate"),
+		}},
+		{Mnemonic: "ANDI", Description: "Bitwise and with immediate", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x7, opImm), "register", "register", "immediate"),
+		}},
+		{Mnemonic: "SLLI", Description: "Shift left logical by immediate", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatShift, word(0x00, 0x1, opImm), "register", "register", "immediate"),
+		}},
+		{Mnemonic: "SRLI", Description: "Shift right logical by immediate", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatShift, word(0x00, 0x5, opImm), "register", "register", "immediate"),
+		}},
+		{Mnemonic: "SRAI", Description: "Shift right arithmetic by immediate", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatShift, word(0x20, 0x5, opImm), "register", "register", "immediate"),
+		}},
+		{Mnemonic: "ADDIW", Description: "Add 12-bit immediate 32-bit and sign-extend", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x0, opImm32), "register", "register", "immediate"),
+		}},
+		{Mnemonic: "SLLIW", Description: "Shift left logical 32-bit by immediate", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatShiftW, word(0x00, 0x1, opImm32), "register", "register", "immediate"),
+		}},
+		{Mnemonic: "SRLIW", Description: "Shift right logical 32-bit by immediate", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatShiftW, word(0x00, 0x5, opImm32), "register", "register", "immediate"),
+		}},
+		{Mnemonic: "SRAIW", Description: "Shift right arithmetic 32-bit by immediate", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatShiftW, word(0x20, 0x5, opImm32), "register", "register", "immediate"),
+		}},
+
+		// Upper immediates.
+		{Mnemonic: "LUI", Description: "Load upper 20-bit immediate", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatU, opLui, "register", "immediate"),
+		}},
+		{Mnemonic: "AUIPC", Description: "Add upper 20-bit immediate to pc", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatU, opAuipc, "register", "immediate"),
+		}},
+
+		// Pseudo-instructions expanding to a single base instruction.
+		{Mnemonic: "NOP", Description: "No operation (addi x0, x0, 0)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatNone, word(0x00, 0x0, opImm)),
+		}},
+		{Mnemonic: "MV", Description: "Copy register (addi rd, rs, 0)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x0, opImm), "register", "register"),
+		}},
+		{Mnemonic: "LI", Description: "Load 12-bit immediate (addi rd, x0, imm)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x0, opImm), "register", "immediate"),
+		}},
+		{Mnemonic: "NOT", Description: "Bitwise complement (xori rd, rs, -1)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, 0xFFF<<20|word(0x00, 0x4, opImm), "register", "register"),
+		}},
+	}
+}
@@ -0,0 +1,49 @@
+package rv64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+type loadStoreProvider struct {
+	architecture.InstructionProvider
+}
+
+func (p loadStoreProvider) Group() string {
+	return "Load and Store"
+}
+
+func (p loadStoreProvider) Provide() []architecture.Instruction {
+	return []architecture.Instruction{
+		{Mnemonic: "LB", Description: "Load sign-extended byte", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x0, opLoad), "register", "memory"),
+		}},
+		{Mnemonic: "LH", Description: "Load sign-extended halfword", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x1, opLoad), "register", "memory"),
+		}},
+		{Mnemonic: "LW", Description: "Load sign-extended word", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x2, opLoad), "register", "memory"),
+		}},
+		{Mnemonic: "LD", Description: "Load doubleword", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x3, opLoad), "register", "memory"),
+		}},
+		{Mnemonic: "LBU", Description: "Load zero-extended byte", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x4, opLoad), "register", "memory"),
+		}},
+		{Mnemonic: "LHU", Description: "Load zero-extended halfword", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x5, opLoad), "register", "memory"),
+		}},
+		{Mnemonic: "LWU", Description: "Load zero-extended word", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatI, word(0x00, 0x6, opLoad), "register", "memory"),
+		}},
+		{Mnemonic: "SB", Description: "Store byte", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatS, word(0x00, 0x0, opStore), "register", "memory"),
+		}},
+		{Mnemonic: "SH", Description: "Store halfword", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatS, word(0x00, 0x1, opStore), "register", "memory"),
+		}},
+		{Mnemonic: "SW", Description: "Store word", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatS, word(0x00, 0x2, opStore), "register", "memory"),
+		}},
+		{Mnemonic: "SD", Description: "Store doubleword", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatS, word(0x00, 0x3, opStore), "register", "memory"),
+		}},
+	}
+}
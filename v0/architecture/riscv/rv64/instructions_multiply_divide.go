@@ -0,0 +1,55 @@
+package rv64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+type multiplyDivideProvider struct {
+	architecture.InstructionProvider
+}
+
+func (p multiplyDivideProvider) Group() string {
+	return "Multiply and Divide"
+}
+
+func (p multiplyDivideProvider) Provide() []architecture.Instruction {
+	return []architecture.Instruction{
+		{Mnemonic: "MUL", Description: "Multiply, low 64 bits", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x0, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "MULH", Description: "Multiply signed, high 64 bits", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x1, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "MULHSU", Description: "Multiply signed by unsigned, high 64 bits", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x2, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "MULHU", Description: "Multiply unsigned, high 64 bits", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x3, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "DIV", Description: "Divide signed", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x4, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "DIVU", Description: "Divide unsigned", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x5, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "REM", Description: "Remainder signed", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x6, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "REMU", Description: "Remainder unsigned", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x7, opOp), "register", "register", "register"),
+		}},
+		{Mnemonic: "MULW", Description: "Multiply 32-bit and sign-extend", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x0, opOp32), "register", "register", "register"),
+		}},
+		{Mnemonic: "DIVW", Description: "Divide signed 32-bit", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x4, opOp32), "register", "register", "register"),
+		}},
+		{Mnemonic: "DIVUW", Description: "Divide unsigned 32-bit", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x5, opOp32), "register", "register", "register"),
+		}},
+		{Mnemonic: "REMW", Description: "Remainder signed 32-bit", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x6, opOp32), "register", "register", "register"),
+		}},
+		{Mnemonic: "REMUW", Description: "Remainder unsigned 32-bit", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatR, word(0x01, 0x7, opOp32), "register", "register", "register"),
+		}},
+	}
+}
@@ -0,0 +1,25 @@
+package rv64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+type systemProvider struct {
+	architecture.InstructionProvider
+}
+
+func (p systemProvider) Group() string {
+	return "System"
+}
+
+func (p systemProvider) Provide() []architecture.Instruction {
+	return []architecture.Instruction{
+		{Mnemonic: "ECALL", Description: "Request a service from the execution environment", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatNone, word(0x00, 0x0, opSystem)),
+		}},
+		{Mnemonic: "EBREAK", Description: "Return control to a debugging environment", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatNone, 1<<20|word(0x00, 0x0, opSystem)),
+		}},
+		{Mnemonic: "FENCE", Description: "Order all device and memory accesses (fence iorw, iorw)", Flags: []string{}, Variants: []architecture.InstructionVariant{
+			variant(FormatNone, 0xFF<<20|word(0x00, 0x0, opMiscMem)),
+		}},
+	}
+}
@@ -0,0 +1,13 @@
+package rv64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+func init() {
+	architecture.Register(architecture.Architecture{
+		Name:         "rv64",
+		Aliases:      []string{"riscv64"},
+		Description:  "RISC-V RV64I with the M and A extensions",
+		Bits:         64,
+		Instructions: Instructions,
+	})
+}
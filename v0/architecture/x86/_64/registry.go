@@ -0,0 +1,13 @@
+package _64
+
+import "github.com/keurnel/assembler/v0/architecture"
+
+func init() {
+	architecture.Register(architecture.Architecture{
+		Name:         "x86_64",
+		Aliases:      []string{"_64", "amd64", "x64"},
+		Description:  "x86-64 (AMD64), 64-bit mode",
+		Bits:         64,
+		Instructions: Instructions,
+	})
+}
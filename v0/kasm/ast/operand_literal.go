@@ -0,0 +1,15 @@
+package ast
+
+// LiteralOperand represents a literal pool reference such as `=0x12345678` or
+// `=label`. Value holds the ImmediateOperand or IdentifierOperand that follows
+// the '='. The constant is placed in the literal pool of the current section
+// at code generation time and the instruction refers to its pool slot.
+type LiteralOperand struct {
+	Value  Operand
+	Line   int
+	Column int
+}
+
+func (o *LiteralOperand) operandNode()       {}
+func (o *LiteralOperand) OperandLine() int   { return o.Line }
+func (o *LiteralOperand) OperandColumn() int { return o.Column }
@@ -0,0 +1,16 @@
+package ast
+
+// RelocationOperand represents a relocation function applied to a symbol, such
+// as `%hi(label)` or `%lo(label)`. Function holds the name without the `%`
+// prefix; Symbol holds the referenced label name. The value is resolved at
+// code generation time and behaves like an immediate for variant matching.
+type RelocationOperand struct {
+	Function string
+	Symbol   string
+	Line     int
+	Column   int
+}
+
+func (o *RelocationOperand) operandNode()       {}
+func (o *RelocationOperand) OperandLine() int   { return o.Line }
+func (o *RelocationOperand) OperandColumn() int { return o.Column }
@@ -0,0 +1,17 @@
+package ast
+
+// IncbinStmt represents an `%incbin "file"[, offset[, length]]` directive,
+// which embeds the raw bytes of a file at code generation time. Offset and
+// Length are nil when omitted: the bytes start at the beginning of the file
+// and run to its end.
+type IncbinStmt struct {
+	Path   string
+	Offset *ImmediateOperand
+	Length *ImmediateOperand
+	Line   int
+	Column int
+}
+
+func (s *IncbinStmt) statementNode()       {}
+func (s *IncbinStmt) StatementLine() int   { return s.Line }
+func (s *IncbinStmt) StatementColumn() int { return s.Column }
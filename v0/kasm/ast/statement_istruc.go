@@ -0,0 +1,26 @@
+package ast
+
+// IstrucStmt represents an `istruc NAME … iend` block, which emits an
+// initialised instance of the structure NAME. Fields that are not given a
+// value by an `at` line are zero-filled.
+type IstrucStmt struct {
+	Name   string
+	Values []*AtValue
+	Line   int
+	Column int
+}
+
+func (s *IstrucStmt) statementNode()       {}
+func (s *IstrucStmt) StatementLine() int   { return s.Line }
+func (s *IstrucStmt) StatementColumn() int { return s.Column }
+
+// AtValue is a single `at NAME.field, dX values…` line of an istruc block.
+// Field is the full field symbol as written (e.g. `task.pid`), Data the data
+// keyword (db, dw, dd or dq) and Operands the values it emits.
+type AtValue struct {
+	Field    string
+	Data     string
+	Operands []Operand
+	Line     int
+	Column   int
+}
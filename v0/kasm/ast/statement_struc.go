@@ -0,0 +1,27 @@
+package ast
+
+// StrucStmt represents a `struc NAME … endstruc` block, which declares the
+// layout of a structure without emitting any bytes. Each field defines the
+// offset symbol NAME.field, and the block as a whole defines NAME_size.
+type StrucStmt struct {
+	Name   string
+	Fields []*StrucField
+	Line   int
+	Column int
+}
+
+func (s *StrucStmt) statementNode()       {}
+func (s *StrucStmt) StatementLine() int   { return s.Line }
+func (s *StrucStmt) StatementColumn() int { return s.Column }
+
+// StrucField is a single `.field: resX count` line of a structure. Name is
+// the field name without its leading '.' or trailing ':', Reserve the
+// reservation keyword (resb, resw, resd or resq) and Count the number of
+// units it reserves.
+type StrucField struct {
+	Name    string
+	Reserve string
+	Count   *ImmediateOperand
+	Line    int
+	Column  int
+}
@@ -0,0 +1,301 @@
+package kasm_test
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/keurnel/assembler/v0/architecture"
+	"github.com/keurnel/assembler/v0/architecture/arm/aarch64"
+	"github.com/keurnel/assembler/v0/kasm"
+	"github.com/keurnel/assembler/v0/kasm/profile"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+// aarch64InstrTable flattens the AArch64 providers into an upper-case mnemonic table.
+func aarch64InstrTable() map[string]architecture.Instruction {
+	table := make(map[string]architecture.Instruction)
+	for _, instructions := range aarch64.Instructions() {
+		for _, instr := range instructions {
+			table[instr.Mnemonic] = instr
+		}
+	}
+	return table
+}
+
+// assembleAArch64 runs lexer → parser → semantic → generator for AArch64
+// source and returns the raw output together with the code generation errors.
+func assembleAArch64(t *testing.T, source string) ([]byte, []kasm.CodegenError) {
+	t.Helper()
+	tokens := kasm.LexerNew(source, profile.NewAArch64Profile()).Start()
+	program, parseErrors := kasm.ParserNew(tokens).Parse()
+	if len(parseErrors) != 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrors)
+	}
+	table := aarch64InstrTable()
+	if semanticErrors := kasm.AnalyserNew(program, table).Analyse(); len(semanticErrors) != 0 {
+		t.Fatalf("unexpected semantic errors: %v", semanticErrors)
+	}
+	return kasm.GeneratorNew(program, table).WithBackend(kasm.AArch64Backend()).Generate()
+}
+
+// assembleAArch64Words is assembleAArch64 for sources without a literal pool:
+// the output is split into 32-bit instruction words.
+func assembleAArch64Words(t *testing.T, source string) ([]uint32, []kasm.CodegenError) {
+	t.Helper()
+	output, errors := assembleAArch64(t, source)
+	words := make([]uint32, len(output)/4)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(output[i*4:])
+	}
+	return words, errors
+}
+
+// ---------------------------------------------------------------------------
+// Instruction classes
+// ---------------------------------------------------------------------------
+
+func TestGenerateAArch64_DataProcessing(t *testing.T) {
+	words, errors := assembleAArch64Words(t, `add x0, x1, #16
+add sp, sp, #-32
+adds w2, w3, #4096
+sub x0, x1, x2
+cmp x0, #5
+cmp w1, w2
+neg x0, x1
+and x0, x1, #0xff
+orr w0, w1, #0xf0f0f0f0
+tst x0, #1
+mvn w0, w1
+lsl x0, x1, #3
+lsr w0, w1, #5
+asr x0, x1, #63
+lsl x0, x1, x2
+mul x0, x1, x2
+madd x0, x1, x2, x3
+udiv w0, w1, w2`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	requireWords(t, words,
+		0x91004020, 0xD10083FF, 0x31400462, 0xCB020020, 0xF100141F, 0x6B02003F,
+		0xCB0103E0, 0x92401C20, 0x3204CC20, 0xF240001F, 0x2A2103E0, 0xD37DF020,
+		0x53057C20, 0x937FFC20, 0x9AC22020, 0x9B027C20, 0x9B020C20, 0x1AC20820,
+	)
+}
+
+func TestGenerateAArch64_Move(t *testing.T) {
+	words, errors := assembleAArch64Words(t, `mov x0, x1
+mov sp, x29
+mov x29, sp
+mov x0, #0x1234
+mov x0, #0x12340000
+mov x0, #-1
+mov w0, #0xffff0000
+mov x0, #0x5555555555555555
+movk x0, #0xbeef, #16
+movz w1, #7`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	requireWords(t, words,
+		0xAA0103E0, 0x910003BF, 0x910003FD, 0xD2824680, 0xD2A24680,
+		0x92800000, 0x52BFFFE0, 0xB200F3E0, 0xF2B7DDE0, 0x528000E1,
+	)
+}
+
+func TestGenerateAArch64_LoadStore(t *testing.T) {
+	words, errors := assembleAArch64Words(t, `ldr x0, [x1]
+ldr w0, [sp, #8]
+ldr x0, [x1, #-8]
+ldr x0, [x1, #16]!
+ldr x0, [x1], #16
+str x30, [sp + 8]
+strb w0, [x1, #3]
+ldrh w0, [x1, #6]
+ldrsw x0, [x1, #4]
+stp x29, x30, [sp, #-16]!
+ldp x29, x30, [sp], #16
+stp w0, w1, [x2, #8]`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	requireWords(t, words,
+		0xF9400020, 0xB9400BE0, 0xF85F8020, 0xF8410C20, 0xF8410420, 0xF90007FE,
+		0x39000C20, 0x79400C20, 0xB9800420, 0xA9BF7BFD, 0xA8C17BFD, 0x29010440,
+	)
+}
+
+func TestGenerateAArch64_StrucDisplacement(t *testing.T) {
+	words, errors := assembleAArch64Words(t, `struc task
+    .pid:   resd 1
+    .flags: resd 1
+    .stack: resq 1
+endstruc
+ldr x0, [x1, task.stack]
+ldr w0, [x1 + task.flags]`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	requireWords(t, words, 0xF9400420, 0xB9400420)
+}
+
+func TestGenerateAArch64_StrucImmediate(t *testing.T) {
+	output, errors := assembleAArch64(t, `struc task
+    .pid:   resd 1
+    .flags: resd 1
+    .stack: resq 1
+endstruc
+mov x0, #task_size
+add x1, x1, #task.stack
+ldr x2, =task.flags`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	// The pool slot after the three instructions (and padding) holds 4.
+	words := make([]uint32, 3)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(output[i*4:])
+	}
+	requireWords(t, words, 0xD2800200, 0x91002021, 0x58000042)
+	if value := binary.LittleEndian.Uint64(output[16:]); value != 4 {
+		t.Errorf("expected the literal 4 in the pool, got %d", value)
+	}
+}
+
+func TestGenerateAArch64_BranchesAndSystem(t *testing.T) {
+	words, errors := assembleAArch64Words(t, `start:
+b.ne start
+cbz w3, done
+cbnz x4, start
+bl done
+b start
+br x16
+blr x1
+svc #0
+done:
+ret
+ret x1
+brk #1
+nop`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	requireWords(t, words,
+		0x54000001, 0x340000E3, 0xB5FFFFC4, 0x94000005, 0x17FFFFFC, 0xD61F0200,
+		0xD63F0020, 0xD4000001, 0xD65F03C0, 0xD65F0020, 0xD4200020, 0xD503201F,
+	)
+}
+
+// ---------------------------------------------------------------------------
+// adr / adrp label handling
+// ---------------------------------------------------------------------------
+
+func TestGenerateAArch64_AdrAdrp(t *testing.T) {
+	// Place msg in the second 4 KiB page of the section.
+	source := "adrp x0, msg\nadd x0, x0, %lo12(msg)\nadr x1, msg\n" +
+		strings.Repeat("nop\n", 1021) +
+		"msg:\nldr x2, [x0, %lo12(msg)]\n"
+
+	words, errors := assembleAArch64Words(t, source)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	// msg is at 0x1000: page +1, page offset 0, adr offset 0xFF8.
+	if words[0] != 0xB0000000 {
+		t.Errorf("adrp: expected B0000000, got %08X", words[0])
+	}
+	if words[1] != 0x91000000 {
+		t.Errorf("add: expected 91000000, got %08X", words[1])
+	}
+	if words[2] != 0x10007FC1 {
+		t.Errorf("adr: expected 10007FC1, got %08X", words[2])
+	}
+	if last := words[len(words)-1]; last != 0xF9400002 {
+		t.Errorf("ldr: expected F9400002, got %08X", last)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Literal pools
+// ---------------------------------------------------------------------------
+
+func TestGenerateAArch64_LiteralPool(t *testing.T) {
+	output, errors := assembleAArch64(t, `ldr x0, =0x123456789
+ldr w1, =16
+ldr x2, =0x10
+ldr x3, =target
+target:
+ret`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+
+	// 5 instructions (20 bytes), padding to 24, then three 8-byte slots:
+	// 0x123456789, 16 (shared by =16 and =0x10) and target (16).
+	if len(output) != 48 {
+		t.Fatalf("expected 48 bytes, got %d", len(output))
+	}
+	word := func(i int) uint32 { return binary.LittleEndian.Uint32(output[i*4:]) }
+	expected := []uint32{0x580000C0, 0x180000E1, 0x580000C2, 0x580000E3, 0xD65F03C0}
+	for i, w := range expected {
+		if word(i) != w {
+			t.Errorf("word %d: expected %08X, got %08X", i, w, word(i))
+		}
+	}
+	for i, v := range []uint64{0x123456789, 16, 16} {
+		if got := binary.LittleEndian.Uint64(output[24+i*8:]); got != v {
+			t.Errorf("pool slot %d: expected %#x, got %#x", i, v, got)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Operand checks
+// ---------------------------------------------------------------------------
+
+func TestGenerateAArch64_OperandErrors(t *testing.T) {
+	cases := []struct {
+		name    string
+		source  string
+		message string
+	}{
+		{"mixed widths", "add x0, w1, w2", "cannot mix"},
+		{"sp as zero register", "sub x0, sp, x1", "stack pointer"},
+		{"imm12 range", "add x0, x1, #4097", "out of range"},
+		{"logical immediate", "and x0, x1, #0x1234", "logical immediate"},
+		{"mov immediate", "mov x0, #0x123456789", "ldr x0, ="},
+		{"pair offset", "stp x0, x1, [sp, #12]", "out of range"},
+		{"fixed size", "ldrb x0, [x1]", "32-bit register"},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, errors := assembleAArch64Words(t, tc.source)
+			if len(errors) != 1 {
+				t.Fatalf("expected 1 error, got %d: %v", len(errors), errors)
+			}
+			if !strings.Contains(errors[0].Message, tc.message) {
+				t.Errorf("expected error containing %q, got %q", tc.message, errors[0].Message)
+			}
+		})
+	}
+}
+
+func TestGenerateAArch64_BranchOutOfRange(t *testing.T) {
+	// cbz reaches back at most 1 MiB (2^18 instructions).
+	source := "start:\n" + strings.Repeat("nop\n", 1<<18+1) + "cbz x0, start\n"
+
+	words, errors := assembleAArch64Words(t, source)
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errors), errors)
+	}
+	if !strings.Contains(errors[0].Message, "out of range") {
+		t.Errorf("expected out of range error, got %q", errors[0].Message)
+	}
+	if len(words) != 1<<18+2 {
+		t.Errorf("expected %d words, got %d", 1<<18+2, len(words))
+	}
+}
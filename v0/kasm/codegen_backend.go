@@ -0,0 +1,58 @@
+package kasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/keurnel/assembler/v0/kasm/ast"
+)
+
+// Backend lays out the machine code of a single instruction for one target
+// architecture. The Generator owns everything that is architecture-neutral —
+// sections, labels, variant lookup and error recording — and delegates the
+// final byte layout of each instruction to its Backend.
+//
+// Backends live in this package so that they can use the Generator's label
+// resolution and error recording helpers directly. The interface is sealed by
+// its unexported methods; obtain a Backend through one of the constructors
+// (X8664Backend, RV64Backend, AArch64Backend).
+type Backend interface {
+	// Name returns the architecture the backend encodes for (e.g. "x86_64").
+	Name() string
+
+	// instructionSize returns the number of bytes the instruction occupies
+	// once encoded with the given variant. Called during Pass 1; must not
+	// record errors or emit bytes, but may reserve literal pool slots.
+	instructionSize(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) int
+
+	// encodeInstruction returns the encoded bytes of the instruction using
+	// the given variant. Called during Pass 2; errors are recorded on the
+	// generator. The returned slice must be exactly instructionSize bytes
+	// long so that label offsets computed in Pass 1 remain valid.
+	encodeInstruction(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) []byte
+}
+
+// backends maps lower-case canonical architecture names (see
+// architecture.Register) to their code generation backend.
+var backends = make(map[string]Backend)
+
+// RegisterBackend adds a backend under the canonical architecture name it
+// reports. Backend files call it from their init function. Registering a name
+// twice is a programming error and panics.
+func RegisterBackend(backend Backend) {
+	if backend == nil || backend.Name() == "" {
+		panic("kasm: RegisterBackend requires a named backend")
+	}
+
+	key := strings.ToLower(backend.Name())
+	if _, exists := backends[key]; exists {
+		panic(fmt.Sprintf("kasm: backend '%s' is already registered", backend.Name()))
+	}
+	backends[key] = backend
+}
+
+// BackendFor returns the backend for the canonical architecture name.
+func BackendFor(name string) (Backend, bool) {
+	b, exists := backends[strings.ToLower(name)]
+	return b, exists
+}
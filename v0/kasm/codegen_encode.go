@@ -43,6 +43,19 @@ func isExtendedRegister(name string) bool {
 // ---------------------------------------------------------------------------
 
 // classifyOperand returns the operand-type string used for variant lookup.
+// A structure symbol is a constant and is classified as an immediate
+// (FR-11.3).
+func (g *Generator) classifyOperand(op ast.Operand) string {
+	if id, ok := op.(*ast.IdentifierOperand); ok {
+		if _, exists := g.strucSymbols[id.Name]; exists {
+			return "immediate"
+		}
+	}
+	return classifyOperand(op)
+}
+
+// classifyOperand returns the operand-type string used for variant lookup,
+// without regard to structure symbols.
 func classifyOperand(op ast.Operand) string {
 	switch op.(type) {
 	case *ast.RegisterOperand:
@@ -55,6 +68,12 @@ func classifyOperand(op ast.Operand) string {
 		// Identifiers are label references; they resolve to relative offsets
 		// at encoding time. Treated as "relative" for variant matching.
 		return "relative"
+	case *ast.RelocationOperand:
+		// %hi(label) / %lo(label) resolve to numeric values at encoding time.
+		return "immediate"
+	case *ast.LiteralOperand:
+		// =value places the value in the section's literal pool.
+		return "literal"
 	default:
 		return "unknown"
 	}
@@ -66,7 +85,7 @@ func classifyOperand(op ast.Operand) string {
 
 // computeInstructionSize determines how many bytes an instruction will occupy
 // without actually emitting bytes. This is used in Pass 1 to compute label
-// offsets (FR-2.1).
+// offsets (FR-2.1). The architecture-specific size is supplied by the backend.
 func (g *Generator) computeInstructionSize(s *ast.InstructionStmt) int {
 	mnemonic := strings.ToUpper(s.Mnemonic)
 	instr, exists := g.instructions[mnemonic]
@@ -77,7 +96,7 @@ func (g *Generator) computeInstructionSize(s *ast.InstructionStmt) int {
 
 	operandTypes := make([]string, len(s.Operands))
 	for i, op := range s.Operands {
-		operandTypes[i] = classifyOperand(op)
+		operandTypes[i] = g.classifyOperand(op)
 	}
 
 	variant := instr.FindVariant(operandTypes...)
@@ -86,14 +105,7 @@ func (g *Generator) computeInstructionSize(s *ast.InstructionStmt) int {
 		return 0
 	}
 
-	size := int(variant.Size)
-
-	// FR-6: Account for REX prefix when 64-bit registers are used.
-	if g.needsREX(s) {
-		size++
-	}
-
-	return size
+	return g.backend.instructionSize(g, s, variant)
 }
 
 // ---------------------------------------------------------------------------
@@ -124,7 +136,7 @@ func (g *Generator) encodeInstruction(s *ast.InstructionStmt) {
 	// FR-5.2: Build the operand-type signature.
 	operandTypes := make([]string, len(s.Operands))
 	for i, op := range s.Operands {
-		operandTypes[i] = classifyOperand(op)
+		operandTypes[i] = g.classifyOperand(op)
 	}
 
 	// FR-5.3: Find the matching variant.
@@ -138,7 +150,62 @@ func (g *Generator) encodeInstruction(s *ast.InstructionStmt) {
 		return
 	}
 
-	// FR-8.4: Trace the encoding.
+	// FR-5.4: Let the backend lay out the instruction bytes.
+	encoded := g.backend.encodeInstruction(g, s, variant)
+
+	// FR-8.4: Verbose trace.
+	if g.debugCtx != nil {
+		g.debugCtx.Trace(
+			g.debugCtx.Loc(g.mapLine(s.Line), s.Column),
+			fmt.Sprintf("encode %s [%s]: %X", s.Mnemonic, variant.Encoding, encoded),
+		)
+	}
+
+	sec.data = append(sec.data, encoded...)
+	sec.size += len(encoded)
+}
+
+// ---------------------------------------------------------------------------
+// x86_64 backend
+// ---------------------------------------------------------------------------
+
+// x8664Backend encodes instructions using the variable-length x86_64 format:
+// an optional REX prefix, the opcode byte, and the operand bytes selected by
+// the variant encoding.
+type x8664Backend struct{}
+
+func init() {
+	RegisterBackend(X8664Backend())
+}
+
+// X8664Backend returns the code generation backend for x86_64. It is the
+// default backend of every Generator.
+func X8664Backend() Backend {
+	return x8664Backend{}
+}
+
+func (b x8664Backend) Name() string { return "x86_64" }
+
+// instructionSize returns the variant size plus one byte when a REX prefix is
+// required (FR-6). Under REX.W the immediate of an "RI" variant widens from
+// 32 to 64 bits.
+func (b x8664Backend) instructionSize(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) int {
+	size := int(variant.Size)
+
+	// FR-6: Account for REX prefix when 64-bit registers are used.
+	if g.needsREX(s) {
+		size++
+		if variant.Encoding == "RI" {
+			size += 4
+		}
+	}
+
+	return size
+}
+
+// encodeInstruction emits the REX prefix (if needed), the opcode byte and the
+// operand bytes for the variant encoding.
+func (b x8664Backend) encodeInstruction(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) []byte {
 	var encoded []byte
 
 	// FR-6: Emit REX prefix if needed.
@@ -147,23 +214,19 @@ func (g *Generator) encodeInstruction(s *ast.InstructionStmt) {
 		encoded = append(encoded, rex)
 	}
 
-	// FR-5.4: Emit the opcode byte.
-	encoded = append(encoded, variant.Opcode)
+	// FR-5.4: Emit the opcode byte. "RI" variants carry the destination
+	// register in the low three opcode bits (e.g. B8+r).
+	opcode := variant.Opcode
+	if variant.Encoding == "RI" && len(s.Operands) > 0 {
+		if reg, ok := s.Operands[0].(*ast.RegisterOperand); ok {
+			opcode += registerNumber[strings.ToUpper(reg.Name)] & 0x07
+		}
+	}
+	encoded = append(encoded, opcode)
 
 	// Encode operands based on the variant encoding.
 	operandBytes := g.encodeOperands(s, variant)
-	encoded = append(encoded, operandBytes...)
-
-	// FR-8.4: Verbose trace.
-	if g.debugCtx != nil {
-		g.debugCtx.Trace(
-			g.debugCtx.Loc(s.Line, s.Column),
-			fmt.Sprintf("encode %s [%s]: %X", s.Mnemonic, variant.Encoding, encoded),
-		)
-	}
-
-	sec.data = append(sec.data, encoded...)
-	sec.size += len(encoded)
+	return append(encoded, operandBytes...)
 }
 
 // ---------------------------------------------------------------------------
@@ -225,20 +288,17 @@ func (g *Generator) encodeMR(s *ast.InstructionStmt) []byte {
 	return []byte{modrm}
 }
 
-// encodeRI encodes a register-immediate instruction (e.g. MOV r64, imm32).
-// The register is encoded in the low 3 bits of the opcode (already handled
-// by variant selection); the immediate follows as a 4-byte little-endian
-// value.
+// encodeRI encodes a register-immediate instruction (e.g. MOV r64, imm64).
+// The register is encoded in the low 3 bits of the opcode by
+// encodeInstruction; its extension bit is carried by REX.B. The immediate
+// follows as a little-endian value of the operand size: 8 bytes under REX.W,
+// 4 bytes otherwise.
 func (g *Generator) encodeRI(s *ast.InstructionStmt) []byte {
 	if len(s.Operands) < 2 {
 		return nil
 	}
 
-	// The register number is added to the opcode by the caller pattern in
-	// x86_64. For RI encoding, the low 3 bits of the opcode are the register.
-	// We encode the register offset and the immediate value.
-	regNum := g.encodeRegOperand(s.Operands[0], s.Line, s.Column)
-	if regNum < 0 {
+	if regNum := g.encodeRegOperand(s.Operands[0], s.Line, s.Column); regNum < 0 {
 		return nil
 	}
 
@@ -247,13 +307,15 @@ func (g *Generator) encodeRI(s *ast.InstructionStmt) []byte {
 		return nil
 	}
 
-	// 4-byte little-endian immediate.
+	if g.needsREX(s) {
+		imm := make([]byte, 8)
+		binary.LittleEndian.PutUint64(imm, uint64(immVal))
+		return imm
+	}
+
 	imm := make([]byte, 4)
 	binary.LittleEndian.PutUint32(imm, uint32(immVal))
-
-	// For RI encoding, the register is encoded as an offset added to the base
-	// opcode. We return the register offset byte followed by the immediate.
-	return append([]byte{byte(regNum & 0x07)}, imm...)
+	return imm
 }
 
 // encodeRelative encodes a relative jump/call operand (e.g. JMP label).
@@ -270,9 +332,11 @@ func (g *Generator) encodeRelative(s *ast.InstructionStmt) []byte {
 		if !ok {
 			return make([]byte, 4) // placeholder
 		}
-		// Relative offset: target - (current position + instruction size)
+		// Relative offset: target - (current position + instruction size).
+		// The instruction has not been appended yet, so the end of the
+		// instruction is the section length plus its full encoded size.
 		sec := g.currentSection()
-		currentPos := len(sec.data) + 4 // +4 for the 4-byte offset itself
+		currentPos := len(sec.data) + g.computeInstructionSize(s)
 		targetOffset = resolved - currentPos
 	case *ast.ImmediateOperand:
 		val, ok := g.parseImmediate(s.Operands[0], s.Line, s.Column)
@@ -331,8 +395,15 @@ func (g *Generator) encodeRegOperand(op ast.Operand, line, column int) int {
 // ---------------------------------------------------------------------------
 
 // parseImmediate extracts and parses an immediate value from an operand.
-// Supports decimal, hexadecimal (0x), and binary (0b) formats.
+// Supports decimal, hexadecimal (0x), and binary (0b) formats, and the
+// NAME.field and NAME_size structure symbols (FR-11.3).
 func (g *Generator) parseImmediate(op ast.Operand, line, column int) (int64, bool) {
+	if id, isID := op.(*ast.IdentifierOperand); isID {
+		if value, exists := g.strucSymbols[id.Name]; exists {
+			return value, true
+		}
+	}
+
 	imm, ok := op.(*ast.ImmediateOperand)
 	if !ok {
 		g.addError(
@@ -342,44 +413,48 @@ func (g *Generator) parseImmediate(op ast.Operand, line, column int) (int64, boo
 		return 0, false
 	}
 
-	val := imm.Value
+	n, err := parseImmediateValue(imm.Value)
+	if err != nil {
+		g.addError(err.Error(), line, column)
+		return 0, false
+	}
+	return n, true
+}
+
+// parseImmediateValue parses a decimal, hexadecimal (0x) or binary (0b)
+// literal, each optionally preceded by '-'. Hexadecimal and binary literals
+// may use the full unsigned 64-bit range; they are returned as the two's
+// complement bit pattern (0xffffffffffffffff is -1).
+func parseImmediateValue(val string) (int64, error) {
+	if strings.HasPrefix(val, "-") {
+		n, err := parseImmediateValue(val[1:])
+		return -n, err
+	}
 
 	// FR-5.6: Hexadecimal.
 	if strings.HasPrefix(val, "0x") || strings.HasPrefix(val, "0X") {
-		n, err := strconv.ParseInt(val[2:], 16, 64)
+		n, err := strconv.ParseUint(val[2:], 16, 64)
 		if err != nil {
-			g.addError(
-				fmt.Sprintf("invalid hexadecimal immediate '%s': %v", val, err),
-				line, column,
-			)
-			return 0, false
+			return 0, fmt.Errorf("invalid hexadecimal immediate '%s': %v", val, err)
 		}
-		return n, true
+		return int64(n), nil
 	}
 
 	// FR-5.6: Binary.
 	if strings.HasPrefix(val, "0b") || strings.HasPrefix(val, "0B") {
-		n, err := strconv.ParseInt(val[2:], 2, 64)
+		n, err := strconv.ParseUint(val[2:], 2, 64)
 		if err != nil {
-			g.addError(
-				fmt.Sprintf("invalid binary immediate '%s': %v", val, err),
-				line, column,
-			)
-			return 0, false
+			return 0, fmt.Errorf("invalid binary immediate '%s': %v", val, err)
 		}
-		return n, true
+		return int64(n), nil
 	}
 
-	// FR-5.6: Decimal (including negative).
+	// FR-5.6: Decimal.
 	n, err := strconv.ParseInt(val, 10, 64)
 	if err != nil {
-		g.addError(
-			fmt.Sprintf("invalid immediate '%s': %v", val, err),
-			line, column,
-		)
-		return 0, false
+		return 0, fmt.Errorf("invalid immediate '%s': %v", val, err)
 	}
-	return n, true
+	return n, nil
 }
 
 // ---------------------------------------------------------------------------
@@ -0,0 +1,922 @@
+package kasm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+	"strconv"
+	"strings"
+
+	"github.com/keurnel/assembler/v0/architecture/arm/aarch64"
+	"github.com/keurnel/assembler/v0/kasm/ast"
+)
+
+// ---------------------------------------------------------------------------
+// AArch64 register encoding table
+// ---------------------------------------------------------------------------
+
+// aarch64Register describes a general-purpose register operand. Number 31
+// encodes either the stack pointer or the zero register depending on the
+// instruction field, so both are tracked explicitly.
+type aarch64Register struct {
+	num  uint32
+	wide bool // 64-bit (X) view
+	sp   bool // sp / wsp
+	zr   bool // xzr / wzr
+}
+
+// aarch64Registers maps upper-case register names to their encoding.
+var aarch64Registers = func() map[string]aarch64Register {
+	registers := map[string]aarch64Register{
+		"SP":  {num: 31, wide: true, sp: true},
+		"WSP": {num: 31, sp: true},
+		"XZR": {num: 31, wide: true, zr: true},
+		"WZR": {num: 31, zr: true},
+		"FP":  {num: 29, wide: true},
+		"LR":  {num: 30, wide: true},
+	}
+	for i := uint32(0); i <= 30; i++ {
+		registers["X"+strconv.Itoa(int(i))] = aarch64Register{num: i, wide: true}
+		registers["W"+strconv.Itoa(int(i))] = aarch64Register{num: i}
+	}
+	return registers
+}()
+
+// Branch and PC-relative ranges of the AArch64 instruction formats.
+const (
+	aarch64Branch26Min = -(1 << 27)
+	aarch64Branch26Max = 1<<27 - 4
+	aarch64Branch19Min = -(1 << 20)
+	aarch64Branch19Max = 1<<20 - 4
+	aarch64AdrMin      = -(1 << 20)
+	aarch64AdrMax      = 1<<20 - 1
+	aarch64AdrpMin     = -(1 << 20) // in 4 KiB pages
+	aarch64AdrpMax     = 1<<20 - 1
+	aarch64Imm9Min     = -256
+	aarch64Imm9Max     = 255
+)
+
+// Bits of the fixed instruction fields that the backend toggles.
+const (
+	aarch64SF         = 1 << 31 // 64-bit operation
+	aarch64SetFlags   = 1 << 29 // S bit of add/sub and logical
+	aarch64AddSubOp   = 1 << 30 // add ↔ sub
+	aarch64LdStSize64 = 1 << 30 // ldr/str: size 10 → 11
+	aarch64LdStUnsOff = 1 << 24 // ldr/str: unsigned-offset form
+	aarch64PairPre    = 1 << 23 // ldp/stp: signed-offset → pre-index
+)
+
+// ---------------------------------------------------------------------------
+// AArch64 backend
+// ---------------------------------------------------------------------------
+
+// aarch64Backend encodes instructions as fixed 32-bit little-endian A64 words.
+// Each variant carries its opcode fields in Base (32-bit form); the backend
+// sets the size bit for X registers and ORs register numbers and immediates
+// into the format-specific bit positions.
+//
+// Label addresses are section offsets, so adrp assumes that each section is
+// loaded at a 4 KiB aligned address.
+type aarch64Backend struct{}
+
+func init() {
+	RegisterBackend(AArch64Backend())
+}
+
+// AArch64Backend returns the code generation backend for AArch64 (ARMv8-A).
+func AArch64Backend() Backend {
+	return aarch64Backend{}
+}
+
+func (b aarch64Backend) Name() string { return "aarch64" }
+
+// instructionSize returns the variant size — always 4 bytes for AArch64. It
+// reserves the literal pool slot of `ldr rt, =value` so that the pool can be
+// laid out at the end of Pass 1.
+func (b aarch64Backend) instructionSize(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) int {
+	for _, op := range s.Operands {
+		if lit, ok := op.(*ast.LiteralOperand); ok {
+			g.reserveLiteral(lit)
+		}
+	}
+	return int(variant.Size)
+}
+
+// encodeInstruction encodes the instruction word. On error the word is left
+// zero so that the instruction still occupies its 4 bytes and subsequent
+// label offsets stay valid.
+func (b aarch64Backend) encodeInstruction(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) []byte {
+	encoded := make([]byte, 4)
+	if word, ok := b.encodeWord(g, s, variant); ok {
+		binary.LittleEndian.PutUint32(encoded, word)
+	}
+	return encoded
+}
+
+// encodeWord dispatches on the variant's instruction format.
+func (b aarch64Backend) encodeWord(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	switch variant.Encoding {
+	case aarch64.FormatImm12:
+		return b.encodeImm12(g, s, variant)
+	case aarch64.FormatReg3:
+		return b.encodeReg3(g, s, variant)
+	case aarch64.FormatReg2:
+		return b.encodeReg2(g, s, variant)
+	case aarch64.FormatReg4:
+		return b.encodeReg4(g, s, variant)
+	case aarch64.FormatLogicalImm:
+		return b.encodeLogicalImm(g, s, variant)
+	case aarch64.FormatMoveWide:
+		return b.encodeMoveWide(g, s, variant)
+	case aarch64.FormatMove:
+		return b.encodeMove(g, s, variant)
+	case aarch64.FormatShiftLeft:
+		return b.encodeShift(g, s, variant, true)
+	case aarch64.FormatShiftRight:
+		return b.encodeShift(g, s, variant, false)
+	case aarch64.FormatLoadStore:
+		return b.encodeLoadStore(g, s, variant, false)
+	case aarch64.FormatLoadStoreFixed:
+		return b.encodeLoadStore(g, s, variant, true)
+	case aarch64.FormatLoadLiteral:
+		return b.encodeLoadLiteral(g, s, variant)
+	case aarch64.FormatPair:
+		return b.encodePair(g, s, variant)
+	case aarch64.FormatBranch:
+		offset, ok := b.pcRelative(g, s, s.Operands[0], aarch64Branch26Min, aarch64Branch26Max, true)
+		return variant.Base | uint32(offset>>2)&0x3FFFFFF, ok
+	case aarch64.FormatCondBranch:
+		offset, ok := b.pcRelative(g, s, s.Operands[0], aarch64Branch19Min, aarch64Branch19Max, true)
+		return variant.Base | (uint32(offset>>2)&0x7FFFF)<<5, ok
+	case aarch64.FormatCompareBranch:
+		return b.encodeCompareBranch(g, s, variant)
+	case aarch64.FormatBranchReg:
+		return b.encodeBranchReg(g, s, variant)
+	case aarch64.FormatAdr, aarch64.FormatAdrp:
+		return b.encodeAdr(g, s, variant)
+	case aarch64.FormatException:
+		return b.encodeException(g, s, variant)
+	case aarch64.FormatNone:
+		return variant.Base, true
+	default:
+		g.addError(
+			fmt.Sprintf("unsupported encoding '%s' for '%s'", variant.Encoding, s.Mnemonic),
+			s.Line, s.Column,
+		)
+		return 0, false
+	}
+}
+
+// encodeImm12 encodes rd|sp, rn|sp, imm12 — or rn|sp, imm12 for cmp/cmn.
+// A negative immediate flips add ↔ sub; a multiple of 4096 up to 0xFFF000
+// uses the shifted form.
+func (b aarch64Backend) encodeImm12(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	word := variant.Base
+	ops := s.Operands
+	regs := make([]aarch64Register, 0, 2)
+
+	if len(ops) == 3 {
+		// The flag-setting forms write the zero register, not sp.
+		rd, ok := b.register(g, ops[0], variant.Base&aarch64SetFlags == 0)
+		if !ok {
+			return 0, false
+		}
+		word |= rd.num
+		regs = append(regs, rd)
+		ops = ops[1:]
+	}
+	rn, ok1 := b.register(g, ops[0], true)
+	imm, ok2 := b.immediate(g, ops[1])
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	regs = append(regs, rn)
+	sf, ok := b.sameWidth(g, s, regs...)
+	if !ok {
+		return 0, false
+	}
+
+	if imm < 0 {
+		word ^= aarch64AddSubOp
+		imm = -imm
+	}
+	switch {
+	case imm <= 0xFFF:
+		word |= uint32(imm) << 10
+	case imm&0xFFF == 0 && imm>>12 <= 0xFFF:
+		word |= 1<<22 | uint32(imm>>12)<<10
+	default:
+		g.addError(
+			fmt.Sprintf("immediate %d out of range for '%s' (allowed 0 to 4095, optionally shifted left by 12)",
+				imm, s.Mnemonic),
+			s.Line, s.Column,
+		)
+		return 0, false
+	}
+	return word | sf | rn.num<<5, true
+}
+
+// encodeReg3 encodes rd, rn, rm — or rn, rm for cmp/cmn/tst.
+func (b aarch64Backend) encodeReg3(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	regs, ok := b.registers(g, s.Operands)
+	if !ok {
+		return 0, false
+	}
+	sf, ok := b.sameWidth(g, s, regs...)
+	if !ok {
+		return 0, false
+	}
+
+	word := variant.Base | sf
+	if len(regs) == 3 {
+		word |= regs[0].num
+		regs = regs[1:]
+	}
+	return word | regs[0].num<<5 | regs[1].num<<16, true
+}
+
+// encodeReg2 encodes rd, rm with rn fixed to the zero register.
+func (b aarch64Backend) encodeReg2(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	regs, ok := b.registers(g, s.Operands)
+	if !ok {
+		return 0, false
+	}
+	sf, ok := b.sameWidth(g, s, regs...)
+	if !ok {
+		return 0, false
+	}
+	return variant.Base | sf | regs[0].num | regs[1].num<<16, true
+}
+
+// encodeReg4 encodes rd, rn, rm, ra.
+func (b aarch64Backend) encodeReg4(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	regs, ok := b.registers(g, s.Operands)
+	if !ok {
+		return 0, false
+	}
+	sf, ok := b.sameWidth(g, s, regs...)
+	if !ok {
+		return 0, false
+	}
+	return variant.Base | sf | regs[0].num | regs[1].num<<5 | regs[2].num<<16 | regs[3].num<<10, true
+}
+
+// encodeLogicalImm encodes rd|sp, rn, bitmask — or rn, bitmask for tst.
+func (b aarch64Backend) encodeLogicalImm(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	word := variant.Base
+	ops := s.Operands
+	regs := make([]aarch64Register, 0, 2)
+
+	if len(ops) == 3 {
+		rd, ok := b.register(g, ops[0], variant.Base&aarch64SetFlags == 0)
+		if !ok {
+			return 0, false
+		}
+		word |= rd.num
+		regs = append(regs, rd)
+		ops = ops[1:]
+	}
+	rn, ok1 := b.register(g, ops[0], false)
+	imm, ok2 := b.immediate(g, ops[1])
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	regs = append(regs, rn)
+	sf, ok := b.sameWidth(g, s, regs...)
+	if !ok {
+		return 0, false
+	}
+
+	fields, ok := aarch64BitmaskImmediate(uint64(imm), sf != 0)
+	if !ok {
+		g.addError(
+			fmt.Sprintf("immediate %#x cannot be encoded as a logical immediate for '%s'", imm, s.Mnemonic),
+			s.Line, s.Column,
+		)
+		return 0, false
+	}
+	return word | sf | fields | rn.num<<5, true
+}
+
+// encodeMoveWide encodes rd, imm16 [, shift] for movz/movn/movk.
+func (b aarch64Backend) encodeMoveWide(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	rd, ok1 := b.register(g, s.Operands[0], false)
+	imm, ok2 := b.immediate(g, s.Operands[1])
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	var shift int64
+	if len(s.Operands) == 3 {
+		var ok bool
+		if shift, ok = b.immediate(g, s.Operands[2]); !ok {
+			return 0, false
+		}
+	}
+
+	maxShift := int64(16)
+	if rd.wide {
+		maxShift = 48
+	}
+	if shift%16 != 0 || shift < 0 || shift > maxShift {
+		g.addError(
+			fmt.Sprintf("shift %d invalid for '%s' (allowed 0 to %d in steps of 16)", shift, s.Mnemonic, maxShift),
+			s.Line, s.Column,
+		)
+		return 0, false
+	}
+	if !g.checkRange(s, imm, 0, 0xFFFF, "16-bit immediate") {
+		return 0, false
+	}
+	return variant.Base | b.sf(rd) | uint32(shift/16)<<21 | uint32(imm)<<5 | rd.num, true
+}
+
+// encodeMove encodes the mov alias. Register moves use orr rd, xzr, rm, or
+// add rd, rn, #0 when the stack pointer is involved. Immediate moves pick the
+// first of movz, movn or orr with a bitmask immediate that can represent the
+// value.
+func (b aarch64Backend) encodeMove(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	if _, isReg := s.Operands[1].(*ast.RegisterOperand); isReg {
+		rd, ok1 := b.register(g, s.Operands[0], true)
+		rm, ok2 := b.register(g, s.Operands[1], true)
+		if !ok1 || !ok2 {
+			return 0, false
+		}
+		sf, ok := b.sameWidth(g, s, rd, rm)
+		if !ok {
+			return 0, false
+		}
+		if rd.sp || rm.sp {
+			if rd.zr || rm.zr {
+				g.addError(fmt.Sprintf("'%s' cannot combine the stack pointer and the zero register", s.Mnemonic), s.Line, s.Column)
+				return 0, false
+			}
+			return 0x11000000 | sf | rm.num<<5 | rd.num, true
+		}
+		return variant.Base | sf | rm.num<<16 | rd.num, true
+	}
+
+	rd, ok1 := b.register(g, s.Operands[0], true)
+	imm, ok2 := b.immediate(g, s.Operands[1])
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	sf := b.sf(rd)
+
+	width := 64
+	value := uint64(imm)
+	if !rd.wide {
+		if imm < -(1<<31) || imm > 1<<32-1 {
+			g.addError(fmt.Sprintf("immediate %d out of range for 32-bit register", imm), s.Line, s.Column)
+			return 0, false
+		}
+		width = 32
+		value &= 0xFFFFFFFF
+	}
+	mask := uint64(1)<<width - 1
+	if width == 64 {
+		mask = ^uint64(0)
+	}
+
+	if !rd.sp {
+		for hw := 0; hw < width/16; hw++ {
+			if value&^(0xFFFF<<(16*hw)) == 0 {
+				return 0x52800000 | sf | uint32(hw)<<21 | uint32(value>>(16*hw)&0xFFFF)<<5 | rd.num, true
+			}
+		}
+		for hw := 0; hw < width/16; hw++ {
+			if inverted := ^value & mask; inverted&^(0xFFFF<<(16*hw)) == 0 {
+				return 0x12800000 | sf | uint32(hw)<<21 | uint32(inverted>>(16*hw)&0xFFFF)<<5 | rd.num, true
+			}
+		}
+	}
+	if fields, ok := aarch64BitmaskImmediate(value, rd.wide); ok {
+		return 0x320003E0 | sf | fields | rd.num, true
+	}
+
+	g.addError(
+		fmt.Sprintf("immediate %#x cannot be encoded in a single '%s'; use 'ldr %s, =%#x'",
+			imm, s.Mnemonic, s.Operands[0].(*ast.RegisterOperand).Name, imm),
+		s.Line, s.Column,
+	)
+	return 0, false
+}
+
+// encodeShift encodes rd, rn, shift as the lsl (ubfm) or lsr/asr (ubfm/sbfm)
+// bitfield alias.
+func (b aarch64Backend) encodeShift(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant, left bool) (uint32, bool) {
+	rd, ok1 := b.register(g, s.Operands[0], false)
+	rn, ok2 := b.register(g, s.Operands[1], false)
+	shift, ok3 := b.immediate(g, s.Operands[2])
+	if !ok1 || !ok2 || !ok3 {
+		return 0, false
+	}
+	sf, ok := b.sameWidth(g, s, rd, rn)
+	if !ok {
+		return 0, false
+	}
+
+	size := int64(32)
+	if sf != 0 {
+		size = 64
+	}
+	if !g.checkRange(s, shift, 0, size-1, "shift amount") {
+		return 0, false
+	}
+
+	immr, imms := shift, size-1
+	if left {
+		immr, imms = (size-shift)%size, size-1-shift
+	}
+	// The N bit equals sf for the bitfield instructions.
+	return variant.Base | sf | sf>>9 | uint32(immr)<<16 | uint32(imms)<<10 | rn.num<<5 | rd.num, true
+}
+
+// encodeLoadStore encodes rt, [rn, imm] (unsigned or unscaled offset),
+// rt, [rn, imm]! (pre-index) and rt, [rn], imm (post-index). For ldr/str the
+// access size follows rt; for the fixed forms it is held in the base word.
+func (b aarch64Backend) encodeLoadStore(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant, fixed bool) (uint32, bool) {
+	rt, ok := b.register(g, s.Operands[0], false)
+	if !ok {
+		return 0, false
+	}
+
+	word := variant.Base
+	if fixed {
+		// Sign-extending loads (opc = 10) target X registers; the rest W.
+		wantWide := word>>22&0x3 == 0x2
+		if rt.wide != wantWide {
+			g.addError(fmt.Sprintf("'%s' requires a %s register", s.Mnemonic, aarch64WidthName(wantWide)), s.Line, s.Column)
+			return 0, false
+		}
+	} else if rt.wide {
+		word |= aarch64LdStSize64
+	}
+	scale := word >> 30
+
+	rn, offset, writeback, ok := b.memory(g, s.Operands[1])
+	if !ok {
+		return 0, false
+	}
+	word |= rn.num<<5 | rt.num
+	unscaled := word &^ aarch64LdStUnsOff
+
+	// Post-index: [rn], imm.
+	if len(s.Operands) == 3 {
+		if offset != 0 || writeback {
+			g.addError("post-index addressing takes the offset after the brackets: [rn], imm", s.Line, s.Column)
+			return 0, false
+		}
+		imm, ok := b.immediate(g, s.Operands[2])
+		if !ok || !g.checkRange(s, imm, aarch64Imm9Min, aarch64Imm9Max, "post-index offset") {
+			return 0, false
+		}
+		return unscaled | uint32(imm&0x1FF)<<12 | 0x1<<10, true
+	}
+
+	// Pre-index: [rn, imm]!.
+	if writeback {
+		if !g.checkRange(s, offset, aarch64Imm9Min, aarch64Imm9Max, "pre-index offset") {
+			return 0, false
+		}
+		return unscaled | uint32(offset&0x1FF)<<12 | 0x3<<10, true
+	}
+
+	// Unsigned scaled offset, falling back to the unscaled (ldur/stur) form.
+	if offset >= 0 && offset%(1<<scale) == 0 && offset>>scale <= 0xFFF {
+		return word | uint32(offset>>scale)<<10, true
+	}
+	if offset >= aarch64Imm9Min && offset <= aarch64Imm9Max {
+		return unscaled | uint32(offset&0x1FF)<<12, true
+	}
+	g.addError(
+		fmt.Sprintf("offset %d out of range for '%s' (allowed %d to %d, or a multiple of %d up to %d)",
+			offset, s.Mnemonic, aarch64Imm9Min, aarch64Imm9Max, 1<<scale, 0xFFF<<scale),
+		s.Line, s.Column,
+	)
+	return 0, false
+}
+
+// encodeLoadLiteral encodes rt, label and rt, =value. The latter loads from
+// the slot reserved for the value in the section's literal pool.
+func (b aarch64Backend) encodeLoadLiteral(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	rt, ok := b.register(g, s.Operands[0], false)
+	if !ok {
+		return 0, false
+	}
+	word := variant.Base | rt.num
+	if rt.wide {
+		word |= aarch64LdStSize64
+	}
+
+	var target int
+	switch op := s.Operands[1].(type) {
+	case *ast.LiteralOperand:
+		if imm, isImm := op.Value.(*ast.ImmediateOperand); isImm && !rt.wide {
+			if value, err := parseImmediateValue(imm.Value); err == nil && (value < -(1<<31) || value > 1<<32-1) {
+				g.addError(fmt.Sprintf("literal %d out of range for 32-bit register", value), imm.Line, imm.Column)
+				return 0, false
+			}
+		}
+		if target, ok = g.literalAddress(op); !ok {
+			return 0, false
+		}
+	default:
+		offset, ok := b.pcRelative(g, s, op, aarch64Branch19Min, aarch64Branch19Max, true)
+		if !ok {
+			return 0, false
+		}
+		return word | (uint32(offset>>2)&0x7FFFF)<<5, true
+	}
+
+	offset := int64(target - len(g.currentSection().data))
+	if offset < aarch64Branch19Min || offset > aarch64Branch19Max {
+		g.addError(
+			fmt.Sprintf("literal pool out of range for '%s' (offset %d, allowed %d to %d)",
+				s.Mnemonic, offset, aarch64Branch19Min, aarch64Branch19Max),
+			s.Line, s.Column,
+		)
+		return 0, false
+	}
+	return word | (uint32(offset>>2)&0x7FFFF)<<5, true
+}
+
+// encodePair encodes rt1, rt2, [rn, imm] (signed offset), rt1, rt2, [rn, imm]!
+// (pre-index) and rt1, rt2, [rn], imm (post-index).
+func (b aarch64Backend) encodePair(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	rt1, ok1 := b.register(g, s.Operands[0], false)
+	rt2, ok2 := b.register(g, s.Operands[1], false)
+	rn, offset, writeback, ok3 := b.memory(g, s.Operands[2])
+	if !ok1 || !ok2 || !ok3 {
+		return 0, false
+	}
+	sf, ok := b.sameWidth(g, s, rt1, rt2)
+	if !ok {
+		return 0, false
+	}
+
+	word := variant.Base | sf | rt2.num<<10 | rn.num<<5 | rt1.num
+	switch {
+	case len(s.Operands) == 4:
+		if offset != 0 || writeback {
+			g.addError("post-index addressing takes the offset after the brackets: [rn], imm", s.Line, s.Column)
+			return 0, false
+		}
+		if offset, ok = b.immediate(g, s.Operands[3]); !ok {
+			return 0, false
+		}
+		word = word&^aarch64LdStUnsOff | aarch64PairPre
+	case writeback:
+		word |= aarch64PairPre
+	}
+
+	scale := int64(4)
+	if sf != 0 {
+		scale = 8
+	}
+	if offset%scale != 0 || offset < -64*scale || offset > 63*scale {
+		g.addError(
+			fmt.Sprintf("offset %d out of range for '%s' (allowed multiples of %d from %d to %d)",
+				offset, s.Mnemonic, scale, -64*scale, 63*scale),
+			s.Line, s.Column,
+		)
+		return 0, false
+	}
+	return word | (uint32(offset/scale)&0x7F)<<15, true
+}
+
+// encodeCompareBranch encodes rt, label for cbz/cbnz.
+func (b aarch64Backend) encodeCompareBranch(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	rt, ok := b.register(g, s.Operands[0], false)
+	if !ok {
+		return 0, false
+	}
+	offset, ok := b.pcRelative(g, s, s.Operands[1], aarch64Branch19Min, aarch64Branch19Max, true)
+	if !ok {
+		return 0, false
+	}
+	return variant.Base | b.sf(rt) | (uint32(offset>>2)&0x7FFFF)<<5 | rt.num, true
+}
+
+// encodeBranchReg encodes br/blr/ret with an X register target.
+func (b aarch64Backend) encodeBranchReg(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	rn, ok := b.register(g, s.Operands[0], false)
+	if !ok {
+		return 0, false
+	}
+	if !rn.wide {
+		g.addError(fmt.Sprintf("'%s' requires a 64-bit register", s.Mnemonic), s.Line, s.Column)
+		return 0, false
+	}
+	return variant.Base | rn.num<<5, true
+}
+
+// encodeAdr encodes rd, label for adr (byte offset) and adrp (4 KiB page
+// offset).
+func (b aarch64Backend) encodeAdr(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	rd, ok := b.register(g, s.Operands[0], false)
+	if !ok {
+		return 0, false
+	}
+	if !rd.wide {
+		g.addError(fmt.Sprintf("'%s' requires a 64-bit register", s.Mnemonic), s.Line, s.Column)
+		return 0, false
+	}
+
+	var offset int64
+	if variant.Encoding == aarch64.FormatAdrp {
+		id, isID := s.Operands[1].(*ast.IdentifierOperand)
+		if !isID {
+			g.addError(fmt.Sprintf("expected label operand, got %T", s.Operands[1]), s.Line, s.Column)
+			return 0, false
+		}
+		target, ok := g.resolveLabel(id.Name, id.Line, id.Column)
+		if !ok {
+			return 0, false
+		}
+		offset = int64(target>>12 - len(g.currentSection().data)>>12)
+		if !g.checkRange(s, offset, aarch64AdrpMin, aarch64AdrpMax, "page offset") {
+			return 0, false
+		}
+	} else {
+		if offset, ok = b.pcRelative(g, s, s.Operands[1], aarch64AdrMin, aarch64AdrMax, false); !ok {
+			return 0, false
+		}
+	}
+
+	u := uint32(offset)
+	return variant.Base | (u&0x3)<<29 | (u>>2&0x7FFFF)<<5 | rd.num, true
+}
+
+// encodeException encodes an imm16 operand (svc, brk).
+func (b aarch64Backend) encodeException(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	imm, ok := b.immediate(g, s.Operands[0])
+	if !ok || !g.checkRange(s, imm, 0, 0xFFFF, "16-bit immediate") {
+		return 0, false
+	}
+	return variant.Base | uint32(imm)<<5, true
+}
+
+// ---------------------------------------------------------------------------
+// Logical immediates
+// ---------------------------------------------------------------------------
+
+// aarch64BitmaskImmediate returns the N:immr:imms fields (already placed at
+// bits 22, 21:16 and 15:10) for value, or false when value is not a
+// replicated, rotated run of ones. 32-bit values are replicated to 64 bits
+// first.
+func aarch64BitmaskImmediate(value uint64, wide bool) (uint32, bool) {
+	if !wide {
+		value &= 0xFFFFFFFF
+		value |= value << 32
+	}
+	if value == 0 || value == ^uint64(0) {
+		return 0, false
+	}
+
+	// Find the smallest element size that the value is a replication of.
+	size := uint(64)
+	for size > 2 {
+		half := size / 2
+		mask := uint64(1)<<half - 1
+		if value&mask != value>>half&mask {
+			break
+		}
+		size = half
+	}
+
+	mask := ^uint64(0)
+	if size < 64 {
+		mask = uint64(1)<<size - 1
+	}
+	element := value & mask
+	ones := uint(bits.OnesCount64(element))
+	pattern := uint64(1)<<ones - 1
+
+	// The element must be a rotation of a run of ones.
+	for r := uint(0); r < size; r++ {
+		rotated := (element>>r | element<<(size-r)) & mask
+		if rotated != pattern {
+			continue
+		}
+		immr := (size - r) % size
+		imms := (^(size-1)<<1)&0x3F | (ones - 1)
+		var n uint32
+		if size == 64 {
+			n = 1
+		}
+		return n<<22 | uint32(immr)<<16 | uint32(imms)<<10, true
+	}
+	return 0, false
+}
+
+// ---------------------------------------------------------------------------
+// Operand helpers
+// ---------------------------------------------------------------------------
+
+// register returns a register operand. allowSP selects how number 31 is
+// interpreted by the field: the stack pointer (allowSP) or the zero register.
+func (b aarch64Backend) register(g *Generator, op ast.Operand, allowSP bool) (aarch64Register, bool) {
+	reg, ok := op.(*ast.RegisterOperand)
+	if !ok {
+		g.addError(
+			fmt.Sprintf("expected register operand, got %T", op),
+			op.OperandLine(), op.OperandColumn(),
+		)
+		return aarch64Register{}, false
+	}
+	return b.lookupRegister(g, reg.Name, reg.Line, reg.Column, allowSP)
+}
+
+// lookupRegister resolves a register name and checks it against the field's
+// interpretation of number 31.
+func (b aarch64Backend) lookupRegister(g *Generator, name string, line, column int, allowSP bool) (aarch64Register, bool) {
+	r, exists := aarch64Registers[strings.ToUpper(name)]
+	if !exists {
+		g.addError(fmt.Sprintf("unknown register '%s'", name), line, column)
+		return aarch64Register{}, false
+	}
+	if r.sp && !allowSP {
+		g.addError(fmt.Sprintf("stack pointer '%s' is not valid in this position", name), line, column)
+		return aarch64Register{}, false
+	}
+	if r.zr && allowSP {
+		g.addError(fmt.Sprintf("zero register '%s' is not valid in this position", name), line, column)
+		return aarch64Register{}, false
+	}
+	return r, true
+}
+
+// registers returns every operand as a register that is not the stack
+// pointer.
+func (b aarch64Backend) registers(g *Generator, ops []ast.Operand) ([]aarch64Register, bool) {
+	regs := make([]aarch64Register, len(ops))
+	for i, op := range ops {
+		r, ok := b.register(g, op, false)
+		if !ok {
+			return nil, false
+		}
+		regs[i] = r
+	}
+	return regs, true
+}
+
+// sf returns the 64-bit size flag for a register.
+func (b aarch64Backend) sf(r aarch64Register) uint32 {
+	if r.wide {
+		return aarch64SF
+	}
+	return 0
+}
+
+// sameWidth checks that all registers are of the same width and returns the
+// matching size flag.
+func (b aarch64Backend) sameWidth(g *Generator, s *ast.InstructionStmt, regs ...aarch64Register) (uint32, bool) {
+	for _, r := range regs[1:] {
+		if r.wide != regs[0].wide {
+			g.addError(
+				fmt.Sprintf("'%s' cannot mix 32-bit and 64-bit registers", s.Mnemonic),
+				s.Line, s.Column,
+			)
+			return 0, false
+		}
+	}
+	return b.sf(regs[0]), true
+}
+
+// immediate returns the value of an immediate or %lo12 relocation operand.
+func (b aarch64Backend) immediate(g *Generator, op ast.Operand) (int64, bool) {
+	if rel, ok := op.(*ast.RelocationOperand); ok {
+		return b.relocation(g, rel.Function, rel.Symbol, rel.Line, rel.Column)
+	}
+	return g.parseImmediate(op, op.OperandLine(), op.OperandColumn())
+}
+
+// relocation resolves %lo12(symbol): the offset of the symbol within its
+// 4 KiB page, to be paired with adrp.
+func (b aarch64Backend) relocation(g *Generator, function, symbol string, line, column int) (int64, bool) {
+	if function != "lo12" {
+		g.addError(fmt.Sprintf("relocation function '%%%s' is not supported on aarch64", function), line, column)
+		return 0, false
+	}
+	addr, ok := g.resolveLabel(symbol, line, column)
+	if !ok {
+		return 0, false
+	}
+	return int64(addr) & 0xFFF, true
+}
+
+// memory decodes a memory operand of the form [rn], [rn, imm], [rn + imm],
+// [rn - imm], [rn, NAME.field] or [rn, %lo12(symbol)], optionally followed by '!' for
+// pre-index writeback.
+func (b aarch64Backend) memory(g *Generator, op ast.Operand) (aarch64Register, int64, bool, bool) {
+	mem, ok := op.(*ast.MemoryOperand)
+	if !ok {
+		g.addError(fmt.Sprintf("expected memory operand, got %T", op), op.OperandLine(), op.OperandColumn())
+		return aarch64Register{}, 0, false, false
+	}
+
+	components := mem.Components
+	writeback := false
+	if n := len(components); n > 0 && components[n-1].Token.Literal == "!" {
+		writeback = true
+		components = components[:n-1]
+	}
+	if len(components) == 0 {
+		g.addError("empty memory operand", mem.Line, mem.Column)
+		return aarch64Register{}, 0, false, false
+	}
+
+	base := components[0].Token
+	if base.Type != TokenRegister {
+		g.addError("memory operand base must be a register", mem.Line, mem.Column)
+		return aarch64Register{}, 0, false, false
+	}
+	rn, ok := b.lookupRegister(g, base.Literal, base.Line, base.Column, true)
+	if !ok {
+		return aarch64Register{}, 0, false, false
+	}
+	if !rn.wide {
+		g.addError("memory operand base must be a 64-bit register", base.Line, base.Column)
+		return aarch64Register{}, 0, false, false
+	}
+
+	rest := components[1:]
+	if len(rest) == 0 {
+		return rn, 0, writeback, true
+	}
+
+	sign := int64(1)
+	switch rest[0].Token.Literal {
+	case ",", "+":
+	case "-":
+		sign = -1
+	default:
+		g.addError("expected ',', '+' or '-' after memory operand base register", mem.Line, mem.Column)
+		return aarch64Register{}, 0, false, false
+	}
+	rest = rest[1:]
+
+	// [rn, imm] or [rn, NAME.field]
+	if len(rest) == 1 && (rest[0].Token.Type == TokenImmediate || rest[0].Token.Type == TokenIdentifier) {
+		disp, ok := g.displacement(rest[0].Token)
+		if !ok {
+			return aarch64Register{}, 0, false, false
+		}
+		return rn, sign * disp, writeback, true
+	}
+
+	// [rn, %lo12(symbol)]
+	if len(rest) == 4 && isRelocationFunction(rest[0].Token) &&
+		rest[1].Token.Literal == "(" && rest[3].Token.Literal == ")" {
+		fn := strings.ToLower(rest[0].Token.Literal[1:])
+		disp, ok := b.relocation(g, fn, rest[2].Token.Literal, rest[0].Token.Line, rest[0].Token.Column)
+		if !ok {
+			return aarch64Register{}, 0, false, false
+		}
+		return rn, sign * disp, writeback, true
+	}
+
+	g.addError("unsupported memory operand offset", mem.Line, mem.Column)
+	return aarch64Register{}, 0, false, false
+}
+
+// pcRelative resolves a label operand to its offset from the current
+// instruction and checks it against the format's reach. Branch targets must
+// be word aligned.
+func (b aarch64Backend) pcRelative(g *Generator, s *ast.InstructionStmt, op ast.Operand, min, max int64, aligned bool) (int64, bool) {
+	id, ok := op.(*ast.IdentifierOperand)
+	if !ok {
+		g.addError(fmt.Sprintf("expected label operand, got %T", op), op.OperandLine(), op.OperandColumn())
+		return 0, false
+	}
+	target, ok := g.resolveLabel(id.Name, id.Line, id.Column)
+	if !ok {
+		return 0, false
+	}
+
+	offset := int64(target - len(g.currentSection().data))
+	if offset < min || offset > max {
+		g.addError(
+			fmt.Sprintf("branch target '%s' out of range for '%s' (offset %d, allowed %d to %d)",
+				id.Name, s.Mnemonic, offset, min, max),
+			id.Line, id.Column,
+		)
+		return 0, false
+	}
+	if aligned && offset%4 != 0 {
+		g.addError(
+			fmt.Sprintf("branch target '%s' is not 4-byte aligned (offset %d)", id.Name, offset),
+			id.Line, id.Column,
+		)
+		return 0, false
+	}
+	return offset, true
+}
+
+// aarch64WidthName returns "64-bit" or "32-bit".
+func aarch64WidthName(wide bool) string {
+	if wide {
+		return "64-bit"
+	}
+	return "32-bit"
+}
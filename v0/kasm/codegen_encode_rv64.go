@@ -0,0 +1,426 @@
+package kasm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/keurnel/assembler/v0/architecture/riscv/rv64"
+	"github.com/keurnel/assembler/v0/kasm/ast"
+)
+
+// ---------------------------------------------------------------------------
+// RISC-V RV64 register encoding table
+// ---------------------------------------------------------------------------
+
+// rv64RegisterNumber maps upper-case RV64 register names — numeric (X0–X31)
+// and ABI names — to their 5-bit encoding numbers.
+var rv64RegisterNumber = func() map[string]uint32 {
+	registers := map[string]uint32{
+		"ZERO": 0, "RA": 1, "SP": 2, "GP": 3, "TP": 4,
+		"T0": 5, "T1": 6, "T2": 7,
+		"S0": 8, "FP": 8, "S1": 9,
+		"A0": 10, "A1": 11, "A2": 12, "A3": 13, "A4": 14, "A5": 15, "A6": 16, "A7": 17,
+		"S2": 18, "S3": 19, "S4": 20, "S5": 21, "S6": 22, "S7": 23,
+		"S8": 24, "S9": 25, "S10": 26, "S11": 27,
+		"T3": 28, "T4": 29, "T5": 30, "T6": 31,
+	}
+	for i := uint32(0); i < 32; i++ {
+		registers["X"+strconv.Itoa(int(i))] = i
+	}
+	return registers
+}()
+
+// Immediate and branch ranges of the RV64 instruction formats.
+const (
+	rv64Imm12Min  = -2048
+	rv64Imm12Max  = 2047
+	rv64BranchMin = -4096
+	rv64BranchMax = 4094
+	rv64JumpMin   = -1048576
+	rv64JumpMax   = 1048574
+)
+
+// ---------------------------------------------------------------------------
+// RV64 backend
+// ---------------------------------------------------------------------------
+
+// rv64Backend encodes instructions as fixed 32-bit little-endian RISC-V words.
+// Each variant carries its opcode and function fields in Base; the backend
+// ORs register numbers and immediates into the format-specific bit positions.
+type rv64Backend struct{}
+
+func init() {
+	RegisterBackend(RV64Backend())
+}
+
+// RV64Backend returns the code generation backend for RISC-V RV64I/M/A.
+func RV64Backend() Backend {
+	return rv64Backend{}
+}
+
+func (b rv64Backend) Name() string { return "rv64" }
+
+// instructionSize returns the variant size — always 4 bytes for RV64.
+func (b rv64Backend) instructionSize(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) int {
+	return int(variant.Size)
+}
+
+// encodeInstruction encodes the instruction word. On error the word is left
+// zero so that the instruction still occupies its 4 bytes and subsequent
+// label offsets stay valid.
+func (b rv64Backend) encodeInstruction(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) []byte {
+	encoded := make([]byte, 4)
+	if word, ok := b.encodeWord(g, s, variant); ok {
+		binary.LittleEndian.PutUint32(encoded, word)
+	}
+	return encoded
+}
+
+// encodeWord dispatches on the variant's instruction format.
+func (b rv64Backend) encodeWord(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	switch variant.Encoding {
+	case rv64.FormatR:
+		return b.encodeR(g, s, variant)
+	case rv64.FormatI:
+		return b.encodeI(g, s, variant)
+	case rv64.FormatShift:
+		return b.encodeShift(g, s, variant, 63)
+	case rv64.FormatShiftW:
+		return b.encodeShift(g, s, variant, 31)
+	case rv64.FormatS:
+		return b.encodeS(g, s, variant)
+	case rv64.FormatB:
+		return b.encodeB(g, s, variant)
+	case rv64.FormatU:
+		return b.encodeU(g, s, variant)
+	case rv64.FormatJ:
+		return b.encodeJ(g, s, variant)
+	case rv64.FormatAtomic:
+		return b.encodeAtomic(g, s, variant)
+	case rv64.FormatNone:
+		return variant.Base, true
+	default:
+		g.addError(
+			fmt.Sprintf("unsupported encoding '%s' for '%s'", variant.Encoding, s.Mnemonic),
+			s.Line, s.Column,
+		)
+		return 0, false
+	}
+}
+
+// encodeR encodes rd, rs1, rs2.
+func (b rv64Backend) encodeR(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	rd, ok1 := b.register(g, s.Operands[0])
+	rs1, ok2 := b.register(g, s.Operands[1])
+	rs2, ok3 := b.register(g, s.Operands[2])
+	if !ok1 || !ok2 || !ok3 {
+		return 0, false
+	}
+	return variant.Base | rd<<7 | rs1<<15 | rs2<<20, true
+}
+
+// encodeI encodes the I-type shapes: rd, rs1, imm — rd, [rs1 + imm] —
+// rd, rs1 (immediate taken from the base word) — rd, imm (rs1 taken from the
+// base word).
+func (b rv64Backend) encodeI(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	rd, ok := b.register(g, s.Operands[0])
+	if !ok {
+		return 0, false
+	}
+	word := variant.Base | rd<<7
+
+	var imm int64
+	for _, op := range s.Operands[1:] {
+		switch op.(type) {
+		case *ast.RegisterOperand:
+			rs1, ok := b.register(g, op)
+			if !ok {
+				return 0, false
+			}
+			word |= rs1 << 15
+		case *ast.MemoryOperand:
+			rs1, disp, ok := b.memory(g, op)
+			if !ok {
+				return 0, false
+			}
+			word |= rs1 << 15
+			imm = disp
+		default:
+			value, ok := b.immediate(g, op)
+			if !ok {
+				return 0, false
+			}
+			imm = value
+		}
+	}
+
+	if !g.checkRange(s, imm, rv64Imm12Min, rv64Imm12Max, "12-bit immediate") {
+		return 0, false
+	}
+	return word | uint32(imm&0xFFF)<<20, true
+}
+
+// encodeShift encodes rd, rs1, shamt where shamt must not exceed maxShamt.
+func (b rv64Backend) encodeShift(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant, maxShamt int64) (uint32, bool) {
+	rd, ok1 := b.register(g, s.Operands[0])
+	rs1, ok2 := b.register(g, s.Operands[1])
+	shamt, ok3 := b.immediate(g, s.Operands[2])
+	if !ok1 || !ok2 || !ok3 {
+		return 0, false
+	}
+	if !g.checkRange(s, shamt, 0, maxShamt, "shift amount") {
+		return 0, false
+	}
+	return variant.Base | rd<<7 | rs1<<15 | uint32(shamt)<<20, true
+}
+
+// encodeS encodes rs2, [rs1 + imm].
+func (b rv64Backend) encodeS(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	rs2, ok1 := b.register(g, s.Operands[0])
+	rs1, imm, ok2 := b.memory(g, s.Operands[1])
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	if !g.checkRange(s, imm, rv64Imm12Min, rv64Imm12Max, "12-bit offset") {
+		return 0, false
+	}
+	u := uint32(imm)
+	return variant.Base | (u&0x1F)<<7 | rs1<<15 | rs2<<20 | (u>>5&0x7F)<<25, true
+}
+
+// encodeB encodes rs1, rs2, label — or rs1, label with rs2 = x0.
+func (b rv64Backend) encodeB(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	rs1, ok := b.register(g, s.Operands[0])
+	if !ok {
+		return 0, false
+	}
+	var rs2 uint32
+	if len(s.Operands) == 3 {
+		if rs2, ok = b.register(g, s.Operands[1]); !ok {
+			return 0, false
+		}
+	}
+
+	offset, ok := b.pcRelative(g, s, s.Operands[len(s.Operands)-1], rv64BranchMin, rv64BranchMax)
+	if !ok {
+		return 0, false
+	}
+	u := uint32(offset)
+	return variant.Base |
+		(u>>11&0x1)<<7 | (u>>1&0xF)<<8 | rs1<<15 | rs2<<20 |
+		(u>>5&0x3F)<<25 | (u>>12&0x1)<<31, true
+}
+
+// encodeU encodes rd, imm[31:12]. The immediate is the 20-bit upper value
+// (e.g. the result of %hi(label)).
+func (b rv64Backend) encodeU(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	rd, ok1 := b.register(g, s.Operands[0])
+	imm, ok2 := b.immediate(g, s.Operands[1])
+	if !ok1 || !ok2 {
+		return 0, false
+	}
+	if !g.checkRange(s, imm, -0x80000, 0xFFFFF, "20-bit upper immediate") {
+		return 0, false
+	}
+	return variant.Base | rd<<7 | uint32(imm&0xFFFFF)<<12, true
+}
+
+// encodeJ encodes rd, label — or label alone, with rd taken from the base word.
+func (b rv64Backend) encodeJ(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	word := variant.Base
+	if len(s.Operands) == 2 {
+		rd, ok := b.register(g, s.Operands[0])
+		if !ok {
+			return 0, false
+		}
+		word |= rd << 7
+	}
+
+	offset, ok := b.pcRelative(g, s, s.Operands[len(s.Operands)-1], rv64JumpMin, rv64JumpMax)
+	if !ok {
+		return 0, false
+	}
+	u := uint32(offset)
+	return word |
+		(u>>12&0xFF)<<12 | (u>>11&0x1)<<20 | (u>>1&0x3FF)<<21 | (u>>20&0x1)<<31, true
+}
+
+// encodeAtomic encodes rd, rs2, [rs1] — or rd, [rs1] for load-reserved.
+func (b rv64Backend) encodeAtomic(g *Generator, s *ast.InstructionStmt, variant *InstructionVariant) (uint32, bool) {
+	rd, ok := b.register(g, s.Operands[0])
+	if !ok {
+		return 0, false
+	}
+	var rs2 uint32
+	if len(s.Operands) == 3 {
+		if rs2, ok = b.register(g, s.Operands[1]); !ok {
+			return 0, false
+		}
+	}
+
+	rs1, disp, ok := b.memory(g, s.Operands[len(s.Operands)-1])
+	if !ok {
+		return 0, false
+	}
+	if disp != 0 {
+		g.addError(
+			fmt.Sprintf("'%s' does not accept a memory displacement", s.Mnemonic),
+			s.Line, s.Column,
+		)
+		return 0, false
+	}
+	return variant.Base | rd<<7 | rs1<<15 | rs2<<20, true
+}
+
+// ---------------------------------------------------------------------------
+// Operand helpers
+// ---------------------------------------------------------------------------
+
+// register returns the encoding number of a register operand.
+func (b rv64Backend) register(g *Generator, op ast.Operand) (uint32, bool) {
+	reg, ok := op.(*ast.RegisterOperand)
+	if !ok {
+		g.addError(
+			fmt.Sprintf("expected register operand, got %T", op),
+			op.OperandLine(), op.OperandColumn(),
+		)
+		return 0, false
+	}
+	num, exists := rv64RegisterNumber[strings.ToUpper(reg.Name)]
+	if !exists {
+		g.addError(
+			fmt.Sprintf("unknown register '%s'", reg.Name),
+			reg.Line, reg.Column,
+		)
+		return 0, false
+	}
+	return num, true
+}
+
+// immediate returns the value of an immediate or relocation operand.
+func (b rv64Backend) immediate(g *Generator, op ast.Operand) (int64, bool) {
+	if rel, ok := op.(*ast.RelocationOperand); ok {
+		return b.relocation(g, rel.Function, rel.Symbol, rel.Line, rel.Column)
+	}
+	return g.parseImmediate(op, op.OperandLine(), op.OperandColumn())
+}
+
+// relocation resolves %hi(symbol) / %lo(symbol). The symbol address is its
+// offset within the current section. %hi is rounded so that adding the
+// sign-extended %lo to it yields the full address.
+func (b rv64Backend) relocation(g *Generator, function, symbol string, line, column int) (int64, bool) {
+	addr, ok := g.resolveLabel(symbol, line, column)
+	if !ok {
+		return 0, false
+	}
+	hi := (int64(addr) + 0x800) >> 12
+	switch function {
+	case "hi":
+		return hi & 0xFFFFF, true
+	case "lo":
+		return int64(addr) - hi<<12, true
+	default:
+		g.addError(fmt.Sprintf("unknown relocation function '%%%s'", function), line, column)
+		return 0, false
+	}
+}
+
+// memory decodes a memory operand of the form [rs1], [rs1 + imm],
+// [rs1 - imm], [rs1 + NAME.field] or [rs1 + %lo(symbol)] into its base
+// register and displacement.
+func (b rv64Backend) memory(g *Generator, op ast.Operand) (uint32, int64, bool) {
+	mem, ok := op.(*ast.MemoryOperand)
+	if !ok {
+		g.addError(fmt.Sprintf("expected memory operand, got %T", op), op.OperandLine(), op.OperandColumn())
+		return 0, 0, false
+	}
+	if len(mem.Components) == 0 {
+		g.addError("empty memory operand", mem.Line, mem.Column)
+		return 0, 0, false
+	}
+
+	base := mem.Components[0].Token
+	if base.Type != TokenRegister {
+		g.addError("memory operand base must be a register", mem.Line, mem.Column)
+		return 0, 0, false
+	}
+	rs1, ok := b.register(g, &ast.RegisterOperand{Name: base.Literal, Line: base.Line, Column: base.Column})
+	if !ok {
+		return 0, 0, false
+	}
+
+	rest := mem.Components[1:]
+	if len(rest) == 0 {
+		return rs1, 0, true
+	}
+
+	sign := int64(1)
+	switch rest[0].Token.Literal {
+	case "+":
+	case "-":
+		sign = -1
+	default:
+		g.addError("expected '+' or '-' after memory operand base register", mem.Line, mem.Column)
+		return 0, 0, false
+	}
+	rest = rest[1:]
+
+	// [rs1 + imm] or [rs1 + NAME.field]
+	if len(rest) == 1 && (rest[0].Token.Type == TokenImmediate || rest[0].Token.Type == TokenIdentifier) {
+		disp, ok := g.displacement(rest[0].Token)
+		if !ok {
+			return 0, 0, false
+		}
+		return rs1, sign * disp, true
+	}
+
+	// [rs1 + %lo(symbol)]
+	if len(rest) == 4 && isRelocationFunction(rest[0].Token) &&
+		rest[1].Token.Literal == "(" && rest[3].Token.Literal == ")" {
+		fn := strings.ToLower(rest[0].Token.Literal[1:])
+		disp, ok := b.relocation(g, fn, rest[2].Token.Literal, rest[0].Token.Line, rest[0].Token.Column)
+		if !ok {
+			return 0, 0, false
+		}
+		return rs1, sign * disp, true
+	}
+
+	g.addError("unsupported memory operand displacement", mem.Line, mem.Column)
+	return 0, 0, false
+}
+
+// pcRelative resolves a label operand to its offset from the current
+// instruction and checks it against the format's reach. Offsets must be even
+// because RISC-V instructions are at least 2-byte aligned.
+func (b rv64Backend) pcRelative(g *Generator, s *ast.InstructionStmt, op ast.Operand, min, max int64) (int64, bool) {
+	id, ok := op.(*ast.IdentifierOperand)
+	if !ok {
+		g.addError(fmt.Sprintf("expected label operand, got %T", op), op.OperandLine(), op.OperandColumn())
+		return 0, false
+	}
+	target, ok := g.resolveLabel(id.Name, id.Line, id.Column)
+	if !ok {
+		return 0, false
+	}
+
+	pc := len(g.currentSection().data)
+	offset := int64(target - pc)
+	if offset < min || offset > max {
+		g.addError(
+			fmt.Sprintf("branch target '%s' out of range for '%s' (offset %d, allowed %d to %d)",
+				id.Name, s.Mnemonic, offset, min, max),
+			id.Line, id.Column,
+		)
+		return 0, false
+	}
+	if offset%2 != 0 {
+		g.addError(
+			fmt.Sprintf("branch target '%s' is not 2-byte aligned (offset %d)", id.Name, offset),
+			id.Line, id.Column,
+		)
+		return 0, false
+	}
+	return offset, true
+}
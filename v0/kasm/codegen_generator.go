@@ -1,6 +1,8 @@
 package kasm
 
 import (
+	"fmt"
+
 	"github.com/keurnel/assembler/internal/debugcontext"
 	"github.com/keurnel/assembler/v0/architecture"
 	"github.com/keurnel/assembler/v0/kasm/ast"
@@ -13,17 +15,26 @@ import (
 type Generator struct {
 	program      *ast.Program
 	instructions map[string]architecture.Instruction
+	backend      Backend
 	labels       map[string]labelEntry
 	sections     map[string]*sectionBuffer
+	pools        map[string]*literalPool
+	binaries     map[string][]byte
+	incbins      map[*ast.IncbinStmt][]byte
+	strucs       map[string]strucLayout
+	strucSymbols map[string]int64
+	istrucs      map[*ast.IstrucStmt][]byte
 	current      string // current section name
 	errors       []CodegenError
 	debugCtx     *debugcontext.DebugContext
+	lineMapper   LineMapper
 }
 
 // GeneratorNew is the sole constructor. It accepts the validated *ast.Program AST
 // and an instruction lookup table (upper-case mnemonic keys), and returns a
 // *Generator ready for Generate() to be called. GeneratorNew is infallible —
-// it cannot fail. A nil program is treated as empty (FR-1.2).
+// it cannot fail. A nil program is treated as empty (FR-1.2). The generator
+// encodes for x86_64 unless another backend is attached with WithBackend.
 func GeneratorNew(program *ast.Program, instructions map[string]architecture.Instruction) *Generator {
 	if program == nil {
 		program = &ast.Program{Statements: make([]ast.Statement, 0)}
@@ -34,8 +45,15 @@ func GeneratorNew(program *ast.Program, instructions map[string]architecture.Ins
 	return &Generator{
 		program:      program,
 		instructions: instructions,
+		backend:      X8664Backend(),
 		labels:       make(map[string]labelEntry),
 		sections:     make(map[string]*sectionBuffer),
+		pools:        make(map[string]*literalPool),
+		binaries:     make(map[string][]byte),
+		incbins:      make(map[*ast.IncbinStmt][]byte),
+		strucs:       make(map[string]strucLayout),
+		strucSymbols: make(map[string]int64),
+		istrucs:      make(map[*ast.IstrucStmt][]byte),
 		current:      "",
 		errors:       make([]CodegenError, 0),
 	}
@@ -50,9 +68,27 @@ func (g *Generator) WithDebugContext(ctx *debugcontext.DebugContext) *Generator
 	return g
 }
 
+// WithBackend selects the architecture backend used to encode instructions.
+// A nil backend keeps the current one. Returns the generator for chaining.
+func (g *Generator) WithBackend(b Backend) *Generator {
+	if b != nil {
+		g.backend = b
+	}
+	return g
+}
+
+// WithLineMapper attaches a LineMapper that translates pre-processed line
+// numbers back to original source line numbers, as for the analyser. When
+// set, every error recorded via debugCtx uses the mapped line number. Returns
+// the generator for chaining.
+func (g *Generator) WithLineMapper(m LineMapper) *Generator {
+	g.lineMapper = m
+	return g
+}
+
 // addError records a code generation error at the given position. If a debug
-// context is attached, the error is also recorded there. The generator never
-// panics (AR-4.2).
+// context is attached, the error is also recorded there, at the original
+// line if a LineMapper is attached. The generator never panics (AR-4.2).
 func (g *Generator) addError(message string, line, column int) {
 	g.errors = append(g.errors, CodegenError{
 		Message: message,
@@ -61,8 +97,28 @@ func (g *Generator) addError(message string, line, column int) {
 	})
 	if g.debugCtx != nil {
 		g.debugCtx.Error(
-			g.debugCtx.Loc(line, column),
+			g.debugCtx.Loc(g.mapLine(line), column),
 			message,
 		)
 	}
 }
+
+// mapLine translates a pre-processed 1-based line number to the original
+// line number using the attached LineMapper.
+func (g *Generator) mapLine(line int) int {
+	return originLine(g.lineMapper, line)
+}
+
+// checkRange records an error for the instruction s when value, the operand
+// field described by what, lies outside [min, max]. Used by the backends to
+// check immediates, offsets and shift amounts before encoding them.
+func (g *Generator) checkRange(s *ast.InstructionStmt, value, min, max int64, what string) bool {
+	if value < min || value > max {
+		g.addError(
+			fmt.Sprintf("%s %d out of range for '%s' (allowed %d to %d)", what, value, s.Mnemonic, min, max),
+			s.Line, s.Column,
+		)
+		return false
+	}
+	return true
+}
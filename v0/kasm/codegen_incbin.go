@@ -0,0 +1,77 @@
+package kasm
+
+import (
+	"fmt"
+
+	"github.com/keurnel/assembler/v0/kasm/ast"
+)
+
+// WithBinaries supplies the contents of the files embedded by %incbin
+// directives, keyed by the path as written in the directive. The generator
+// performs no file I/O (AR-2.4); the orchestrator reads the files. Returns
+// the generator for chaining.
+func (g *Generator) WithBinaries(files map[string][]byte) *Generator {
+	if files != nil {
+		g.binaries = files
+	}
+	return g
+}
+
+// collectIncbin selects the bytes embedded by an %incbin directive and
+// records them for the emission pass, so that they count towards the label
+// offsets that follow (FR-10.2). Errors are recorded via addError.
+func (g *Generator) collectIncbin(s *ast.IncbinStmt) int {
+	content, exists := g.binaries[s.Path]
+	if !exists {
+		g.addError(fmt.Sprintf("no contents supplied for %%incbin file '%s'", s.Path), s.Line, s.Column)
+		return 0
+	}
+
+	offset := int64(0)
+	if s.Offset != nil {
+		value, ok := g.parseImmediate(s.Offset, s.Offset.Line, s.Offset.Column)
+		if !ok {
+			return 0
+		}
+		offset = value
+	}
+	if offset < 0 || offset > int64(len(content)) {
+		g.addError(
+			fmt.Sprintf("%%incbin offset %d is outside of '%s' (%d byte(s))", offset, s.Path, len(content)),
+			s.Line, s.Column,
+		)
+		return 0
+	}
+	content = content[offset:]
+
+	// FR-10.3: A length beyond the end of the file is clipped to the file.
+	if s.Length != nil {
+		length, ok := g.parseImmediate(s.Length, s.Length.Line, s.Length.Column)
+		if !ok {
+			return 0
+		}
+		if length < 0 {
+			g.addError(fmt.Sprintf("%%incbin length must not be negative, got %d", length), s.Line, s.Column)
+			return 0
+		}
+		if length < int64(len(content)) {
+			content = content[:length]
+		}
+	}
+
+	g.incbins[s] = content
+	return len(content)
+}
+
+// emitIncbin appends the bytes selected for an %incbin directive in the
+// collection pass to the current section buffer.
+func (g *Generator) emitIncbin(s *ast.IncbinStmt) {
+	sec := g.currentSection()
+	content, exists := g.incbins[s]
+	if sec == nil || !exists {
+		return
+	}
+
+	sec.data = append(sec.data, content...)
+	sec.size += len(content)
+}
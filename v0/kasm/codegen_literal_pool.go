@@ -0,0 +1,159 @@
+package kasm
+
+import (
+	"encoding/binary"
+	"fmt"
+	"sort"
+
+	"github.com/keurnel/assembler/v0/kasm/ast"
+)
+
+// ---------------------------------------------------------------------------
+// Internal types (literalPool)
+// ---------------------------------------------------------------------------
+
+// literalSlotSize is the size of a single literal pool entry. Every entry is
+// a 64-bit little-endian value; 32-bit loads read its low half.
+const literalSlotSize = 8
+
+// literalPool collects the constants referenced through `=value` operands in
+// a single section. The pool is placed directly after the section's code,
+// aligned to literalSlotSize. Identical values share one slot.
+type literalPool struct {
+	entries []*ast.LiteralOperand // first operand for each slot, in order of first use
+	slots   map[string]int        // literal key → slot index
+	base    int                   // section offset of the pool, set at the end of Pass 1
+}
+
+// literalKey returns the de-duplication key of a literal operand. Immediates
+// are keyed by value so that `=16` and `=0x10` share a slot; labels are keyed
+// by name. Malformed immediates return false and are reported in Pass 2.
+func literalKey(op *ast.LiteralOperand) (string, bool) {
+	switch v := op.Value.(type) {
+	case *ast.ImmediateOperand:
+		value, err := parseImmediateValue(v.Value)
+		if err != nil {
+			return "", false
+		}
+		return fmt.Sprintf("#%d", value), true
+	case *ast.IdentifierOperand:
+		return "@" + v.Name, true
+	default:
+		return "", false
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Pool layout (Pass 1)
+// ---------------------------------------------------------------------------
+
+// reserveLiteral allocates a pool slot in the current section for the
+// operand's value, unless an identical value already has one. Backends call
+// it while sizing instructions in Pass 1.
+func (g *Generator) reserveLiteral(op *ast.LiteralOperand) {
+	if g.current == "" {
+		return
+	}
+	key, ok := literalKey(op)
+	if !ok {
+		return
+	}
+
+	pool, exists := g.pools[g.current]
+	if !exists {
+		pool = &literalPool{slots: make(map[string]int)}
+		g.pools[g.current] = pool
+	}
+	if _, exists := pool.slots[key]; exists {
+		return
+	}
+	pool.slots[key] = len(pool.entries)
+	pool.entries = append(pool.entries, op)
+}
+
+// layoutLiteralPools fixes the offset of every pool at the end of its
+// section's code. Must run at the end of Pass 1, before section sizes are
+// reset.
+func (g *Generator) layoutLiteralPools() {
+	for name, pool := range g.pools {
+		size := g.sections[name].size
+		pool.base = (size + literalSlotSize - 1) &^ (literalSlotSize - 1)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Pool resolution and emission (Pass 2)
+// ---------------------------------------------------------------------------
+
+// literalAddress returns the section offset of the pool slot holding the
+// operand's value.
+func (g *Generator) literalAddress(op *ast.LiteralOperand) (int, bool) {
+	key, ok := literalKey(op)
+	if !ok {
+		if imm, isImm := op.Value.(*ast.ImmediateOperand); isImm {
+			if _, err := parseImmediateValue(imm.Value); err != nil {
+				g.addError(err.Error(), imm.Line, imm.Column)
+				return 0, false
+			}
+		}
+		g.addError("invalid literal pool value", op.Line, op.Column)
+		return 0, false
+	}
+
+	pool, exists := g.pools[g.current]
+	if !exists {
+		g.addError("literal pool entry was not reserved", op.Line, op.Column)
+		return 0, false
+	}
+	slot, exists := pool.slots[key]
+	if !exists {
+		g.addError("literal pool entry was not reserved", op.Line, op.Column)
+		return 0, false
+	}
+	return pool.base + slot*literalSlotSize, true
+}
+
+// emitLiteralPools appends every pool to its section, padding the section up
+// to the pool offset chosen in Pass 1. Label values are section offsets, like
+// every other label reference. Must run at the end of Pass 2.
+func (g *Generator) emitLiteralPools() {
+	names := make([]string, 0, len(g.pools))
+	for name := range g.pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pool := g.pools[name]
+		sec := g.sections[name]
+		g.current = name
+
+		for len(sec.data) < pool.base {
+			sec.data = append(sec.data, 0)
+		}
+
+		for _, op := range pool.entries {
+			var value int64
+			switch v := op.Value.(type) {
+			case *ast.ImmediateOperand:
+				value, _ = parseImmediateValue(v.Value)
+			case *ast.IdentifierOperand:
+				if constant, exists := g.strucSymbols[v.Name]; exists {
+					value = constant
+				} else if offset, ok := g.resolveLabel(v.Name, v.Line, v.Column); ok {
+					value = int64(offset)
+				}
+			}
+			sec.data = binary.LittleEndian.AppendUint64(sec.data, uint64(value))
+		}
+		sec.size = len(sec.data)
+
+		if g.debugCtx != nil {
+			g.debugCtx.Trace(
+				g.debugCtx.Loc(0, 0),
+				fmt.Sprintf("literal pool for '%s': %d entr(y/ies) at offset %d",
+					name, len(pool.entries), pool.base),
+			)
+		}
+	}
+}
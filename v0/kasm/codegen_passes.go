@@ -41,6 +41,8 @@ func (g *Generator) Generate() ([]byte, []CodegenError) {
 // collectPass walks all statements to collect label addresses, section
 // boundaries, and compute instruction sizes. No bytes are emitted.
 func (g *Generator) collectPass() {
+	g.collectStrucs()
+
 	for _, stmt := range g.program.Statements {
 		switch s := stmt.(type) {
 		case *ast.SectionStmt:
@@ -57,9 +59,26 @@ func (g *Generator) collectPass() {
 			if sec != nil {
 				sec.size += size
 			}
+
+		case *ast.IncbinStmt:
+			g.ensureSection(s.Line, s.Column)
+			size := g.collectIncbin(s)
+			if sec := g.currentSection(); sec != nil {
+				sec.size += size
+			}
+
+		case *ast.IstrucStmt:
+			g.ensureSection(s.Line, s.Column)
+			size := g.collectIstruc(s)
+			if sec := g.currentSection(); sec != nil {
+				sec.size += size
+			}
 		}
 	}
 
+	// Literal pools follow the code of their section.
+	g.layoutLiteralPools()
+
 	// Reset section offsets for Pass 2.
 	for _, sec := range g.sections {
 		sec.size = 0
@@ -84,6 +103,17 @@ func (g *Generator) emitPass() {
 		case *ast.InstructionStmt:
 			g.ensureSection(s.Line, s.Column)
 			g.encodeInstruction(s)
+
+		case *ast.IncbinStmt:
+			g.ensureSection(s.Line, s.Column)
+			g.emitIncbin(s)
+
+		case *ast.IstrucStmt:
+			g.ensureSection(s.Line, s.Column)
+			g.emitIstruc(s)
 		}
 	}
+
+	// Append the literal pools collected in Pass 1.
+	g.emitLiteralPools()
 }
@@ -0,0 +1,355 @@
+package kasm_test
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/keurnel/assembler/v0/architecture"
+	_64 "github.com/keurnel/assembler/v0/architecture/x86/_64"
+	"github.com/keurnel/assembler/v0/kasm"
+	"github.com/keurnel/assembler/v0/kasm/ast"
+	"github.com/keurnel/assembler/v0/kasm/decoder"
+	"github.com/keurnel/assembler/v0/kasm/profile"
+)
+
+// The round-trip harness enumerates every x86_64 InstructionVariant with
+// representative operands, assembles each case and checks that:
+//
+//   - the Pass 1 size (computeInstructionSize) matches the emitted length,
+//   - the emitted bytes match the golden vectors in testdata,
+//   - the bytes decode back to an instruction that re-assembles to the same
+//     bytes, or for relative operands to the label's address.
+//
+// After an intentional encoding change, regenerate the golden vectors with:
+//
+//	go test ./v0/kasm -run TestRoundTrip_X8664 -update
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden vectors in testdata")
+
+const x8664GoldenPath = "testdata/x86_64_roundtrip.golden"
+
+// roundTripRegisters lists every 64-bit general-purpose register, in encoding
+// order, so that each ModR/M field and REX extension bit is exercised.
+var roundTripRegisters = []string{
+	"rax", "rcx", "rdx", "rbx", "rsp", "rbp", "rsi", "rdi",
+	"r8", "r9", "r10", "r11", "r12", "r13", "r14", "r15",
+}
+
+// roundTripImmediates lists the boundary values of the 8-, 32- and 64-bit
+// immediate ranges, signed and unsigned.
+var roundTripImmediates = []string{
+	"0", "1", "-1",
+	"127", "128", "-128", "-129", "255",
+	"0x7fffffff", "0x80000000", "-0x80000000", "0xffffffff",
+	"0x7fffffffffffffff", "0x8000000000000000", "0xffffffffffffffff",
+}
+
+// roundTripPadding is the instruction used to place labels at a distance.
+// It encodes to 3 bytes, so 42 and 43 copies straddle the rel8 boundary.
+const roundTripPadding = "mov rax, rbx"
+
+// unreachableOperandTypes lists operand types that no source operand
+// classifies as; variants using them cannot be selected and are skipped.
+var unreachableOperandTypes = map[string]string{
+	"far": "identifiers always classify as 'relative'",
+}
+
+// roundTripCase is a single generated program.
+type roundTripCase struct {
+	// name - the golden vector key.
+	name string
+	// lines - the source lines of the program.
+	lines []string
+	// target - for relative variants, the index of the instruction the label
+	// refers to (equal to the instruction count for the end of the program);
+	// -1 otherwise.
+	target int
+}
+
+// x8664InstrTable flattens the x86_64 providers into an upper-case mnemonic table.
+func x8664InstrTable() map[string]architecture.Instruction {
+	table := make(map[string]architecture.Instruction)
+	for _, instructions := range _64.Instructions() {
+		for _, instr := range instructions {
+			table[instr.Mnemonic] = instr
+		}
+	}
+	return table
+}
+
+// generateRoundTripCases enumerates the corpus from the instruction table.
+// Variants whose operand types cannot be generated are returned separately
+// so that the coverage test can report them.
+func generateRoundTripCases(table map[string]architecture.Instruction) ([]roundTripCase, []string) {
+	cases := make([]roundTripCase, 0)
+	unsupported := make([]string, 0)
+
+	for _, mnemonic := range sortedKeys(table) {
+		for _, variant := range table[mnemonic].Variants {
+			label := fmt.Sprintf("%s %s [%s]", mnemonic, variant.Encoding, strings.Join(variant.Operands, ", "))
+			name := strings.ToLower(mnemonic)
+
+			switch strings.Join(variant.Operands, ",") {
+			case "register,register":
+				for _, dst := range roundTripRegisters {
+					for _, src := range roundTripRegisters {
+						cases = append(cases, singleLineCase(fmt.Sprintf("%s %s, %s", name, dst, src)))
+					}
+				}
+			case "register,immediate":
+				for _, dst := range roundTripRegisters {
+					for _, imm := range roundTripImmediates {
+						cases = append(cases, singleLineCase(fmt.Sprintf("%s %s, %s", name, dst, imm)))
+					}
+				}
+			case "relative":
+				cases = append(cases, relativeCases(name)...)
+			default:
+				skip := false
+				for _, operand := range variant.Operands {
+					if _, ok := unreachableOperandTypes[operand]; ok {
+						skip = true
+					}
+				}
+				if !skip {
+					unsupported = append(unsupported, label)
+				}
+			}
+		}
+	}
+
+	return cases, unsupported
+}
+
+// singleLineCase wraps a single instruction into a case.
+func singleLineCase(line string) roundTripCase {
+	return roundTripCase{name: line, lines: []string{line}, target: -1}
+}
+
+// relativeCases places the target label on the instruction itself, directly
+// after it, and before/after a run of padding on either side of the rel8
+// boundary.
+func relativeCases(mnemonic string) []roundTripCase {
+	jump := mnemonic + " target"
+	padding := func(n int) []string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = roundTripPadding
+		}
+		return lines
+	}
+
+	cases := []roundTripCase{
+		{name: jump + " (self)", lines: []string{"target:", jump}, target: 0},
+		{name: jump + " (next)", lines: []string{jump, "target:"}, target: 1},
+	}
+	for _, n := range []int{1, 42, 43} {
+		forward := append(append([]string{jump}, padding(n)...), "target:")
+		cases = append(cases, roundTripCase{
+			name: fmt.Sprintf("%s (forward %d)", jump, n), lines: forward, target: n + 1,
+		})
+		backward := append(append([]string{"target:"}, padding(n)...), jump)
+		cases = append(cases, roundTripCase{
+			name: fmt.Sprintf("%s (backward %d)", jump, n), lines: backward, target: 0,
+		})
+	}
+	return cases
+}
+
+// sortedKeys returns the keys of the instruction table in order.
+func sortedKeys(table map[string]architecture.Instruction) []string {
+	keys := make([]string, 0, len(table))
+	for k := range table {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// assembleX8664Program runs lexer → parser → semantic → generator for x86_64
+// source and returns the program alongside the generator that encoded it.
+func assembleX8664Program(t *testing.T, source string, table map[string]architecture.Instruction) (*ast.Program, *kasm.Generator, []byte) {
+	t.Helper()
+	tokens := kasm.LexerNew(source, profile.NewX8664Profile()).Start()
+	program, parseErrors := kasm.ParserNew(tokens).Parse()
+	if len(parseErrors) != 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrors)
+	}
+	if semanticErrors := kasm.AnalyserNew(program, table).Analyse(); len(semanticErrors) != 0 {
+		t.Fatalf("unexpected semantic errors: %v", semanticErrors)
+	}
+	gen := kasm.GeneratorNew(program, table)
+	output, errors := gen.Generate()
+	if len(errors) != 0 {
+		t.Fatalf("unexpected codegen errors: %v", errors)
+	}
+	return program, gen, output
+}
+
+// formatHex renders bytes as space-separated lower-case hex pairs.
+func formatHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = fmt.Sprintf("%02x", v)
+	}
+	return strings.Join(parts, " ")
+}
+
+// readGolden loads the golden vectors as a name → hex map.
+func readGolden(t *testing.T, path string) map[string]string {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("unable to open golden vectors (run with -update to create them): %v", err)
+	}
+	defer file.Close()
+
+	golden := make(map[string]string)
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, hex, ok := strings.Cut(line, "\t")
+		if !ok {
+			t.Fatalf("malformed golden vector line: %q", line)
+		}
+		golden[name] = hex
+	}
+	if err := scanner.Err(); err != nil {
+		t.Fatalf("unable to read golden vectors: %v", err)
+	}
+	return golden
+}
+
+// writeGolden rewrites the golden vectors from the generated corpus.
+func writeGolden(t *testing.T, path string, cases []roundTripCase, actual map[string]string) {
+	t.Helper()
+	var b strings.Builder
+	b.WriteString("# x86_64 round-trip golden vectors: <case>\\t<bytes>.\n")
+	b.WriteString("# Regenerate with: go test ./v0/kasm -run TestRoundTrip_X8664 -update\n")
+	for _, c := range cases {
+		fmt.Fprintf(&b, "%s\t%s\n", c.name, actual[c.name])
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatalf("unable to create testdata directory: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(b.String()), 0o644); err != nil {
+		t.Fatalf("unable to write golden vectors: %v", err)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Corpus coverage
+// ---------------------------------------------------------------------------
+
+func TestRoundTrip_X8664_CoversEveryVariant(t *testing.T) {
+	cases, unsupported := generateRoundTripCases(x8664InstrTable())
+	if len(unsupported) != 0 {
+		t.Fatalf("the corpus generator has no operands for: %s", strings.Join(unsupported, "; "))
+	}
+	if len(cases) == 0 {
+		t.Fatal("expected a non-empty corpus")
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Round trip
+// ---------------------------------------------------------------------------
+
+func TestRoundTrip_X8664(t *testing.T) {
+	table := x8664InstrTable()
+	cases, _ := generateRoundTripCases(table)
+	dec := decoder.DecoderNew(_64.Instructions())
+
+	actual := make(map[string]string, len(cases))
+	for _, c := range cases {
+		source := strings.Join(c.lines, "\n") + "\n"
+		program, gen, output := assembleX8664Program(t, source, table)
+		actual[c.name] = formatHex(output)
+
+		// Pass 1 size against Pass 2 output.
+		size := 0
+		for _, stmt := range program.Statements {
+			if instr, ok := stmt.(*ast.InstructionStmt); ok {
+				size += gen.ComputeInstructionSize(instr)
+			}
+		}
+		if size != len(output) {
+			t.Errorf("%s: computed size %d, emitted %d bytes (%s)", c.name, size, len(output), actual[c.name])
+		}
+
+		// Decode and re-assemble.
+		decoded, decodeErrors := dec.Decode(output, 0)
+		if len(decodeErrors) != 0 {
+			t.Errorf("%s: decode errors: %v", c.name, decodeErrors)
+			continue
+		}
+		for i, instr := range decoded {
+			if instr.HasTarget {
+				expected := uint64(len(output))
+				if c.target < len(decoded) {
+					expected = decoded[c.target].Address
+				}
+				if instr.Target != expected {
+					t.Errorf("%s: instruction %d decodes to target %#x, expected %#x", c.name, i, instr.Target, expected)
+				}
+				continue
+			}
+			_, _, reassembled := assembleX8664Program(t, instr.String()+"\n", table)
+			if formatHex(reassembled) != formatHex(instr.Bytes) {
+				t.Errorf("%s: '%s' re-assembles to %s, expected %s",
+					c.name, instr, formatHex(reassembled), formatHex(instr.Bytes))
+			}
+		}
+	}
+
+	if *updateGolden {
+		writeGolden(t, x8664GoldenPath, cases, actual)
+		return
+	}
+
+	golden := readGolden(t, x8664GoldenPath)
+	for _, c := range cases {
+		expected, exists := golden[c.name]
+		if !exists {
+			t.Errorf("%s: no golden vector (run with -update after verifying the encoding)", c.name)
+			continue
+		}
+		if actual[c.name] != expected {
+			t.Errorf("%s: expected %s, got %s", c.name, expected, actual[c.name])
+		}
+	}
+	if len(golden) != len(cases) {
+		t.Errorf("expected %d golden vectors, found %d", len(cases), len(golden))
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Targeted regressions
+// ---------------------------------------------------------------------------
+
+func TestRoundTrip_X8664_MovImmediateUsesOpcodeRegister(t *testing.T) {
+	_, _, output := assembleX8664Program(t, "mov r9, 1\n", x8664InstrTable())
+
+	expected := "49 b9 01 00 00 00 00 00 00 00"
+	if formatHex(output) != expected {
+		t.Errorf("expected %s, got %s", expected, formatHex(output))
+	}
+}
+
+func TestRoundTrip_X8664_JumpRelativeToInstructionEnd(t *testing.T) {
+	_, _, output := assembleX8664Program(t, "jmp target\ntarget:\n", x8664InstrTable())
+
+	expected := "e9 00 00 00 00"
+	if formatHex(output) != expected {
+		t.Errorf("expected %s, got %s", expected, formatHex(output))
+	}
+}
@@ -0,0 +1,221 @@
+package kasm_test
+
+import (
+	"encoding/binary"
+	"strings"
+	"testing"
+
+	"github.com/keurnel/assembler/v0/architecture"
+	"github.com/keurnel/assembler/v0/architecture/riscv/rv64"
+	"github.com/keurnel/assembler/v0/kasm"
+	"github.com/keurnel/assembler/v0/kasm/profile"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+// rv64InstrTable flattens the RV64 providers into an upper-case mnemonic table.
+func rv64InstrTable() map[string]architecture.Instruction {
+	table := make(map[string]architecture.Instruction)
+	for _, instructions := range rv64.Instructions() {
+		for _, instr := range instructions {
+			table[instr.Mnemonic] = instr
+		}
+	}
+	return table
+}
+
+// assembleRV64 runs lexer → parser → generator for RV64 source and returns
+// the output split into 32-bit words.
+func assembleRV64(t *testing.T, source string) ([]uint32, []kasm.CodegenError) {
+	t.Helper()
+	tokens := kasm.LexerNew(source, profile.NewRV64Profile()).Start()
+	program, parseErrors := kasm.ParserNew(tokens).Parse()
+	if len(parseErrors) != 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrors)
+	}
+	output, errors := kasm.GeneratorNew(program, rv64InstrTable()).WithBackend(kasm.RV64Backend()).Generate()
+	if len(output)%4 != 0 {
+		t.Fatalf("expected output to be a multiple of 4 bytes, got %d", len(output))
+	}
+	words := make([]uint32, len(output)/4)
+	for i := range words {
+		words[i] = binary.LittleEndian.Uint32(output[i*4:])
+	}
+	return words, errors
+}
+
+func requireWords(t *testing.T, got []uint32, expected ...uint32) {
+	t.Helper()
+	if len(got) != len(expected) {
+		t.Fatalf("expected %d word(s), got %d: %08X", len(expected), len(got), got)
+	}
+	for i := range expected {
+		if got[i] != expected[i] {
+			t.Errorf("word %d: expected %08X, got %08X", i, expected[i], got[i])
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Instruction formats
+// ---------------------------------------------------------------------------
+
+func TestGenerateRV64_RType(t *testing.T) {
+	words, errors := assembleRV64(t, `add a0, a1, a2
+sub x5, x6, x7
+mulw t0, t1, t2`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	requireWords(t, words, 0x00C58533, 0x407302B3, 0x027302BB)
+}
+
+func TestGenerateRV64_IType(t *testing.T) {
+	words, errors := assembleRV64(t, `addi sp, sp, -16
+ld a0, [sp + 8]
+lbu t0, [a1 - 1]
+li a0, 42
+mv s0, sp
+slli a0, a0, 63`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	requireWords(t, words, 0xFF010113, 0x00813503, 0xFFF5C283, 0x02A00513, 0x00010413, 0x03F51513)
+}
+
+func TestGenerateRV64_SType(t *testing.T) {
+	words, errors := assembleRV64(t, `sd ra, [sp + 8]
+sw a0, [sp - 4]`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	requireWords(t, words, 0x00113423, 0xFEA12E23)
+}
+
+func TestGenerateRV64_BranchesAndJumps(t *testing.T) {
+	words, errors := assembleRV64(t, `loop:
+beq a0, zero, done
+addi a0, a0, -1
+j loop
+done:
+jal ra, loop
+ret`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	requireWords(t, words, 0x00050663, 0xFFF50513, 0xFF9FF06F, 0xFF5FF0EF, 0x00008067)
+}
+
+func TestGenerateRV64_AtomicAndSystem(t *testing.T) {
+	words, errors := assembleRV64(t, `amoadd.w t0, t1, [a2]
+lr.d a0, [a1]
+ecall
+ebreak`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	requireWords(t, words, 0x006622AF, 0x1005B52F, 0x00000073, 0x00100073)
+}
+
+// ---------------------------------------------------------------------------
+// %hi / %lo label splitting
+// ---------------------------------------------------------------------------
+
+func TestGenerateRV64_HiLo(t *testing.T) {
+	// Pad with 0x800 bytes of nops so that %lo(data) is negative and %hi
+	// must round up.
+	source := "lui a0, %hi(data)\naddi a0, a0, %lo(data)\n" +
+		strings.Repeat("nop\n", 0x1FE) +
+		"data:\nld a1, [a0 + %lo(data)]\n"
+
+	words, errors := assembleRV64(t, source)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	// data is at 0x800: %hi = 1, %lo = -2048.
+	if words[0] != 0x00001537 {
+		t.Errorf("lui: expected 00001537, got %08X", words[0])
+	}
+	if words[1] != 0x80050513 {
+		t.Errorf("addi: expected 80050513, got %08X", words[1])
+	}
+	if last := words[len(words)-1]; last != 0x80053583 {
+		t.Errorf("ld: expected 80053583, got %08X", last)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Range checks
+// ---------------------------------------------------------------------------
+
+func TestGenerateRV64_StrucDisplacementAndInstance(t *testing.T) {
+	words, errors := assembleRV64(t, `struc task
+    .pid:   resd 1
+    .flags: resw 2
+    .stack: resq 1
+endstruc
+section .text: code
+    ld a0, [a1 + task.stack]
+    sd a0, [a1 - task_size]
+section .data: tasks
+    istruc task
+        at task.flags, dw 1, 2
+        at task.stack, dq 0x1122334455667788
+    iend`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	// The .data instance is 16 bytes; pid is zero-filled.
+	requireWords(t, words, 0x0085B503, 0xFEA5B823, 0x00000000, 0x00020001, 0x55667788, 0x11223344)
+}
+
+func TestGenerateRV64_StrucImmediate(t *testing.T) {
+	words, errors := assembleRV64(t, `struc pt
+    .flags: resq 1
+    .next:  resq 1
+endstruc
+li a0, pt_size
+addi a0, a0, pt.next`)
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %v", errors)
+	}
+	requireWords(t, words, 0x01000513, 0x00850513)
+}
+
+func TestGenerateRV64_UnknownDisplacementSymbol(t *testing.T) {
+	_, errors := assembleRV64(t, `lw a0, [a1 + nowhere]`)
+	if len(errors) != 1 || errors[0].Message != "'nowhere' is not a structure field or size symbol" {
+		t.Fatalf("expected unknown symbol error, got %v", errors)
+	}
+}
+
+func TestGenerateRV64_BranchOutOfRange(t *testing.T) {
+	source := "start:\n" + strings.Repeat("nop\n", 1100) + "beq a0, a1, start\n"
+
+	words, errors := assembleRV64(t, source)
+	if len(errors) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errors), errors)
+	}
+	if !strings.Contains(errors[0].Message, "out of range") {
+		t.Errorf("expected out of range error, got %q", errors[0].Message)
+	}
+	// The failing branch still occupies its 4 bytes.
+	if len(words) != 1101 {
+		t.Errorf("expected 1101 words, got %d", len(words))
+	}
+}
+
+func TestGenerateRV64_ImmediateOutOfRange(t *testing.T) {
+	_, errors := assembleRV64(t, `addi a0, a0, 2048
+slliw a0, a0, 32`)
+	if len(errors) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(errors), errors)
+	}
+	for _, err := range errors {
+		if !strings.Contains(err.Message, "out of range") {
+			t.Errorf("expected out of range error, got %q", err.Message)
+		}
+	}
+}
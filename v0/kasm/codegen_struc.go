@@ -0,0 +1,100 @@
+package kasm
+
+import (
+	"fmt"
+
+	"github.com/keurnel/assembler/v0/kasm/ast"
+)
+
+// collectStrucs computes the layout of every structure in the program before
+// the collection pass walks it, so that an istruc block or a displacement
+// may use a structure declared further down (FR-11.1).
+func (g *Generator) collectStrucs() {
+	for _, stmt := range g.program.Statements {
+		if s, ok := stmt.(*ast.StrucStmt); ok {
+			g.strucs[s.Name] = layoutStruc(s)
+		}
+	}
+	g.strucSymbols = strucSymbols(g.strucs)
+
+	// A label shadows a structure symbol of the same name.
+	for _, stmt := range g.program.Statements {
+		if s, ok := stmt.(*ast.LabelStmt); ok {
+			delete(g.strucSymbols, s.Name)
+		}
+	}
+}
+
+// collectIstruc lays out the bytes of an initialised structure instance and
+// records them for the emission pass (FR-11.2). The instance is as large as
+// its structure; fields without an `at` line are zero-filled. Errors are
+// recorded via addError.
+func (g *Generator) collectIstruc(s *ast.IstrucStmt) int {
+	layout, exists := g.strucs[s.Name]
+	if !exists {
+		g.addError(fmt.Sprintf("unknown structure '%s'", s.Name), s.Line, s.Column)
+		return 0
+	}
+
+	data := make([]byte, layout.size)
+	for _, v := range s.Values {
+		field, ok := layout.field(v.Field)
+		if !ok {
+			g.addError(fmt.Sprintf("'%s' is not a field of structure '%s'", v.Field, s.Name), v.Line, v.Column)
+			continue
+		}
+		offset := field.offset
+		for _, op := range v.Operands {
+			value, err := atOperandBytes(dataSizes[v.Data], op)
+			if err != nil {
+				g.addError(err.Error(), op.OperandLine(), op.OperandColumn())
+				continue
+			}
+			if offset+int64(len(value)) > layout.size {
+				g.addError(
+					fmt.Sprintf("values for field '%s' overrun structure '%s' of %d byte(s)", v.Field, s.Name, layout.size),
+					v.Line, v.Column,
+				)
+				break
+			}
+			copy(data[offset:], value)
+			offset += int64(len(value))
+		}
+	}
+
+	g.istrucs[s] = data
+	return len(data)
+}
+
+// emitIstruc appends the bytes laid out for an istruc block in the collection
+// pass to the current section buffer.
+func (g *Generator) emitIstruc(s *ast.IstrucStmt) {
+	sec := g.currentSection()
+	data, exists := g.istrucs[s]
+	if sec == nil || !exists {
+		return
+	}
+
+	sec.data = append(sec.data, data...)
+	sec.size += len(data)
+}
+
+// displacement resolves a memory operand displacement token: an immediate,
+// or a NAME.field or NAME_size structure symbol (FR-11.3).
+func (g *Generator) displacement(tok Token) (int64, bool) {
+	if tok.Type == TokenImmediate {
+		disp, err := parseImmediateValue(tok.Literal)
+		if err != nil {
+			g.addError(err.Error(), tok.Line, tok.Column)
+			return 0, false
+		}
+		return disp, true
+	}
+
+	disp, exists := g.strucSymbols[tok.Literal]
+	if !exists {
+		g.addError(fmt.Sprintf("'%s' is not a structure field or size symbol", tok.Literal), tok.Line, tok.Column)
+		return 0, false
+	}
+	return disp, true
+}
@@ -6,12 +6,37 @@ import (
 	"github.com/keurnel/assembler/v0/architecture"
 	"github.com/keurnel/assembler/v0/kasm"
 	"github.com/keurnel/assembler/v0/kasm/ast"
+	"github.com/keurnel/assembler/v0/kasm/profile"
 )
 
 // ---------------------------------------------------------------------------
 // FR-1: Construction
 // ---------------------------------------------------------------------------
 
+// TestRegisterBackend_Duplicate verifies that registering a second backend
+// under an existing architecture name panics, like architecture.Register.
+func TestRegisterBackend_Duplicate(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for a duplicate backend")
+		}
+	}()
+	kasm.RegisterBackend(kasm.RV64Backend())
+}
+
+// TestBackendFor_CanonicalNames verifies that the built-in backends are
+// registered under their canonical architecture names, case-insensitively.
+func TestBackendFor_CanonicalNames(t *testing.T) {
+	for _, name := range []string{"x86_64", "rv64", "AArch64"} {
+		if _, exists := kasm.BackendFor(name); !exists {
+			t.Errorf("expected a backend for %q", name)
+		}
+	}
+	if _, exists := kasm.BackendFor("_64"); exists {
+		t.Error("expected no backend under the alias '_64'")
+	}
+}
+
 func TestGeneratorNew_NilProgram(t *testing.T) {
 	gen := kasm.GeneratorNew(nil, nil)
 	output, errors := gen.Generate()
@@ -460,6 +485,166 @@ func TestGenerate_MultipleInstructions(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// FR-10: Embedded Binaries (%incbin)
+// ---------------------------------------------------------------------------
+
+func TestGenerate_IncbinCountsTowardsLabels(t *testing.T) {
+	program := &ast.Program{
+		Statements: []ast.Statement{
+			&ast.SectionStmt{Type: ".text", Name: "code", Line: 1, Column: 1},
+			&ast.IncbinStmt{Path: "blob.bin", Line: 2, Column: 1},
+			&ast.LabelStmt{Name: "after", Line: 3, Column: 1},
+			&ast.InstructionStmt{
+				Mnemonic: "JMP",
+				Operands: []ast.Operand{
+					&ast.IdentifierOperand{Name: "after", Line: 4, Column: 5},
+				},
+				Line:   4,
+				Column: 1,
+			},
+		},
+	}
+
+	gen := kasm.GeneratorNew(program, jmpInstrTable()).
+		WithBinaries(map[string][]byte{"blob.bin": {0xDE, 0xAD, 0xBE}})
+	output, errors := gen.Generate()
+
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %d: %v", len(errors), errors)
+	}
+	// The blob, then JMP rel32 back to offset 3: 3 - (3 + 5) = -5.
+	expected := []byte{0xDE, 0xAD, 0xBE, 0xE9, 0xFB, 0xFF, 0xFF, 0xFF}
+	if string(output) != string(expected) {
+		t.Errorf("expected % X, got % X", expected, output)
+	}
+}
+
+func TestGenerate_IncbinOffsetAndLength(t *testing.T) {
+	binaries := map[string][]byte{"font.bin": []byte("ABCDEFGH")}
+	tests := []struct {
+		offset, length string
+		expected       string
+	}{
+		{"2", "", "CDEFGH"},
+		{"2", "3", "CDE"},
+		{"0x6", "100", "GH"},
+		{"8", "", ""},
+	}
+
+	for _, tt := range tests {
+		stmt := &ast.IncbinStmt{Path: "font.bin", Offset: &ast.ImmediateOperand{Value: tt.offset}, Line: 1, Column: 1}
+		if tt.length != "" {
+			stmt.Length = &ast.ImmediateOperand{Value: tt.length}
+		}
+		program := &ast.Program{Statements: []ast.Statement{stmt}}
+
+		output, errors := kasm.GeneratorNew(program, nil).WithBinaries(binaries).Generate()
+		if len(errors) != 0 || string(output) != tt.expected {
+			t.Errorf("offset %s, length %q: expected %q, got %q (errors: %v)", tt.offset, tt.length, tt.expected, output, errors)
+		}
+	}
+}
+
+func TestGenerate_IncbinErrors(t *testing.T) {
+	tests := []struct {
+		stmt    *ast.IncbinStmt
+		message string
+	}{
+		{&ast.IncbinStmt{Path: "missing.bin", Line: 1, Column: 1}, "no contents supplied for %incbin file 'missing.bin'"},
+		{&ast.IncbinStmt{Path: "font.bin", Offset: &ast.ImmediateOperand{Value: "9"}, Line: 1, Column: 1}, "%incbin offset 9 is outside of 'font.bin' (8 byte(s))"},
+	}
+
+	for _, tt := range tests {
+		program := &ast.Program{Statements: []ast.Statement{tt.stmt}}
+		_, errors := kasm.GeneratorNew(program, nil).
+			WithBinaries(map[string][]byte{"font.bin": []byte("ABCDEFGH")}).
+			Generate()
+		if len(errors) != 1 || errors[0].Message != tt.message {
+			t.Errorf("expected %q, got %v", tt.message, errors)
+		}
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FR-11: Structures
+// ---------------------------------------------------------------------------
+
+func TestGenerate_IstrucBeforeStruc(t *testing.T) {
+	// The instance precedes the structure it uses and counts towards the
+	// label that follows it.
+	program := &ast.Program{
+		Statements: []ast.Statement{
+			&ast.IstrucStmt{
+				Name: "point",
+				Values: []*ast.AtValue{
+					{Field: "point.y", Data: "dw", Operands: []ast.Operand{&ast.ImmediateOperand{Value: "-2"}}, Line: 2, Column: 5},
+				},
+				Line:   1,
+				Column: 1,
+			},
+			&ast.LabelStmt{Name: "after", Line: 3, Column: 1},
+			&ast.InstructionStmt{
+				Mnemonic: "JMP",
+				Operands: []ast.Operand{
+					&ast.IdentifierOperand{Name: "after", Line: 4, Column: 5},
+				},
+				Line:   4,
+				Column: 1,
+			},
+			&ast.StrucStmt{
+				Name: "point",
+				Fields: []*ast.StrucField{
+					{Name: "x", Reserve: "resw", Count: &ast.ImmediateOperand{Value: "1"}},
+					{Name: "y", Reserve: "resw", Count: &ast.ImmediateOperand{Value: "1"}},
+				},
+				Line:   5,
+				Column: 1,
+			},
+		},
+	}
+
+	output, errors := kasm.GeneratorNew(program, jmpInstrTable()).Generate()
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %d: %v", len(errors), errors)
+	}
+	// x is zero-filled, y is -2; then JMP rel32 back to offset 4: 4 - (4 + 5) = -5.
+	expected := []byte{0x00, 0x00, 0xFE, 0xFF, 0xE9, 0xFB, 0xFF, 0xFF, 0xFF}
+	if string(output) != string(expected) {
+		t.Errorf("expected % X, got % X", expected, output)
+	}
+}
+
+func TestGenerate_StrucImmediate(t *testing.T) {
+	// Structure symbols are constants wherever an immediate is accepted.
+	tokens := kasm.LexerNew(`struc task
+    .pid:   resd 1
+    .stack: resq 1
+endstruc
+mov rax, task_size
+mov rcx, task.stack`, profile.NewX8664Profile()).Start()
+	program, parseErrors := kasm.ParserNew(tokens).Parse()
+	if len(parseErrors) != 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrors)
+	}
+	if semanticErrors := kasm.AnalyserNew(program, movInstrTable()).Analyse(); len(semanticErrors) != 0 {
+		t.Fatalf("unexpected semantic errors: %v", semanticErrors)
+	}
+
+	output, errors := kasm.GeneratorNew(program, movInstrTable()).Generate()
+	if len(errors) != 0 {
+		t.Fatalf("expected 0 errors, got %d: %v", len(errors), errors)
+	}
+	// REX.W B8+r imm64: task_size is 12, task.stack is 4.
+	expected := []byte{
+		0x48, 0xB8, 0x0C, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x48, 0xB9, 0x04, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+	}
+	if string(output) != string(expected) {
+		t.Errorf("expected % X, got % X", expected, output)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Helpers
 // ---------------------------------------------------------------------------
@@ -0,0 +1,16 @@
+package decoder
+
+import "fmt"
+
+// DecodeError represents a single error encountered while decoding. It is a
+// plain data struct — not an error interface implementation — so that
+// multiple errors can be accumulated and returned as a slice.
+type DecodeError struct {
+	Message string
+	Address uint64
+}
+
+// String returns a human-readable representation of the decode error.
+func (e DecodeError) String() string {
+	return fmt.Sprintf("%#x: %s", e.Address, e.Message)
+}
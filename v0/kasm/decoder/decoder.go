@@ -0,0 +1,402 @@
+package decoder
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/keurnel/assembler/v0/architecture"
+)
+
+// maxInstructionLength is the architectural limit of a single x86 instruction.
+const maxInstructionLength = 15
+
+// registerNames lists the general-purpose registers by encoding number for
+// each operand size.
+var registerNames = map[int][16]string{
+	64: {"rax", "rcx", "rdx", "rbx", "rsp", "rbp", "rsi", "rdi",
+		"r8", "r9", "r10", "r11", "r12", "r13", "r14", "r15"},
+	32: {"eax", "ecx", "edx", "ebx", "esp", "ebp", "esi", "edi",
+		"r8d", "r9d", "r10d", "r11d", "r12d", "r13d", "r14d", "r15d"},
+	16: {"ax", "cx", "dx", "bx", "sp", "bp", "si", "di",
+		"r8w", "r9w", "r10w", "r11w", "r12w", "r13w", "r14w", "r15w"},
+}
+
+// segmentPrefixes maps segment override prefixes to their register names.
+var segmentPrefixes = map[byte]string{
+	0x26: "es", 0x2E: "cs", 0x36: "ss", 0x3E: "ds", 0x64: "fs", 0x65: "gs",
+}
+
+// opcodeEntry is a single decodable variant, indexed by its opcode byte.
+type opcodeEntry struct {
+	mnemonic string
+	variant  architecture.InstructionVariant
+}
+
+// Decoder turns x86_64 machine code back into kasm syntax. It is built from
+// the same architecture.Instruction tables the code generator encodes from,
+// so every variant the assembler can emit can also be decoded. If a Decoder
+// value exists, its opcode index is fully built and immutable.
+type Decoder struct {
+	opcodes map[byte][]opcodeEntry
+}
+
+// DecoderNew builds a Decoder from x86_64 instruction groups. Groups are
+// visited in name order so that the result is deterministic when two
+// instructions share an opcode; the first one wins. Variants whose register
+// is encoded in the low three opcode bits ("RI") are indexed under all eight
+// opcodes.
+func DecoderNew(instructions map[string][]architecture.Instruction) *Decoder {
+	groups := make([]string, 0, len(instructions))
+	for group := range instructions {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+
+	d := &Decoder{opcodes: make(map[byte][]opcodeEntry)}
+	for _, group := range groups {
+		for _, instr := range instructions[group] {
+			for _, v := range instr.Variants {
+				opcodes := []byte{v.Opcode}
+				if v.Encoding == "RI" {
+					opcodes = opcodes[:0]
+					for r := byte(0); r < 8; r++ {
+						opcodes = append(opcodes, v.Opcode&^0x07+r)
+					}
+				}
+				for _, op := range opcodes {
+					d.add(op, opcodeEntry{mnemonic: strings.ToLower(instr.Mnemonic), variant: v})
+				}
+			}
+		}
+	}
+	return d
+}
+
+// add indexes an entry unless an identical mnemonic/encoding pair is already
+// present for the opcode.
+func (d *Decoder) add(opcode byte, entry opcodeEntry) {
+	for _, existing := range d.opcodes[opcode] {
+		if existing.mnemonic == entry.mnemonic && existing.variant.Encoding == entry.variant.Encoding {
+			return
+		}
+	}
+	d.opcodes[opcode] = append(d.opcodes[opcode], entry)
+}
+
+// Decode decodes code as a sequence of instructions, the first of which is
+// located at origin. Bytes that cannot be decoded are emitted as a one-byte
+// "db" pseudo-instruction and reported as a DecodeError; decoding then
+// resumes at the next byte.
+func (d *Decoder) Decode(code []byte, origin uint64) ([]Instruction, []DecodeError) {
+	instructions := make([]Instruction, 0)
+	errors := make([]DecodeError, 0)
+
+	for pos := 0; pos < len(code); {
+		address := origin + uint64(pos)
+		instr, message := d.decodeOne(code[pos:], address)
+		if message != "" {
+			errors = append(errors, DecodeError{Message: message, Address: address})
+			instr = Instruction{
+				Address:  address,
+				Bytes:    code[pos : pos+1],
+				Mnemonic: "db",
+				Operands: []string{fmt.Sprintf("0x%02x", code[pos])},
+			}
+		}
+		instructions = append(instructions, instr)
+		pos += len(instr.Bytes)
+	}
+
+	return instructions, errors
+}
+
+// ---------------------------------------------------------------------------
+// Single instruction decoding
+// ---------------------------------------------------------------------------
+
+// decodeState tracks the position and the prefix state while decoding a
+// single instruction.
+type decodeState struct {
+	code        []byte
+	pos         int
+	rex         byte
+	operandSize int // 16, 32 or 64
+	addressSize int // 32 or 64
+	segment     string
+}
+
+// decodeOne decodes the instruction at the start of code. A non-empty message
+// reports why the bytes could not be decoded.
+func (d *Decoder) decodeOne(code []byte, address uint64) (Instruction, string) {
+	if len(code) > maxInstructionLength {
+		code = code[:maxInstructionLength]
+	}
+	s := &decodeState{code: code, operandSize: 32, addressSize: 64}
+
+	// Legacy prefixes.
+	var prefixes []string
+	operandOverride := false
+prefixLoop:
+	for s.pos < len(code) {
+		b := code[s.pos]
+		switch {
+		case b == 0x66:
+			operandOverride = true
+		case b == 0x67:
+			s.addressSize = 32
+		case b == 0xF0:
+			prefixes = append(prefixes, "lock")
+		case b == 0xF2:
+			prefixes = append(prefixes, "repne")
+		case b == 0xF3:
+			prefixes = append(prefixes, "rep")
+		case segmentPrefixes[b] != "":
+			s.segment = segmentPrefixes[b]
+		default:
+			break prefixLoop
+		}
+		s.pos++
+	}
+
+	// REX prefix — must directly precede the opcode.
+	if b, ok := s.peek(); ok && b&0xF0 == 0x40 {
+		s.rex = b
+		s.pos++
+	}
+	switch {
+	case s.rex&0x08 != 0:
+		s.operandSize = 64
+	case operandOverride:
+		s.operandSize = 16
+	}
+
+	opcode, ok := s.next()
+	if !ok {
+		return Instruction{}, "truncated instruction: missing opcode"
+	}
+	if opcode == 0x0F {
+		if second, ok := s.peek(); ok {
+			return Instruction{}, fmt.Sprintf("unsupported two-byte opcode 0x0f 0x%02x", second)
+		}
+		return Instruction{}, "truncated instruction: missing second opcode byte"
+	}
+	entries := d.opcodes[opcode]
+	if len(entries) == 0 {
+		return Instruction{}, fmt.Sprintf("unknown opcode 0x%02x", opcode)
+	}
+	entry := entries[0]
+
+	instr := Instruction{Address: address, Mnemonic: entry.mnemonic}
+	if len(prefixes) > 0 {
+		instr.Mnemonic = strings.Join(prefixes, " ") + " " + entry.mnemonic
+	}
+
+	var message string
+	switch entry.variant.Encoding {
+	case "RM", "MR":
+		message = s.decodeModRM(&instr, entry.variant.Encoding == "MR")
+	case "RI":
+		message = s.decodeRegisterImmediate(&instr, opcode)
+	case "R", "F":
+		message = s.decodeRelative(&instr, address)
+	default:
+		message = fmt.Sprintf("unsupported encoding '%s' for '%s'", entry.variant.Encoding, entry.mnemonic)
+	}
+	if message != "" {
+		return Instruction{}, message
+	}
+
+	instr.Bytes = code[:s.pos]
+	return instr, ""
+}
+
+// decodeModRM decodes a ModR/M operand pair. For "RM" the r/m operand is the
+// destination (e.g. mov r/m64, r64); for "MR" the reg operand is.
+func (s *decodeState) decodeModRM(instr *Instruction, regFirst bool) string {
+	modrm, ok := s.next()
+	if !ok {
+		return "truncated instruction: missing ModR/M byte"
+	}
+	mod, reg, rm := modrm>>6, modrm>>3&0x07, modrm&0x07
+
+	regOperand := registerNames[s.operandSize][reg|s.rexBit(0x04)<<3]
+	rmOperand, message := s.decodeRM(mod, rm)
+	if message != "" {
+		return message
+	}
+
+	if regFirst {
+		instr.Operands = []string{regOperand, rmOperand}
+	} else {
+		instr.Operands = []string{rmOperand, regOperand}
+	}
+	return ""
+}
+
+// decodeRM decodes the r/m half of a ModR/M byte, consuming the SIB byte and
+// displacement when present.
+func (s *decodeState) decodeRM(mod, rm byte) (string, string) {
+	if mod == 0x03 {
+		return registerNames[s.operandSize][rm|s.rexBit(0x01)<<3], ""
+	}
+
+	var base, index string
+	scale := 1
+	var disp int64
+	var ok bool
+
+	switch {
+	case rm == 0x04:
+		// SIB byte follows.
+		sib, hasSIB := s.next()
+		if !hasSIB {
+			return "", "truncated instruction: missing SIB byte"
+		}
+		ss, idx, b := sib>>6, sib>>3&0x07|s.rexBit(0x02)<<3, sib&0x07
+		if idx != 0x04 {
+			index = registerNames[s.addressSize][idx]
+			scale = 1 << ss
+		}
+		if b == 0x05 && mod == 0x00 {
+			if disp, ok = s.readSigned(4); !ok {
+				return "", "truncated instruction: missing displacement"
+			}
+		} else {
+			base = registerNames[s.addressSize][b|s.rexBit(0x01)<<3]
+		}
+	case rm == 0x05 && mod == 0x00:
+		// RIP-relative addressing.
+		base = "rip"
+		if s.addressSize == 32 {
+			base = "eip"
+		}
+		if disp, ok = s.readSigned(4); !ok {
+			return "", "truncated instruction: missing displacement"
+		}
+	default:
+		base = registerNames[s.addressSize][rm|s.rexBit(0x01)<<3]
+	}
+
+	switch mod {
+	case 0x01:
+		if disp, ok = s.readSigned(1); !ok {
+			return "", "truncated instruction: missing displacement"
+		}
+	case 0x02:
+		if disp, ok = s.readSigned(4); !ok {
+			return "", "truncated instruction: missing displacement"
+		}
+	}
+
+	return s.formatMemory(base, index, scale, disp), ""
+}
+
+// formatMemory renders a memory operand in kasm syntax, e.g.
+// "[rbx + rcx*4 - 0x8]".
+func (s *decodeState) formatMemory(base, index string, scale int, disp int64) string {
+	parts := make([]string, 0, 2)
+	if base != "" {
+		parts = append(parts, base)
+	}
+	if index != "" {
+		if scale > 1 {
+			index = fmt.Sprintf("%s*%d", index, scale)
+		}
+		parts = append(parts, index)
+	}
+
+	expr := strings.Join(parts, " + ")
+	switch {
+	case expr == "":
+		expr = fmt.Sprintf("%#x", uint32(disp))
+	case disp > 0:
+		expr += fmt.Sprintf(" + %#x", disp)
+	case disp < 0:
+		expr += fmt.Sprintf(" - %#x", -disp)
+	}
+
+	if s.segment != "" {
+		return s.segment + ":[" + expr + "]"
+	}
+	return "[" + expr + "]"
+}
+
+// decodeRegisterImmediate decodes a register encoded in the low three opcode
+// bits followed by an immediate of the operand size (imm64 with REX.W).
+func (s *decodeState) decodeRegisterImmediate(instr *Instruction, opcode byte) string {
+	reg := registerNames[s.operandSize][opcode&0x07|s.rexBit(0x01)<<3]
+	size := s.operandSize / 8
+
+	imm, ok := s.readUnsigned(size)
+	if !ok {
+		return "truncated instruction: missing immediate"
+	}
+	instr.Operands = []string{reg, fmt.Sprintf("%#x", imm)}
+	return ""
+}
+
+// decodeRelative decodes a 32-bit displacement relative to the end of the
+// instruction and reports the absolute target.
+func (s *decodeState) decodeRelative(instr *Instruction, address uint64) string {
+	rel, ok := s.readSigned(4)
+	if !ok {
+		return "truncated instruction: missing relative offset"
+	}
+	instr.Target = address + uint64(s.pos) + uint64(rel)
+	instr.HasTarget = true
+	instr.Operands = []string{fmt.Sprintf("%#x", instr.Target)}
+	return ""
+}
+
+// ---------------------------------------------------------------------------
+// Byte helpers
+// ---------------------------------------------------------------------------
+
+// rexBit returns 1 if the given REX bit (W=0x08, R=0x04, X=0x02, B=0x01) is set.
+func (s *decodeState) rexBit(bit byte) byte {
+	if s.rex&bit != 0 {
+		return 1
+	}
+	return 0
+}
+
+// peek returns the next byte without consuming it.
+func (s *decodeState) peek() (byte, bool) {
+	if s.pos >= len(s.code) {
+		return 0, false
+	}
+	return s.code[s.pos], true
+}
+
+// next consumes and returns the next byte.
+func (s *decodeState) next() (byte, bool) {
+	b, ok := s.peek()
+	if ok {
+		s.pos++
+	}
+	return b, ok
+}
+
+// readUnsigned consumes an n-byte little-endian unsigned value.
+func (s *decodeState) readUnsigned(n int) (uint64, bool) {
+	if s.pos+n > len(s.code) {
+		return 0, false
+	}
+	var value uint64
+	for i := n - 1; i >= 0; i-- {
+		value = value<<8 | uint64(s.code[s.pos+i])
+	}
+	s.pos += n
+	return value, true
+}
+
+// readSigned consumes an n-byte little-endian two's complement value.
+func (s *decodeState) readSigned(n int) (int64, bool) {
+	value, ok := s.readUnsigned(n)
+	if !ok {
+		return 0, false
+	}
+	shift := uint(64 - 8*n)
+	return int64(value<<shift) >> shift, true
+}
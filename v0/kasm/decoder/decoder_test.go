@@ -0,0 +1,155 @@
+package decoder_test
+
+import (
+	"testing"
+
+	_64 "github.com/keurnel/assembler/v0/architecture/x86/_64"
+	"github.com/keurnel/assembler/v0/kasm/decoder"
+)
+
+// ---------------------------------------------------------------------------
+// Helpers
+// ---------------------------------------------------------------------------
+
+// decode decodes code at origin 0 using the x86_64 instruction tables.
+func decode(t *testing.T, code ...byte) ([]decoder.Instruction, []decoder.DecodeError) {
+	t.Helper()
+	return decoder.DecoderNew(_64.Instructions()).Decode(code, 0)
+}
+
+// requireSingle asserts that code decodes, without errors, into exactly one
+// instruction with the expected text.
+func requireSingle(t *testing.T, expected string, code ...byte) decoder.Instruction {
+	t.Helper()
+	instructions, errs := decode(t, code...)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected decode errors: %v", errs)
+	}
+	if len(instructions) != 1 {
+		t.Fatalf("expected 1 instruction, got %d: %v", len(instructions), instructions)
+	}
+	if got := instructions[0].String(); got != expected {
+		t.Errorf("expected %q, got %q", expected, got)
+	}
+	if len(instructions[0].Bytes) != len(code) {
+		t.Errorf("expected %d bytes, got %d", len(code), len(instructions[0].Bytes))
+	}
+	return instructions[0]
+}
+
+// ---------------------------------------------------------------------------
+// Register operands
+// ---------------------------------------------------------------------------
+
+func TestDecode_RegisterToRegister(t *testing.T) {
+	requireSingle(t, "mov rax, rbx", 0x48, 0x89, 0xD8)
+	requireSingle(t, "mov eax, ebx", 0x89, 0xD8)
+	requireSingle(t, "mov ax, bx", 0x66, 0x89, 0xD8)
+}
+
+func TestDecode_ExtendedRegisters(t *testing.T) {
+	requireSingle(t, "mov rax, r8", 0x4C, 0x89, 0xC0)
+	requireSingle(t, "mov r8, rax", 0x49, 0x89, 0xC0)
+	requireSingle(t, "mov r15, r9", 0x4D, 0x89, 0xCF)
+}
+
+// ---------------------------------------------------------------------------
+// Memory operands
+// ---------------------------------------------------------------------------
+
+func TestDecode_MemoryDisplacement(t *testing.T) {
+	requireSingle(t, "mov [rbp - 0x8], rax", 0x48, 0x89, 0x45, 0xF8)
+	requireSingle(t, "mov [rax], rbx", 0x48, 0x89, 0x18)
+	requireSingle(t, "mov [rbx + 0x100], rcx", 0x48, 0x89, 0x8B, 0x00, 0x01, 0x00, 0x00)
+}
+
+func TestDecode_MemorySIB(t *testing.T) {
+	requireSingle(t, "mov [rbx + rcx*4], rax", 0x48, 0x89, 0x04, 0x8B)
+	requireSingle(t, "mov [rsp], rax", 0x48, 0x89, 0x04, 0x24)
+	requireSingle(t, "mov [r12 + r13*8 + 0x10], rax", 0x4B, 0x89, 0x44, 0xEC, 0x10)
+	requireSingle(t, "mov [0x1000], rax", 0x48, 0x89, 0x04, 0x25, 0x00, 0x10, 0x00, 0x00)
+}
+
+func TestDecode_MemoryRIPRelative(t *testing.T) {
+	requireSingle(t, "mov [rip + 0x10], rax", 0x48, 0x89, 0x05, 0x10, 0x00, 0x00, 0x00)
+}
+
+func TestDecode_Prefixes(t *testing.T) {
+	requireSingle(t, "mov fs:[rax], rbx", 0x64, 0x48, 0x89, 0x18)
+	requireSingle(t, "mov [eax], ebx", 0x67, 0x89, 0x18)
+}
+
+// ---------------------------------------------------------------------------
+// Immediates and relative targets
+// ---------------------------------------------------------------------------
+
+func TestDecode_RegisterImmediate(t *testing.T) {
+	requireSingle(t, "mov eax, 0x2a", 0xB8, 0x2A, 0x00, 0x00, 0x00)
+	requireSingle(t, "mov r8d, 0x2a", 0x41, 0xB8, 0x2A, 0x00, 0x00, 0x00)
+	requireSingle(t, "mov rcx, 0x1122334455667788",
+		0x48, 0xB9, 0x88, 0x77, 0x66, 0x55, 0x44, 0x33, 0x22, 0x11)
+}
+
+func TestDecode_RelativeTarget(t *testing.T) {
+	instr := requireSingle(t, "jmp 0x15", 0xE9, 0x10, 0x00, 0x00, 0x00)
+	if !instr.HasTarget || instr.Target != 0x15 {
+		t.Errorf("expected target 0x15, got %#x (has=%v)", instr.Target, instr.HasTarget)
+	}
+
+	instructions, _ := decoder.DecoderNew(_64.Instructions()).Decode([]byte{0xE9, 0xFB, 0xFF, 0xFF, 0xFF}, 0x1000)
+	if instructions[0].Target != 0x1000 {
+		t.Errorf("expected backward target 0x1000, got %#x", instructions[0].Target)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Sequences and errors
+// ---------------------------------------------------------------------------
+
+func TestDecode_Sequence(t *testing.T) {
+	instructions, errs := decoder.DecoderNew(_64.Instructions()).Decode([]byte{
+		0x48, 0x89, 0xD8,
+		0xB8, 0x01, 0x00, 0x00, 0x00,
+	}, 0x400000)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected decode errors: %v", errs)
+	}
+	if len(instructions) != 2 {
+		t.Fatalf("expected 2 instructions, got %d", len(instructions))
+	}
+	if instructions[1].Address != 0x400003 {
+		t.Errorf("expected second address 0x400003, got %#x", instructions[1].Address)
+	}
+}
+
+func TestDecode_UnknownOpcode(t *testing.T) {
+	instructions, errs := decode(t, 0xFF, 0x48, 0x89, 0xD8)
+	if len(errs) != 1 || errs[0].Address != 0 {
+		t.Fatalf("expected 1 error at address 0, got %v", errs)
+	}
+	if instructions[0].String() != "db 0xff" {
+		t.Errorf("expected 'db 0xff', got %q", instructions[0].String())
+	}
+	if len(instructions) != 2 || instructions[1].String() != "mov rax, rbx" {
+		t.Errorf("expected decoding to resume after the bad byte, got %v", instructions)
+	}
+}
+
+func TestDecode_Truncated(t *testing.T) {
+	_, errs := decode(t, 0x48, 0x89)
+	if len(errs) == 0 {
+		t.Fatal("expected an error for a truncated instruction")
+	}
+
+	_, errs = decode(t, 0xB8, 0x01, 0x00)
+	if len(errs) == 0 {
+		t.Fatal("expected an error for a truncated immediate")
+	}
+}
+
+func TestDecode_TwoByteOpcodeUnsupported(t *testing.T) {
+	_, errs := decode(t, 0x0F, 0x05)
+	if len(errs) == 0 {
+		t.Fatal("expected an error for an unsupported two-byte opcode")
+	}
+}
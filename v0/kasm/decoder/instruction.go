@@ -0,0 +1,28 @@
+package decoder
+
+import "strings"
+
+// Instruction is a single decoded instruction in kasm syntax.
+type Instruction struct {
+	// Address - the address of the first byte of the instruction.
+	Address uint64
+	// Bytes - the raw bytes the instruction was decoded from.
+	Bytes []byte
+	// Mnemonic - the lower-case mnemonic (e.g. "mov"). Undecodable bytes are
+	// reported as "db".
+	Mnemonic string
+	// Operands - the operands in kasm syntax, in source order.
+	Operands []string
+	// Target - the absolute branch target of a relative operand.
+	Target uint64
+	// HasTarget - true when Target is set.
+	HasTarget bool
+}
+
+// String returns the instruction in kasm syntax, e.g. "mov rax, rbx".
+func (i Instruction) String() string {
+	if len(i.Operands) == 0 {
+		return i.Mnemonic
+	}
+	return i.Mnemonic + " " + strings.Join(i.Operands, ", ")
+}
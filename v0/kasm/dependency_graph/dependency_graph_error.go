@@ -0,0 +1,21 @@
+package dependency_graph
+
+import "fmt"
+
+// DependencyGraphError represents a single unresolvable %include found while
+// building the graph. It is a plain data struct — not an error interface
+// implementation — so that every problem in the file tree can be accumulated
+// and reported in one run (FR-9).
+type DependencyGraphError struct {
+	Message string
+	// FilePath - the file containing the offending directive. Empty for the
+	// top-level source when the graph has no root file path.
+	FilePath string
+	// Line - the 1-based line of the directive within FilePath.
+	Line int
+}
+
+// String returns a human-readable representation of the dependency graph error.
+func (e DependencyGraphError) String() string {
+	return fmt.Sprintf("%s:%d: %s", e.FilePath, e.Line, e.Message)
+}
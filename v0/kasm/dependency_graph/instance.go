@@ -6,6 +6,8 @@ import (
 	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
 )
 
 var (
@@ -24,10 +26,21 @@ type Instance struct {
 	cwd string
 	// rootFilePath - absolute path of the root source file
 	rootFilePath string
+	// searchPath - ordered include directories searched after the
+	// including file's directory (FR-6.1)
+	searchPath []string
+	// scanner - tracks the active conditional branches across files, fed
+	// in textual order as the graph is built depth-first (FR-4.8)
+	scanner *preProcessing.ConditionalScanner
+	// scanning - files on the include path of the directive being scanned,
+	// from the root down (FR-5.5)
+	scanning map[string]bool
 	// source - original source code
 	source string
 	// nodes - map of nodes in the graph
 	nodes map[string]*DependencyGraphNode
+	// errors - unresolvable %include directives found while building (FR-9)
+	errors []DependencyGraphError
 }
 
 // New - creates a new instance of the dependency graph.
@@ -36,12 +49,38 @@ type Instance struct {
 // Pass an empty string to omit the root node (e.g. in tests that build graphs
 // programmatically).
 func New(source, cwd, rootFilePath string) *Instance {
+	return NewWithOptions(source, cwd, rootFilePath, Options{})
+}
+
+// Options - configures how NewWithOptions follows %include directives.
+type Options struct {
+	// SearchPath - ordered include directories searched after the
+	// including file's directory (FR-6.1)
+	SearchPath []string
+	// DefinedSymbols - symbols defined before the root source, which decide
+	// the active conditional branches (FR-4.8)
+	DefinedSymbols map[string]bool
+	// CommandLine - symbols defined and undefined on the command line,
+	// which take precedence over the source (FR-4.8)
+	CommandLine *preProcessing.CommandLineSymbols
+}
+
+// NewWithOptions - creates a new instance of the dependency graph whose
+// include paths are resolved relative to the including file and then through
+// the ordered search path directories, as the pre-processor resolves them
+// (FR-6.1). Only directives in active conditional branches are followed
+// (FR-4.8).
+func NewWithOptions(source, cwd, rootFilePath string, options Options) *Instance {
 	instance := Instance{
 		metaData:     &InstanceMetaData{},
 		cwd:          cwd,
 		rootFilePath: rootFilePath,
+		searchPath:   options.SearchPath,
+		scanner:      preProcessing.ConditionalScannerNew(options.DefinedSymbols).WithCommandLine(options.CommandLine),
+		scanning:     make(map[string]bool),
 		source:       source,
 		nodes:        make(map[string]*DependencyGraphNode),
+		errors:       make([]DependencyGraphError, 0),
 	}
 
 	// -- FR-1.1.1 - dependency graph receives working directory
@@ -56,6 +95,13 @@ func New(source, cwd, rootFilePath string) *Instance {
 	return &instance
 }
 
+// Errors - returns the unresolvable %include directives found while building
+// the graph, in discovery order. Offending directives contribute no node or
+// edge; the rest of the graph is still built.
+func (i *Instance) Errors() []DependencyGraphError {
+	return i.errors
+}
+
 // Nodes - returns the nodes in the dependency graph.
 func (i *Instance) Nodes() map[string]*DependencyGraphNode {
 	return i.nodes
@@ -112,9 +158,26 @@ func (i *Instance) build() {
 func (i *Instance) scanSource(source string, parentNode *DependencyGraphNode) {
 	lines := strings.Split(source, "\n")
 
-	for _, line := range lines {
+	filePath := i.rootFilePath
+	if parentNode != nil {
+		filePath = parentNode.name
+		i.scanning[filePath] = true
+		defer delete(i.scanning, filePath)
+	}
+
+	for lineIndex, line := range lines {
+		// FR-4.8: Directives in inactive conditional branches are skipped.
+		if !i.scanner.Active(line) {
+			continue
+		}
 		line = strings.TrimSpace(line)
 
+		// FR-4.7: %incbin embeds a binary file, which becomes a leaf node.
+		if strings.HasPrefix(line, "%incbin") {
+			i.scanIncbin(line, filePath, lineIndex+1, parentNode)
+			continue
+		}
+
 		// FR-4.3: Skip non-include lines.
 		if !strings.HasPrefix(line, "%include") {
 			continue
@@ -139,23 +202,37 @@ func (i *Instance) scanSource(source string, parentNode *DependencyGraphNode) {
 
 		// FR-5.4: Only .kasm files may appear as include targets.
 		if !strings.HasSuffix(rawPath, ".kasm") {
-			panic(fmt.Sprintf("dependency graph error: included file '%s' is not a .kasm file", rawPath))
+			i.errors = append(i.errors, DependencyGraphError{
+				Message:  fmt.Sprintf("included file '%s' is not a .kasm file", rawPath),
+				FilePath: filePath,
+				Line:     lineIndex + 1,
+			})
+			continue
 		}
 
-		// FR-6.1: Resolve the file path relative to the graph's cwd.
-		resolvedPath := rawPath
-		if !filepath.IsAbs(rawPath) {
-			resolvedPath = filepath.Join(i.cwd, rawPath)
-		}
+		// FR-6.1: Resolve the file path relative to the including file, then
+		// through the search path.
+		resolvedPath := i.resolve(rawPath, filePath)
 
 		// FR-3.2: Check if a node for this path already exists (shared dependency).
 		existingNode, alreadyExists := i.nodes[resolvedPath]
 
+		// FR-5.5: A guarded file that includes itself, directly or through
+		// its own includes, is a no-op rather than a cycle.
+		if alreadyExists && i.scanning[resolvedPath] && preProcessing.IncludeOnce(existingNode.source) {
+			continue
+		}
+
 		if !alreadyExists {
 			// FR-6.2: Read file content.
 			contentBytes, err := OsReadFile(resolvedPath)
 			if err != nil {
-				panic(fmt.Sprintf("dependency graph error: failed to read file '%s': %v", resolvedPath, err))
+				i.errors = append(i.errors, DependencyGraphError{
+					Message:  fmt.Sprintf("failed to read file '%s': %v", resolvedPath, err),
+					FilePath: filePath,
+					Line:     lineIndex + 1,
+				})
+				continue
 			}
 
 			// FR-6.3 / FR-3.4: Create a new node with the file content.
@@ -178,21 +255,84 @@ func (i *Instance) scanSource(source string, parentNode *DependencyGraphNode) {
 	}
 }
 
-// SharedDependencies returns the sorted file paths of nodes that have more
-// than one incoming edge — i.e. files that are included by multiple parents.
-// The root file is excluded even if it has incoming edges.
+// scanIncbin adds the binary file embedded by an %incbin directive as a leaf
+// node, connected to parentNode by an "incbin" edge (FR-4.7). The file is
+// only checked for existence: its bytes are never scanned for directives.
+// Directives without a quoted path are skipped; the parser reports them.
+func (i *Instance) scanIncbin(line, filePath string, lineNumber int, parentNode *DependencyGraphNode) {
+	parts := strings.SplitN(line, "\"", 3)
+	if len(parts) < 3 || strings.TrimSpace(parts[0]) != "%incbin" {
+		return
+	}
+
+	resolvedPath := i.resolve(parts[1], filePath)
+
+	node, exists := i.nodes[resolvedPath]
+	if !exists {
+		info, err := OsStat(resolvedPath)
+		if err == nil && info.IsDir() {
+			err = fmt.Errorf("is a directory")
+		}
+		if err != nil {
+			i.errors = append(i.errors, DependencyGraphError{
+				Message:  fmt.Sprintf("failed to find binary file '%s': %v", resolvedPath, err),
+				FilePath: filePath,
+				Line:     lineNumber,
+			})
+			return
+		}
+
+		node = DependencyGraphNodeNew(resolvedPath, "")
+		i.AddNode(node)
+	}
+
+	if parentNode != nil {
+		parentNode.AddEdge(DependencyGraphEdgeNew("incbin", parentNode, node))
+	}
+}
+
+// resolve returns the path an %include of rawPath in includingFile refers
+// to: the first of preProcessing.IncludeCandidates that exists, or the first
+// candidate if none does (FR-6.1). The top-level source of a graph without a
+// root file resolves relative to the graph's cwd.
+func (i *Instance) resolve(rawPath, includingFile string) string {
+	includingDir := i.cwd
+	if includingFile != "" {
+		includingDir = filepath.Dir(includingFile)
+	}
+
+	candidates := preProcessing.IncludeCandidates(rawPath, includingDir, i.searchPath)
+	for _, candidate := range candidates {
+		if info, err := OsStat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+	return candidates[0]
+}
+
+// SharedDependencies returns the sorted file paths of nodes that have
+// incoming "include" edges from more than one node — i.e. files that are
+// included by multiple parents. A file included twice by the same parent is
+// not shared. The root file is excluded even if it has incoming edges, and
+// binaries embedded with %incbin are never shared dependencies.
 func (i *Instance) SharedDependencies() []string {
-	// Count incoming edges per node.
-	incomingCount := make(map[string]int, len(i.nodes))
+	// Collect the parents including each node.
+	parents := make(map[string]map[string]bool, len(i.nodes))
 	for _, node := range i.nodes {
 		for _, edge := range node.edges {
-			incomingCount[edge.to.name]++
+			if edge.dependencyType != "include" {
+				continue
+			}
+			if parents[edge.to.name] == nil {
+				parents[edge.to.name] = make(map[string]bool)
+			}
+			parents[edge.to.name][edge.from.name] = true
 		}
 	}
 
 	var shared []string
-	for name, count := range incomingCount {
-		if count > 1 && name != i.rootFilePath {
+	for name, from := range parents {
+		if len(from) > 1 && name != i.rootFilePath {
 			shared = append(shared, name)
 		}
 	}
@@ -201,6 +341,22 @@ func (i *Instance) SharedDependencies() []string {
 	return shared
 }
 
+// Dependencies returns the sorted file paths of every node other than the
+// root file: the files it includes, transitively, and the binaries they
+// embed with %incbin (FR-12.1). These are the files an output built from the
+// root depends on.
+func (i *Instance) Dependencies() []string {
+	dependencies := make([]string, 0, len(i.nodes))
+	for name := range i.nodes {
+		if name != i.rootFilePath {
+			dependencies = append(dependencies, name)
+		}
+	}
+
+	sort.Strings(dependencies)
+	return dependencies
+}
+
 // NodeSource returns the source content of the node with the given name.
 // Returns an empty string if the node does not exist.
 func (i *Instance) NodeSource(name string) string {
@@ -313,52 +469,53 @@ func (i *Instance) String() string {
 		return "(empty graph)"
 	}
 
-	// When rootFilePath is set, use it as the sole tree root so the graph
-	// always starts from the root file.
-	var roots []string
-	if i.rootFilePath != "" {
-		if _, ok := i.nodes[i.rootFilePath]; ok {
-			roots = []string{i.rootFilePath}
+	var sb strings.Builder
+	expanded := make(map[string]bool, len(i.nodes))
+
+	for idx, rootName := range i.roots() {
+		if idx > 0 {
+			sb.WriteByte('\n')
 		}
+		i.writeTree(&sb, rootName, "", expanded)
 	}
 
-	// Fall back to inferring roots: nodes that are not the target of any edge.
-	if len(roots) == 0 {
-		targets := make(map[string]bool, len(i.nodes))
-		for _, node := range i.nodes {
-			for _, edge := range node.edges {
-				targets[edge.to.name] = true
-			}
-		}
+	return sb.String()
+}
 
-		for name := range i.nodes {
-			if !targets[name] {
-				roots = append(roots, name)
-			}
+// roots returns the nodes the graph is traversed from: the root file when it
+// is set, so the graph always starts from it, and otherwise the nodes that
+// are not the target of any edge.
+func (i *Instance) roots() []string {
+	if i.rootFilePath != "" {
+		if _, ok := i.nodes[i.rootFilePath]; ok {
+			return []string{i.rootFilePath}
 		}
+	}
 
-		// If all nodes are targets (e.g. a pure cycle with no root), list all.
-		if len(roots) == 0 {
-			for name := range i.nodes {
-				roots = append(roots, name)
-			}
+	targets := make(map[string]bool, len(i.nodes))
+	for _, node := range i.nodes {
+		for _, edge := range node.edges {
+			targets[edge.to.name] = true
 		}
-
-		// FR-11.5.2: Sort roots for deterministic output across calls.
-		sort.Strings(roots)
 	}
 
-	var sb strings.Builder
-	expanded := make(map[string]bool, len(i.nodes))
+	var roots []string
+	for name := range i.nodes {
+		if !targets[name] {
+			roots = append(roots, name)
+		}
+	}
 
-	for idx, rootName := range roots {
-		if idx > 0 {
-			sb.WriteByte('\n')
+	// If all nodes are targets (e.g. a pure cycle with no root), list all.
+	if len(roots) == 0 {
+		for name := range i.nodes {
+			roots = append(roots, name)
 		}
-		i.writeTree(&sb, rootName, "", expanded)
 	}
 
-	return sb.String()
+	// FR-11.5.2: Sort roots for deterministic output across calls.
+	sort.Strings(roots)
+	return roots
 }
 
 // writeTree recursively writes the tree representation for a single node.
@@ -0,0 +1,166 @@
+package dependency_graph
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// jsonGraph is the document produced by ToJSON (FR-11.6).
+type jsonGraph struct {
+	Root    string     `json:"root,omitempty"`
+	Acyclic bool       `json:"acyclic"`
+	Nodes   []jsonNode `json:"nodes"`
+	Edges   []jsonEdge `json:"edges"`
+}
+
+// jsonNode is a node of the document produced by ToJSON. Depth is omitted
+// for nodes that cannot be reached from the graph's roots.
+type jsonNode struct {
+	Name   string `json:"name"`
+	Depth  *int   `json:"depth,omitempty"`
+	Shared bool   `json:"shared"`
+	Cycle  bool   `json:"cycle"`
+}
+
+// jsonEdge is an edge of the document produced by ToJSON.
+type jsonEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Type  string `json:"type"`
+	Cycle bool   `json:"cycle"`
+}
+
+// ToJSON produces a machine-readable JSON representation of the dependency
+// graph (FR-11.6): its nodes with their depth and shared and cycle flags, and
+// its edges with their dependency type and cycle flag. Nodes are sorted by
+// name and edges follow them in insertion order (FR-11.5.2), once each.
+func (i *Instance) ToJSON() string {
+	shared := make(map[string]bool)
+	for _, name := range i.SharedDependencies() {
+		shared[name] = true
+	}
+	depths := i.Depths()
+	components := i.cycleComponents()
+
+	graph := jsonGraph{
+		Root:    i.rootFilePath,
+		Acyclic: len(components) == 0,
+		Nodes:   make([]jsonNode, 0, len(i.nodes)),
+		Edges:   make([]jsonEdge, 0),
+	}
+
+	names := i.sortedNodeNames()
+	for _, name := range names {
+		node := jsonNode{Name: name, Shared: shared[name]}
+		if depth, ok := depths[name]; ok {
+			node.Depth = &depth
+		}
+		_, node.Cycle = components[name]
+		graph.Nodes = append(graph.Nodes, node)
+	}
+	for _, edge := range i.exportEdges(names) {
+		graph.Edges = append(graph.Edges, jsonEdge{
+			From:  edge.from.name,
+			To:    edge.to.name,
+			Type:  edge.dependencyType,
+			Cycle: onCycle(components, edge),
+		})
+	}
+
+	// The document holds only strings, booleans and integers, which always
+	// marshal.
+	content, _ := json.MarshalIndent(graph, "", "  ")
+	return string(content)
+}
+
+// ToMermaid produces a Mermaid flowchart of the dependency graph (FR-11.7),
+// for rendering in Markdown documentation. Nodes get the identifiers n0, n1,
+// … in sorted name order; "incbin" edges are dotted, edges on a cycle are
+// drawn in red, and shared dependencies and nodes on a cycle are given the
+// classes "shared" and "cycle".
+func (i *Instance) ToMermaid() string {
+	var sb strings.Builder
+
+	sb.WriteString("flowchart TD")
+
+	// FR-11.7.4: Empty graph produces a valid but empty flowchart.
+	if len(i.nodes) == 0 {
+		return sb.String()
+	}
+
+	components := i.cycleComponents()
+	names := i.sortedNodeNames()
+	ids := make(map[string]string, len(names))
+	for index, name := range names {
+		ids[name] = fmt.Sprintf("n%d", index)
+		sb.WriteString(fmt.Sprintf("\n    %s[\"%s\"]", ids[name], strings.ReplaceAll(name, `"`, "#quot;")))
+	}
+
+	cycleLinks := make([]string, 0)
+	for link, edge := range i.exportEdges(names) {
+		arrow := "-->"
+		if edge.dependencyType == "incbin" {
+			arrow = "-.->"
+		}
+		sb.WriteString(fmt.Sprintf("\n    %s %s|%s| %s", ids[edge.from.name], arrow, edge.dependencyType, ids[edge.to.name]))
+		if onCycle(components, edge) {
+			cycleLinks = append(cycleLinks, fmt.Sprint(link))
+		}
+	}
+	if len(cycleLinks) > 0 {
+		sb.WriteString("\n    linkStyle " + strings.Join(cycleLinks, ",") + " stroke:red")
+	}
+
+	sharedNodes := make([]string, 0)
+	for _, name := range i.SharedDependencies() {
+		sharedNodes = append(sharedNodes, ids[name])
+	}
+	cycleNodes := make([]string, 0)
+	for _, name := range names {
+		if _, ok := components[name]; ok {
+			cycleNodes = append(cycleNodes, ids[name])
+		}
+	}
+	if len(sharedNodes) > 0 {
+		sb.WriteString("\n    classDef shared stroke-dasharray:5 5")
+		sb.WriteString("\n    class " + strings.Join(sharedNodes, ",") + " shared")
+	}
+	if len(cycleNodes) > 0 {
+		sb.WriteString("\n    classDef cycle stroke:red")
+		sb.WriteString("\n    class " + strings.Join(cycleNodes, ",") + " cycle")
+	}
+
+	return sb.String()
+}
+
+// exportEdges returns the edges of the nodes names, in order, once per
+// (from, to, type): a file that includes or embeds the same file twice has an
+// edge for each directive (FR-7.2), but the exports draw a single one.
+func (i *Instance) exportEdges(names []string) []*DependencyGraphEdge {
+	type edgeKey struct{ from, to, dependencyType string }
+	seen := make(map[edgeKey]bool)
+	edges := make([]*DependencyGraphEdge, 0)
+	for _, name := range names {
+		for _, edge := range i.nodes[name].edges {
+			key := edgeKey{edge.from.name, edge.to.name, edge.dependencyType}
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			edges = append(edges, edge)
+		}
+	}
+	return edges
+}
+
+// onCycle reports whether edge lies on a cycle: whether both of its ends
+// belong to the same cyclic component (FR-13.4).
+func onCycle(components map[string]int, edge *DependencyGraphEdge) bool {
+	from, ok := components[edge.from.name]
+	if !ok {
+		return false
+	}
+	to, ok := components[edge.to.name]
+	return ok && from == to
+}
@@ -0,0 +1,183 @@
+package dependency_graph
+
+import "sort"
+
+// ReverseDependencies returns the sorted names of the nodes that depend on
+// the node with the given name, directly or through other files: for an
+// included file, every file whose assembly reads it (FR-13.1). The node
+// itself is not listed, even when it is part of a cycle. Returns an empty
+// slice if the node does not exist or nothing depends on it.
+func (i *Instance) ReverseDependencies(name string) []string {
+	dependents := make(map[string][]string, len(i.nodes))
+	for _, node := range i.nodes {
+		for _, edge := range node.edges {
+			dependents[edge.to.name] = append(dependents[edge.to.name], node.name)
+		}
+	}
+
+	visited := map[string]bool{name: true}
+	queue := []string{name}
+	reverse := make([]string, 0)
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, dependent := range dependents[current] {
+			if visited[dependent] {
+				continue
+			}
+			visited[dependent] = true
+			reverse = append(reverse, dependent)
+			queue = append(queue, dependent)
+		}
+	}
+
+	sort.Strings(reverse)
+	return reverse
+}
+
+// TopologicalOrder returns the node names ordered so that every file comes
+// before the files it includes or embeds (FR-13.2). Among the nodes whose
+// parents have all been listed, names are taken in sorted order, so the order
+// is deterministic. Returns nil if the graph is cyclic, as no such order
+// exists; CyclePath reports the cycle.
+func (i *Instance) TopologicalOrder() []string {
+	inDegree := make(map[string]int, len(i.nodes))
+	for _, node := range i.nodes {
+		for _, edge := range node.edges {
+			inDegree[edge.to.name]++
+		}
+	}
+
+	ready := make([]string, 0)
+	for name := range i.nodes {
+		if inDegree[name] == 0 {
+			ready = append(ready, name)
+		}
+	}
+	sort.Strings(ready)
+
+	order := make([]string, 0, len(i.nodes))
+	for len(ready) > 0 {
+		current := ready[0]
+		ready = ready[1:]
+		order = append(order, current)
+
+		released := false
+		for _, edge := range i.nodes[current].edges {
+			inDegree[edge.to.name]--
+			if inDegree[edge.to.name] == 0 {
+				ready = append(ready, edge.to.name)
+				released = true
+			}
+		}
+		if released {
+			sort.Strings(ready)
+		}
+	}
+
+	// FR-13.2.2: Nodes on a cycle never become ready.
+	if len(order) != len(i.nodes) {
+		return nil
+	}
+	return order
+}
+
+// Depths returns the depth of every node reachable from the graph's roots:
+// 0 for a root, and otherwise the number of edges on the shortest path from a
+// root (FR-13.3). The roots are those String starts its trees from.
+func (i *Instance) Depths() map[string]int {
+	depths := make(map[string]int, len(i.nodes))
+	queue := i.roots()
+	for _, root := range queue {
+		depths[root] = 0
+	}
+
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, edge := range i.nodes[current].edges {
+			if _, seen := depths[edge.to.name]; seen {
+				continue
+			}
+			depths[edge.to.name] = depths[current] + 1
+			queue = append(queue, edge.to.name)
+		}
+	}
+
+	return depths
+}
+
+// cycleComponents returns, for every node that lies on a cycle, the index of
+// its strongly connected component, so that an edge lies on a cycle if and
+// only if both of its ends map to the same index (FR-13.4). Tarjan's
+// algorithm visits each node and edge once.
+func (i *Instance) cycleComponents() map[string]int {
+	index := make(map[string]int, len(i.nodes))
+	lowLink := make(map[string]int, len(i.nodes))
+	onStack := make(map[string]bool, len(i.nodes))
+	stack := make([]string, 0, len(i.nodes))
+	components := make(map[string]int)
+	next, component := 0, 0
+
+	var connect func(name string)
+	connect = func(name string) {
+		index[name] = next
+		lowLink[name] = next
+		next++
+		stack = append(stack, name)
+		onStack[name] = true
+
+		selfLoop := false
+		for _, edge := range i.nodes[name].edges {
+			target := edge.to.name
+			if target == name {
+				selfLoop = true
+			}
+			if _, visited := index[target]; !visited {
+				connect(target)
+				lowLink[name] = min(lowLink[name], lowLink[target])
+			} else if onStack[target] {
+				lowLink[name] = min(lowLink[name], index[target])
+			}
+		}
+
+		if lowLink[name] != index[name] {
+			return
+		}
+
+		// name is the root of a component: pop it off the stack.
+		start := len(stack) - 1
+		for stack[start] != name {
+			start--
+		}
+		members := stack[start:]
+		stack = stack[:start]
+		for _, member := range members {
+			onStack[member] = false
+		}
+		if len(members) > 1 || selfLoop {
+			for _, member := range members {
+				components[member] = component
+			}
+			component++
+		}
+	}
+
+	for _, name := range i.sortedNodeNames() {
+		if _, visited := index[name]; !visited {
+			connect(name)
+		}
+	}
+	return components
+}
+
+// sortedNodeNames returns the names of all nodes in sorted order, for
+// deterministic output (FR-11.5.2).
+func (i *Instance) sortedNodeNames() []string {
+	names := make([]string, 0, len(i.nodes))
+	for name := range i.nodes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
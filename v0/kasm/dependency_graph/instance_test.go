@@ -2,7 +2,9 @@ package dependency_graph_test
 
 import (
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"testing"
 	"time"
 
@@ -483,51 +485,62 @@ func TestBuild_SharedDependency(t *testing.T) {
 	}
 }
 
-// TestBuild_NonKasmPanics verifies FR-5.4: non-.kasm include targets cause
-// a panic.
-func TestBuild_NonKasmPanics(t *testing.T) {
+// TestBuild_NonKasmRecordsError verifies FR-5.4: non-.kasm include targets
+// are recorded as errors with the including file and line.
+func TestBuild_NonKasmRecordsError(t *testing.T) {
 	dependency_graph.OsStat = func(name string) (os.FileInfo, error) {
 		return &mockFileInfo{isDir: true}, nil
 	}
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatal("expected panic for non-.kasm include")
-		}
-		msg := fmt.Sprintf("%v", r)
-		if !containsSubstring(msg, "not a .kasm file") {
-			t.Errorf("unexpected panic message: %s", msg)
-		}
-		dependency_graph.OsStat = os.Stat
-	}()
+	defer func() { dependency_graph.OsStat = os.Stat }()
+
+	source := "; header\n%include \"module.asm\""
+	graph := dependency_graph.New(source, "/project", "/project/main.kasm")
 
-	source := `%include "module.asm"`
-	dependency_graph.New(source, "/project", "")
+	errs := graph.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	if !containsSubstring(errs[0].Message, "not a .kasm file") {
+		t.Errorf("unexpected error message: %s", errs[0].Message)
+	}
+	if errs[0].FilePath != "/project/main.kasm" || errs[0].Line != 2 {
+		t.Errorf("expected error at /project/main.kasm:2, got %s:%d", errs[0].FilePath, errs[0].Line)
+	}
+	if len(graph.Nodes()) != 1 {
+		t.Errorf("expected only the root node, got %d nodes", len(graph.Nodes()))
+	}
 }
 
-// TestBuild_FileReadErrorPanics verifies FR-6.2: unreadable files cause a panic.
-func TestBuild_FileReadErrorPanics(t *testing.T) {
+// TestBuild_FileReadErrorRecordsError verifies FR-6.2: unreadable files are
+// recorded as errors and the remaining includes are still resolved.
+func TestBuild_FileReadErrorRecordsError(t *testing.T) {
 	dependency_graph.OsStat = func(name string) (os.FileInfo, error) {
 		return &mockFileInfo{isDir: true}, nil
 	}
 	dependency_graph.OsReadFile = func(name string) ([]byte, error) {
-		return nil, fmt.Errorf("permission denied")
+		if name == "/project/missing.kasm" {
+			return nil, fmt.Errorf("permission denied")
+		}
+		return []byte("nop"), nil
 	}
 	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatal("expected panic for file read error")
-		}
-		msg := fmt.Sprintf("%v", r)
-		if !containsSubstring(msg, "failed to read file") {
-			t.Errorf("unexpected panic message: %s", msg)
-		}
 		dependency_graph.OsStat = os.Stat
 		dependency_graph.OsReadFile = os.ReadFile
 	}()
 
-	source := `%include "missing.kasm"`
-	dependency_graph.New(source, "/project", "")
+	source := "%include \"missing.kasm\"\n%include \"present.kasm\""
+	graph := dependency_graph.New(source, "/project", "")
+
+	errs := graph.Errors()
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	if !containsSubstring(errs[0].Message, "failed to read file") || errs[0].Line != 1 {
+		t.Errorf("unexpected error: %s", errs[0])
+	}
+	if _, exists := graph.Nodes()["/project/present.kasm"]; !exists {
+		t.Error("expected the readable include to be resolved")
+	}
 }
 
 // TestBuild_NoIncludes verifies that build() creates no nodes when there are
@@ -563,6 +576,236 @@ func TestBuild_SkipsMalformedDirective(t *testing.T) {
 	}
 }
 
+// TestBuild_ResolvesRelativeToIncludingFile verifies FR-6.1: include paths
+// are resolved relative to the including file first, then through the search
+// path in order.
+func TestBuild_ResolvesRelativeToIncludingFile(t *testing.T) {
+	files := map[string]string{
+		"/project/src/main.kasm":        "%include \"lib/io.kasm\"\n%include \"util.kasm\"",
+		"/project/src/lib/io.kasm":      "%include \"syscall.kasm\"",
+		"/project/src/lib/syscall.kasm": "nop",
+		"/opt/first/util.kasm":          "nop",
+		"/opt/second/util.kasm":         "nop",
+	}
+	dependency_graph.OsStat = func(name string) (os.FileInfo, error) {
+		if _, ok := files[name]; ok {
+			return &mockFileInfo{}, nil
+		}
+		if name == "/elsewhere" {
+			return &mockFileInfo{isDir: true}, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	dependency_graph.OsReadFile = func(name string) ([]byte, error) {
+		if content, ok := files[name]; ok {
+			return []byte(content), nil
+		}
+		return nil, fmt.Errorf("file not found: %s", name)
+	}
+	defer func() {
+		dependency_graph.OsStat = os.Stat
+		dependency_graph.OsReadFile = os.ReadFile
+	}()
+
+	graph := dependency_graph.NewWithOptions(files["/project/src/main.kasm"], "/elsewhere",
+		"/project/src/main.kasm", dependency_graph.Options{SearchPath: []string{"/opt/missing", "/opt/first", "/opt/second"}})
+
+	if len(graph.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", graph.Errors())
+	}
+	for _, name := range []string{"/project/src/lib/io.kasm", "/project/src/lib/syscall.kasm", "/opt/first/util.kasm"} {
+		if _, ok := graph.Nodes()[name]; !ok {
+			t.Errorf("expected node for '%s', got %v", name, graph.String())
+		}
+	}
+	if _, ok := graph.Nodes()["/opt/second/util.kasm"]; ok {
+		t.Error("expected the first search path match to win")
+	}
+}
+
+// TestBuild_Incbin verifies FR-4.7: binaries embedded with %incbin become
+// leaf nodes behind "incbin" edges and are never shared dependencies.
+func TestBuild_Incbin(t *testing.T) {
+	dependency_graph.OsStat = func(name string) (os.FileInfo, error) {
+		return &mockFileInfo{isDir: name == "/project"}, nil
+	}
+	dependency_graph.OsReadFile = func(name string) ([]byte, error) {
+		if name == "/project/fonts.kasm" {
+			return []byte(`%incbin "font.bin", 16`), nil
+		}
+		return nil, fmt.Errorf("unexpected read of %s", name)
+	}
+	defer func() {
+		dependency_graph.OsStat = os.Stat
+		dependency_graph.OsReadFile = os.ReadFile
+	}()
+
+	source := "%include \"fonts.kasm\"\n%incbin \"font.bin\""
+	graph := dependency_graph.New(source, "/project", "/project/main.kasm")
+
+	if len(graph.Errors()) != 0 {
+		t.Fatalf("unexpected errors: %v", graph.Errors())
+	}
+	if _, ok := graph.Nodes()["/project/font.bin"]; !ok {
+		t.Fatalf("expected a node for the binary, got:\n%s", graph.String())
+	}
+	if !containsSubstring(graph.ToDot(), `"/project/main.kasm" -> "/project/font.bin" [label="incbin"]`) {
+		t.Errorf("expected an incbin edge, got:\n%s", graph.ToDot())
+	}
+	if shared := graph.SharedDependencies(); len(shared) != 0 {
+		t.Errorf("expected no shared dependencies, got %v", shared)
+	}
+}
+
+// TestBuild_IncbinMissingRecordsError verifies FR-4.7: a missing binary is
+// recorded at the directive.
+func TestBuild_IncbinMissingRecordsError(t *testing.T) {
+	dependency_graph.OsStat = func(name string) (os.FileInfo, error) {
+		if name == "/project" {
+			return &mockFileInfo{isDir: true}, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	defer func() { dependency_graph.OsStat = os.Stat }()
+
+	graph := dependency_graph.New("nop\n%incbin \"missing.bin\"", "/project", "/project/main.kasm")
+
+	errs := graph.Errors()
+	if len(errs) != 1 || errs[0].Line != 2 || !containsSubstring(errs[0].Message, "failed to find binary file '/project/missing.bin'") {
+		t.Errorf("expected a missing binary error at line 2, got %v", errs)
+	}
+}
+
+// TestBuild_GuardedSelfInclusionNotCyclic verifies FR-5.5: a guarded file
+// re-included by one of its own includes adds no edge, while the same chain
+// without a guard is still a cycle.
+func TestBuild_GuardedSelfInclusionNotCyclic(t *testing.T) {
+	files := map[string]string{
+		"/project/main.kasm": "%include \"a.kasm\"",
+		"/project/a.kasm":    "%pragma once\n%include \"b.kasm\"",
+		"/project/b.kasm":    "%include \"a.kasm\"",
+	}
+	dependency_graph.OsStat = func(name string) (os.FileInfo, error) {
+		if _, ok := files[name]; ok {
+			return &mockFileInfo{}, nil
+		}
+		if name == "/project" {
+			return &mockFileInfo{isDir: true}, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	dependency_graph.OsReadFile = func(name string) ([]byte, error) {
+		if content, ok := files[name]; ok {
+			return []byte(content), nil
+		}
+		return nil, fmt.Errorf("file not found: %s", name)
+	}
+	defer func() {
+		dependency_graph.OsStat = os.Stat
+		dependency_graph.OsReadFile = os.ReadFile
+	}()
+
+	graph := dependency_graph.New(files["/project/main.kasm"], "/project", "/project/main.kasm")
+	if !graph.Acyclic() {
+		t.Errorf("expected the guarded re-inclusion to be a no-op, got cycle %v", graph.CyclePath())
+	}
+
+	files["/project/a.kasm"] = "%include \"b.kasm\""
+	graph = dependency_graph.New(files["/project/main.kasm"], "/project", "/project/main.kasm")
+	if graph.Acyclic() {
+		t.Error("expected the unguarded re-inclusion to be a cycle")
+	}
+}
+
+// TestBuild_SkipsInactiveIncludes verifies FR-4.8: directives in inactive
+// conditional branches are not followed, and definitions in included files
+// decide the branches that follow their %include.
+func TestBuild_SkipsInactiveIncludes(t *testing.T) {
+	files := map[string]string{
+		"/project/main.kasm":   "%include \"config.kasm\"\n%ifdef ARCH_RISCV\n%include \"riscv.kasm\"\n%else\n%include \"x86.kasm\"\n%endif",
+		"/project/config.kasm": "%define ARCH_RISCV",
+		"/project/riscv.kasm":  "nop",
+	}
+	dependency_graph.OsStat = func(name string) (os.FileInfo, error) {
+		if _, ok := files[name]; ok {
+			return &mockFileInfo{}, nil
+		}
+		if name == "/project" {
+			return &mockFileInfo{isDir: true}, nil
+		}
+		return nil, os.ErrNotExist
+	}
+	dependency_graph.OsReadFile = func(name string) ([]byte, error) {
+		if content, ok := files[name]; ok {
+			return []byte(content), nil
+		}
+		return nil, fmt.Errorf("file not found: %s", name)
+	}
+	defer func() {
+		dependency_graph.OsStat = os.Stat
+		dependency_graph.OsReadFile = os.ReadFile
+	}()
+
+	graph := dependency_graph.New(files["/project/main.kasm"], "/project", "/project/main.kasm")
+	if len(graph.Errors()) != 0 {
+		t.Fatalf("expected the missing x86.kasm in the inactive branch to be skipped, got %v", graph.Errors())
+	}
+	if _, ok := graph.Nodes()["/project/riscv.kasm"]; !ok {
+		t.Error("expected a node for riscv.kasm")
+	}
+
+	graph = dependency_graph.NewWithOptions("%ifndef ARCH_RISCV\n%include \"x86.kasm\"\n%endif", "/project",
+		"/project/main.kasm", dependency_graph.Options{DefinedSymbols: map[string]bool{"ARCH_RISCV": true}})
+	if len(graph.Errors()) != 0 || len(graph.Nodes()) != 1 {
+		t.Errorf("expected only the root node, got %v (errors: %v)", graph.Nodes(), graph.Errors())
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FR-12: Dependency listing
+// ---------------------------------------------------------------------------
+
+// TestDependencies verifies FR-12.1: every included file and embedded binary
+// is listed once, sorted, without the root file.
+func TestDependencies(t *testing.T) {
+	dependency_graph.OsStat = func(name string) (os.FileInfo, error) {
+		return &mockFileInfo{isDir: name == "/project"}, nil
+	}
+	dependency_graph.OsReadFile = func(name string) ([]byte, error) {
+		switch name {
+		case "/project/b.kasm":
+			return []byte("%include \"shared.kasm\"\n%incbin \"logo.bin\""), nil
+		case "/project/a.kasm":
+			return []byte(`%include "shared.kasm"`), nil
+		case "/project/shared.kasm":
+			return []byte("nop"), nil
+		default:
+			return nil, fmt.Errorf("file not found: %s", name)
+		}
+	}
+	defer func() {
+		dependency_graph.OsStat = os.Stat
+		dependency_graph.OsReadFile = os.ReadFile
+	}()
+
+	source := "%include \"b.kasm\"\n%include \"a.kasm\""
+	graph := dependency_graph.New(source, "/project", "/project/main.kasm")
+
+	expected := []string{"/project/a.kasm", "/project/b.kasm", "/project/logo.bin", "/project/shared.kasm"}
+	if dependencies := graph.Dependencies(); !slices.Equal(dependencies, expected) {
+		t.Errorf("expected %v, got %v", expected, dependencies)
+	}
+}
+
+// TestDependencies_NoIncludes verifies FR-12.1 for a root without includes.
+func TestDependencies_NoIncludes(t *testing.T) {
+	graph := dependency_graph.New("nop", t.TempDir(), "/project/main.kasm")
+
+	if dependencies := graph.Dependencies(); len(dependencies) != 0 {
+		t.Errorf("expected no dependencies, got %v", dependencies)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // FR-11.3: CyclePath
 // ---------------------------------------------------------------------------
@@ -909,6 +1152,268 @@ func TestToDot_Idempotent(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// FR-11.6 / FR-11.7 / FR-13: Exports and queries
+// ---------------------------------------------------------------------------
+
+// exampleGraph builds a graph rooted at "main" that includes "a" and "b",
+// which both include "shared"; "b" also embeds "logo.bin". "c" and "d"
+// include each other and are not reachable from the root.
+func exampleGraph(t *testing.T) *dependency_graph.Instance {
+	t.Helper()
+	instance := dependency_graph.New("", t.TempDir(), "main")
+	for _, name := range []string{"a", "b", "c", "d", "shared", "logo.bin"} {
+		instance.AddNode(dependency_graph.DependencyGraphNodeNew(name, ""))
+	}
+	nodes := instance.Nodes()
+	for _, edge := range [][3]string{
+		{"include", "main", "a"},
+		{"include", "main", "b"},
+		{"include", "a", "shared"},
+		{"include", "b", "shared"},
+		{"incbin", "b", "logo.bin"},
+		{"include", "c", "d"},
+		{"include", "d", "c"},
+	} {
+		from, to := nodes[edge[1]], nodes[edge[2]]
+		from.AddEdge(dependency_graph.DependencyGraphEdgeNew(edge[0], from, to))
+	}
+	return instance
+}
+
+// repeatedEdges adds a second edge for directives of the example graph, as
+// for a file that includes or embeds the same file twice, and returns the
+// graph.
+func repeatedEdges(instance *dependency_graph.Instance) *dependency_graph.Instance {
+	nodes := instance.Nodes()
+	for _, edge := range [][3]string{
+		{"include", "main", "a"},
+		{"incbin", "b", "logo.bin"},
+		{"include", "d", "c"},
+	} {
+		from, to := nodes[edge[1]], nodes[edge[2]]
+		from.AddEdge(dependency_graph.DependencyGraphEdgeNew(edge[0], from, to))
+	}
+	return instance
+}
+
+// TestToJSON verifies FR-11.6: nodes carry their depth and shared and cycle
+// flags, edges their type and cycle flag.
+func TestToJSON(t *testing.T) {
+	expected := `{
+  "root": "main",
+  "acyclic": false,
+  "nodes": [
+    {
+      "name": "a",
+      "depth": 1,
+      "shared": false,
+      "cycle": false
+    },
+    {
+      "name": "b",
+      "depth": 1,
+      "shared": false,
+      "cycle": false
+    },
+    {
+      "name": "c",
+      "shared": false,
+      "cycle": true
+    },
+    {
+      "name": "d",
+      "shared": false,
+      "cycle": true
+    },
+    {
+      "name": "logo.bin",
+      "depth": 2,
+      "shared": false,
+      "cycle": false
+    },
+    {
+      "name": "main",
+      "depth": 0,
+      "shared": false,
+      "cycle": false
+    },
+    {
+      "name": "shared",
+      "depth": 2,
+      "shared": true,
+      "cycle": false
+    }
+  ],
+  "edges": [
+    {
+      "from": "a",
+      "to": "shared",
+      "type": "include",
+      "cycle": false
+    },
+    {
+      "from": "b",
+      "to": "shared",
+      "type": "include",
+      "cycle": false
+    },
+    {
+      "from": "b",
+      "to": "logo.bin",
+      "type": "incbin",
+      "cycle": false
+    },
+    {
+      "from": "c",
+      "to": "d",
+      "type": "include",
+      "cycle": true
+    },
+    {
+      "from": "d",
+      "to": "c",
+      "type": "include",
+      "cycle": true
+    },
+    {
+      "from": "main",
+      "to": "a",
+      "type": "include",
+      "cycle": false
+    },
+    {
+      "from": "main",
+      "to": "b",
+      "type": "include",
+      "cycle": false
+    }
+  ]
+}`
+	for name, instance := range map[string]*dependency_graph.Instance{
+		"example":        exampleGraph(t),
+		"repeated edges": repeatedEdges(exampleGraph(t)),
+	} {
+		if result := instance.ToJSON(); result != expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", name, expected, result)
+		}
+	}
+}
+
+// TestToJSON_EmptyGraph verifies FR-11.6.3.
+func TestToJSON_EmptyGraph(t *testing.T) {
+	result := dependency_graph.New("", t.TempDir(), "").ToJSON()
+
+	expected := "{\n  \"acyclic\": true,\n  \"nodes\": [],\n  \"edges\": []\n}"
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+// TestToMermaid verifies FR-11.7: sorted node identifiers, dotted incbin
+// edges, red cycle edges and the shared and cycle classes.
+func TestToMermaid(t *testing.T) {
+	expected := `flowchart TD
+    n0["a"]
+    n1["b"]
+    n2["c"]
+    n3["d"]
+    n4["logo.bin"]
+    n5["main"]
+    n6["shared"]
+    n0 -->|include| n6
+    n1 -->|include| n6
+    n1 -.->|incbin| n4
+    n2 -->|include| n3
+    n3 -->|include| n2
+    n5 -->|include| n0
+    n5 -->|include| n1
+    linkStyle 3,4 stroke:red
+    classDef shared stroke-dasharray:5 5
+    class n6 shared
+    classDef cycle stroke:red
+    class n2,n3 cycle`
+	for name, instance := range map[string]*dependency_graph.Instance{
+		"example":        exampleGraph(t),
+		"repeated edges": repeatedEdges(exampleGraph(t)),
+	} {
+		if result := instance.ToMermaid(); result != expected {
+			t.Errorf("%s: expected:\n%s\ngot:\n%s", name, expected, result)
+		}
+	}
+}
+
+// TestToMermaid_EmptyGraph verifies FR-11.7.4.
+func TestToMermaid_EmptyGraph(t *testing.T) {
+	if result := dependency_graph.New("", t.TempDir(), "").ToMermaid(); result != "flowchart TD" {
+		t.Errorf("expected an empty flowchart, got %q", result)
+	}
+}
+
+// TestToMermaid_QuotesNames verifies that a double quote in a node name does
+// not end its label.
+func TestToMermaid_QuotesNames(t *testing.T) {
+	instance := dependency_graph.New("", t.TempDir(), "")
+	instance.AddNode(dependency_graph.DependencyGraphNodeNew(`say "hi".kasm`, ""))
+
+	if result := instance.ToMermaid(); !containsSubstring(result, `n0["say #quot;hi#quot;.kasm"]`) {
+		t.Errorf("expected the quotes to be escaped, got:\n%s", result)
+	}
+}
+
+// TestReverseDependencies verifies FR-13.1: direct and indirect dependents
+// are listed, sorted, without the node itself.
+func TestReverseDependencies(t *testing.T) {
+	instance := exampleGraph(t)
+
+	tests := map[string][]string{
+		"shared":   {"a", "b", "main"},
+		"logo.bin": {"b", "main"},
+		"main":     {},
+		"c":        {"d"},
+		"missing":  {},
+	}
+	for name, expected := range tests {
+		if reverse := instance.ReverseDependencies(name); !slices.Equal(reverse, expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, reverse)
+		}
+	}
+}
+
+// TestTopologicalOrder verifies FR-13.2: every node precedes the nodes it
+// depends on, ties are broken by name, and a cyclic graph has no order.
+func TestTopologicalOrder(t *testing.T) {
+	instance := dependency_graph.New("", t.TempDir(), "")
+	nodes := make(map[string]*dependency_graph.DependencyGraphNode)
+	for _, name := range []string{"main", "z", "b", "shared"} {
+		nodes[name] = dependency_graph.DependencyGraphNodeNew(name, "")
+		instance.AddNode(nodes[name])
+	}
+	for _, edge := range [][2]string{{"main", "z"}, {"main", "b"}, {"z", "shared"}, {"b", "shared"}} {
+		nodes[edge[0]].AddEdge(dependency_graph.DependencyGraphEdgeNew("include", nodes[edge[0]], nodes[edge[1]]))
+	}
+
+	expected := []string{"main", "b", "z", "shared"}
+	if order := instance.TopologicalOrder(); !slices.Equal(order, expected) {
+		t.Errorf("expected %v, got %v", expected, order)
+	}
+
+	if order := exampleGraph(t).TopologicalOrder(); order != nil {
+		t.Errorf("expected no order for a cyclic graph, got %v", order)
+	}
+}
+
+// TestDepths verifies FR-13.3: the shortest distance from the root, for the
+// reachable nodes only.
+func TestDepths(t *testing.T) {
+	depths := exampleGraph(t).Depths()
+
+	expected := map[string]int{"main": 0, "a": 1, "b": 1, "shared": 2, "logo.bin": 2}
+	if !maps.Equal(depths, expected) {
+		t.Errorf("expected %v, got %v", expected, depths)
+	}
+}
+
 // --- helpers ---
 
 func containsSubstring(s, substr string) bool {
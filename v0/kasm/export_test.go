@@ -0,0 +1,9 @@
+package kasm
+
+import "github.com/keurnel/assembler/v0/kasm/ast"
+
+// ComputeInstructionSize exposes the Pass 1 size computation to the external
+// test package so that it can be checked against the Pass 2 output.
+func (g *Generator) ComputeInstructionSize(s *ast.InstructionStmt) int {
+	return g.computeInstructionSize(s)
+}
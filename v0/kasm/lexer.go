@@ -21,9 +21,10 @@ type Lexer struct {
 	Line   int // Current line number (for error reporting).
 	Column int // Current column number (for error reporting).
 
-	Tokens   []Token
-	profile  profile.ArchitectureProfile // Architecture-specific vocabulary for classification.
-	debugCtx *debugcontext.DebugContext  // Optional debug context for diagnostic recording. May be nil.
+	Tokens     []Token
+	profile    profile.ArchitectureProfile // Architecture-specific vocabulary for classification.
+	debugCtx   *debugcontext.DebugContext  // Optional debug context for diagnostic recording. May be nil.
+	lineMapper LineMapper                  // Optional mapping to original line numbers for diagnostics. May be nil.
 }
 
 // LexerNew is the sole constructor. It accepts the pre-processed source string
@@ -57,6 +58,15 @@ func (l *Lexer) WithDebugContext(ctx *debugcontext.DebugContext) *Lexer {
 	return l
 }
 
+// WithLineMapper attaches a LineMapper that translates pre-processed line
+// numbers back to original source line numbers, as for the analyser. When
+// set, every warning recorded via debugCtx uses the mapped line number; token
+// positions are left as they are. Returns the lexer for chaining.
+func (l *Lexer) WithLineMapper(m LineMapper) *Lexer {
+	l.lineMapper = m
+	return l
+}
+
 // previousTokenType - returns the type of the most recently emitted token, or
 // -1 if no tokens have been emitted yet. Because the Tokens slice is
 // initialised as non-nil and empty, a length check is sufficient — no nil
@@ -68,6 +78,36 @@ func (l *Lexer) previousTokenType() TokenType {
 	return l.Tokens[len(l.Tokens)-1].Type
 }
 
+// atOperandStart - returns true when the next token begins an operand, i.e.
+// the previous token is an instruction mnemonic or an operand separator.
+func (l *Lexer) atOperandStart() bool {
+	if len(l.Tokens) == 0 {
+		return false
+	}
+	prev := l.Tokens[len(l.Tokens)-1]
+	if prev.Type == TokenKeyword && dataKeywords[strings.ToLower(prev.Literal)] {
+		return true
+	}
+	return prev.Type == TokenInstruction || (prev.Type == TokenIdentifier && prev.Literal == ",")
+}
+
+// namingKeywords are the keywords followed by a name. The word after one of
+// them is always an identifier (FR-4.6.8); the word after any other keyword,
+// such as `endstruc` at the end of a line, is classified as usual.
+var namingKeywords = map[string]bool{
+	"namespace": true,
+	"struc":     true,
+	"istruc":    true,
+	"at":        true,
+}
+
+// dataKeywords are the keywords followed by values, like an instruction is
+// followed by operands, so a '-' after them starts a negative immediate.
+var dataKeywords = map[string]bool{
+	"db": true, "dw": true, "dd": true, "dq": true,
+	"resb": true, "resw": true, "resd": true, "resq": true,
+}
+
 // readChar - reads the next character from the input and advances the positions accordingly.
 func (l *Lexer) readChar() {
 	if l.ReadPosition >= len(l.Input) {
@@ -138,21 +178,23 @@ func (l *Lexer) readNumber() string {
 	return l.Input[start:l.Position]
 }
 
-// readString - reads a string literal enclosed in double quotes.
+// readString - reads a string literal enclosed in double or single quotes.
+// An unterminated literal ends at the end of its line.
 func (l *Lexer) readString() string {
 	startLine := l.Line
 	startCol := l.Column
-	l.readChar() // skip opening '"'
+	quote := l.Ch
+	l.readChar() // skip opening quote
 	start := l.Position
-	for l.Ch != '"' && l.Ch != 0 {
+	for l.Ch != quote && l.Ch != '\n' && l.Ch != 0 {
 		l.readChar()
 	}
 	str := l.Input[start:l.Position]
-	if l.Ch == '"' {
-		l.readChar() // skip closing '"'
+	if l.Ch == quote {
+		l.readChar() // skip closing quote
 	} else if l.debugCtx != nil {
 		l.debugCtx.Warning(
-			l.debugCtx.Loc(startLine, startCol),
+			l.debugCtx.Loc(originLine(l.lineMapper, startLine), startCol),
 			"unterminated string literal",
 		)
 	}
@@ -193,9 +235,12 @@ func (l *Lexer) readDirective() string {
 // (FR-4.8.1). It is checked before profile lookups and takes precedence over
 // any profile entry with the same name (FR-4.8.4).
 func classifyWord(word string, lexer *Lexer) TokenType {
-	// Context-sensitive override: keyword and section arguments are always identifiers.
+	// Context-sensitive override: keyword names and section arguments are always identifiers.
 	prev := lexer.previousTokenType()
-	if prev == TokenKeyword || prev == TokenSection {
+	if prev == TokenSection {
+		return TokenIdentifier
+	}
+	if prev == TokenKeyword && namingKeywords[strings.ToLower(lexer.Tokens[len(lexer.Tokens)-1].Literal)] {
 		return TokenIdentifier
 	}
 
@@ -254,8 +299,8 @@ func (l *Lexer) Start() []Token {
 			directive := l.readDirective()
 			l.addToken(TokenDirective, directive, line, col)
 
-		// String literal — "...".
-		case l.Ch == '"':
+		// String literal — "..." or '...'.
+		case l.Ch == '"' || l.Ch == '\'':
 			str := l.readString()
 			l.addToken(TokenString, str, line, col)
 
@@ -264,6 +309,32 @@ func (l *Lexer) Start() []Token {
 			num := l.readNumber()
 			l.addToken(TokenImmediate, num, line, col)
 
+		// Negative numeric literal — only where an operand starts, so that
+		// '-' keeps acting as an operator inside memory operands.
+		case l.Ch == '-' && isDigit(l.peekChar()) && l.atOperandStart():
+			l.readChar() // skip '-'
+			num := l.readNumber()
+			l.addToken(TokenImmediate, "-"+num, line, col)
+
+		// Immediate with a '#' prefix (AArch64 style, e.g. #16 or #-8). The
+		// '#' is dropped so the literal reads like any other immediate.
+		case l.Ch == '#' && (isDigit(l.peekChar()) || l.peekChar() == '-'):
+			l.readChar() // skip '#'
+			sign := ""
+			if l.Ch == '-' {
+				sign = "-"
+				l.readChar()
+			}
+			num := l.readNumber()
+			l.addToken(TokenImmediate, sign+num, line, col)
+
+		// A '#' prefix before a name (#task_size) marks a structure symbol
+		// used as an immediate; the '#' is dropped and the name is an
+		// identifier.
+		case l.Ch == '#' && (isLetter(l.peekChar()) || l.peekChar() == '_'):
+			l.readChar() // skip '#'
+			l.addToken(TokenIdentifier, l.readWord(), line, col)
+
 		// Word — could be an instruction, register or identifier.
 		case isLetter(l.Ch) || l.Ch == '_' || l.Ch == '.':
 			word := l.readWord()
@@ -308,5 +379,5 @@ func isHexDigit(ch byte) bool {
 }
 
 func isWordChar(ch byte) bool {
-	return isLetter(ch) || isDigit(ch) || ch == '_' || ch == '.'
+	return isLetter(ch) || isDigit(ch) || ch == '_' || ch == '.' || ch == '@'
 }
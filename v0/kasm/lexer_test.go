@@ -182,6 +182,36 @@ func TestLexer_ImmediateZero(t *testing.T) {
 	requireToken(t, tokens[0], kasm.TokenImmediate, "0")
 }
 
+func TestLexer_ImmediateNegative(t *testing.T) {
+	tokens := kasm.LexerNew("mov rax, -1", x86Profile).Start()
+	requireTokenCount(t, tokens, 4)
+	requireToken(t, tokens[3], kasm.TokenImmediate, "-1")
+}
+
+func TestLexer_ImmediateHashPrefix(t *testing.T) {
+	tokens := kasm.LexerNew("#16 #-8 #0x10", x86Profile).Start()
+	requireTokenCount(t, tokens, 3)
+	requireToken(t, tokens[0], kasm.TokenImmediate, "16")
+	requireToken(t, tokens[1], kasm.TokenImmediate, "-8")
+	requireToken(t, tokens[2], kasm.TokenImmediate, "0x10")
+}
+
+func TestLexer_HashPrefixedName(t *testing.T) {
+	tokens := kasm.LexerNew("#task_size #task.pid", x86Profile).Start()
+	requireTokenCount(t, tokens, 2)
+	requireToken(t, tokens[0], kasm.TokenIdentifier, "task_size")
+	requireToken(t, tokens[1], kasm.TokenIdentifier, "task.pid")
+}
+
+func TestLexer_MinusInsideMemoryOperand(t *testing.T) {
+	tokens := kasm.LexerNew("mov rax, [rbp - 8]", x86Profile).Start()
+	for _, token := range tokens {
+		if token.Type == kasm.TokenImmediate && token.Literal != "8" {
+			t.Errorf("expected '-' to stay an operator, got immediate %q", token.Literal)
+		}
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Tests: string literals
 // ---------------------------------------------------------------------------
@@ -220,6 +250,13 @@ func TestLexer_DotPrefixedLabel(t *testing.T) {
 	requireToken(t, tokens[0], kasm.TokenIdentifier, ".loop:")
 }
 
+func TestLexer_MacroLocalLabel(t *testing.T) {
+	tokens := kasm.LexerNew("..@3.retry: jmp ..@3.retry", x86Profile).Start()
+	requireTokenCount(t, tokens, 3)
+	requireToken(t, tokens[0], kasm.TokenIdentifier, "..@3.retry:")
+	requireToken(t, tokens[2], kasm.TokenIdentifier, "..@3.retry")
+}
+
 func TestLexer_IdentifierWithDot(t *testing.T) {
 	tokens := kasm.LexerNew("section.text", x86Profile).Start()
 	requireTokenCount(t, tokens, 1)
@@ -452,6 +489,20 @@ func TestLexer_UnterminatedString(t *testing.T) {
 	requireToken(t, tokens[0], kasm.TokenString, "unterminated")
 }
 
+func TestLexer_UnterminatedStringEndsAtLine(t *testing.T) {
+	tokens := kasm.LexerNew("db \"open\nnop", x86Profile).Start()
+	requireTokenCount(t, tokens, 3)
+	requireToken(t, tokens[1], kasm.TokenString, "open")
+	requireToken(t, tokens[2], kasm.TokenInstruction, "nop")
+}
+
+func TestLexer_SingleQuotedString(t *testing.T) {
+	tokens := kasm.LexerNew(`db 'say "hi";', "it's"`, x86Profile).Start()
+	requireTokenCount(t, tokens, 4)
+	requireToken(t, tokens[1], kasm.TokenString, `say "hi";`)
+	requireToken(t, tokens[3], kasm.TokenString, "it's")
+}
+
 func TestLexer_DirectiveAlone(t *testing.T) {
 	tokens := kasm.LexerNew("%endif", x86Profile).Start()
 	requireTokenCount(t, tokens, 1)
@@ -644,3 +695,22 @@ message:
 		kasm.LexerNew(source, x86Profile).Start()
 	}
 }
+
+func TestLexer_StrucKeywords(t *testing.T) {
+	source := `struc mov
+endstruc
+section .data: d
+at mov.x, dd -1`
+	tokens := kasm.LexerNew(source, x86Profile).Start()
+	requireTokenCount(t, tokens, 11)
+	// The name after struc is an identifier, even if it is an instruction.
+	requireToken(t, tokens[0], kasm.TokenKeyword, "struc")
+	requireToken(t, tokens[1], kasm.TokenIdentifier, "mov")
+	// endstruc takes no name, so the section keyword after it is kept.
+	requireToken(t, tokens[2], kasm.TokenKeyword, "endstruc")
+	requireToken(t, tokens[3], kasm.TokenSection, "section")
+	requireToken(t, tokens[7], kasm.TokenIdentifier, "mov.x")
+	// A '-' after a data keyword starts a negative immediate.
+	requireToken(t, tokens[9], kasm.TokenKeyword, "dd")
+	requireToken(t, tokens[10], kasm.TokenImmediate, "-1")
+}
@@ -24,6 +24,10 @@ type Parser struct {
 
 	// debugCtx is an optional debug context for diagnostic recording. May be nil.
 	debugCtx *debugcontext.DebugContext
+
+	// lineMapper optionally maps pre-processed line numbers to original
+	// source line numbers for diagnostics. May be nil.
+	lineMapper LineMapper
 }
 
 // ParserNew is the sole constructor. It accepts the []Token slice produced by
@@ -49,6 +53,15 @@ func (p *Parser) WithDebugContext(ctx *debugcontext.DebugContext) *Parser {
 	return p
 }
 
+// WithLineMapper attaches a LineMapper that translates pre-processed line
+// numbers back to original source line numbers, as for the analyser. When
+// set, every error recorded via debugCtx uses the mapped line number.
+// Returns the parser for chaining.
+func (p *Parser) WithLineMapper(m LineMapper) *Parser {
+	p.lineMapper = m
+	return p
+}
+
 // ---------------------------------------------------------------------------
 // Token consumption helpers (FR-4)
 // ---------------------------------------------------------------------------
@@ -102,7 +115,8 @@ func (p *Parser) isAtEnd() bool {
 }
 
 // addError records a parse error at the given position. If a debug context
-// is attached, the error is also recorded there.
+// is attached, the error is also recorded there, at the original line if a
+// LineMapper is attached.
 func (p *Parser) addError(message string, line, column int) {
 	p.errors = append(p.errors, ParseError{
 		Message: message,
@@ -111,7 +125,7 @@ func (p *Parser) addError(message string, line, column int) {
 	})
 	if p.debugCtx != nil {
 		p.debugCtx.Error(
-			p.debugCtx.Loc(line, column),
+			p.debugCtx.Loc(originLine(p.lineMapper, line), column),
 			message,
 		)
 	}
@@ -127,11 +141,28 @@ func (p *Parser) addErrorAtCurrent(message string) {
 // Recovery (FR-5)
 // ---------------------------------------------------------------------------
 
+// relocationFunctions lists the `%`-prefixed functions that may be applied to
+// a symbol inside an operand (e.g. `%hi(label)`). They are lexed as directives
+// but never start a statement.
+var relocationFunctions = map[string]bool{
+	"%hi":   true,
+	"%lo":   true,
+	"%lo12": true,
+}
+
+// isRelocationFunction returns true if the token is a relocation function
+// such as `%hi` or `%lo`.
+func isRelocationFunction(tok Token) bool {
+	return tok.Type == TokenDirective && relocationFunctions[strings.ToLower(tok.Literal)]
+}
+
 // isStatementStart returns true if the given token can begin a new statement.
 func isStatementStart(tok Token) bool {
 	switch tok.Type {
-	case TokenInstruction, TokenKeyword, TokenDirective, TokenSection:
+	case TokenInstruction, TokenKeyword, TokenSection:
 		return true
+	case TokenDirective:
+		return !isRelocationFunction(tok)
 	case TokenIdentifier:
 		// Labels (trailing ':') start a statement.
 		return strings.HasSuffix(tok.Literal, ":")
@@ -308,11 +339,22 @@ func (p *Parser) parseOperand() ast.Operand {
 		p.advance()
 		return &ast.StringOperand{Value: tok.Literal, Line: tok.Line, Column: tok.Column}
 
+	case TokenDirective:
+		// Relocation function → %hi(symbol) / %lo(symbol).
+		if isRelocationFunction(tok) {
+			return p.parseRelocationOperand()
+		}
+		return nil
+
 	case TokenIdentifier:
 		// Opening bracket → memory operand.
 		if tok.Literal == "[" {
 			return p.parseMemoryOperand()
 		}
+		// '=' → literal pool reference.
+		if tok.Literal == "=" {
+			return p.parseLiteralOperand()
+		}
 		// Closing bracket or comma → not an operand, stop.
 		if tok.Literal == "]" || tok.Literal == "," {
 			return nil
@@ -334,9 +376,14 @@ func (p *Parser) parseMemoryOperand() ast.Operand {
 	for !p.isAtEnd() {
 		tok := p.current()
 
-		// Closing bracket — consume and return.
+		// Closing bracket — consume and return. A '!' directly after the
+		// bracket (pre-index writeback) is kept as a trailing component.
 		if tok.Type == TokenIdentifier && tok.Literal == "]" {
 			p.advance()
+			if bang := p.current(); !p.isAtEnd() && bang.Type == TokenIdentifier && bang.Literal == "!" {
+				components = append(components, ast.MemoryComponent{Token: bang})
+				p.advance()
+			}
 			return &ast.MemoryOperand{
 				Components: components,
 				Line:       openBracket.Line,
@@ -363,6 +410,64 @@ func (p *Parser) parseMemoryOperand() ast.Operand {
 	}
 }
 
+// parseRelocationOperand parses a relocation function applied to a symbol,
+// e.g. `%hi(label)`. The function token must be the current token.
+func (p *Parser) parseRelocationOperand() ast.Operand {
+	fnTok := p.advance() // consume '%hi' / '%lo'
+
+	if open := p.current(); open.Type != TokenIdentifier || open.Literal != "(" {
+		p.addError("expected '(' after "+fnTok.Literal, fnTok.Line, fnTok.Column)
+		return nil
+	}
+	p.advance() // consume '('
+
+	symTok, ok := p.expect(TokenIdentifier)
+	if !ok || symTok.Literal == ")" {
+		p.addError("expected symbol name in "+fnTok.Literal+"(...)", fnTok.Line, fnTok.Column)
+		return nil
+	}
+
+	if closeTok := p.current(); closeTok.Type != TokenIdentifier || closeTok.Literal != ")" {
+		p.addError("expected ')' after symbol in "+fnTok.Literal+"(...)", fnTok.Line, fnTok.Column)
+		return nil
+	}
+	p.advance() // consume ')'
+
+	return &ast.RelocationOperand{
+		Function: strings.ToLower(fnTok.Literal[1:]),
+		Symbol:   symTok.Literal,
+		Line:     fnTok.Line,
+		Column:   fnTok.Column,
+	}
+}
+
+// parseLiteralOperand parses a literal pool reference, e.g. `=0x1234` or
+// `=label`. The '=' must be the current token.
+func (p *Parser) parseLiteralOperand() ast.Operand {
+	eqTok := p.advance() // consume '='
+
+	valTok := p.current()
+	var value ast.Operand
+	switch {
+	case p.isAtEnd():
+	case valTok.Type == TokenImmediate:
+		value = &ast.ImmediateOperand{Value: valTok.Literal, Line: valTok.Line, Column: valTok.Column}
+	case valTok.Type == TokenIdentifier && valTok.Literal != "," && valTok.Literal != "[" && valTok.Literal != "]":
+		value = &ast.IdentifierOperand{Name: valTok.Literal, Line: valTok.Line, Column: valTok.Column}
+	}
+	if value == nil {
+		p.addError("expected immediate or label after '='", eqTok.Line, eqTok.Column)
+		return nil
+	}
+	p.advance() // consume the value
+
+	return &ast.LiteralOperand{
+		Value:  value,
+		Line:   eqTok.Line,
+		Column: eqTok.Column,
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Label parsing (FR-8)
 // ---------------------------------------------------------------------------
@@ -387,8 +492,36 @@ func (p *Parser) parseLabel() ast.Statement {
 func (p *Parser) parseKeyword() ast.Statement {
 	tok := p.current()
 
-	if strings.EqualFold(tok.Literal, "namespace") {
+	switch strings.ToLower(tok.Literal) {
+	case "namespace":
 		return p.parseNamespace()
+	case "struc":
+		return p.parseStruc()
+	case "istruc":
+		return p.parseIstruc()
+	case "endstruc":
+		p.addErrorAtCurrent("'endstruc' without matching 'struc'")
+		p.recover()
+		return nil
+	case "iend":
+		p.addErrorAtCurrent("'iend' without matching 'istruc'")
+		p.recover()
+		return nil
+	case "at":
+		p.addErrorAtCurrent("'at' outside of an 'istruc' block")
+		p.advance()
+		p.skipToStatementStart()
+		return nil
+	}
+	if reservationSizes[strings.ToLower(tok.Literal)] > 0 {
+		p.addErrorAtCurrent("'" + tok.Literal + "' outside of a 'struc' block")
+		p.recover()
+		return nil
+	}
+	if dataSizes[strings.ToLower(tok.Literal)] > 0 {
+		p.addErrorAtCurrent("'" + tok.Literal + "' outside of an 'at' line")
+		p.recover()
+		return nil
 	}
 
 	// Unknown keyword.
@@ -423,6 +556,218 @@ func (p *Parser) parseNamespace() ast.Statement {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// Structure parsing (FR-13)
+// ---------------------------------------------------------------------------
+
+// parseStruc parses a `struc NAME` block of `.field: resX count` lines up to
+// its `endstruc` (FR-13.1). A token that cannot start a field ends the block
+// with an error, so a missing `endstruc` does not swallow the statements that
+// follow it.
+func (p *Parser) parseStruc() ast.Statement {
+	kwTok := p.advance() // consume 'struc' keyword
+
+	nameTok, ok := p.expectName()
+	if !ok {
+		p.addError("expected structure name after 'struc', got "+describeToken(nameTok), kwTok.Line, kwTok.Column)
+		p.recover()
+		return nil
+	}
+
+	stmt := &ast.StrucStmt{
+		Name:   nameTok.Literal,
+		Fields: make([]*ast.StrucField, 0),
+		Line:   kwTok.Line,
+		Column: kwTok.Column,
+	}
+
+	for !p.isAtEnd() {
+		tok := p.current()
+		if tok.Type == TokenKeyword && strings.EqualFold(tok.Literal, "endstruc") {
+			p.advance()
+			return stmt
+		}
+		if tok.Type != TokenIdentifier {
+			break
+		}
+		if field := p.parseStrucField(); field != nil {
+			stmt.Fields = append(stmt.Fields, field)
+		}
+	}
+
+	p.addError("unterminated structure '"+stmt.Name+"', expected 'endstruc'", kwTok.Line, kwTok.Column)
+	return stmt
+}
+
+// parseStrucField parses a single `.field: resX count` line (FR-13.2). The
+// leading '.' and the trailing ':' of the field name are both optional.
+func (p *Parser) parseStrucField() *ast.StrucField {
+	nameTok := p.advance()
+	name := strings.TrimPrefix(strings.TrimSuffix(nameTok.Literal, ":"), ".")
+	if name == "" || isPunctuation(nameTok.Literal) {
+		p.addError("expected structure field name, got "+nameTok.Literal, nameTok.Line, nameTok.Column)
+		p.skipToStatementStart()
+		return nil
+	}
+
+	resTok := p.current()
+	if p.isAtEnd() || resTok.Type != TokenKeyword || reservationSizes[strings.ToLower(resTok.Literal)] == 0 {
+		p.addError("expected resb, resw, resd or resq after structure field '"+name+"'", nameTok.Line, nameTok.Column)
+		p.skipToStatementStart()
+		return nil
+	}
+	p.advance()
+
+	countTok, ok := p.expect(TokenImmediate)
+	if !ok {
+		p.addError("expected reservation count after '"+resTok.Literal+"'", resTok.Line, resTok.Column)
+		p.skipToStatementStart()
+		return nil
+	}
+
+	return &ast.StrucField{
+		Name:    name,
+		Reserve: strings.ToLower(resTok.Literal),
+		Count:   &ast.ImmediateOperand{Value: countTok.Literal, Line: countTok.Line, Column: countTok.Column},
+		Line:    nameTok.Line,
+		Column:  nameTok.Column,
+	}
+}
+
+// parseIstruc parses an `istruc NAME` block of `at` lines up to its `iend`
+// (FR-13.3). Like parseStruc, a token that cannot start an `at` line ends the
+// block with an error.
+func (p *Parser) parseIstruc() ast.Statement {
+	kwTok := p.advance() // consume 'istruc' keyword
+
+	nameTok, ok := p.expectName()
+	if !ok {
+		p.addError("expected structure name after 'istruc', got "+describeToken(nameTok), kwTok.Line, kwTok.Column)
+		p.recover()
+		return nil
+	}
+
+	stmt := &ast.IstrucStmt{
+		Name:   nameTok.Literal,
+		Values: make([]*ast.AtValue, 0),
+		Line:   kwTok.Line,
+		Column: kwTok.Column,
+	}
+
+	for !p.isAtEnd() {
+		tok := p.current()
+		if tok.Type != TokenKeyword {
+			break
+		}
+		if strings.EqualFold(tok.Literal, "iend") {
+			p.advance()
+			return stmt
+		}
+		if !strings.EqualFold(tok.Literal, "at") {
+			break
+		}
+		if value := p.parseAt(); value != nil {
+			stmt.Values = append(stmt.Values, value)
+		}
+	}
+
+	p.addError("unterminated structure instance '"+stmt.Name+"', expected 'iend'", kwTok.Line, kwTok.Column)
+	return stmt
+}
+
+// parseAt parses a single `at NAME.field, dX value, …` line (FR-13.4).
+func (p *Parser) parseAt() *ast.AtValue {
+	atTok := p.advance() // consume 'at' keyword
+
+	fieldTok, ok := p.expectName()
+	if !ok {
+		p.addError("expected structure field after 'at', got "+describeToken(fieldTok), atTok.Line, atTok.Column)
+		p.skipToStatementStart()
+		return nil
+	}
+
+	if comma := p.current(); p.isAtEnd() || comma.Type != TokenIdentifier || comma.Literal != "," {
+		p.addError("expected ',' after structure field '"+fieldTok.Literal+"'", fieldTok.Line, fieldTok.Column)
+		p.skipToStatementStart()
+		return nil
+	}
+	p.advance()
+
+	dataTok := p.current()
+	if p.isAtEnd() || dataTok.Type != TokenKeyword || dataSizes[strings.ToLower(dataTok.Literal)] == 0 {
+		p.addError("expected db, dw, dd or dq after structure field '"+fieldTok.Literal+"'", fieldTok.Line, fieldTok.Column)
+		p.skipToStatementStart()
+		return nil
+	}
+	p.advance()
+
+	operands := p.parseOperandList()
+	if len(operands) == 0 {
+		p.addError("expected value after '"+dataTok.Literal+"'", dataTok.Line, dataTok.Column)
+		return nil
+	}
+
+	return &ast.AtValue{
+		Field:    fieldTok.Literal,
+		Data:     strings.ToLower(dataTok.Literal),
+		Operands: operands,
+		Line:     atTok.Line,
+		Column:   atTok.Column,
+	}
+}
+
+// expectName consumes and returns the current token if it is a name: an
+// identifier that is neither punctuation nor a label.
+func (p *Parser) expectName() (Token, bool) {
+	tok := p.current()
+	if p.isAtEnd() || tok.Type != TokenIdentifier || isPunctuation(tok.Literal) || strings.HasSuffix(tok.Literal, ":") {
+		return tok, false
+	}
+	p.advance()
+	return tok, true
+}
+
+// skipToStatementStart advances past tokens until the start of a statement
+// or the end of input. Unlike recover, it consumes nothing when the current
+// token already starts a statement, so the next line of a block is kept.
+// Reservation and data keywords never start a statement of their own, so
+// they are skipped with the rest of the line.
+func (p *Parser) skipToStatementStart() {
+	for !p.isAtEnd() {
+		tok := p.current()
+		if isStatementStart(tok) && !isValueKeyword(tok) {
+			return
+		}
+		p.advance()
+	}
+}
+
+// isValueKeyword reports whether tok is a reservation (resb…resq) or data
+// (db…dq) keyword.
+func isValueKeyword(tok Token) bool {
+	lower := strings.ToLower(tok.Literal)
+	return tok.Type == TokenKeyword && (reservationSizes[lower] > 0 || dataSizes[lower] > 0)
+}
+
+// isPunctuation reports whether literal is a single-character punctuation
+// token, which the lexer emits as a TokenIdentifier (e.g. ',' or '[').
+func isPunctuation(literal string) bool {
+	if len(literal) != 1 {
+		return false
+	}
+	ch := literal[0]
+	return !(ch == '_' || ch == '.' || ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z')
+}
+
+// describeToken returns the literal of tok for an error message, or
+// "end of input" for the sentinel token.
+func describeToken(tok Token) string {
+	if tok.Literal == "" {
+		return "end of input"
+	}
+	return tok.Literal
+}
+
 // ---------------------------------------------------------------------------
 // Use parsing (FR-10)
 // ---------------------------------------------------------------------------
@@ -457,6 +802,11 @@ func (p *Parser) parseUse(useTok Token) ast.Statement {
 func (p *Parser) parseDirective() ast.Statement {
 	dirTok := p.advance() // consume the directive
 
+	// FR-11.4: %incbin has a fixed argument list of its own.
+	if strings.EqualFold(dirTok.Literal, "%incbin") {
+		return p.parseIncbin(dirTok)
+	}
+
 	args := make([]Token, 0)
 	for !p.isAtEnd() {
 		tok := p.current()
@@ -480,6 +830,41 @@ func (p *Parser) parseDirective() ast.Statement {
 	}
 }
 
+// parseIncbin parses the arguments of an `%incbin "file"[, offset[, length]]`
+// directive whose token has already been consumed (FR-11.4).
+func (p *Parser) parseIncbin(dirTok Token) ast.Statement {
+	operands := p.parseOperandList()
+
+	if len(operands) == 0 {
+		p.addError("expected file path string after %incbin", dirTok.Line, dirTok.Column)
+		return nil
+	}
+	path, ok := operands[0].(*ast.StringOperand)
+	if !ok {
+		p.addError("expected file path string after %incbin", operands[0].OperandLine(), operands[0].OperandColumn())
+		return nil
+	}
+	if len(operands) > 3 {
+		p.addError("too many arguments to %incbin, expected a file, an offset and a length", operands[3].OperandLine(), operands[3].OperandColumn())
+		return nil
+	}
+
+	stmt := &ast.IncbinStmt{Path: path.Value, Line: dirTok.Line, Column: dirTok.Column}
+	for i, operand := range operands[1:] {
+		immediate, ok := operand.(*ast.ImmediateOperand)
+		if !ok {
+			p.addError("%incbin offset and length must be immediate values", operand.OperandLine(), operand.OperandColumn())
+			return nil
+		}
+		if i == 0 {
+			stmt.Offset = immediate
+		} else {
+			stmt.Length = immediate
+		}
+	}
+	return stmt
+}
+
 // ---------------------------------------------------------------------------
 // Section parsing (FR-12)
 // ---------------------------------------------------------------------------
@@ -1,6 +1,7 @@
 package kasm_test
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/keurnel/assembler/v0/kasm"
@@ -291,6 +292,113 @@ func TestParse_MemoryOperandWithDisplacement(t *testing.T) {
 	}
 }
 
+func TestParse_RelocationOperand(t *testing.T) {
+	// lui a0, %hi(msg)
+	tokens := []kasm.Token{
+		tok(kasm.TokenInstruction, "lui", 1, 1),
+		tok(kasm.TokenRegister, "a0", 1, 5),
+		tok(kasm.TokenIdentifier, ",", 1, 7),
+		tok(kasm.TokenDirective, "%hi", 1, 9),
+		tok(kasm.TokenIdentifier, "(", 1, 12),
+		tok(kasm.TokenIdentifier, "msg", 1, 13),
+		tok(kasm.TokenIdentifier, ")", 1, 16),
+	}
+	program, errors := kasm.ParserNew(tokens).Parse()
+	requireNoErrors(t, errors)
+	requireStatementCount(t, program, 1)
+
+	stmt := program.Statements[0].(*ast.InstructionStmt)
+	if len(stmt.Operands) != 2 {
+		t.Fatalf("expected 2 operands, got %d", len(stmt.Operands))
+	}
+	reloc, ok := stmt.Operands[1].(*ast.RelocationOperand)
+	if !ok {
+		t.Fatalf("expected *RelocationOperand, got %T", stmt.Operands[1])
+	}
+	if reloc.Function != "hi" || reloc.Symbol != "msg" {
+		t.Errorf("expected hi(msg), got %s(%s)", reloc.Function, reloc.Symbol)
+	}
+}
+
+func TestParse_RelocationOperandMissingParen(t *testing.T) {
+	// lui a0, %hi msg
+	tokens := []kasm.Token{
+		tok(kasm.TokenInstruction, "lui", 1, 1),
+		tok(kasm.TokenRegister, "a0", 1, 5),
+		tok(kasm.TokenIdentifier, ",", 1, 7),
+		tok(kasm.TokenDirective, "%hi", 1, 9),
+		tok(kasm.TokenIdentifier, "msg", 1, 13),
+	}
+	_, errors := kasm.ParserNew(tokens).Parse()
+	if len(errors) == 0 {
+		t.Fatal("expected a parse error for missing '('")
+	}
+}
+
+func TestParse_MemoryOperandWriteback(t *testing.T) {
+	// stp x29, x30, [sp, -16]!
+	tokens := []kasm.Token{
+		tok(kasm.TokenInstruction, "stp", 1, 1),
+		tok(kasm.TokenRegister, "x29", 1, 5),
+		tok(kasm.TokenIdentifier, ",", 1, 8),
+		tok(kasm.TokenRegister, "x30", 1, 10),
+		tok(kasm.TokenIdentifier, ",", 1, 13),
+		tok(kasm.TokenIdentifier, "[", 1, 15),
+		tok(kasm.TokenRegister, "sp", 1, 16),
+		tok(kasm.TokenIdentifier, ",", 1, 18),
+		tok(kasm.TokenImmediate, "-16", 1, 20),
+		tok(kasm.TokenIdentifier, "]", 1, 23),
+		tok(kasm.TokenIdentifier, "!", 1, 24),
+	}
+	program, errors := kasm.ParserNew(tokens).Parse()
+	requireNoErrors(t, errors)
+	requireStatementCount(t, program, 1)
+
+	stmt := program.Statements[0].(*ast.InstructionStmt)
+	if len(stmt.Operands) != 3 {
+		t.Fatalf("expected 3 operands, got %d", len(stmt.Operands))
+	}
+	mem := stmt.Operands[2].(*ast.MemoryOperand)
+	if last := mem.Components[len(mem.Components)-1].Token.Literal; last != "!" {
+		t.Errorf("expected trailing '!' component, got %q", last)
+	}
+}
+
+func TestParse_LiteralOperand(t *testing.T) {
+	// ldr x0, =0x1234
+	tokens := []kasm.Token{
+		tok(kasm.TokenInstruction, "ldr", 1, 1),
+		tok(kasm.TokenRegister, "x0", 1, 5),
+		tok(kasm.TokenIdentifier, ",", 1, 7),
+		tok(kasm.TokenIdentifier, "=", 1, 9),
+		tok(kasm.TokenImmediate, "0x1234", 1, 10),
+	}
+	program, errors := kasm.ParserNew(tokens).Parse()
+	requireNoErrors(t, errors)
+	requireStatementCount(t, program, 1)
+
+	stmt := program.Statements[0].(*ast.InstructionStmt)
+	lit, ok := stmt.Operands[1].(*ast.LiteralOperand)
+	if !ok {
+		t.Fatalf("expected *LiteralOperand, got %T", stmt.Operands[1])
+	}
+	if imm, ok := lit.Value.(*ast.ImmediateOperand); !ok || imm.Value != "0x1234" {
+		t.Errorf("expected immediate 0x1234, got %#v", lit.Value)
+	}
+}
+
+func TestParse_LiteralOperandMissingValue(t *testing.T) {
+	// ldr x0, =
+	tokens := []kasm.Token{
+		tok(kasm.TokenInstruction, "ldr", 1, 1),
+		tok(kasm.TokenRegister, "x0", 1, 5),
+		tok(kasm.TokenIdentifier, ",", 1, 7),
+		tok(kasm.TokenIdentifier, "=", 1, 9),
+	}
+	_, errors := kasm.ParserNew(tokens).Parse()
+	requireErrorCount(t, errors, 1)
+}
+
 func TestParse_MemoryOperandUnterminated(t *testing.T) {
 	// mov [rax   (no closing bracket, followed by next instruction)
 	tokens := []kasm.Token{
@@ -537,6 +645,181 @@ func TestParse_DirectiveWithStringArg(t *testing.T) {
 	}
 }
 
+func TestParse_Incbin(t *testing.T) {
+	// %incbin "font.bin", 16, 0x20
+	tokens := []kasm.Token{
+		tok(kasm.TokenDirective, "%incbin", 1, 1),
+		tok(kasm.TokenString, "font.bin", 1, 9),
+		tok(kasm.TokenIdentifier, ",", 1, 19),
+		tok(kasm.TokenImmediate, "16", 1, 21),
+		tok(kasm.TokenIdentifier, ",", 1, 23),
+		tok(kasm.TokenImmediate, "0x20", 1, 25),
+	}
+	program, errors := kasm.ParserNew(tokens).Parse()
+	requireNoErrors(t, errors)
+	requireStatementCount(t, program, 1)
+
+	stmt, ok := program.Statements[0].(*ast.IncbinStmt)
+	if !ok {
+		t.Fatalf("expected *IncbinStmt, got %T", program.Statements[0])
+	}
+	if stmt.Path != "font.bin" || stmt.Line != 1 || stmt.Column != 1 {
+		t.Errorf("expected font.bin at 1:1, got %q at %d:%d", stmt.Path, stmt.Line, stmt.Column)
+	}
+	if stmt.Offset == nil || stmt.Offset.Value != "16" || stmt.Length == nil || stmt.Length.Value != "0x20" {
+		t.Errorf("expected offset 16 and length 0x20, got %+v and %+v", stmt.Offset, stmt.Length)
+	}
+}
+
+func TestParse_IncbinPathOnly(t *testing.T) {
+	tokens := []kasm.Token{
+		tok(kasm.TokenDirective, "%incbin", 1, 1),
+		tok(kasm.TokenString, "blob.bin", 1, 9),
+		tok(kasm.TokenInstruction, "ret", 2, 1),
+	}
+	program, errors := kasm.ParserNew(tokens).Parse()
+	requireNoErrors(t, errors)
+	requireStatementCount(t, program, 2)
+
+	stmt := program.Statements[0].(*ast.IncbinStmt)
+	if stmt.Offset != nil || stmt.Length != nil {
+		t.Errorf("expected no offset or length, got %+v and %+v", stmt.Offset, stmt.Length)
+	}
+}
+
+func TestParse_IncbinErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		tokens  []kasm.Token
+		message string
+	}{
+		{"no path", []kasm.Token{tok(kasm.TokenDirective, "%incbin", 1, 1)}, "expected file path string after %incbin"},
+		{"path not a string", []kasm.Token{
+			tok(kasm.TokenDirective, "%incbin", 1, 1),
+			tok(kasm.TokenImmediate, "1", 1, 9),
+		}, "expected file path string after %incbin"},
+		{"offset not an immediate", []kasm.Token{
+			tok(kasm.TokenDirective, "%incbin", 1, 1),
+			tok(kasm.TokenString, "a.bin", 1, 9),
+			tok(kasm.TokenIdentifier, ",", 1, 16),
+			tok(kasm.TokenRegister, "rax", 1, 18),
+		}, "%incbin offset and length must be immediate values"},
+		{"too many arguments", []kasm.Token{
+			tok(kasm.TokenDirective, "%incbin", 1, 1),
+			tok(kasm.TokenString, "a.bin", 1, 9),
+			tok(kasm.TokenIdentifier, ",", 1, 16),
+			tok(kasm.TokenImmediate, "1", 1, 18),
+			tok(kasm.TokenIdentifier, ",", 1, 19),
+			tok(kasm.TokenImmediate, "2", 1, 21),
+			tok(kasm.TokenIdentifier, ",", 1, 22),
+			tok(kasm.TokenImmediate, "3", 1, 24),
+		}, "too many arguments to %incbin"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			program, errors := kasm.ParserNew(tt.tokens).Parse()
+			if len(errors) != 1 || !strings.Contains(errors[0].Message, tt.message) {
+				t.Fatalf("expected error containing %q, got %v", tt.message, errors)
+			}
+			requireStatementCount(t, program, 0)
+		})
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FR-13: Structures
+// ---------------------------------------------------------------------------
+
+func TestParse_Struc(t *testing.T) {
+	source := `struc task
+    .pid:   resd 1
+    state   resb 0x10
+endstruc
+section .text: code`
+	program, errors := kasm.ParserNew(kasm.LexerNew(source, profile.NewRV64Profile()).Start()).Parse()
+	requireNoErrors(t, errors)
+	requireStatementCount(t, program, 2)
+
+	stmt, ok := program.Statements[0].(*ast.StrucStmt)
+	if !ok {
+		t.Fatalf("expected *StrucStmt, got %T", program.Statements[0])
+	}
+	if stmt.Name != "task" || len(stmt.Fields) != 2 {
+		t.Fatalf("expected structure task with 2 fields, got %q with %d", stmt.Name, len(stmt.Fields))
+	}
+	for i, expected := range []struct{ name, reserve, count string }{
+		{"pid", "resd", "1"},
+		{"state", "resb", "0x10"},
+	} {
+		f := stmt.Fields[i]
+		if f.Name != expected.name || f.Reserve != expected.reserve || f.Count.Value != expected.count {
+			t.Errorf("field %d: expected %+v, got %s %s %s", i, expected, f.Name, f.Reserve, f.Count.Value)
+		}
+	}
+	if _, ok := program.Statements[1].(*ast.SectionStmt); !ok {
+		t.Errorf("expected the section after endstruc to parse, got %T", program.Statements[1])
+	}
+}
+
+func TestParse_Istruc(t *testing.T) {
+	source := `istruc task
+    at task.pid, dd -1
+    at task.name, db "init", 0
+iend
+ret`
+	program, errors := kasm.ParserNew(kasm.LexerNew(source, profile.NewRV64Profile()).Start()).Parse()
+	requireNoErrors(t, errors)
+	requireStatementCount(t, program, 2)
+
+	stmt, ok := program.Statements[0].(*ast.IstrucStmt)
+	if !ok {
+		t.Fatalf("expected *IstrucStmt, got %T", program.Statements[0])
+	}
+	if stmt.Name != "task" || len(stmt.Values) != 2 {
+		t.Fatalf("expected instance of task with 2 values, got %q with %d", stmt.Name, len(stmt.Values))
+	}
+	pid := stmt.Values[0]
+	if pid.Field != "task.pid" || pid.Data != "dd" || len(pid.Operands) != 1 {
+		t.Fatalf("expected at task.pid, dd with 1 value, got %+v", pid)
+	}
+	if imm, ok := pid.Operands[0].(*ast.ImmediateOperand); !ok || imm.Value != "-1" {
+		t.Errorf("expected immediate -1, got %+v", pid.Operands[0])
+	}
+	if name := stmt.Values[1]; name.Field != "task.name" || len(name.Operands) != 2 {
+		t.Errorf("expected at task.name with 2 values, got %+v", name)
+	}
+}
+
+func TestParse_StrucErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		message string
+	}{
+		{"missing name", "struc", "expected structure name after 'struc', got end of input"},
+		{"unterminated", "struc task\n.pid: resd 1\nret", "unterminated structure 'task', expected 'endstruc'"},
+		{"missing reservation", "struc task\n.pid: 4\nendstruc", "expected resb, resw, resd or resq after structure field 'pid'"},
+		{"missing count", "struc task\n.pid: resd\nendstruc", "expected reservation count after 'resd'"},
+		{"stray endstruc", "endstruc", "'endstruc' without matching 'struc'"},
+		{"unterminated istruc", "istruc task\nat task.pid, dd 1\nret", "unterminated structure instance 'task', expected 'iend'"},
+		{"missing comma", "istruc task\nat task.pid dd 1\niend", "expected ',' after structure field 'task.pid'"},
+		{"missing data keyword", "istruc task\nat task.pid, 1\niend", "expected db, dw, dd or dq after structure field 'task.pid'"},
+		{"missing value", "istruc task\nat task.pid, dd\niend", "expected value after 'dd'"},
+		{"stray at", "at task.pid, dd 1", "'at' outside of an 'istruc' block"},
+		{"stray reservation", "resb 4", "'resb' outside of a 'struc' block"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, errors := kasm.ParserNew(kasm.LexerNew(tt.source, profile.NewRV64Profile()).Start()).Parse()
+			if len(errors) != 1 || !strings.Contains(errors[0].Message, tt.message) {
+				t.Fatalf("expected error containing %q, got %v", tt.message, errors)
+			}
+		})
+	}
+}
+
 func TestParse_DirectiveStopsAtNextStatement(t *testing.T) {
 	// %define FOO
 	// mov rax, 1
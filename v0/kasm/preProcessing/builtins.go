@@ -0,0 +1,126 @@
+package preProcessing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Predefined describes the assembly run to the predefined symbols (FR-11.1):
+//
+//	__ARCH__          "x86_64"       ; the target architecture
+//	__BITS__          64             ; the target word size in bits
+//	__KASM_VERSION__  "0.1.0"        ; the assembler version
+//	__DATE__          "2026-01-31"   ; the build date, in UTC
+//	__TIME__          "13:37:00"     ; the build time, in UTC
+//
+// BuildTime is the time of the build, which honours SOURCE_DATE_EPOCH so that
+// builds are reproducible; see BuildTime.
+type Predefined struct {
+	Arch      string
+	Bits      int
+	Version   string
+	BuildTime time.Time
+}
+
+// lineSymbols are the predefined symbols whose value depends on the line
+// they appear on (FR-11.2). They are substituted by HandleLineSymbols and
+// cannot be defined or undefined on the command line.
+var lineSymbols = map[string]bool{"__FILE__": true, "__LINE__": true}
+
+// definitions returns the definitions of the predefined symbols. __FILE__
+// and __LINE__ are flags, so that %ifdef sees them; their values are
+// substituted by HandleLineSymbols.
+func (p Predefined) definitions() map[string]definition {
+	buildTime := p.BuildTime.UTC()
+	values := map[string]string{
+		"__FILE__":         "",
+		"__LINE__":         "",
+		"__ARCH__":         quoteString(p.Arch),
+		"__BITS__":         strconv.Itoa(p.Bits),
+		"__KASM_VERSION__": quoteString(p.Version),
+		"__DATE__":         quoteString(buildTime.Format("2006-01-02")),
+		"__TIME__":         quoteString(buildTime.Format("15:04:05")),
+	}
+
+	definitions := make(map[string]definition, len(values))
+	for name, value := range values {
+		definitions[name] = definition{name: name, body: value}
+	}
+	return definitions
+}
+
+// BuildTime returns the time of the build: the Unix timestamp in
+// sourceDateEpoch, the value of the SOURCE_DATE_EPOCH environment variable,
+// or now if it is empty (FR-11.1.3).
+func BuildTime(sourceDateEpoch string, now time.Time) (time.Time, error) {
+	if sourceDateEpoch == "" {
+		return now, nil
+	}
+	seconds, err := strconv.ParseInt(sourceDateEpoch, 10, 64)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid SOURCE_DATE_EPOCH '%s': expected a Unix timestamp", sourceDateEpoch)
+	}
+	return time.Unix(seconds, 0), nil
+}
+
+// HandleLineSymbols substitutes __FILE__ and __LINE__ with the file, as a
+// string literal, and the line each occurrence originates from (FR-11.2).
+// locate maps a 1-based line of source to its original file and line; the
+// caller traces lines through includes and macro expansions, so that a
+// __LINE__ in a macro body resolves to the line of the call.
+//
+// Symbols are substituted as whole words outside string literals and
+// comments, like defines. Lines with %define and %undef directives are left
+// untouched, so that a define whose body uses __LINE__ is resolved where it
+// is used, and so are %ifdef-style directives, which name the symbols rather
+// than use their values. The transformation is line-preserving.
+func HandleLineSymbols(source string, locate func(lineNumber int) (file string, line int)) string {
+	// Early-exit: no line symbols to substitute (AR-8.1).
+	if !strings.Contains(source, "__FILE__") && !strings.Contains(source, "__LINE__") {
+		return source
+	}
+
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		if (!strings.Contains(line, "__FILE__") && !strings.Contains(line, "__LINE__")) || namesSymbols(line) {
+			continue
+		}
+
+		file, lineNumber := locate(i + 1)
+		expander := &defineExpander{
+			definitions: map[string]definition{
+				"__FILE__": {name: "__FILE__", body: quoteString(file)},
+				"__LINE__": {name: "__LINE__", body: strconv.Itoa(lineNumber)},
+			},
+			active: make(map[string]bool),
+		}
+		lines[i] = expander.expand(line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// namesSymbols returns true if line is a directive that takes a symbol name
+// rather than a value: %define, %undef, %ifdef, %ifndef, %elifdef and
+// %elifndef.
+func namesSymbols(line string) bool {
+	if directive, _ := splitDefineDirective(line); directive != "" {
+		return true
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return false
+	}
+	switch fields[0] {
+	case "%ifdef", "%ifndef", "%elifdef", "%elifndef":
+		return true
+	}
+	return false
+}
+
+// quoteString returns value as a string literal. The lexer does not process
+// escape sequences, so value is enclosed in double quotes as is.
+func quoteString(value string) string {
+	return `"` + value + `"`
+}
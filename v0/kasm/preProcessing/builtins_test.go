@@ -0,0 +1,114 @@
+package preProcessing_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+)
+
+// --- FR-11.1: Predefined symbols ---
+
+var testPredefined = preProcessing.Predefined{
+	Arch:      "x86_64",
+	Bits:      64,
+	Version:   "1.2.3",
+	BuildTime: time.Unix(1700000000, 0),
+}
+
+func TestWithPredefined_Values(t *testing.T) {
+	commandLine := mustCommandLine(t, nil, nil).WithPredefined(testPredefined)
+	source := "%if __BITS__ == 64\nmov rax, __ARCH__\n%endif\ndb __KASM_VERSION__, __DATE__, __TIME__"
+
+	resolved, errs := preProcessing.HandleConditionalsWithCommandLine(source, nil, commandLine)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	result, errs := preProcessing.HandleDefinesWithCommandLine(resolved, commandLine)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if result != "\nmov rax, \"x86_64\"\n\ndb \"1.2.3\", \"2023-11-14\", \"22:13:20\"" {
+		t.Errorf("unexpected result %q", result)
+	}
+}
+
+func TestWithPredefined_NilReceiver(t *testing.T) {
+	commandLine := (*preProcessing.CommandLineSymbols)(nil).WithPredefined(testPredefined)
+	if got := strings.Join(commandLine.Defined(), ","); got != "__ARCH__,__BITS__,__DATE__,__FILE__,__KASM_VERSION__,__LINE__,__TIME__" {
+		t.Errorf("expected every predefined symbol to be defined, got %s", got)
+	}
+}
+
+func TestWithPredefined_CommandLineTakesPrecedence(t *testing.T) {
+	commandLine := mustCommandLine(t, []string{"__BITS__=32"}, []string{"__DATE__"}).WithPredefined(testPredefined)
+
+	result, errs := preProcessing.HandleDefinesWithCommandLine("dd __BITS__\ndb __DATE__", commandLine)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if result != "dd 32\ndb __DATE__" {
+		t.Errorf("expected -D and -U to override the predefined symbols, got %q", result)
+	}
+}
+
+func TestWithPredefined_SourceDefineIgnored(t *testing.T) {
+	commandLine := mustCommandLine(t, nil, nil).WithPredefined(testPredefined)
+
+	result, errs := preProcessing.HandleDefinesWithCommandLine("%define __BITS__ 16\ndw __BITS__", commandLine)
+	if result != "\ndw 64" {
+		t.Errorf("unexpected result %q", result)
+	}
+	expected := "1: warning: %define of symbol '__BITS__' ignored: it is predefined by the assembler"
+	if len(errs) != 1 || errs[0].String() != expected {
+		t.Errorf("expected %q, got %v", expected, errs)
+	}
+}
+
+func TestCommandLineSymbolsNew_LineSymbolsRejected(t *testing.T) {
+	if _, err := preProcessing.CommandLineSymbolsNew([]string{"__LINE__=1"}, nil); err == nil || err.Error() != "cannot define built-in symbol '__LINE__'" {
+		t.Errorf("expected -D __LINE__ to be rejected, got %v", err)
+	}
+	if _, err := preProcessing.CommandLineSymbolsNew(nil, []string{"__FILE__"}); err == nil || err.Error() != "cannot undefine built-in symbol '__FILE__'" {
+		t.Errorf("expected -U __FILE__ to be rejected, got %v", err)
+	}
+}
+
+func TestBuildTime(t *testing.T) {
+	now := time.Unix(42, 0)
+
+	if got, err := preProcessing.BuildTime("", now); err != nil || !got.Equal(now) {
+		t.Errorf("expected the current time without SOURCE_DATE_EPOCH, got %v (error: %v)", got, err)
+	}
+	if got, err := preProcessing.BuildTime("1700000000", now); err != nil || got.Unix() != 1700000000 {
+		t.Errorf("expected the SOURCE_DATE_EPOCH time, got %v (error: %v)", got, err)
+	}
+	if _, err := preProcessing.BuildTime("yesterday", now); err == nil || err.Error() != "invalid SOURCE_DATE_EPOCH 'yesterday': expected a Unix timestamp" {
+		t.Errorf("expected an invalid SOURCE_DATE_EPOCH to be rejected, got %v", err)
+	}
+}
+
+// --- FR-11.2: __FILE__ and __LINE__ ---
+
+func TestHandleLineSymbols(t *testing.T) {
+	locate := func(lineNumber int) (string, int) { return "lib/io.kasm", lineNumber * 10 }
+	source := "mov rax, __LINE__\ndb __FILE__, \"__LINE__\" ; __LINE__\n%define HERE __LINE__\n%ifdef __FILE__\nmov rbx, __LINE__X"
+
+	result := preProcessing.HandleLineSymbols(source, locate)
+
+	expected := "mov rax, 10\ndb \"lib/io.kasm\", \"__LINE__\" ; __LINE__\n%define HERE __LINE__\n%ifdef __FILE__\nmov rbx, __LINE__X"
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestHandleLineSymbols_NoSymbols(t *testing.T) {
+	called := false
+	source := "mov rax, 1\nmov rbx, 2"
+
+	result := preProcessing.HandleLineSymbols(source, func(int) (string, int) { called = true; return "", 0 })
+	if result != source || called {
+		t.Errorf("expected the source to be returned unchanged, got %q", result)
+	}
+}
@@ -0,0 +1,143 @@
+package preProcessing
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CommandLineSymbols holds the symbols defined (-D) and undefined (-U) on the
+// command line (FR-10). They are in effect before the first line of the
+// source and take precedence over it: %define and %undef directives for a
+// command-line symbol are ignored with a warning, so that a build can
+// override a default the source defines unconditionally.
+//
+// The predefined symbols added by WithPredefined (FR-11.1) are held in the
+// same way, below -D and -U: a -D of a predefined symbol replaces its value,
+// and a -U removes it.
+//
+// A nil *CommandLineSymbols defines nothing.
+type CommandLineSymbols struct {
+	definitions map[string]definition
+	undefined   map[string]bool
+	predefined  map[string]bool
+}
+
+// CommandLineSymbolsNew parses -D and -U arguments (FR-10.1):
+//
+//	-D DEBUG            ; flag, for %ifdef
+//	-D LOG_LEVEL=3      ; value-carrying, substituted like %define LOG_LEVEL 3
+//	-D 'SLOT(n)=n*8'    ; parameterised
+//	-U FEATURE_X        ; undefined, whatever the source or -D say
+//
+// Defining a symbol twice with different values is an error; -U takes
+// precedence over -D for the same symbol. __FILE__ and __LINE__ take their
+// value from the line they appear on and can be neither defined nor
+// undefined.
+func CommandLineSymbolsNew(defines, undefines []string) (*CommandLineSymbols, error) {
+	symbols := &CommandLineSymbols{
+		definitions: make(map[string]definition, len(defines)),
+		undefined:   make(map[string]bool, len(undefines)),
+		predefined:  make(map[string]bool),
+	}
+
+	for _, argument := range defines {
+		name, value, _ := strings.Cut(argument, "=")
+		def, message := parseDefinition(name + " " + value)
+		if message != "" || strings.ContainsAny(name, " \t") {
+			return nil, fmt.Errorf("invalid -D definition '%s'", argument)
+		}
+		if previous, ok := symbols.definitions[def.name]; ok && !sameDefinition(previous, def) {
+			return nil, fmt.Errorf("conflicting -D definitions for symbol '%s'", def.name)
+		}
+		if lineSymbols[def.name] {
+			return nil, fmt.Errorf("cannot define built-in symbol '%s'", def.name)
+		}
+		symbols.definitions[def.name] = def
+	}
+
+	for _, name := range undefines {
+		if !isSymbolName(name) {
+			return nil, fmt.Errorf("invalid -U symbol name '%s'", name)
+		}
+		if lineSymbols[name] {
+			return nil, fmt.Errorf("cannot undefine built-in symbol '%s'", name)
+		}
+		symbols.undefined[name] = true
+		delete(symbols.definitions, name)
+	}
+
+	return symbols, nil
+}
+
+// WithPredefined adds the predefined symbols describing the assembly run
+// (FR-11.1), except those defined or undefined on the command line, and
+// returns s. On a nil receiver it returns symbols holding only the
+// predefined symbols.
+func (s *CommandLineSymbols) WithPredefined(predefined Predefined) *CommandLineSymbols {
+	if s == nil {
+		s, _ = CommandLineSymbolsNew(nil, nil)
+	}
+	for name, def := range predefined.definitions() {
+		if s.undefined[name] || s.hasDefinition(name) {
+			continue
+		}
+		s.definitions[name] = def
+		s.predefined[name] = true
+	}
+	return s
+}
+
+// Defined returns the names of the symbols defined on the command line,
+// including the predefined symbols, in sorted order.
+func (s *CommandLineSymbols) Defined() []string {
+	if s == nil {
+		return nil
+	}
+	names := make([]string, 0, len(s.definitions))
+	for name := range s.definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// overrides returns a non-empty description of the command-line option or
+// predefined symbol that fixes name, such as "defined on the command line",
+// or an empty string if the source may define and undefine name itself.
+func (s *CommandLineSymbols) overrides(name string) string {
+	switch {
+	case s == nil:
+		return ""
+	case s.undefined[name]:
+		return "undefined on the command line (-U)"
+	case s.predefined[name]:
+		return "predefined by the assembler"
+	case s.hasDefinition(name):
+		return "defined on the command line (-D)"
+	}
+	return ""
+}
+
+// hasDefinition returns true if name is defined on the command line.
+func (s *CommandLineSymbols) hasDefinition(name string) bool {
+	_, ok := s.definitions[name]
+	return ok
+}
+
+// overrideWarning returns the warning for a %define or %undef directive on
+// lineNumber that is ignored because reason fixes name on the command line.
+func overrideWarning(directive, name, reason string, lineNumber int) PreProcessingError {
+	return PreProcessingError{
+		Message:  fmt.Sprintf("%%%s of symbol '%s' ignored: it is %s", directive, name, reason),
+		Line:     lineNumber,
+		Severity: SeverityWarning,
+	}
+}
+
+// sameDefinition returns true if a and b define the same replacement.
+func sameDefinition(a, b definition) bool {
+	return a.hasParameters == b.hasParameters &&
+		a.body == b.body &&
+		strings.Join(a.parameters, ",") == strings.Join(b.parameters, ",")
+}
@@ -0,0 +1,157 @@
+package preProcessing_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+)
+
+// --- FR-10.1: CommandLineSymbolsNew ---
+
+func TestCommandLineSymbolsNew_Defined(t *testing.T) {
+	symbols, err := preProcessing.CommandLineSymbolsNew([]string{"DEBUG", "LOG_LEVEL=3", "SLOT(n)=n*8", "DEBUG"}, []string{"FEATURE_X"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(symbols.Defined(), ","); got != "DEBUG,LOG_LEVEL,SLOT" {
+		t.Errorf("expected DEBUG, LOG_LEVEL and SLOT to be defined, got %s", got)
+	}
+}
+
+func TestCommandLineSymbolsNew_UndefineWins(t *testing.T) {
+	symbols, err := preProcessing.CommandLineSymbolsNew([]string{"DEBUG", "TRACE"}, []string{"DEBUG"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got := strings.Join(symbols.Defined(), ","); got != "TRACE" {
+		t.Errorf("expected -U to remove DEBUG, got %s", got)
+	}
+}
+
+func TestCommandLineSymbolsNew_Errors(t *testing.T) {
+	tests := []struct {
+		name      string
+		defines   []string
+		undefines []string
+		message   string
+	}{
+		{"empty name", []string{"=3"}, nil, "invalid -D definition '=3'"},
+		{"invalid name", []string{"1ST"}, nil, "invalid -D definition '1ST'"},
+		{"name with space", []string{"A B=1"}, nil, "invalid -D definition 'A B=1'"},
+		{"conflicting values", []string{"LEVEL=1", "LEVEL=2"}, nil, "conflicting -D definitions for symbol 'LEVEL'"},
+		{"invalid -U", nil, []string{"FEATURE-X"}, "invalid -U symbol name 'FEATURE-X'"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := preProcessing.CommandLineSymbolsNew(tt.defines, tt.undefines)
+			if err == nil || err.Error() != tt.message {
+				t.Errorf("expected error %q, got %v", tt.message, err)
+			}
+		})
+	}
+}
+
+// --- FR-10.2: Precedence ---
+
+func mustCommandLine(t *testing.T, defines, undefines []string) *preProcessing.CommandLineSymbols {
+	t.Helper()
+	symbols, err := preProcessing.CommandLineSymbolsNew(defines, undefines)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return symbols
+}
+
+func TestHandleConditionalsWithCommandLine_Values(t *testing.T) {
+	commandLine := mustCommandLine(t, []string{"DEBUG", "LOG_LEVEL=3"}, nil)
+	source := "%ifdef DEBUG\ndebug\n%endif\n%if LOG_LEVEL > 2\nverbose\n%endif"
+
+	result, errs := preProcessing.HandleConditionalsWithCommandLine(source, nil, commandLine)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if result != "\ndebug\n\n\nverbose\n" {
+		t.Errorf("expected both branches to be active, got %q", result)
+	}
+}
+
+func TestHandleConditionalsWithCommandLine_OverridesSource(t *testing.T) {
+	commandLine := mustCommandLine(t, []string{"LOG_LEVEL=1"}, []string{"FEATURE_X"})
+	source := "%define FEATURE_X\n%define LOG_LEVEL 3\n%ifdef FEATURE_X\nfeature\n%endif\n%if LOG_LEVEL == 1\nquiet\n%endif"
+
+	result, errs := preProcessing.HandleConditionalsWithCommandLine(source, map[string]bool{"FEATURE_X": true}, commandLine)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if strings.Contains(result, "feature") || !strings.Contains(result, "quiet") {
+		t.Errorf("expected the command line to override the source, got %q", result)
+	}
+}
+
+func TestHandleConditionalsWithCommandLine_DefaultIdiom(t *testing.T) {
+	commandLine := mustCommandLine(t, []string{"LOG_LEVEL=3"}, nil)
+	source := "%ifndef LOG_LEVEL\n%define LOG_LEVEL 1\n%endif\n%if LOG_LEVEL == 3\nverbose\n%endif"
+
+	result, errs := preProcessing.HandleConditionalsWithCommandLine(source, nil, commandLine)
+	if len(errs) != 0 || !strings.Contains(result, "verbose") {
+		t.Errorf("expected the -D value to replace the default, got %q (errors: %v)", result, errs)
+	}
+}
+
+func TestHandleDefinesWithCommandLine_Substitutes(t *testing.T) {
+	commandLine := mustCommandLine(t, []string{"LOG_LEVEL=3", "SLOT(n)=n*8", "DEBUG"}, nil)
+
+	result, errs := preProcessing.HandleDefinesWithCommandLine("mov rax, LOG_LEVEL\nmov rbx, [rsp+SLOT(2)]\nDEBUG", commandLine)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if result != "mov rax, 3\nmov rbx, [rsp+2*8]\nDEBUG" {
+		t.Errorf("unexpected result %q", result)
+	}
+}
+
+func TestHandleDefinesWithCommandLine_SourceDirectivesIgnored(t *testing.T) {
+	commandLine := mustCommandLine(t, []string{"LOG_LEVEL=3"}, []string{"FEATURE_X"})
+	source := "%define LOG_LEVEL 1\n%define FEATURE_X 1\n%undef LOG_LEVEL\nmov rax, LOG_LEVEL\nmov rbx, FEATURE_X"
+
+	result, errs := preProcessing.HandleDefinesWithCommandLine(source, commandLine)
+	if result != "\n\n\nmov rax, 3\nmov rbx, FEATURE_X" {
+		t.Errorf("unexpected result %q", result)
+	}
+	expected := []string{
+		"1: warning: %define of symbol 'LOG_LEVEL' ignored: it is defined on the command line (-D)",
+		"2: warning: %define of symbol 'FEATURE_X' ignored: it is undefined on the command line (-U)",
+		"3: warning: %undef of symbol 'LOG_LEVEL' ignored: it is defined on the command line (-D)",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d warnings, got %v", len(expected), errs)
+	}
+	for i, e := range errs {
+		if e.Severity != preProcessing.SeverityWarning || e.String() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], e.String())
+		}
+	}
+}
+
+func TestCreateSymbolTableWithCommandLine(t *testing.T) {
+	commandLine := mustCommandLine(t, []string{"DEBUG", "LOG_LEVEL=3"}, []string{"FEATURE_X"})
+	source := "%define LOG_LEVEL 1\n%define LOG_LEVEL 2\n%define FEATURE_X\n%define OTHER"
+
+	table, errs := preProcessing.CreateSymbolTableWithCommandLine(source, nil, commandLine)
+	if len(errs) != 0 {
+		t.Fatalf("expected no duplicate errors for command-line symbols, got %v", errs)
+	}
+	if !table["DEBUG"] || !table["LOG_LEVEL"] || !table["OTHER"] || table["FEATURE_X"] {
+		t.Errorf("unexpected symbol table %v", table)
+	}
+}
+
+func TestHandleRepetitionsWithCommandLine_Count(t *testing.T) {
+	commandLine := mustCommandLine(t, []string{"COPIES=3"}, nil)
+
+	result, _, errs := preProcessing.HandleRepetitionsWithCommandLine("%rep COPIES\nnop\n%endrep", nil, commandLine)
+	if len(errs) != 0 || strings.Count(result, "nop") != 3 {
+		t.Errorf("expected three copies, got %q (errors: %v)", result, errs)
+	}
+}
@@ -0,0 +1,83 @@
+package preProcessing
+
+// scannedConditions lists the directives whose condition the scanner
+// evaluates; an unevaluable condition opens an active branch.
+var scannedConditions = map[string]bool{
+	"if": true, "ifdef": true, "ifndef": true,
+	"elif": true, "elifdef": true, "elifndef": true,
+}
+
+// ConditionalScanner reports, one line at a time, whether lines of a
+// translation unit are in active conditional branches (FR-4.9). It lets
+// include resolution follow only the %include directives that survive
+// HandleConditionals, before the translation unit has been assembled.
+//
+// Lines are fed in textual order, across file boundaries: a file's lines are
+// fed at the point where it is included, so that %define, %undef and %macro
+// directives in included files affect the conditionals that follow them.
+//
+// The scanner runs before %assign variables and macro parameters are known,
+// so a condition that cannot be evaluated is assumed to hold: the includes
+// it guards are resolved as if it were unconditional. Malformed directives are
+// not reported; HandleConditionals reports them once the source is complete.
+type ConditionalScanner struct {
+	evaluator *conditionalEvaluator
+	inMacro   bool // Inside a %macro … %endmacro definition
+}
+
+// ConditionalScannerNew returns a scanner whose defined symbols are seeded
+// from definedSymbols, as for HandleConditionals.
+func ConditionalScannerNew(definedSymbols map[string]bool) *ConditionalScanner {
+	return &ConditionalScanner{evaluator: newConditionalEvaluator(definedSymbols)}
+}
+
+// WithCommandLine puts the symbols defined and undefined on the command line
+// into effect, as for HandleConditionalsWithCommandLine (FR-10.2).
+func (s *ConditionalScanner) WithCommandLine(commandLine *CommandLineSymbols) *ConditionalScanner {
+	s.evaluator.seedCommandLine(commandLine)
+	return s
+}
+
+// Active feeds the next line to the scanner and returns true if the line is
+// in an active branch and is not itself a conditional directive.
+//
+// Macro bodies are evaluated where the macro is called, so lines inside a
+// %macro … %endmacro definition are always active and conditional directives
+// in them are not evaluated.
+func (s *ConditionalScanner) Active(line string) bool {
+	c := s.evaluator
+	c.errors = c.errors[:0]
+
+	if s.inMacro {
+		if directive, _, _ := splitDirective(line); directive == "endmacro" {
+			s.inMacro = false
+		}
+		return true
+	}
+
+	directive, rest, restOffset := splitConditionalDirective(line)
+	if directive != "" {
+		c.handleDirective(directive, rest, restOffset, 0)
+		if len(c.errors) > 0 && scannedConditions[directive] && len(c.stack) > 0 {
+			top := &c.stack[len(c.stack)-1]
+			if top.parentActive && top.elseLine == 0 {
+				top.active = true
+				top.taken = true
+			}
+		}
+		return false
+	}
+
+	if !c.active() {
+		return false
+	}
+
+	if name, _, ok := macroDefinition(line); ok {
+		c.defined[name] = true
+		s.inMacro = true
+		return true
+	}
+
+	c.trackDefinition(line, 0)
+	return true
+}
@@ -2,229 +2,333 @@ package preProcessing
 
 import (
 	"fmt"
-	"regexp"
+	"sort"
 	"strings"
 )
 
-// Pre-compiled regex for conditional directives: %ifdef, %ifndef, %else, %endif (AR-6.1).
-var conditionalDirectiveRegex = regexp.MustCompile(`(?m)^\s*%(ifdef|ifndef|else|endif)\s*(\w*)\s*$`)
+// conditionalDirectives lists the conditional directives, without the leading
+// '%' (FR-4.1).
+var conditionalDirectives = map[string]bool{
+	"if": true, "ifdef": true, "ifndef": true,
+	"elif": true, "elifdef": true, "elifndef": true,
+	"else": true, "endif": true,
+}
 
-// Pre-compiled regex for %define directives used for stripping (FR-3.4, AR-6.3).
-var defineStripRegex = regexp.MustCompile(`(?m)^\s*%define\s+\w+\s*\n?`)
+// HandleConditionals evaluates conditional assembly blocks and produces a
+// source string with only the active branches retained (FR-4):
+//
+//	%if KERNEL_STACK_SIZE > 0x4000     ; constant expression
+//	%elif defined(ARCH) && ARCH == 2
+//	%elifdef DEBUG                     ; also %elifndef
+//	%else
+//	%endif
+//
+// The transformation is line-preserving: directive lines and lines of
+// inactive branches are blanked, so line i of the result is line i of source
+// or empty. Blocks nest to any depth.
+//
+// The source is evaluated top to bottom. definedSymbols seeds the set of
+// defined symbols; %define and %undef directives in active lines update it
+// from the line after the directive, and value-carrying defines supply the
+// values of %if expressions. The directives themselves are left in place for
+// HandleDefines (FR-3.4).
+//
+// Unbalanced directives, malformed conditions and duplicate %define
+// directives are reported as errors; the offending directive is ignored and
+// evaluation continues so that every problem is reported in one run.
+// Expression errors carry the column of the offending token.
+//
+// %error, %warning and %fatal directives in active lines are returned with the
+// matching Severity and blanked (FR-9). Evaluation stops at the first %fatal.
+func HandleConditionals(source string, definedSymbols map[string]bool) (string, []PreProcessingError) {
+	return HandleConditionalsWithCommandLine(source, definedSymbols, nil)
+}
 
-// HandleConditionals evaluates conditional assembly blocks
-// (%ifdef, %ifndef, %else, %endif) and produces a source string with only
-// the active branches retained. Directive lines are removed from the output.
-func HandleConditionals(source string, definedSymbols map[string]bool) string {
+// HandleConditionalsWithCommandLine is HandleConditionals with the symbols
+// defined and undefined on the command line in effect from the first line
+// (FR-10.2). They take precedence over definedSymbols, and %define and %undef
+// directives for them are ignored; HandleDefines reports those directives.
+func HandleConditionalsWithCommandLine(source string, definedSymbols map[string]bool, commandLine *CommandLineSymbols) (string, []PreProcessingError) {
+	errors := make([]PreProcessingError, 0)
 
 	// When the source is empty, there is nothing
 	// to process, so we can return it immediately.
 	//
 	if len(source) == 0 {
-		return source
+		return source, errors
 	}
 
-	// Quick check to skip regex processing if there
+	// Quick check to skip line scanning if there
 	// are no conditional directives in the source code.
 	//
-	hasConditionals := strings.Contains(source, "%ifdef") ||
-		strings.Contains(source, "%ifndef") ||
+	hasConditionals := strings.Contains(source, "%if") ||
+		strings.Contains(source, "%el") ||
 		strings.Contains(source, "%endif")
 
-	if !hasConditionals {
-		// FR-3.4: Even without conditionals, strip %define lines so they
-		// do not leak into the lexer.
-		return stripDefineDirectives(source)
+	if !hasConditionals && !hasDiagnostics(source) {
+		return source, errors
 	}
 
-	directiveRegex := conditionalDirectiveRegex
-	matches := directiveRegex.FindAllStringSubmatchIndex(source, -1)
-
-	// Uncommon case: if there are no matches, we can skip all the processing
-	// and return the original source immediately.
-	//
-	if len(matches) == 0 {
-		return source
-	}
+	c := newConditionalEvaluator(definedSymbols)
+	c.seedCommandLine(commandLine)
 
-	lineNumbers := precomputeLineNumbers(source, matches)
+	fatal := false
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		lineNumber := i + 1
 
-	stack := make([]stackEntry, 0, 4)
-	blocks := make([]conditionalBlock, 0, len(matches)/2)
+		directive, rest, restOffset := splitConditionalDirective(line)
+		if directive != "" {
+			c.handleDirective(directive, rest, restOffset, lineNumber)
+			lines[i] = ""
+			continue
+		}
 
-	for mi, matchIdx := range matches {
-		if len(matchIdx) < 6 {
+		if !c.active() {
+			lines[i] = ""
 			continue
 		}
 
-		matchStart := matchIdx[0]
-		matchEnd := matchIdx[1]
-		directive := source[matchIdx[2]:matchIdx[3]]
-		symbol := ""
-		if matchIdx[4] != matchIdx[5] {
-			symbol = source[matchIdx[4]:matchIdx[5]]
-		}
-		lineNumber := lineNumbers[mi]
-
-		switch directive {
-		case "ifdef", "ifndef":
-			stack = append(stack, stackEntry{
-				directive:  directive,
-				symbol:     symbol,
-				start:      matchStart,
-				end:        matchEnd,
-				lineNumber: lineNumber,
-				elseStart:  -1,
-				elseEnd:    -1,
-			})
-		case "else":
-			if len(stack) == 0 {
-				panic(fmt.Sprintf("pre-processing error: %%else without matching %%ifdef/%%ifndef at line %d", lineNumber))
-			}
-			top := &stack[len(stack)-1]
-			if top.elseStart != -1 {
-				panic(fmt.Sprintf("pre-processing error: Duplicate %%else for %%ifdef/%%ifndef at line %d (first %%else already seen)", lineNumber))
+		if diagnostic, ok := userDiagnostic(line, lineNumber); ok {
+			c.errors = append(c.errors, diagnostic)
+			lines[i] = ""
+			if diagnostic.Severity == SeverityFatal {
+				fatal = true
+				break
 			}
-			top.elseStart = matchStart
-			top.elseEnd = matchEnd
-		case "endif":
-			if len(stack) == 0 {
-				panic(fmt.Sprintf("pre-processing error: %%endif without matching %%ifdef/%%ifndef at line %d", lineNumber))
-			}
-			top := stack[len(stack)-1]
-			stack = stack[:len(stack)-1]
-			blocks = append(blocks, conditionalBlock{
-				ifDirective: top.directive,
-				symbol:      top.symbol,
-				ifStart:     top.start,
-				ifEnd:       top.end,
-				elseStart:   top.elseStart,
-				elseEnd:     top.elseEnd,
-				endifStart:  matchStart,
-				endifEnd:    matchEnd,
-				lineNumber:  top.lineNumber,
-			})
+			continue
 		}
+
+		c.trackDefinition(line, lineNumber)
 	}
 
-	if len(stack) > 0 {
-		top := stack[len(stack)-1]
-		panic(fmt.Sprintf("pre-processing error: %%ifdef/%%ifndef at line %d has no matching %%endif", top.lineNumber))
+	// Unterminated blocks are reported last, so re-establish source order
+	// for all errors. After a %fatal, the remaining source was not evaluated.
+	for _, open := range c.stack {
+		if fatal {
+			break
+		}
+		c.errors = append(c.errors, PreProcessingError{
+			Message: fmt.Sprintf("%%%s has no matching %%endif", open.directive),
+			Line:    open.line,
+		})
 	}
+	errors = append(errors, c.errors...)
+	sort.SliceStable(errors, func(a, b int) bool { return errors[a].Line < errors[b].Line })
 
-	sortBlocksByStart(blocks)
+	return strings.Join(lines, "\n"), errors
+}
 
-	var sb strings.Builder
-	sb.Grow(len(source))
-	cursor := 0
+// conditionalEvaluator holds the state of HandleConditionals: the stack of
+// open blocks and the definitions in effect at the current line.
+type conditionalEvaluator struct {
+	stack       []conditionalFrame
+	defined     map[string]bool
+	definitions map[string]definition
+	definedAt   map[string]int // Line of the %define in effect, for duplicate errors
+	commandLine *CommandLineSymbols
+	errors      []PreProcessingError
+}
 
-	for _, block := range blocks {
-		if block.ifStart > cursor {
-			sb.WriteString(source[cursor:block.ifStart])
+// newConditionalEvaluator returns an evaluator with no open blocks whose
+// defined symbols are seeded from definedSymbols.
+func newConditionalEvaluator(definedSymbols map[string]bool) *conditionalEvaluator {
+	c := &conditionalEvaluator{
+		defined:     make(map[string]bool, len(definedSymbols)),
+		definitions: make(map[string]definition),
+		definedAt:   make(map[string]int),
+		stack:       make([]conditionalFrame, 0, 4),
+	}
+	for name, ok := range definedSymbols {
+		if ok {
+			c.defined[name] = true
 		}
+	}
+	return c
+}
+
+// seedCommandLine puts the command-line symbols into effect. They take
+// precedence over the seeded symbols, and trackDefinition ignores the source's
+// directives for them (FR-10.2).
+func (c *conditionalEvaluator) seedCommandLine(commandLine *CommandLineSymbols) {
+	c.commandLine = commandLine
+	if commandLine == nil {
+		return
+	}
+	for name, def := range commandLine.definitions {
+		c.defined[name] = true
+		c.definitions[name] = def
+	}
+	for name := range commandLine.undefined {
+		delete(c.defined, name)
+	}
+}
 
-		conditionMet := definedSymbols[block.symbol]
-		if block.ifDirective == "ifndef" {
-			conditionMet = !conditionMet
+// active returns true if the current line is in an active branch.
+func (c *conditionalEvaluator) active() bool {
+	return len(c.stack) == 0 || c.stack[len(c.stack)-1].active
+}
+
+// handleDirective applies a single conditional directive.
+func (c *conditionalEvaluator) handleDirective(directive, rest string, restOffset, lineNumber int) {
+	switch directive {
+	case "if", "ifdef", "ifndef":
+		parentActive := c.active()
+		taken := false
+		if parentActive {
+			taken = c.evaluateCondition(directive, rest, restOffset, lineNumber)
 		}
+		c.stack = append(c.stack, conditionalFrame{
+			directive:    directive,
+			line:         lineNumber,
+			parentActive: parentActive,
+			active:       taken,
+			taken:        taken,
+		})
 
-		var branchStart, branchEnd int
-		hasBranch := false
-		if block.elseStart == -1 {
-			if conditionMet {
-				branchStart = block.ifEnd
-				branchEnd = block.endifStart
-				hasBranch = true
-			}
-		} else {
-			if conditionMet {
-				branchStart = block.ifEnd
-				branchEnd = block.elseStart
-			} else {
-				branchStart = block.elseEnd
-				branchEnd = block.endifStart
-			}
-			hasBranch = true
+	case "elif", "elifdef", "elifndef":
+		top := c.top(directive, lineNumber)
+		if top == nil {
+			return
+		}
+		if top.elseLine > 0 {
+			c.errors = append(c.errors, PreProcessingError{
+				Message:  fmt.Sprintf("%%%s after %%else", directive),
+				Line:     lineNumber,
+				Previous: top.elseLine,
+			})
+			top.active = false
+			return
+		}
+		top.active = false
+		if top.parentActive && !top.taken {
+			top.active = c.evaluateCondition(strings.TrimPrefix(directive, "el"), rest, restOffset, lineNumber)
+			top.taken = top.active
 		}
 
-		if hasBranch {
-			// Inline trim without allocation: find first/last non-whitespace byte offsets
-			s, e := trimSpaceBounds(source, branchStart, branchEnd)
-			if s < e {
-				sb.WriteByte('\n')
-				sb.WriteString(source[s:e])
-				sb.WriteByte('\n')
-			}
+	case "else":
+		top := c.top(directive, lineNumber)
+		if top == nil {
+			return
+		}
+		if top.elseLine > 0 {
+			c.errors = append(c.errors, PreProcessingError{
+				Message:  fmt.Sprintf("duplicate %%else for %%%s", top.directive),
+				Line:     lineNumber,
+				Previous: top.elseLine,
+			})
+			top.active = false
+			return
 		}
+		top.elseLine = lineNumber
+		top.active = top.parentActive && !top.taken
+		top.taken = true
 
-		cursor = block.endifEnd
+	case "endif":
+		if c.top(directive, lineNumber) == nil {
+			return
+		}
+		c.stack = c.stack[:len(c.stack)-1]
 	}
+}
 
-	if cursor < len(source) {
-		sb.WriteString(source[cursor:])
+// top returns the innermost open block, or reports directive as unmatched
+// and returns nil if there is none.
+func (c *conditionalEvaluator) top(directive string, lineNumber int) *conditionalFrame {
+	if len(c.stack) == 0 {
+		c.errors = append(c.errors, PreProcessingError{
+			Message: fmt.Sprintf("%%%s without matching %%if/%%ifdef/%%ifndef", directive),
+			Line:    lineNumber,
+		})
+		return nil
 	}
-
-	// FR-3.4: Strip %define directives from the output so they do not leak
-	// into the lexer.
-	return stripDefineDirectives(sb.String())
+	return &c.stack[len(c.stack)-1]
 }
 
-// stripDefineDirectives removes all %define directive lines from the source
-// so that they do not leak into the lexer (FR-3.4). Uses the early-exit
-// pattern (AR-8.2, AR-8.3) to skip processing when no %define exists.
-func stripDefineDirectives(source string) string {
-	if !strings.Contains(source, "%define") {
-		return source
+// evaluateCondition evaluates the condition of an if, ifdef or ifndef
+// directive (the el- prefix of %elif directives is removed by the caller).
+// A malformed condition is reported and evaluates to false.
+func (c *conditionalEvaluator) evaluateCondition(kind, rest string, restOffset, lineNumber int) bool {
+	text := stripComment(rest)
+	trimmed := strings.TrimLeft(text, " \t")
+	offset := restOffset + len(text) - len(trimmed)
+	trimmed = strings.TrimRight(trimmed, " \t\r")
+
+	if kind == "if" {
+		if trimmed == "" {
+			c.errors = append(c.errors, PreProcessingError{
+				Message: "%if requires an expression",
+				Line:    lineNumber,
+			})
+			return false
+		}
+		value, err := evaluateExpression(trimmed, c.definitions, c.defined)
+		if err != nil {
+			c.errors = append(c.errors, PreProcessingError{
+				Message: err.message,
+				Line:    lineNumber,
+				Column:  offset + err.offset + 1,
+			})
+			return false
+		}
+		return value != 0
 	}
-	return defineStripRegex.ReplaceAllString(source, "")
-}
 
-// trimSpaceBounds returns the start and end indices within source[start:end]
-// (as absolute offsets) with leading/trailing whitespace removed, without allocating.
-func trimSpaceBounds(source string, start, end int) (int, int) {
-	for start < end && (source[start] == ' ' || source[start] == '\t' || source[start] == '\n' || source[start] == '\r') {
-		start++
+	if !isSymbolName(trimmed) {
+		c.errors = append(c.errors, PreProcessingError{
+			Message: fmt.Sprintf("%%%s requires a symbol name", kind),
+			Line:    lineNumber,
+			Column:  offset + 1,
+		})
+		return false
 	}
-	for end > start && (source[end-1] == ' ' || source[end-1] == '\t' || source[end-1] == '\n' || source[end-1] == '\r') {
-		end--
+	if kind == "ifndef" {
+		return !c.defined[trimmed]
 	}
-	return start, end
+	return c.defined[trimmed]
 }
 
-// precomputeLineNumbers computes the line number for each match in a single pass.
-// Uses direct byte scanning instead of strings.Count to avoid sub-slice overhead.
-func precomputeLineNumbers(source string, matches [][]int) []int {
-	result := make([]int, len(matches))
-	line := 1
-	prev := 0
-	for i, m := range matches {
-		if len(m) < 2 {
-			continue
+// trackDefinition records the effect of a %define or %undef directive on an
+// active line. Malformed directives, and directives for command-line symbols,
+// are left for HandleDefines to report.
+func (c *conditionalEvaluator) trackDefinition(line string, lineNumber int) {
+	directive, rest := splitDefineDirective(line)
+	switch directive {
+	case "define":
+		def, message := parseDefinition(rest)
+		if message != "" || c.commandLine.overrides(def.name) != "" {
+			return
 		}
-		offset := m[0]
-		// Count newlines from prev to offset by scanning bytes directly
-		for j := prev; j < offset; j++ {
-			if source[j] == '\n' {
-				line++
-			}
+		if previous, ok := c.definedAt[def.name]; ok {
+			c.errors = append(c.errors, PreProcessingError{
+				Message:  fmt.Sprintf("duplicate %%define for symbol '%s'", def.name),
+				Line:     lineNumber,
+				Previous: previous,
+			})
+			return
+		}
+		def.lineNumber = lineNumber
+		c.definitions[def.name] = def
+		c.definedAt[def.name] = lineNumber
+		c.defined[def.name] = true
+	case "undef":
+		name := strings.TrimSpace(stripComment(rest))
+		if c.commandLine.overrides(name) != "" {
+			return
 		}
-		result[i] = line
-		prev = offset
+		delete(c.definitions, name)
+		delete(c.definedAt, name)
+		delete(c.defined, name)
 	}
-	return result
 }
 
-// sortBlocksByStart sorts conditional blocks by their ifStart offset using
-// insertion sort. The number of blocks is typically small (< 20), making
-// insertion sort efficient and allocation-free.
-func sortBlocksByStart(blocks []conditionalBlock) {
-	for i := 1; i < len(blocks); i++ {
-		key := blocks[i]
-		j := i - 1
-		for j >= 0 && blocks[j].ifStart > key.ifStart {
-			blocks[j+1] = blocks[j]
-			j--
-		}
-		blocks[j+1] = key
+// splitConditionalDirective returns the directive name, the remainder of the
+// line and the 0-based offset of the remainder if line is a conditional
+// directive, or empty strings otherwise.
+func splitConditionalDirective(line string) (string, string, int) {
+	directive, rest, offset := splitDirective(line)
+	if !conditionalDirectives[directive] {
+		return "", "", 0
 	}
+	return directive, rest, offset
 }
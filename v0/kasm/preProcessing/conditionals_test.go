@@ -15,7 +15,7 @@ func TestPreProcessingHandleConditionals_IfdefTrue(t *testing.T) {
 mov rax, 1
 %endif`
 	symbols := map[string]bool{"DEBUG": true}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	if !containsSubstring(result, "mov rax, 1") {
 		t.Error("expected body to be included when symbol is defined")
@@ -30,7 +30,7 @@ func TestPreProcessingHandleConditionals_IfdefFalse(t *testing.T) {
 mov rax, 1
 %endif`
 	symbols := map[string]bool{}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	if containsSubstring(result, "mov rax, 1") {
 		t.Error("expected body to be excluded when symbol is not defined")
@@ -44,7 +44,7 @@ func TestPreProcessingHandleConditionals_IfndefTrue(t *testing.T) {
 mov rax, 1
 %endif`
 	symbols := map[string]bool{}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	if !containsSubstring(result, "mov rax, 1") {
 		t.Error("expected body to be included when symbol is NOT defined")
@@ -56,7 +56,7 @@ func TestPreProcessingHandleConditionals_IfndefFalse(t *testing.T) {
 mov rax, 1
 %endif`
 	symbols := map[string]bool{"RELEASE": true}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	if containsSubstring(result, "mov rax, 1") {
 		t.Error("expected body to be excluded when symbol IS defined")
@@ -72,7 +72,7 @@ mov rax, 1
 mov rax, 0
 %endif`
 	symbols := map[string]bool{"DEBUG": true}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	if !containsSubstring(result, "mov rax, 1") {
 		t.Error("expected ifdef branch to be included")
@@ -89,7 +89,7 @@ mov rax, 1
 mov rax, 0
 %endif`
 	symbols := map[string]bool{}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	if containsSubstring(result, "mov rax, 1") {
 		t.Error("expected ifdef branch to be excluded")
@@ -102,14 +102,13 @@ mov rax, 0
 // --- PreProcessingHandleConditionals: nested ---
 
 func TestPreProcessingHandleConditionals_Nested(t *testing.T) {
-	t.Skip("nested conditionals not yet supported — byte offsets shift after first block replacement")
 	source := `%ifdef OUTER
 %ifdef INNER
 mov rax, 1
 %endif
 %endif`
 	symbols := map[string]bool{"OUTER": true, "INNER": true}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	if !containsSubstring(result, "mov rax, 1") {
 		t.Error("expected nested body to be included when both symbols are defined")
@@ -117,27 +116,168 @@ mov rax, 1
 }
 
 func TestPreProcessingHandleConditionals_Nested_OuterFalse(t *testing.T) {
-	t.Skip("nested conditionals not yet supported — byte offsets shift after first block replacement")
 	source := `%ifdef OUTER
 %ifdef INNER
 mov rax, 1
 %endif
 %endif`
 	symbols := map[string]bool{"INNER": true}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	if containsSubstring(result, "mov rax, 1") {
 		t.Error("expected nested body to be excluded when outer symbol is not defined")
 	}
 }
 
+func TestPreProcessingHandleConditionals_NestedInsideInactiveElse(t *testing.T) {
+	source := `%ifdef OUTER
+mov rax, 1
+%else
+%ifdef INNER
+mov rax, 2
+%else
+mov rax, 3
+%endif
+%endif`
+	symbols := map[string]bool{"OUTER": true}
+	result := mustHandleConditionals(t, source, symbols)
+
+	if !containsSubstring(result, "mov rax, 1") || containsSubstring(result, "mov rax, 2") || containsSubstring(result, "mov rax, 3") {
+		t.Errorf("expected only the outer branch to be included, got:\n%s", result)
+	}
+}
+
+// --- PreProcessingHandleConditionals: %elif chains ---
+
+func TestPreProcessingHandleConditionals_ElifChain(t *testing.T) {
+	source := `%define ARCH 2
+%if ARCH == 1
+mov rax, 1
+%elif ARCH == 2
+mov rax, 2
+%elif ARCH >= 2
+mov rax, 3
+%else
+mov rax, 4
+%endif`
+	result := mustHandleConditionals(t, source, map[string]bool{})
+
+	for _, body := range []string{"mov rax, 1", "mov rax, 3", "mov rax, 4"} {
+		if containsSubstring(result, body) {
+			t.Errorf("expected %q to be excluded", body)
+		}
+	}
+	if !containsSubstring(result, "mov rax, 2") {
+		t.Errorf("expected the first true %%elif branch to be included")
+	}
+}
+
+func TestPreProcessingHandleConditionals_ElifdefAndElifndef(t *testing.T) {
+	source := `%ifdef A
+mov rax, 1
+%elifdef B
+mov rax, 2
+%elifndef C
+mov rax, 3
+%endif`
+
+	tests := []struct {
+		symbols  map[string]bool
+		expected string
+	}{
+		{map[string]bool{"A": true, "B": true}, "mov rax, 1"},
+		{map[string]bool{"B": true}, "mov rax, 2"},
+		{map[string]bool{}, "mov rax, 3"},
+		{map[string]bool{"C": true}, ""},
+	}
+
+	for _, tt := range tests {
+		result := mustHandleConditionals(t, source, tt.symbols)
+		if strings.TrimSpace(result) != tt.expected {
+			t.Errorf("symbols %v: expected %q, got %q", tt.symbols, tt.expected, strings.TrimSpace(result))
+		}
+	}
+}
+
+func TestPreProcessingHandleConditionals_ElifNotEvaluatedAfterTakenBranch(t *testing.T) {
+	source := `%if 1
+mov rax, 1
+%elif 1 / 0
+mov rax, 2
+%endif`
+	result := mustHandleConditionals(t, source, map[string]bool{})
+
+	if !containsSubstring(result, "mov rax, 1") || containsSubstring(result, "mov rax, 2") {
+		t.Errorf("expected only the first branch, got:\n%s", result)
+	}
+}
+
+func TestPreProcessingHandleConditionals_ElifAfterElse_ReportsError(t *testing.T) {
+	source := `%if 0
+%else
+%elif 1
+%endif`
+	_, errs := preProcessing.HandleConditionals(source, map[string]bool{})
+
+	e := requireError(t, errs, "%elif after %else")
+	if e.Line != 3 || e.Previous != 2 {
+		t.Errorf("expected error at line 3 (else at 2), got %d (else at %d)", e.Line, e.Previous)
+	}
+}
+
+func TestPreProcessingHandleConditionals_ElifWithoutIf_ReportsError(t *testing.T) {
+	_, errs := preProcessing.HandleConditionals("%elifdef DEBUG", map[string]bool{})
+
+	requireError(t, errs, "%elifdef without matching %if/%ifdef/%ifndef")
+}
+
+// --- PreProcessingHandleConditionals: line preservation ---
+
+func TestPreProcessingHandleConditionals_PreservesLineNumbers(t *testing.T) {
+	source := `mov rax, 0
+%if 0
+mov rax, 1
+%else
+mov rax, 2
+%endif
+mov rax, 3`
+	result := mustHandleConditionals(t, source, map[string]bool{})
+
+	expected := "mov rax, 0\n\n\n\nmov rax, 2\n\nmov rax, 3"
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestPreProcessingHandleConditionals_DuplicateDefineInActiveBranch_ReportsError(t *testing.T) {
+	source := `%define SIZE 8
+%ifdef SIZE
+%define SIZE 16
+%endif`
+	_, errs := preProcessing.HandleConditionals(source, map[string]bool{})
+
+	e := requireError(t, errs, "duplicate %define for symbol 'SIZE'")
+	if e.Line != 3 || e.Previous != 1 {
+		t.Errorf("expected duplicate at line 3 first defined at line 1, got %d and %d", e.Line, e.Previous)
+	}
+}
+
+func TestPreProcessingHandleConditionals_AlternativeDefinesInBranches(t *testing.T) {
+	source := `%ifdef DEBUG
+%define LEVEL 2
+%else
+%define LEVEL 0
+%endif`
+	mustHandleConditionals(t, source, map[string]bool{})
+}
+
 // --- PreProcessingHandleConditionals: no conditionals ---
 
 func TestPreProcessingHandleConditionals_NoConditionals(t *testing.T) {
 	source := `mov rax, 1
 mov rdi, 0`
 	symbols := map[string]bool{}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	if result != source {
 		t.Errorf("expected source unchanged, got '%s'", result)
@@ -146,61 +286,29 @@ mov rdi, 0`
 
 // --- PreProcessingHandleConditionals: structural errors ---
 
-func TestPreProcessingHandleConditionals_UnmatchedEndif_Panics(t *testing.T) {
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatal("expected panic for unmatched endif")
-		}
-		msg, ok := r.(string)
-		if !ok {
-			t.Fatalf("expected string panic, got %T", r)
-		}
-		if !containsSubstring(msg, "%endif without matching") {
-			t.Errorf("unexpected panic message: %s", msg)
-		}
-	}()
-
+func TestPreProcessingHandleConditionals_UnmatchedEndif_ReportsError(t *testing.T) {
 	source := `mov rax, 1
 %endif`
-	preProcessing.HandleConditionals(source, map[string]bool{})
-}
+	_, errs := preProcessing.HandleConditionals(source, map[string]bool{})
 
-func TestPreProcessingHandleConditionals_UnmatchedIfdef_Panics(t *testing.T) {
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatal("expected panic for unmatched ifdef")
-		}
-		msg, ok := r.(string)
-		if !ok {
-			t.Fatalf("expected string panic, got %T", r)
-		}
-		if !containsSubstring(msg, "has no matching %endif") {
-			t.Errorf("unexpected panic message: %s", msg)
-		}
-	}()
+	e := requireError(t, errs, "%endif without matching")
+	if e.Line != 2 {
+		t.Errorf("expected error at line 2, got %d", e.Line)
+	}
+}
 
+func TestPreProcessingHandleConditionals_UnmatchedIfdef_ReportsError(t *testing.T) {
 	source := `%ifdef DEBUG
 mov rax, 1`
-	preProcessing.HandleConditionals(source, map[string]bool{})
-}
+	_, errs := preProcessing.HandleConditionals(source, map[string]bool{})
 
-func TestPreProcessingHandleConditionals_DuplicateElse_Panics(t *testing.T) {
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatal("expected panic for duplicate else")
-		}
-		msg, ok := r.(string)
-		if !ok {
-			t.Fatalf("expected string panic, got %T", r)
-		}
-		if !containsSubstring(msg, "Duplicate %else") {
-			t.Errorf("unexpected panic message: %s", msg)
-		}
-	}()
+	e := requireError(t, errs, "%ifdef has no matching %endif")
+	if e.Line != 1 {
+		t.Errorf("expected error at line 1, got %d", e.Line)
+	}
+}
 
+func TestPreProcessingHandleConditionals_DuplicateElse_ReportsError(t *testing.T) {
 	source := `%ifdef DEBUG
 mov rax, 1
 %else
@@ -208,29 +316,30 @@ mov rax, 0
 %else
 mov rax, 2
 %endif`
-	preProcessing.HandleConditionals(source, map[string]bool{"DEBUG": true})
-}
+	_, errs := preProcessing.HandleConditionals(source, map[string]bool{"DEBUG": true})
 
-func TestPreProcessingHandleConditionals_ElseWithoutIfdef_Panics(t *testing.T) {
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatal("expected panic for else without ifdef")
-		}
-		msg, ok := r.(string)
-		if !ok {
-			t.Fatalf("expected string panic, got %T", r)
-		}
-		if !containsSubstring(msg, "%else without matching") {
-			t.Errorf("unexpected panic message: %s", msg)
-		}
-	}()
+	e := requireError(t, errs, "duplicate %else")
+	if e.Line != 5 || e.Previous != 3 {
+		t.Errorf("expected error at line 5 (first at 3), got %d (first at %d)", e.Line, e.Previous)
+	}
+}
 
+func TestPreProcessingHandleConditionals_ElseWithoutIfdef_ReportsError(t *testing.T) {
 	source := `mov rax, 1
 %else
 mov rax, 0
 %endif`
-	preProcessing.HandleConditionals(source, map[string]bool{})
+	_, errs := preProcessing.HandleConditionals(source, map[string]bool{})
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors (%%else and %%endif), got %d: %v", len(errs), errs)
+	}
+	if !containsSubstring(errs[0].Message, "%else without matching") || errs[0].Line != 2 {
+		t.Errorf("unexpected first error: %s", errs[0])
+	}
+	if !containsSubstring(errs[1].Message, "%endif without matching") || errs[1].Line != 4 {
+		t.Errorf("unexpected second error: %s", errs[1])
+	}
 }
 
 // --- PreProcessingHandleConditionals: surrounding code preserved ---
@@ -242,7 +351,7 @@ mov rbx, 1
 %endif
 mov rcx, 2`
 	symbols := map[string]bool{"DEBUG": true}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	if !containsSubstring(result, "mov rax, 0") {
 		t.Error("expected code before block to be preserved")
@@ -262,7 +371,7 @@ mov rbx, 1
 %endif
 mov rcx, 2`
 	symbols := map[string]bool{}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	if !containsSubstring(result, "mov rax, 0") {
 		t.Error("expected code before block to be preserved")
@@ -278,7 +387,7 @@ mov rcx, 2`
 // --- PreProcessingHandleConditionals: empty source ---
 
 func TestPreProcessingHandleConditionals_EmptySource(t *testing.T) {
-	result := preProcessing.HandleConditionals("", map[string]bool{})
+	result := mustHandleConditionals(t, "", map[string]bool{})
 	if result != "" {
 		t.Errorf("expected empty result, got '%s'", result)
 	}
@@ -293,7 +402,7 @@ mov rax, 1
 mov rax, 0
 %endif`
 	symbols := map[string]bool{}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	if !containsSubstring(result, "mov rax, 1") {
 		t.Error("expected ifndef branch when symbol is not defined")
@@ -310,7 +419,7 @@ mov rax, 1
 mov rax, 0
 %endif`
 	symbols := map[string]bool{"RELEASE": true}
-	result := preProcessing.HandleConditionals(source, symbols)
+	result := mustHandleConditionals(t, source, symbols)
 
 	result = strings.TrimSpace(result)
 
@@ -437,3 +546,13 @@ func BenchmarkPreProcessingHandleConditionals_EmptySource(b *testing.B) {
 		preProcessing.HandleConditionals("", symbols)
 	}
 }
+
+// mustHandleConditionals evaluates conditionals and fails the test on pre-processing errors.
+func mustHandleConditionals(t *testing.T, source string, symbols map[string]bool) string {
+	t.Helper()
+	result, errs := preProcessing.HandleConditionals(source, symbols)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected pre-processing errors: %v", errs)
+	}
+	return result
+}
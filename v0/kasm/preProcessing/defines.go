@@ -0,0 +1,190 @@
+package preProcessing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// HandleDefines substitutes value-carrying %define symbols and blanks all
+// %define and %undef directive lines (FR-3.4, FR-7). The transformation is
+// line-preserving: line i of the result is produced from line i of source.
+//
+// The source is processed line by line, so a define is in effect from the
+// line after its directive until a matching %undef:
+//
+//	%define PAGE_SIZE 4096      ; object-like: PAGE_SIZE → 4096
+//	%define OFFSET(x) (x*8)     ; parameterised: OFFSET(2) → (2*8)
+//	%undef PAGE_SIZE
+//
+// A define is substituted wherever its name appears as a whole word outside
+// string literals and comments, so it also applies inside expanded macro
+// bodies and memory operands. Replacement text is rescanned for further
+// defines; a define is never expanded inside its own expansion. Defines
+// without a value are flags for %ifdef and are left untouched.
+//
+// Malformed directives and calls of a parameterised define with the wrong
+// number of arguments are reported as errors; the offending text is left
+// unexpanded.
+func HandleDefines(source string) (string, []PreProcessingError) {
+	return HandleDefinesWithCommandLine(source, nil)
+}
+
+// HandleDefinesWithCommandLine is HandleDefines with the symbols defined on
+// the command line in effect from the first line (FR-10.2). A %define or
+// %undef directive for a symbol defined or undefined on the command line is
+// blanked without effect and reported as a warning.
+func HandleDefinesWithCommandLine(source string, commandLine *CommandLineSymbols) (string, []PreProcessingError) {
+	errors := make([]PreProcessingError, 0)
+
+	// Early-exit: nothing to substitute or strip (AR-8.1, AR-8.2).
+	if len(source) == 0 {
+		return source, errors
+	}
+	if !strings.Contains(source, "%define") && !strings.Contains(source, "%undef") && len(commandLine.Defined()) == 0 {
+		return source, errors
+	}
+
+	expander := &defineExpander{
+		definitions: make(map[string]definition),
+		active:      make(map[string]bool),
+	}
+	if commandLine != nil {
+		for name, def := range commandLine.definitions {
+			expander.definitions[name] = def
+		}
+	}
+
+	lines := strings.Split(source, "\n")
+	result := make([]string, 0, len(lines))
+
+	for i, line := range lines {
+		lineNumber := i + 1
+
+		directive, rest := splitDefineDirective(line)
+		if directive == "" {
+			expander.errors = expander.errors[:0]
+			result = append(result, expander.expand(line))
+			for _, message := range expander.errors {
+				errors = append(errors, PreProcessingError{Message: message, Line: lineNumber})
+			}
+			continue
+		}
+
+		// Directive lines are blanked rather than removed, so that the
+		// result stays line-preserving.
+		result = append(result, "")
+
+		switch directive {
+		case "define":
+			def, message := parseDefinition(rest)
+			if message != "" {
+				errors = append(errors, PreProcessingError{Message: message, Line: lineNumber})
+				continue
+			}
+			if reason := commandLine.overrides(def.name); reason != "" {
+				errors = append(errors, overrideWarning(directive, def.name, reason, lineNumber))
+				continue
+			}
+			def.lineNumber = lineNumber
+			expander.definitions[def.name] = def
+		case "undef":
+			name := strings.TrimSpace(stripComment(rest))
+			if !isSymbolName(name) {
+				errors = append(errors, PreProcessingError{
+					Message: "%undef requires a symbol name",
+					Line:    lineNumber,
+				})
+				continue
+			}
+			if reason := commandLine.overrides(name); reason != "" {
+				errors = append(errors, overrideWarning(directive, name, reason, lineNumber))
+				continue
+			}
+			delete(expander.definitions, name)
+		}
+	}
+
+	return strings.Join(result, "\n"), errors
+}
+
+// defineExpander expands defines within a line of text. The active set holds
+// the names currently being expanded, which guards against self-referential
+// and mutually recursive definitions.
+type defineExpander struct {
+	definitions map[string]definition
+	active      map[string]bool
+	errors      []string
+}
+
+// expand returns text with every define substituted.
+func (e *defineExpander) expand(text string) string {
+	if len(e.definitions) == 0 {
+		return text
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(text))
+
+	i := 0
+	for i < len(text) {
+		t := nextToken(text, i)
+		i = t.end()
+		// Only words are substituted: string literals, comments, directives,
+		// macro parameters and numeric literals are copied as they are.
+		if t.kind != tokenWord {
+			sb.WriteString(t.text)
+			continue
+		}
+
+		word := t.text
+		def, ok := e.definitions[word]
+		if !ok || e.active[word] || (!def.hasParameters && def.body == "") {
+			sb.WriteString(word)
+			continue
+		}
+
+		if !def.hasParameters {
+			sb.WriteString(e.expandDefinition(def, nil))
+			continue
+		}
+
+		// A parameterised define is only expanded when followed by an
+		// argument list; a bare name is left as is.
+		args, argsEnd, found := splitDefineArguments(text, i)
+		if !found {
+			sb.WriteString(word)
+			continue
+		}
+		if argsEnd < 0 {
+			e.errors = append(e.errors, fmt.Sprintf("unterminated argument list for define '%s'", word))
+			sb.WriteString(text[t.offset:])
+			return sb.String()
+		}
+		if len(args) != len(def.parameters) {
+			e.errors = append(e.errors, fmt.Sprintf("define '%s' expects %d arguments, but got %d",
+				word, len(def.parameters), len(args)))
+			sb.WriteString(text[t.offset:argsEnd])
+			i = argsEnd
+			continue
+		}
+		sb.WriteString(e.expandDefinition(def, args))
+		i = argsEnd
+	}
+
+	return sb.String()
+}
+
+// expandDefinition substitutes args for the parameters of def and rescans
+// the result with def marked as active.
+func (e *defineExpander) expandDefinition(def definition, args []string) string {
+	body := def.body
+	if def.hasParameters {
+		body = substituteParameters(body, def.parameters, args)
+	}
+
+	e.active[def.name] = true
+	expanded := e.expand(body)
+	delete(e.active, def.name)
+
+	return expanded
+}
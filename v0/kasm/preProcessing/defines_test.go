@@ -0,0 +1,328 @@
+package preProcessing_test
+
+import (
+	"testing"
+
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+)
+
+// --- HandleDefines: object-like defines ---
+
+func TestHandleDefines_SubstitutesValue(t *testing.T) {
+	source := `%define PAGE_SIZE 4096
+mov rax, PAGE_SIZE`
+	result := mustHandleDefines(t, source)
+
+	if result != "\nmov rax, 4096" {
+		t.Errorf("expected 'mov rax, 4096', got %q", result)
+	}
+}
+
+func TestHandleDefines_WholeWordsOnly(t *testing.T) {
+	source := `%define SIZE 8
+mov rax, SIZE
+mov rbx, SIZE_MAX
+mov rcx, MY_SIZE
+mov rdx, point.SIZE`
+	result := mustHandleDefines(t, source)
+
+	expected := `
+mov rax, 8
+mov rbx, SIZE_MAX
+mov rcx, MY_SIZE
+mov rdx, point.SIZE`
+	if result != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, result)
+	}
+}
+
+func TestHandleDefines_SkipsStringsAndComments(t *testing.T) {
+	source := `%define NAME 1
+msg db "NAME", 0 ; NAME stays
+mov rax, NAME`
+	result := mustHandleDefines(t, source)
+
+	expected := `
+msg db "NAME", 0 ; NAME stays
+mov rax, 1`
+	if result != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, result)
+	}
+}
+
+func TestHandleDefines_SkipsSingleQuotedStrings(t *testing.T) {
+	source := `%define N 3
+db 'N', "N", N`
+	result := mustHandleDefines(t, source)
+
+	if result != "\ndb 'N', \"N\", 3" {
+		t.Errorf("expected only the bare N to be replaced, got %q", result)
+	}
+}
+
+func TestHandleDefines_SemicolonInsideQuotes(t *testing.T) {
+	source := `%define SEMI ';' ; a semicolon
+%define DQ ";"
+db SEMI, 1
+db DQ, 2`
+	result := mustHandleDefines(t, source)
+
+	expected := "\n\ndb ';', 1\ndb \";\", 2"
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestHandleDefines_MemoryOperand(t *testing.T) {
+	source := `%define SLOT 16
+mov rax, [rbp - SLOT]`
+	result := mustHandleDefines(t, source)
+
+	if result != "\nmov rax, [rbp - 16]" {
+		t.Errorf("expected 'mov rax, [rbp - 16]', got %q", result)
+	}
+}
+
+func TestHandleDefines_ValueCommentIsNotPartOfValue(t *testing.T) {
+	source := `%define SIZE 8 ; bytes
+mov rax, SIZE`
+	result := mustHandleDefines(t, source)
+
+	if result != "\nmov rax, 8" {
+		t.Errorf("expected 'mov rax, 8', got %q", result)
+	}
+}
+
+func TestHandleDefines_OnlyAfterDefinition(t *testing.T) {
+	source := `mov rax, SIZE
+%define SIZE 8
+mov rbx, SIZE`
+	result := mustHandleDefines(t, source)
+
+	expected := `mov rax, SIZE
+
+mov rbx, 8`
+	if result != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, result)
+	}
+}
+
+func TestHandleDefines_NestedDefines(t *testing.T) {
+	source := `%define BASE 0x1000
+%define TOP BASE + 16
+mov rax, TOP`
+	result := mustHandleDefines(t, source)
+
+	if result != "\n\nmov rax, 0x1000 + 16" {
+		t.Errorf("expected 'mov rax, 0x1000 + 16', got %q", result)
+	}
+}
+
+func TestHandleDefines_SelfReferenceIsNotExpanded(t *testing.T) {
+	source := `%define A B
+%define B A
+mov rax, A`
+	result := mustHandleDefines(t, source)
+
+	if result != "\n\nmov rax, A" {
+		t.Errorf("expected recursion to stop at 'A', got %q", result)
+	}
+}
+
+func TestHandleDefines_FlagDefinesAreNotSubstituted(t *testing.T) {
+	source := `%define DEBUG
+mov rax, DEBUG`
+	result := mustHandleDefines(t, source)
+
+	if result != "\nmov rax, DEBUG" {
+		t.Errorf("expected flag define to be left untouched, got %q", result)
+	}
+}
+
+// --- HandleDefines: parameterised defines ---
+
+func TestHandleDefines_Parameterised(t *testing.T) {
+	source := `%define OFFSET(x) (x*8)
+mov rax, [rbx + OFFSET(2)]`
+	result := mustHandleDefines(t, source)
+
+	if result != "\nmov rax, [rbx + (2*8)]" {
+		t.Errorf("expected 'mov rax, [rbx + (2*8)]', got %q", result)
+	}
+}
+
+func TestHandleDefines_ParameterisedMultipleArguments(t *testing.T) {
+	source := `%define ADDR(base, index) [base + index*8]
+mov rax, ADDR(rbx, rcx)`
+	result := mustHandleDefines(t, source)
+
+	if result != "\nmov rax, [rbx + rcx*8]" {
+		t.Errorf("expected 'mov rax, [rbx + rcx*8]', got %q", result)
+	}
+}
+
+func TestHandleDefines_ParameterisedNestedArguments(t *testing.T) {
+	source := `%define FIRST(a, b) a
+%define OFFSET(x) (x*8)
+mov rax, FIRST(OFFSET(1), (2, 3))`
+	result := mustHandleDefines(t, source)
+
+	if result != "\n\nmov rax, (1*8)" {
+		t.Errorf("expected 'mov rax, (1*8)', got %q", result)
+	}
+}
+
+func TestHandleDefines_ParameterisedQuotedArguments(t *testing.T) {
+	source := `%define PAIR(a, b) db a, 'a', b
+PAIR(',', ';')`
+	result := mustHandleDefines(t, source)
+
+	if result != "\ndb ',', 'a', ';'" {
+		t.Errorf("expected \"db ',', 'a', ';'\", got %q", result)
+	}
+}
+
+func TestHandleDefines_ParameterisedWithoutArgumentsIsLeftAlone(t *testing.T) {
+	source := `%define OFFSET(x) (x*8)
+mov rax, OFFSET`
+	result := mustHandleDefines(t, source)
+
+	if result != "\nmov rax, OFFSET" {
+		t.Errorf("expected bare name to be left untouched, got %q", result)
+	}
+}
+
+func TestHandleDefines_SpaceBeforeParenthesisIsAValue(t *testing.T) {
+	source := `%define SUM (1+2)
+mov rax, SUM`
+	result := mustHandleDefines(t, source)
+
+	if result != "\nmov rax, (1+2)" {
+		t.Errorf("expected 'mov rax, (1+2)', got %q", result)
+	}
+}
+
+func TestHandleDefines_WrongArgumentCount_ReportsError(t *testing.T) {
+	source := `%define OFFSET(x) (x*8)
+mov rax, OFFSET(1, 2)`
+	result, errs := preProcessing.HandleDefines(source)
+
+	e := requireError(t, errs, "define 'OFFSET' expects 1 arguments, but got 2")
+	if e.Line != 2 {
+		t.Errorf("expected error at line 2, got %d", e.Line)
+	}
+	if result != "\nmov rax, OFFSET(1, 2)" {
+		t.Errorf("expected the call to be left unexpanded, got %q", result)
+	}
+}
+
+func TestHandleDefines_UnterminatedArguments_ReportsError(t *testing.T) {
+	source := `%define OFFSET(x) (x*8)
+mov rax, OFFSET(1`
+	_, errs := preProcessing.HandleDefines(source)
+
+	requireError(t, errs, "unterminated argument list for define 'OFFSET'")
+}
+
+// --- HandleDefines: %undef ---
+
+func TestHandleDefines_Undef(t *testing.T) {
+	source := `%define SIZE 8
+mov rax, SIZE
+%undef SIZE
+mov rbx, SIZE`
+	result := mustHandleDefines(t, source)
+
+	expected := `
+mov rax, 8
+
+mov rbx, SIZE`
+	if result != expected {
+		t.Errorf("expected:\n%s\ngot:\n%s", expected, result)
+	}
+}
+
+func TestHandleDefines_UndefWithoutName_ReportsError(t *testing.T) {
+	_, errs := preProcessing.HandleDefines("%undef")
+
+	requireError(t, errs, "%undef requires a symbol name")
+}
+
+// --- HandleDefines: directive validation ---
+
+func TestHandleDefines_BlanksDirectives(t *testing.T) {
+	source := `%define DEBUG
+  %define SIZE 8
+%undef SIZE
+mov rax, 1`
+	result := mustHandleDefines(t, source)
+
+	if result != "\n\n\nmov rax, 1" {
+		t.Errorf("expected all directive lines to be blanked, got %q", result)
+	}
+}
+
+func TestHandleDefines_MalformedDirectives_ReportErrors(t *testing.T) {
+	source := `%define
+%define 9LIVES 9
+%define A-B 1
+%define F(x, x) x
+%define G(x 1`
+	_, errs := preProcessing.HandleDefines(source)
+
+	expected := []string{
+		"%define requires a symbol name",
+		"%define requires a symbol name",
+		"malformed %define 'A'",
+		"duplicate parameter 'x' in %define 'F'",
+		"unterminated parameter list in %define 'G'",
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, message := range expected {
+		if errs[i].Message != message || errs[i].Line != i+1 {
+			t.Errorf("expected %q at line %d, got %v", message, i+1, errs[i])
+		}
+	}
+}
+
+func TestHandleDefines_NoDirectives(t *testing.T) {
+	source := "mov rax, SIZE"
+	if result := mustHandleDefines(t, source); result != source {
+		t.Errorf("expected source to be unchanged, got %q", result)
+	}
+}
+
+// --- CreateSymbolTable: value-carrying defines ---
+
+func TestPreProcessingCreateSymbolTable_ValueAndParameterisedDefines(t *testing.T) {
+	source := `%define PAGE_SIZE 4096
+%define OFFSET(x) (x*8)`
+	symbols := mustCreateSymbolTable(t, source, nil)
+
+	if !symbols["PAGE_SIZE"] || !symbols["OFFSET"] {
+		t.Errorf("expected PAGE_SIZE and OFFSET to be defined, got %v", symbols)
+	}
+}
+
+func TestPreProcessingCreateSymbolTable_RedefineAfterUndef(t *testing.T) {
+	source := `%define SIZE 8
+%undef SIZE
+%define SIZE 16`
+	symbols := mustCreateSymbolTable(t, source, nil)
+
+	if !symbols["SIZE"] {
+		t.Error("expected SIZE to be defined")
+	}
+}
+
+// mustHandleDefines substitutes defines and fails the test on pre-processing errors.
+func mustHandleDefines(t *testing.T, source string) string {
+	t.Helper()
+	result, errs := preProcessing.HandleDefines(source)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected pre-processing errors: %v", errs)
+	}
+	return result
+}
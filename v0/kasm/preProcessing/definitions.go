@@ -0,0 +1,194 @@
+package preProcessing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// This file holds the %define parsing and text scanning helpers shared by the
+// conditionals phase, which tracks definitions to evaluate %if expressions,
+// and the defines phase, which substitutes them (AR-1.2).
+
+// substituteParameters replaces whole-word occurrences of each parameter in
+// body with the matching argument. String literals are left untouched.
+func substituteParameters(body string, parameters []string, args []string) string {
+	values := make(map[string]string, len(parameters))
+	for i, name := range parameters {
+		values[name] = args[i]
+	}
+
+	return rewriteTokens(body, func(t token) (string, bool) {
+		if t.kind != tokenWord {
+			return "", false
+		}
+		value, ok := values[t.text]
+		return value, ok
+	})
+}
+
+// splitDefineDirective returns the directive name ("define" or "undef") and
+// the remainder of the line if line is a %define or %undef directive, or two
+// empty strings otherwise.
+func splitDefineDirective(line string) (string, string) {
+	directive, rest, _ := splitDirective(line)
+	if (directive != "define" && directive != "undef") || !separatesOperands(rest) {
+		return "", ""
+	}
+	return directive, rest
+}
+
+// separatesOperands returns true if rest, the remainder of a line after a
+// directive name, is empty or starts with whitespace, so that the directive
+// is not followed directly by punctuation such as '(' or '+'.
+func separatesOperands(rest string) bool {
+	return rest == "" || rest[0] == ' ' || rest[0] == '\t' || rest[0] == '\r'
+}
+
+// parseDefinition parses the remainder of a %define line. It returns an
+// error message instead of a definition if the directive is malformed.
+func parseDefinition(rest string) (definition, string) {
+	text := strings.TrimSpace(stripComment(rest))
+
+	nameEnd := 0
+	for nameEnd < len(text) && isSymbolChar(text[nameEnd]) {
+		nameEnd++
+	}
+	name := text[:nameEnd]
+	if !isSymbolName(name) {
+		return definition{}, "%define requires a symbol name"
+	}
+
+	def := definition{name: name}
+	remainder := text[nameEnd:]
+
+	switch {
+	case remainder == "":
+	case remainder[0] == '(':
+		closing := strings.IndexByte(remainder, ')')
+		if closing < 0 {
+			return definition{}, fmt.Sprintf("unterminated parameter list in %%define '%s'", name)
+		}
+		def.hasParameters = true
+		def.parameters = make([]string, 0)
+		if list := strings.TrimSpace(remainder[1:closing]); list != "" {
+			seen := make(map[string]bool)
+			for _, param := range strings.Split(list, ",") {
+				param = strings.TrimSpace(param)
+				if !isSymbolName(param) {
+					return definition{}, fmt.Sprintf("invalid parameter '%s' in %%define '%s'", param, name)
+				}
+				if seen[param] {
+					return definition{}, fmt.Sprintf("duplicate parameter '%s' in %%define '%s'", param, name)
+				}
+				seen[param] = true
+				def.parameters = append(def.parameters, param)
+			}
+		}
+		def.body = strings.TrimSpace(remainder[closing+1:])
+	case remainder[0] == ' ' || remainder[0] == '\t':
+		def.body = strings.TrimSpace(remainder)
+	default:
+		return definition{}, fmt.Sprintf("malformed %%define '%s'", name)
+	}
+
+	return def, ""
+}
+
+// splitDefineArguments parses the argument list of a parameterised define
+// call starting at offset start (directly after the name). found is false if
+// no '(' follows; end is -1 if the list is not closed before the end of the
+// line or a comment. Commas inside nested parentheses, brackets and string
+// literals do not split arguments.
+func splitDefineArguments(text string, start int) (args []string, end int, found bool) {
+	i := start
+	for i < len(text) && (text[i] == ' ' || text[i] == '\t') {
+		i++
+	}
+	if i >= len(text) || text[i] != '(' {
+		return nil, 0, false
+	}
+
+	depth := 0
+	argStart := i + 1
+	for j := i + 1; j < len(text); {
+		t := nextToken(text, j)
+		j = t.end()
+		if t.kind == tokenComment {
+			break
+		}
+		if t.kind != tokenPunct {
+			continue
+		}
+		switch t.text {
+		case "(", "[":
+			depth++
+		case "]":
+			depth--
+		case ")":
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(text[argStart:t.offset]))
+				// An empty list is a call without arguments.
+				if len(args) == 1 && args[0] == "" {
+					args = args[:0]
+				}
+				return args, j, true
+			}
+			depth--
+		case ",":
+			if depth == 0 {
+				args = append(args, strings.TrimSpace(text[argStart:t.offset]))
+				argStart = j
+			}
+		}
+	}
+
+	return nil, -1, true
+}
+
+// stripComment returns text up to its ';' comment, if any.
+func stripComment(text string) string {
+	for offset := 0; offset < len(text); {
+		t := nextToken(text, offset)
+		if t.kind == tokenComment {
+			return text[:t.offset]
+		}
+		offset = t.end()
+	}
+	return text
+}
+
+// scanWord returns the offset just past the word starting at offset start.
+// Words use the lexer's word characters, so dotted names such as
+// `point.x` and macro-local labels such as `..@1.loop` are a single word.
+func scanWord(text string, start int) int {
+	for start < len(text) && (isSymbolChar(text[start]) || text[start] == '.' || text[start] == '@') {
+		start++
+	}
+	return start
+}
+
+// isSymbolName returns true if name is a valid %define symbol name (\w+, not
+// starting with a digit).
+func isSymbolName(name string) bool {
+	if name == "" || isDigit(name[0]) {
+		return false
+	}
+	for i := 0; i < len(name); i++ {
+		if !isSymbolChar(name[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isWordStart(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || ch == '_' || ch == '.'
+}
+
+func isSymbolChar(ch byte) bool {
+	return (ch >= 'a' && ch <= 'z') || (ch >= 'A' && ch <= 'Z') || isDigit(ch) || ch == '_'
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
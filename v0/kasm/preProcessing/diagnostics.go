@@ -0,0 +1,50 @@
+package preProcessing
+
+import (
+	"fmt"
+	"strings"
+)
+
+// diagnosticSeverities maps the user diagnostic directives, without the
+// leading '%', to the severity they are reported at (FR-9.1).
+var diagnosticSeverities = map[string]Severity{
+	"error":   SeverityError,
+	"warning": SeverityWarning,
+	"fatal":   SeverityFatal,
+}
+
+// hasDiagnostics returns true if source may contain a user diagnostic
+// directive. Used as an early-exit check.
+func hasDiagnostics(source string) bool {
+	return strings.Contains(source, "%error") ||
+		strings.Contains(source, "%warning") ||
+		strings.Contains(source, "%fatal")
+}
+
+// userDiagnostic returns the diagnostic reported by line if it is an %error,
+// %warning or %fatal directive. ok is false for any other line.
+//
+// The message is the operand of the directive up to a comment. A message
+// wrapped in double or single quotes is reported without them; a directive
+// without a message reports its own name.
+func userDiagnostic(line string, lineNumber int) (diagnostic PreProcessingError, ok bool) {
+	directive, rest, _ := splitDirective(line)
+	severity, ok := diagnosticSeverities[directive]
+	if !ok {
+		return PreProcessingError{}, false
+	}
+
+	message := strings.TrimSpace(rest)
+	if len(message) > 0 && (message[0] == '"' || message[0] == '\'') {
+		if closing := strings.IndexByte(message[1:], message[0]); closing >= 0 {
+			message = message[1 : closing+1]
+		}
+	} else {
+		message = strings.TrimSpace(stripComment(message))
+	}
+	if message == "" {
+		message = fmt.Sprintf("%%%s", directive)
+	}
+
+	return PreProcessingError{Message: message, Line: lineNumber, Severity: severity}, true
+}
@@ -0,0 +1,82 @@
+package preProcessing_test
+
+import (
+	"testing"
+
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+)
+
+// --- FR-9.1: %error, %warning and %fatal ---
+
+func TestHandleConditionals_UserDiagnostics(t *testing.T) {
+	tests := []struct {
+		line     string
+		message  string
+		severity preProcessing.Severity
+	}{
+		{`%error "KERNEL_BASE must be defined"`, "KERNEL_BASE must be defined", preProcessing.SeverityError},
+		{`  %warning 'stack is small' ; why`, "stack is small", preProcessing.SeverityWarning},
+		{`%fatal unsupported target ; why`, "unsupported target", preProcessing.SeverityFatal},
+		{`%error "a ; b"`, "a ; b", preProcessing.SeverityError},
+		{`%warning`, "%warning", preProcessing.SeverityWarning},
+	}
+
+	for _, tt := range tests {
+		result, errs := preProcessing.HandleConditionals("mov rax, 1\n"+tt.line, nil)
+		if len(errs) != 1 {
+			t.Fatalf("%q: expected 1 diagnostic, got %v", tt.line, errs)
+		}
+		e := errs[0]
+		if e.Message != tt.message || e.Severity != tt.severity || e.Line != 2 {
+			t.Errorf("%q: expected %s %q at line 2, got %s %q at line %d", tt.line, tt.severity, tt.message, e.Severity, e.Message, e.Line)
+		}
+		if result != "mov rax, 1\n" {
+			t.Errorf("%q: expected the directive to be blanked, got %q", tt.line, result)
+		}
+	}
+}
+
+func TestHandleConditionals_UserDiagnosticsOnlyInActiveBranches(t *testing.T) {
+	source := `%ifndef KERNEL_BASE
+%error "KERNEL_BASE must be defined"
+%elif KERNEL_BASE < 0x1000
+%warning "KERNEL_BASE is in the first page"
+%endif`
+
+	_, errs := preProcessing.HandleConditionals("%define KERNEL_BASE 0x100000\n"+source, nil)
+	if len(errs) != 0 {
+		t.Errorf("expected no diagnostics when KERNEL_BASE is valid, got %v", errs)
+	}
+
+	_, errs = preProcessing.HandleConditionals(source, nil)
+	e := requireError(t, errs, "KERNEL_BASE must be defined")
+	if len(errs) != 1 || e.Line != 2 || e.Severity != preProcessing.SeverityError {
+		t.Errorf("expected a single error at line 2, got %v", errs)
+	}
+
+	_, errs = preProcessing.HandleConditionals("%define KERNEL_BASE 16\n"+source, nil)
+	e = requireError(t, errs, "KERNEL_BASE is in the first page")
+	if len(errs) != 1 || e.Line != 5 || e.Severity != preProcessing.SeverityWarning {
+		t.Errorf("expected a single warning at line 5, got %v", errs)
+	}
+}
+
+func TestHandleConditionals_FatalStopsEvaluation(t *testing.T) {
+	source := `%fatal "stop"
+%error "after"
+%ifdef X`
+
+	_, errs := preProcessing.HandleConditionals(source, nil)
+
+	if len(errs) != 1 || errs[0].Severity != preProcessing.SeverityFatal {
+		t.Errorf("expected only the fatal diagnostic, got %v", errs)
+	}
+}
+
+func TestPreProcessingError_StringIncludesSeverity(t *testing.T) {
+	e := preProcessing.PreProcessingError{Message: "careful", Line: 3, Severity: preProcessing.SeverityWarning}
+
+	if e.String() != "3: warning: careful" {
+		t.Errorf("expected the severity in the string form, got: %s", e.String())
+	}
+}
@@ -0,0 +1,68 @@
+package preProcessing
+
+import "fmt"
+
+// Severity classifies a PreProcessingError. The zero value is SeverityError,
+// so the pre-processor's own errors do not set it; user diagnostics
+// (%warning, %fatal) do (FR-9).
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+	SeverityFatal
+)
+
+// String returns the name of the severity: "error", "warning" or "fatal".
+func (s Severity) String() string {
+	switch s {
+	case SeverityWarning:
+		return "warning"
+	case SeverityFatal:
+		return "fatal"
+	default:
+		return "error"
+	}
+}
+
+// PreProcessingError represents a single error encountered during
+// pre-processing. It is a plain data struct — not an error interface
+// implementation — so that multiple errors can be accumulated and returned as
+// a slice (FR-5.2).
+//
+// Line refers to the source passed to the reporting function. The
+// pre-processor does not know which file a line came from; the orchestrator
+// maps it back to the original file and line before recording it (AR-2.4).
+type PreProcessingError struct {
+	Message string
+	Line    int
+	// Column - the 1-based column within Line, or 0 for the entire line.
+	Column int
+	// Previous - the line of the first occurrence for duplicate errors, or 0.
+	Previous int
+	// Severity - how the orchestrator records the error (FR-9.2).
+	Severity Severity
+	// Phase - the step that reported the error, if it is not the one the
+	// reporting function belongs to, e.g. PhaseStringDirectives; or "".
+	Phase string
+}
+
+// PhaseStringDirectives is the Phase of errors in the directive forms of the
+// string functions (%strlen, %substr, %strcat), which are evaluated by
+// HandleRepetitions (FR-14.6.5).
+const PhaseStringDirectives = "string-directives"
+
+// String returns a human-readable representation of the pre-processing error.
+func (e PreProcessingError) String() string {
+	position := fmt.Sprintf("%d", e.Line)
+	if e.Column > 0 {
+		position = fmt.Sprintf("%d:%d", e.Line, e.Column)
+	}
+	if e.Severity != SeverityError {
+		position = fmt.Sprintf("%s: %s", position, e.Severity)
+	}
+	if e.Previous > 0 {
+		return fmt.Sprintf("%s: %s (first defined at line %d)", position, e.Message, e.Previous)
+	}
+	return fmt.Sprintf("%s: %s", position, e.Message)
+}
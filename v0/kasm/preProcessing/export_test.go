@@ -0,0 +1,22 @@
+package preProcessing
+
+// LineStrings exposes the tokeniser to the external test package: it returns
+// the string literals of line, without their quotes, with the byte offset of
+// each, and the offset of the line's comment, or -1 if it has none.
+func LineStrings(line string) (literals []string, offsets []int, comment int) {
+	comment = -1
+	for _, t := range tokenizeLine(line) {
+		switch t.kind {
+		case tokenString:
+			literal := t.text[1:]
+			if len(literal) > 0 && literal[len(literal)-1] == t.text[0] {
+				literal = literal[:len(literal)-1]
+			}
+			literals = append(literals, literal)
+			offsets = append(offsets, t.offset)
+		case tokenComment:
+			comment = t.offset
+		}
+	}
+	return literals, offsets, comment
+}
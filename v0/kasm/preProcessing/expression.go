@@ -0,0 +1,549 @@
+package preProcessing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// expressionTokenKind classifies a token of a %if expression.
+type expressionTokenKind int
+
+const (
+	expressionNumber expressionTokenKind = iota
+	expressionIdentifier
+	expressionOperator
+	expressionEnd
+)
+
+// expressionToken is a single token of a %if expression. Offset is the 0-based
+// byte offset of the token within the expression text.
+type expressionToken struct {
+	kind   expressionTokenKind
+	text   string
+	offset int
+}
+
+// expressionError is an error found while evaluating a %if expression. Offset
+// is the 0-based byte offset of the offending token within the expression.
+type expressionError struct {
+	message string
+	offset  int
+}
+
+// expressionOperators lists the operators of the expression language, longest
+// first so that tokenising is greedy.
+var expressionOperators = []string{
+	"<<", ">>", "<=", ">=", "==", "!=", "<>", "&&", "||",
+	"+", "-", "*", "/", "%", "<", ">", "=", "!", "~", "&", "|", "^", "(", ")", ",",
+}
+
+// expressionEvaluator evaluates a constant integer expression (FR-4.7). It is
+// a recursive-descent parser with C operator precedence that evaluates while
+// parsing. Identifiers are resolved through the definitions in effect; the
+// active set holds the definitions currently being evaluated, which stops
+// recursive definitions.
+//
+// When skip is set the evaluator only parses: identifiers are not resolved and
+// arithmetic errors are not reported. It is used for the operand of && and ||
+// that short-circuiting leaves unevaluated, so that `defined(X) && X > 0` is
+// valid when X is not defined.
+type expressionEvaluator struct {
+	tokens      []expressionToken
+	position    int
+	definitions map[string]definition
+	defined     map[string]bool
+	active      map[string]bool
+	skip        bool
+	err         *expressionError
+}
+
+// evaluateExpression evaluates text as a constant integer expression.
+func evaluateExpression(text string, definitions map[string]definition, defined map[string]bool) (int64, *expressionError) {
+	return evaluateNested(text, definitions, defined, make(map[string]bool))
+}
+
+// evaluateNested evaluates text with the given set of active definitions.
+// Pre-processor functions and pasting operators in text are evaluated first
+// (FR-14.5), so that `%strlen(NAME) > 8` is an expression.
+func evaluateNested(text string, definitions map[string]definition, defined map[string]bool, active map[string]bool) (int64, *expressionError) {
+	if strings.Contains(text, "%") {
+		functions := &functionExpander{definitions: definitions, defined: defined, active: active}
+		text = functions.expand(text)
+		if len(functions.errors) > 0 {
+			return 0, &expressionError{message: functions.errors[0].message, offset: functions.errors[0].offset}
+		}
+	}
+
+	tokens, err := tokeniseExpression(text)
+	if err != nil {
+		return 0, err
+	}
+
+	e := &expressionEvaluator{
+		tokens:      tokens,
+		definitions: definitions,
+		defined:     defined,
+		active:      active,
+	}
+
+	if e.peek().kind == expressionEnd {
+		return 0, &expressionError{message: "expected an expression", offset: e.peek().offset}
+	}
+
+	value := e.parseLogicalOr()
+	if e.err == nil && e.peek().kind != expressionEnd {
+		e.fail(fmt.Sprintf("unexpected '%s' in expression", e.peek().text), e.peek().offset)
+	}
+	if e.err != nil {
+		return 0, e.err
+	}
+	return value, nil
+}
+
+// tokeniseExpression splits text into expression tokens.
+func tokeniseExpression(text string) ([]expressionToken, *expressionError) {
+	tokens := make([]expressionToken, 0)
+
+	i := 0
+	for i < len(text) {
+		ch := text[i]
+		switch {
+		case ch == ' ' || ch == '\t' || ch == '\r':
+			i++
+		case isDigit(ch):
+			end := i
+			for end < len(text) && (isSymbolChar(text[end])) {
+				end++
+			}
+			tokens = append(tokens, expressionToken{kind: expressionNumber, text: text[i:end], offset: i})
+			i = end
+		case isWordStart(ch):
+			end := scanWord(text, i)
+			tokens = append(tokens, expressionToken{kind: expressionIdentifier, text: text[i:end], offset: i})
+			i = end
+		default:
+			operator := ""
+			for _, candidate := range expressionOperators {
+				if strings.HasPrefix(text[i:], candidate) {
+					operator = candidate
+					break
+				}
+			}
+			if operator == "" {
+				return nil, &expressionError{message: fmt.Sprintf("unexpected character '%c' in expression", ch), offset: i}
+			}
+			tokens = append(tokens, expressionToken{kind: expressionOperator, text: operator, offset: i})
+			i += len(operator)
+		}
+	}
+
+	tokens = append(tokens, expressionToken{kind: expressionEnd, text: "end of expression", offset: len(text)})
+	return tokens, nil
+}
+
+// --- Token access ---
+
+func (e *expressionEvaluator) peek() expressionToken {
+	return e.tokens[e.position]
+}
+
+func (e *expressionEvaluator) next() expressionToken {
+	token := e.tokens[e.position]
+	if token.kind != expressionEnd {
+		e.position++
+	}
+	return token
+}
+
+// accept consumes the next token if it is one of the given operators.
+func (e *expressionEvaluator) accept(operators ...string) (string, bool) {
+	token := e.peek()
+	if token.kind != expressionOperator {
+		return "", false
+	}
+	for _, operator := range operators {
+		if token.text == operator {
+			e.position++
+			return operator, true
+		}
+	}
+	return "", false
+}
+
+// expect consumes the given operator or records an error.
+func (e *expressionEvaluator) expect(operator string) {
+	if _, ok := e.accept(operator); !ok && e.err == nil {
+		e.fail(fmt.Sprintf("expected '%s' but found '%s'", operator, e.peek().text), e.peek().offset)
+	}
+}
+
+// fail records the first error. Later errors are consequences of the first
+// and are dropped.
+func (e *expressionEvaluator) fail(message string, offset int) {
+	if e.err == nil {
+		e.err = &expressionError{message: message, offset: offset}
+	}
+}
+
+// --- Grammar, lowest precedence first ---
+
+func (e *expressionEvaluator) parseLogicalOr() int64 {
+	left := e.parseLogicalAnd()
+	for e.err == nil {
+		if _, ok := e.accept("||"); !ok {
+			break
+		}
+		right := e.parseSkippedIf(left != 0, e.parseLogicalAnd)
+		left = boolValue(left != 0 || right != 0)
+	}
+	return left
+}
+
+func (e *expressionEvaluator) parseLogicalAnd() int64 {
+	left := e.parseBitwiseOr()
+	for e.err == nil {
+		if _, ok := e.accept("&&"); !ok {
+			break
+		}
+		right := e.parseSkippedIf(left == 0, e.parseBitwiseOr)
+		left = boolValue(left != 0 && right != 0)
+	}
+	return left
+}
+
+// parseSkippedIf parses an operand, only evaluating it if skip is false.
+func (e *expressionEvaluator) parseSkippedIf(skip bool, parse func() int64) int64 {
+	previous := e.skip
+	e.skip = e.skip || skip
+	value := parse()
+	e.skip = previous
+	return value
+}
+
+func (e *expressionEvaluator) parseBitwiseOr() int64 {
+	left := e.parseBitwiseXor()
+	for e.err == nil {
+		if _, ok := e.accept("|"); !ok {
+			break
+		}
+		left |= e.parseBitwiseXor()
+	}
+	return left
+}
+
+func (e *expressionEvaluator) parseBitwiseXor() int64 {
+	left := e.parseBitwiseAnd()
+	for e.err == nil {
+		if _, ok := e.accept("^"); !ok {
+			break
+		}
+		left ^= e.parseBitwiseAnd()
+	}
+	return left
+}
+
+func (e *expressionEvaluator) parseBitwiseAnd() int64 {
+	left := e.parseEquality()
+	for e.err == nil {
+		if _, ok := e.accept("&"); !ok {
+			break
+		}
+		left &= e.parseEquality()
+	}
+	return left
+}
+
+func (e *expressionEvaluator) parseEquality() int64 {
+	left := e.parseRelational()
+	for e.err == nil {
+		operator, ok := e.accept("==", "=", "!=", "<>")
+		if !ok {
+			break
+		}
+		right := e.parseRelational()
+		if operator == "==" || operator == "=" {
+			left = boolValue(left == right)
+		} else {
+			left = boolValue(left != right)
+		}
+	}
+	return left
+}
+
+func (e *expressionEvaluator) parseRelational() int64 {
+	left := e.parseShift()
+	for e.err == nil {
+		operator, ok := e.accept("<=", ">=", "<", ">")
+		if !ok {
+			break
+		}
+		right := e.parseShift()
+		switch operator {
+		case "<":
+			left = boolValue(left < right)
+		case "<=":
+			left = boolValue(left <= right)
+		case ">":
+			left = boolValue(left > right)
+		case ">=":
+			left = boolValue(left >= right)
+		}
+	}
+	return left
+}
+
+func (e *expressionEvaluator) parseShift() int64 {
+	left := e.parseAdditive()
+	for e.err == nil {
+		operator, ok := e.accept("<<", ">>")
+		if !ok {
+			break
+		}
+		offset := e.peek().offset
+		right := e.parseAdditive()
+		if right < 0 || right > 63 {
+			if !e.skip {
+				e.fail(fmt.Sprintf("shift count %d out of range", right), offset)
+			}
+			continue
+		}
+		if operator == "<<" {
+			left <<= uint(right)
+		} else {
+			left >>= uint(right)
+		}
+	}
+	return left
+}
+
+func (e *expressionEvaluator) parseAdditive() int64 {
+	left := e.parseMultiplicative()
+	for e.err == nil {
+		operator, ok := e.accept("+", "-")
+		if !ok {
+			break
+		}
+		if operator == "+" {
+			left += e.parseMultiplicative()
+		} else {
+			left -= e.parseMultiplicative()
+		}
+	}
+	return left
+}
+
+func (e *expressionEvaluator) parseMultiplicative() int64 {
+	left := e.parseUnary()
+	for e.err == nil {
+		operator, ok := e.accept("*", "/", "%")
+		if !ok {
+			break
+		}
+		offset := e.tokens[e.position-1].offset
+		right := e.parseUnary()
+		if operator == "*" {
+			left *= right
+			continue
+		}
+		if right == 0 {
+			if !e.skip {
+				e.fail("division by zero", offset)
+			}
+			continue
+		}
+		if operator == "/" {
+			left /= right
+		} else {
+			left %= right
+		}
+	}
+	return left
+}
+
+func (e *expressionEvaluator) parseUnary() int64 {
+	operator, ok := e.accept("-", "+", "!", "~")
+	if !ok {
+		return e.parsePrimary()
+	}
+	value := e.parseUnary()
+	switch operator {
+	case "-":
+		return -value
+	case "!":
+		return boolValue(value == 0)
+	case "~":
+		return ^value
+	}
+	return value
+}
+
+func (e *expressionEvaluator) parsePrimary() int64 {
+	token := e.next()
+
+	switch token.kind {
+	case expressionNumber:
+		value, ok := parseNumber(token.text)
+		if !ok {
+			e.fail(fmt.Sprintf("invalid number '%s'", token.text), token.offset)
+			return 0
+		}
+		return value
+
+	case expressionIdentifier:
+		if token.text == "defined" {
+			return e.parseDefined(token)
+		}
+		return e.resolve(token)
+
+	case expressionOperator:
+		if token.text == "(" {
+			value := e.parseLogicalOr()
+			e.expect(")")
+			return value
+		}
+	}
+
+	e.fail(fmt.Sprintf("expected a value but found '%s'", token.text), token.offset)
+	return 0
+}
+
+// parseNumber parses a numeric literal the way the code generator parses an
+// immediate: `0x` hexadecimal, `0b` binary, and decimal otherwise, so that
+// `010` is ten in both. Any other form, such as `0o17` or `1_000`, is
+// rejected.
+func parseNumber(text string) (int64, bool) {
+	base, digits := 10, text
+	switch {
+	case strings.HasPrefix(text, "0x") || strings.HasPrefix(text, "0X"):
+		base, digits = 16, text[2:]
+	case strings.HasPrefix(text, "0b") || strings.HasPrefix(text, "0B"):
+		base, digits = 2, text[2:]
+	}
+	if digits == "" || strings.ContainsRune(digits, '_') {
+		return 0, false
+	}
+
+	if base == 10 {
+		value, err := strconv.ParseInt(digits, 10, 64)
+		return value, err == nil
+	}
+	value, err := strconv.ParseUint(digits, base, 64)
+	return int64(value), err == nil
+}
+
+// parseDefined parses `defined(NAME)` or `defined NAME`.
+func (e *expressionEvaluator) parseDefined(keyword expressionToken) int64 {
+	_, parenthesised := e.accept("(")
+
+	name := e.next()
+	if name.kind != expressionIdentifier || !isSymbolName(name.text) {
+		e.fail("defined requires a symbol name", name.offset)
+		return 0
+	}
+	if parenthesised {
+		e.expect(")")
+	}
+
+	return boolValue(e.defined[name.text])
+}
+
+// resolve evaluates the value of the define named by token. A parameterised
+// define consumes its argument list. Errors inside the definition are
+// reported at the identifier, since the definition text has no position in
+// the expression.
+func (e *expressionEvaluator) resolve(token expressionToken) int64 {
+	name := token.text
+	def, ok := e.definitions[name]
+
+	var args []string
+	if ok && def.hasParameters {
+		args = e.parseArguments(token)
+		if e.err != nil {
+			return 0
+		}
+	}
+
+	if e.skip {
+		return 0
+	}
+
+	switch {
+	case !ok && e.defined[name]:
+		e.fail(fmt.Sprintf("symbol '%s' has no value", name), token.offset)
+		return 0
+	case !ok:
+		e.fail(fmt.Sprintf("undefined symbol '%s' in expression", name), token.offset)
+		return 0
+	case e.active[name]:
+		e.fail(fmt.Sprintf("recursive definition of '%s'", name), token.offset)
+		return 0
+	case !def.hasParameters && def.body == "":
+		e.fail(fmt.Sprintf("symbol '%s' has no value", name), token.offset)
+		return 0
+	}
+
+	if def.hasParameters && len(args) != len(def.parameters) {
+		e.fail(fmt.Sprintf("define '%s' expects %d arguments, but got %d", name, len(def.parameters), len(args)), token.offset)
+		return 0
+	}
+
+	body := def.body
+	if def.hasParameters {
+		body = substituteParameters(body, def.parameters, args)
+	}
+
+	e.active[name] = true
+	value, err := evaluateNested(body, e.definitions, e.defined, e.active)
+	delete(e.active, name)
+
+	if err != nil {
+		e.fail(fmt.Sprintf("in expansion of '%s': %s", name, err.message), token.offset)
+		return 0
+	}
+	return value
+}
+
+// parseArguments consumes the parenthesised argument list of a
+// parameterised define and returns the source text of each argument.
+func (e *expressionEvaluator) parseArguments(name expressionToken) []string {
+	if e.peek().text != "(" {
+		e.fail(fmt.Sprintf("define '%s' requires an argument list", name.text), name.offset)
+		return nil
+	}
+	e.next()
+
+	args := make([]string, 0)
+	var current []string
+	depth := 0
+	for {
+		token := e.next()
+		switch {
+		case token.kind == expressionEnd:
+			e.fail(fmt.Sprintf("unterminated argument list for define '%s'", name.text), name.offset)
+			return nil
+		case token.text == "(":
+			depth++
+		case token.text == ")" && depth > 0:
+			depth--
+		case token.text == ")":
+			if len(current) > 0 || len(args) > 0 {
+				args = append(args, strings.Join(current, " "))
+			}
+			return args
+		case token.text == "," && depth == 0:
+			args = append(args, strings.Join(current, " "))
+			current = nil
+			continue
+		}
+		current = append(current, token.text)
+	}
+}
+
+// boolValue converts a truth value to 1 or 0.
+func boolValue(b bool) int64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,220 @@
+package preProcessing_test
+
+import (
+	"testing"
+
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+)
+
+// --- %if expressions: operators ---
+
+func TestHandleConditionals_IfExpression_Operators(t *testing.T) {
+	tests := []struct {
+		expression string
+		expected   bool
+	}{
+		{"1", true},
+		{"0", false},
+		{"0x10 == 16", true},
+		{"0b101 == 5", true},
+		{"0X10 == 16 && 0B11 == 3", true},
+		{"010 == 10", true},
+		{"007 == 7", true},
+		{"2 + 3 * 4 == 14", true},
+		{"(2 + 3) * 4 == 20", true},
+		{"7 / 2 == 3 && 7 % 2 == 1", true},
+		{"-1 < 0", true},
+		{"1 << 4 == 16 && 256 >> 4 == 16", true},
+		{"(6 & 3) == 2 && (6 | 1) == 7 && (6 ^ 3) == 5", true},
+		{"~0 == -1", true},
+		{"!0 && !!5", true},
+		{"1 <> 2 && 1 != 2 && 2 = 2", true},
+		{"3 >= 3 && 3 <= 3 && 4 > 3", true},
+		{"0 || 0", false},
+		{"1 && 0", false},
+		{"1 + 1 == 2 == 1", true},
+	}
+
+	for _, tt := range tests {
+		source := "%if " + tt.expression + "\nyes\n%endif"
+		result := mustHandleConditionals(t, source, nil)
+		if containsSubstring(result, "yes") != tt.expected {
+			t.Errorf("%%if %s: expected %v", tt.expression, tt.expected)
+		}
+	}
+}
+
+// --- %if expressions: defines ---
+
+func TestHandleConditionals_IfExpression_UsesDefineValues(t *testing.T) {
+	source := `%define KERNEL_STACK_SIZE 0x8000
+%if KERNEL_STACK_SIZE > 0x4000
+mov rax, 1
+%endif`
+	result := mustHandleConditionals(t, source, nil)
+
+	if !containsSubstring(result, "mov rax, 1") {
+		t.Error("expected body to be included when KERNEL_STACK_SIZE > 0x4000")
+	}
+}
+
+func TestHandleConditionals_IfExpression_NestedAndParameterisedDefines(t *testing.T) {
+	source := `%define PAGE 4096
+%define PAGES(n) (n * PAGE)
+%define HEAP PAGES(4)
+%if HEAP == 16384
+mov rax, 1
+%endif`
+	result := mustHandleConditionals(t, source, nil)
+
+	if !containsSubstring(result, "mov rax, 1") {
+		t.Error("expected nested define values to be evaluated")
+	}
+}
+
+func TestHandleConditionals_IfExpression_Defined(t *testing.T) {
+	source := `%define DEBUG
+%if defined(DEBUG) && !defined RELEASE
+mov rax, 1
+%endif`
+	result := mustHandleConditionals(t, source, nil)
+
+	if !containsSubstring(result, "mov rax, 1") {
+		t.Error("expected defined() to see DEBUG and not RELEASE")
+	}
+}
+
+func TestHandleConditionals_IfExpression_ShortCircuit(t *testing.T) {
+	source := `%if defined(LEVEL) && LEVEL > 2 || 0 && 1 / 0
+mov rax, 1
+%else
+mov rax, 0
+%endif`
+	result := mustHandleConditionals(t, source, nil)
+
+	if !containsSubstring(result, "mov rax, 0") {
+		t.Error("expected the unevaluated operands to be skipped without errors")
+	}
+}
+
+func TestHandleConditionals_IfExpression_DefineOnlyAfterDirective(t *testing.T) {
+	source := `%if defined(SIZE)
+mov rax, 1
+%endif
+%define SIZE 8
+%if defined(SIZE)
+mov rax, 2
+%endif
+%undef SIZE
+%if defined(SIZE)
+mov rax, 3
+%endif`
+	result := mustHandleConditionals(t, source, nil)
+
+	if containsSubstring(result, "mov rax, 1") || !containsSubstring(result, "mov rax, 2") || containsSubstring(result, "mov rax, 3") {
+		t.Errorf("expected defines to apply in source order, got:\n%s", result)
+	}
+}
+
+func TestHandleConditionals_IfExpression_InactiveDefinesAreIgnored(t *testing.T) {
+	source := `%ifdef NOPE
+%define SIZE 8
+%endif
+%ifndef SIZE
+mov rax, 1
+%endif`
+	result := mustHandleConditionals(t, source, nil)
+
+	if !containsSubstring(result, "mov rax, 1") {
+		t.Errorf("expected a %%define in an inactive branch to have no effect")
+	}
+}
+
+// --- %if expressions: errors ---
+
+func TestHandleConditionals_IfExpression_Errors(t *testing.T) {
+	tests := []struct {
+		line    string
+		message string
+		column  int
+	}{
+		{"%if", "%if requires an expression", 0},
+		{"%if 1 +", "expected a value but found 'end of expression'", 8},
+		{"%if (1", "expected ')' but found 'end of expression'", 7},
+		{"%if 1 2", "unexpected '2' in expression", 7},
+		{"%if 4 / 0", "division by zero", 7},
+		{"%if  1 % 0", "division by zero", 8},
+		{"%if 1 << 64", "shift count 64 out of range", 10},
+		{"%if 1 $ 2", "unexpected character '$' in expression", 7},
+		{"%if UNKNOWN", "undefined symbol 'UNKNOWN' in expression", 5},
+		{"%if defined(1)", "defined requires a symbol name", 13},
+		{"%if 0x1G", "invalid number '0x1G'", 5},
+		{"%if 0o17", "invalid number '0o17'", 5},
+		{"%if 1_000", "invalid number '1_000'", 5},
+		{"%if 0x", "invalid number '0x'", 5},
+		{"%if 0b12", "invalid number '0b12'", 5},
+		{"%ifdef", "%ifdef requires a symbol name", 7},
+	}
+
+	for _, tt := range tests {
+		_, errs := preProcessing.HandleConditionals(tt.line+"\n%endif", nil)
+		e := requireError(t, errs, tt.message)
+		if e.Line != 1 || e.Column != tt.column {
+			t.Errorf("%q: expected error at 1:%d, got %d:%d", tt.line, tt.column, e.Line, e.Column)
+		}
+	}
+}
+
+func TestHandleConditionals_IfExpression_DefineErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+		column  int
+	}{
+		{"%define DEBUG\n%if DEBUG", "symbol 'DEBUG' has no value", 5},
+		{"%define A B\n%define B A\n%if A", "in expansion of 'A': in expansion of 'B': recursive definition of 'A'", 5},
+		{"%define BAD 1 +\n%if 2 * BAD", "in expansion of 'BAD': expected a value", 9},
+		{"%define F(x) x\n%if F(1, 2)", "define 'F' expects 1 arguments, but got 2", 5},
+		{"%define F(x) x\n%if F", "define 'F' requires an argument list", 5},
+	}
+
+	for _, tt := range tests {
+		_, errs := preProcessing.HandleConditionals(tt.source+"\n%endif", nil)
+		e := requireError(t, errs, tt.message)
+		if e.Column != tt.column {
+			t.Errorf("%q: expected error at column %d, got %d", tt.source, tt.column, e.Column)
+		}
+	}
+}
+
+func TestHandleConditionals_IfExpression_ErrorColumnIncludesIndent(t *testing.T) {
+	_, errs := preProcessing.HandleConditionals("  %if 1 / 0 ; comment\n%endif", nil)
+
+	e := requireError(t, errs, "division by zero")
+	if e.Column != 9 {
+		t.Errorf("expected error at column 9, got %d", e.Column)
+	}
+	if e.String() != "1:9: division by zero" {
+		t.Errorf("expected the string form to include the column, got: %s", e.String())
+	}
+}
+
+func TestHandleConditionals_IfExpression_ErrorInInactiveBranchIsIgnored(t *testing.T) {
+	source := `%ifdef NOPE
+%if 1 / 0
+%endif
+%endif`
+	mustHandleConditionals(t, source, nil)
+}
+
+func BenchmarkHandleConditionals_IfExpression(b *testing.B) {
+	source := `%define STACK 0x8000
+%define ARCH 2
+%if STACK > 0x4000 && (ARCH == 1 || ARCH == 2)
+mov rax, 1
+%endif`
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		preProcessing.HandleConditionals(source, nil)
+	}
+}
@@ -0,0 +1,391 @@
+package preProcessing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// HandleFunctions evaluates the pre-processor functions and token pasting
+// operators left in source once every other phase has run (FR-14). The
+// transformation is line-preserving: line i of the result is produced from
+// line i of source.
+//
+//	%strlen("kernel")          ; → 6
+//	%substr("kernel", 1, 3)    ; → "ker"
+//	%strcat("isr_", "entry")   ; → "isr_entry"
+//	%str(mov rax, 1)           ; → "mov rax, 1"
+//	isr_ %+ 14                 ; → isr_14
+//
+// Running last means that functions and pasting operators in %define bodies
+// and macro bodies are evaluated after the define values and macro arguments
+// have been substituted, so `%str(%1)` stringifies the argument of a call.
+// Functions in constant expressions (%if, %assign, %rep) are evaluated by the
+// expression evaluator instead, when the expression is.
+//
+// A malformed call is reported as an error and left unexpanded.
+func HandleFunctions(source string) (string, []PreProcessingError) {
+	errors := make([]PreProcessingError, 0)
+
+	// Early-exit: no function call or pasting operator (AR-8.1).
+	if !strings.Contains(source, "%") {
+		return source, errors
+	}
+
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		if !strings.Contains(line, "%") {
+			continue
+		}
+		expander := &functionExpander{active: make(map[string]bool)}
+		lines[i] = expander.expand(line)
+		for _, err := range expander.errors {
+			errors = append(errors, PreProcessingError{Message: err.message, Line: i + 1, Column: err.offset + 1})
+		}
+	}
+
+	return strings.Join(lines, "\n"), errors
+}
+
+// functionError is an error in a function call or pasting operator, at the
+// 0-based offset of the offending token in the expanded text.
+type functionError struct {
+	message string
+	offset  int
+}
+
+// functionExpander evaluates the functions and pasting operators of a text.
+// Symbol arguments are resolved through definitions, which is empty outside
+// constant expressions; active holds the definitions currently being
+// evaluated, like the expression evaluator's, so that a definition that
+// refers to itself through a function argument is reported rather than
+// expanded forever.
+type functionExpander struct {
+	definitions map[string]definition
+	defined     map[string]bool
+	active      map[string]bool
+	errors      []functionError
+}
+
+// preProcessorFunctions lists the functions a '%name(' token may call.
+var preProcessorFunctions = map[string]bool{
+	"strlen": true,
+	"substr": true,
+	"strcat": true,
+	"str":    true,
+}
+
+// expand returns text with every function call replaced by its result and
+// every pasting operator applied. Comments are copied verbatim.
+func (e *functionExpander) expand(text string) string {
+	var sb strings.Builder
+	sb.Grow(len(text))
+
+	pasted := false
+	for offset := 0; offset < len(text); {
+		t := nextToken(text, offset)
+		offset = t.end()
+
+		if t.kind == tokenPaste {
+			pasted = true
+		}
+		if t.kind != tokenDirective || !preProcessorFunctions[t.text[1:]] || offset >= len(text) || text[offset] != '(' {
+			sb.WriteString(t.text)
+			continue
+		}
+
+		args, end := functionArguments(text, offset)
+		if end < 0 {
+			e.fail(fmt.Sprintf("unterminated argument list for %s", t.text), t.offset)
+			sb.WriteString(text[t.offset:])
+			break
+		}
+		result, ok := e.call(t, text[offset+1:end-1], args)
+		if !ok {
+			result = text[t.offset:end]
+		}
+		sb.WriteString(result)
+		offset = end
+	}
+
+	if !pasted {
+		return sb.String()
+	}
+	return e.paste(sb.String())
+}
+
+// fail records an error at offset.
+func (e *functionExpander) fail(message string, offset int) {
+	e.errors = append(e.errors, functionError{message: message, offset: offset})
+}
+
+// functionArgument is a single argument of a function call and the offset of
+// its text in the line.
+type functionArgument struct {
+	text   string
+	offset int
+}
+
+// functionArguments splits the argument list of a function call whose '(' is
+// at offset open. It returns the arguments and the offset just past the
+// closing ')', or -1 if the list is not closed on the line. Commas inside
+// nested parentheses, brackets and string literals do not split arguments.
+func functionArguments(text string, open int) ([]functionArgument, int) {
+	args := make([]functionArgument, 0, 3)
+	depth := 0
+	start := open + 1
+	for offset := open + 1; offset < len(text); {
+		t := nextToken(text, offset)
+		offset = t.end()
+		if t.kind != tokenPunct {
+			if t.kind == tokenComment {
+				break
+			}
+			continue
+		}
+		switch t.text {
+		case "(", "[":
+			depth++
+		case "]":
+			depth--
+		case ")":
+			if depth > 0 {
+				depth--
+				continue
+			}
+			args = append(args, functionArgument{text: text[start:t.offset], offset: start})
+			// An empty list is a call without arguments.
+			if len(args) == 1 && strings.TrimSpace(args[0].text) == "" {
+				args = args[:0]
+			}
+			return args, offset
+		case ",":
+			if depth == 0 {
+				args = append(args, functionArgument{text: text[start:t.offset], offset: start})
+				start = offset
+			}
+		}
+	}
+	return nil, -1
+}
+
+// call evaluates the call of the function named by fn. raw is the text
+// between the parentheses. It returns false if the call is malformed; the
+// error has been recorded.
+func (e *functionExpander) call(fn token, raw string, args []functionArgument) (string, bool) {
+	name := fn.text[1:]
+
+	// %str stringifies its argument text as written, commas included.
+	if name == "str" {
+		text := strings.TrimSpace(raw)
+		if tokens := operandTokens(text); len(tokens) == 1 && tokens[0].kind == tokenString {
+			return text, true
+		}
+		return e.quote(fn, text)
+	}
+
+	// The other functions take expanded arguments, so calls nest.
+	for i, arg := range args {
+		nested := &functionExpander{definitions: e.definitions, defined: e.defined, active: e.active}
+		args[i].text = strings.TrimSpace(nested.expand(arg.text))
+		for _, err := range nested.errors {
+			e.fail(err.message, arg.offset+err.offset)
+		}
+		if len(nested.errors) > 0 {
+			return "", false
+		}
+	}
+
+	switch name {
+	case "strlen":
+		if !e.arity(fn, args, 1, 1) {
+			return "", false
+		}
+		value, ok := e.stringArgument(fn, args[0])
+		if !ok {
+			return "", false
+		}
+		return strconv.Itoa(len(value)), true
+
+	case "substr":
+		if !e.arity(fn, args, 2, 3) {
+			return "", false
+		}
+		value, ok := e.stringArgument(fn, args[0])
+		if !ok {
+			return "", false
+		}
+		start, ok := e.integerArgument(fn, args[1])
+		if !ok {
+			return "", false
+		}
+		if start < 1 {
+			e.fail(fmt.Sprintf("%s start must be at least 1, got %d", fn.text, start), fn.offset)
+			return "", false
+		}
+		length := int64(len(value))
+		if len(args) == 3 {
+			if length, ok = e.integerArgument(fn, args[2]); !ok {
+				return "", false
+			}
+			if length < 0 {
+				e.fail(fmt.Sprintf("%s length must not be negative, got %d", fn.text, length), fn.offset)
+				return "", false
+			}
+		}
+		// Positions past the end of the string select nothing.
+		from := min(start-1, int64(len(value)))
+		to := min(from+length, int64(len(value)))
+		return e.quote(fn, value[from:to])
+
+	case "strcat":
+		if !e.arity(fn, args, 1, -1) {
+			return "", false
+		}
+		var sb strings.Builder
+		for _, arg := range args {
+			value, ok := e.stringArgument(fn, arg)
+			if !ok {
+				return "", false
+			}
+			sb.WriteString(value)
+		}
+		return e.quote(fn, sb.String())
+	}
+	return "", false
+}
+
+// arity checks that a call has between least and most arguments; most is -1
+// for no upper bound.
+func (e *functionExpander) arity(fn token, args []functionArgument, least, most int) bool {
+	if len(args) >= least && (most < 0 || len(args) <= most) {
+		return true
+	}
+	var expected string
+	switch {
+	case most < 0:
+		expected = fmt.Sprintf("at least %d", least)
+	case least == most:
+		expected = strconv.Itoa(least)
+	default:
+		expected = fmt.Sprintf("%d or %d", least, most)
+	}
+	e.fail(fmt.Sprintf("%s expects %s argument(s), but got %d", fn.text, expected, len(args)), fn.offset)
+	return false
+}
+
+// stringArgument returns the contents of a string literal argument. A symbol
+// whose definition is a string literal, directly or through other symbols,
+// stands for that literal.
+func (e *functionExpander) stringArgument(fn token, arg functionArgument) (string, bool) {
+	text := arg.text
+	resolved := make([]string, 0)
+	defer func() {
+		for _, name := range resolved {
+			delete(e.active, name)
+		}
+	}()
+
+	for {
+		tokens := operandTokens(text)
+		if len(tokens) == 1 && tokens[0].kind == tokenString {
+			literal := tokens[0].text
+			if len(literal) < 2 || literal[len(literal)-1] != literal[0] {
+				e.fail(fmt.Sprintf("unterminated string in %s", fn.text), arg.offset)
+				return "", false
+			}
+			return literal[1 : len(literal)-1], true
+		}
+		if len(tokens) != 1 || tokens[0].kind != tokenWord {
+			break
+		}
+		name := tokens[0].text
+		def, ok := e.definitions[name]
+		if !ok || def.hasParameters || e.active[name] {
+			break
+		}
+		e.active[name] = true
+		resolved = append(resolved, name)
+		text = def.body
+	}
+
+	e.fail(fmt.Sprintf("%s expects a string, got '%s'", fn.text, arg.text), arg.offset)
+	return "", false
+}
+
+// integerArgument evaluates a constant expression argument (FR-4.7).
+func (e *functionExpander) integerArgument(fn token, arg functionArgument) (int64, bool) {
+	value, err := evaluateNested(arg.text, e.definitions, e.defined, e.active)
+	if err != nil {
+		e.fail(fmt.Sprintf("invalid %s argument '%s': %s", fn.text, arg.text, err.message), arg.offset)
+		return 0, false
+	}
+	return value, true
+}
+
+// quote returns value as a string literal. The kasm lexer has no escape
+// sequences, so a value containing a double quote cannot be quoted.
+func (e *functionExpander) quote(fn token, value string) (string, bool) {
+	if strings.Contains(value, `"`) {
+		e.fail(fmt.Sprintf("%s result contains '\"' and cannot be quoted", fn.text), fn.offset)
+		return "", false
+	}
+	return quoteString(value), true
+}
+
+// paste joins the tokens on either side of every pasting operator in text,
+// dropping the operator and the whitespace around it: `isr_ %+ 14` becomes
+// `isr_14`. An operator without a token on each side is reported and
+// dropped.
+func (e *functionExpander) paste(text string) string {
+	tokens := tokenizeLine(text)
+
+	var sb strings.Builder
+	sb.Grow(len(text))
+
+	left := false // A token that can be pasted to precedes the current one.
+	for i, t := range tokens {
+		switch t.kind {
+		case tokenSpace:
+			if next := nextSignificant(tokens, i); next >= 0 && tokens[next].kind == tokenPaste {
+				continue
+			}
+			if previous := previousSignificant(tokens, i); previous >= 0 && tokens[previous].kind == tokenPaste {
+				continue
+			}
+			left = false
+			sb.WriteString(t.text)
+		case tokenPaste:
+			next := nextSignificant(tokens, i)
+			if !left || next < 0 || tokens[next].kind == tokenComment || tokens[next].kind == tokenPaste {
+				e.fail("'%+' requires a token on each side", t.offset)
+			}
+		default:
+			left = t.kind != tokenComment
+			sb.WriteString(t.text)
+		}
+	}
+	return sb.String()
+}
+
+// nextSignificant returns the index of the first token after i that is not
+// whitespace, or -1.
+func nextSignificant(tokens []token, i int) int {
+	for j := i + 1; j < len(tokens); j++ {
+		if tokens[j].kind != tokenSpace {
+			return j
+		}
+	}
+	return -1
+}
+
+// previousSignificant returns the index of the last token before i that is
+// not whitespace, or -1.
+func previousSignificant(tokens []token, i int) int {
+	for j := i - 1; j >= 0; j-- {
+		if tokens[j].kind != tokenSpace {
+			return j
+		}
+	}
+	return -1
+}
@@ -0,0 +1,228 @@
+package preProcessing_test
+
+import (
+	"testing"
+
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+)
+
+// --- FR-14.1: Functions ---
+
+func TestHandleFunctions_Functions(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{`%strlen("kernel")`, `6`},
+		{`%strlen('')`, `0`},
+		{`%substr("kernel", 1, 3)`, `"ker"`},
+		{`%substr("kernel", 4)`, `"nel"`},
+		{`%substr("kernel", 5, 10)`, `"el"`},
+		{`%substr("kernel", 9, 2)`, `""`},
+		{`%substr("kernel", 1 + 1, 2 * 2)`, `"erne"`},
+		{`%strcat("isr_", "entry")`, `"isr_entry"`},
+		{`%strcat('a', "b", "c")`, `"abc"`},
+		{`%str(mov rax, 1)`, `"mov rax, 1"`},
+		{`%str( spaced )`, `"spaced"`},
+		{`%str("as is")`, `"as is"`},
+		{`%strlen(%substr("kernel", 1, 5))`, `5`},
+		{`%strcat(%str(a, b), "!")`, `"a, b!"`},
+		{`    db %strlen("abc"), 0 ; %strlen("kept")`, `    db 3, 0 ; %strlen("kept")`},
+		{`db "%strlen(x)"`, `db "%strlen(x)"`},
+		{`%macro(1)`, `%macro(1)`},
+	}
+
+	for _, tt := range tests {
+		result := mustHandleFunctions(t, tt.source)
+		if result != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.source, tt.expected, result)
+		}
+	}
+}
+
+func TestHandleFunctions_PreservesLines(t *testing.T) {
+	source := "start:\n    mov rax, %strlen(\"abc\")\n\n    ret"
+
+	result := mustHandleFunctions(t, source)
+
+	if result != "start:\n    mov rax, 3\n\n    ret" {
+		t.Errorf("expected one output line per input line, got:\n%s", result)
+	}
+}
+
+// --- FR-14.2: Token pasting ---
+
+func TestHandleFunctions_Paste(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{`isr_ %+ 14:`, `isr_14:`},
+		{`isr_%+14:`, `isr_14:`},
+		{`a %+ b %+ c`, `abc`},
+		{`jmp isr_ %+ 14 ; %+ in a comment`, `jmp isr_14 ; %+ in a comment`},
+		{`db "%+"`, `db "%+"`},
+		{`len_ %+ %strlen("abc")`, `len_3`},
+	}
+
+	for _, tt := range tests {
+		result := mustHandleFunctions(t, tt.source)
+		if result != tt.expected {
+			t.Errorf("%s: expected %s, got %s", tt.source, tt.expected, result)
+		}
+	}
+}
+
+func TestHandleFunctions_PasteDefineAndMacroArguments(t *testing.T) {
+	defined, errs := preProcessing.HandleDefines("%define HANDLER(n) isr_ %+ n\nHANDLER(14):")
+	if len(errs) != 0 {
+		t.Fatalf("unexpected pre-processing errors: %v", errs)
+	}
+
+	result := mustHandleFunctions(t, defined)
+
+	if !containsSubstring(result, "isr_14:") {
+		t.Errorf("expected the define argument to be pasted, got:\n%s", result)
+	}
+}
+
+// --- FR-14.3: Errors ---
+
+func TestHandleFunctions_Errors(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+		column  int
+	}{
+		{`%strlen()`, "%strlen expects 1 argument(s), but got 0", 1},
+		{`%strlen("a", "b")`, "%strlen expects 1 argument(s), but got 2", 1},
+		{`%substr("a")`, "%substr expects 2 or 3 argument(s), but got 1", 1},
+		{`%strcat()`, "%strcat expects at least 1 argument(s), but got 0", 1},
+		{`mov rax, %strlen(rbx)`, "%strlen expects a string, got 'rbx'", 18},
+		{`%substr("abc", 0)`, "%substr start must be at least 1, got 0", 1},
+		{`%substr("abc", 1, -1)`, "%substr length must not be negative, got -1", 1},
+		{`%substr("abc", x)`, "invalid %substr argument 'x'", 15},
+		{`db %strlen("abc"`, "unterminated argument list for %strlen", 4},
+		{`%str(say "hi")`, "%str result contains '\"' and cannot be quoted", 1},
+		{`%+ a`, "'%+' requires a token on each side", 1},
+		{`a %+`, "'%+' requires a token on each side", 3},
+		{`a %+ ; comment`, "'%+' requires a token on each side", 3},
+	}
+
+	for _, tt := range tests {
+		_, errs := preProcessing.HandleFunctions(tt.source)
+		e := requireError(t, errs, tt.message)
+		if e.Line != 1 || e.Column != tt.column {
+			t.Errorf("%q: expected error at 1:%d, got %d:%d", tt.source, tt.column, e.Line, e.Column)
+		}
+	}
+}
+
+func TestHandleFunctions_MalformedCallIsLeftUnexpanded(t *testing.T) {
+	result, errs := preProcessing.HandleFunctions("\n    db %strlen(1, 2), %strlen(\"ab\")")
+
+	e := requireError(t, errs, "%strlen expects 1 argument(s), but got 2")
+	if e.Line != 2 || e.Column != 8 {
+		t.Errorf("expected error at 2:8, got %d:%d", e.Line, e.Column)
+	}
+	if result != "\n    db %strlen(1, 2), 2" {
+		t.Errorf("expected the malformed call to be left unexpanded, got:\n%s", result)
+	}
+}
+
+// --- FR-14.5: Constant expressions ---
+
+func TestHandleConditionals_IfExpression_Functions(t *testing.T) {
+	source := `%define GREETING "hello, world"
+%if %strlen(GREETING) > 8 && %strlen(%substr(GREETING, 1, 5)) == 5
+long
+%endif`
+	result := mustHandleConditionals(t, source, nil)
+
+	if !containsSubstring(result, "long") {
+		t.Errorf("expected the function calls to be evaluated, got:\n%s", result)
+	}
+}
+
+func TestHandleConditionals_IfExpression_FunctionErrors(t *testing.T) {
+	_, errs := preProcessing.HandleConditionals("%define N 1\n%if %strlen(N) > 0\n%endif", nil)
+
+	e := requireError(t, errs, "%strlen expects a string, got 'N'")
+	if e.Line != 2 {
+		t.Errorf("expected the error on line 2, got %d", e.Line)
+	}
+}
+
+func TestHandleRepetitions_AssignFunction(t *testing.T) {
+	source := `%define NAME "kernel"
+%assign n %strlen(NAME)
+db n`
+	result, _ := mustHandleRepetitions(t, source, nil)
+
+	if !containsSubstring(result, "db 6") {
+		t.Errorf("expected the assigned length to be substituted, got:\n%s", result)
+	}
+}
+
+// --- FR-14.6: Directive forms ---
+
+func TestHandleRepetitions_StringFunctionDirectives(t *testing.T) {
+	source := `%define NAME "kernel"
+%strlen len NAME
+%substr first NAME 1,3
+%substr c 'abc' 2
+%strlen n "abc"
+%strlen n "abcd" ; again
+%strcat C "ab", 'cd', NAME
+dd len, n
+%strlen(NAME)
+%strcat("x", "y")`
+	result, _ := mustHandleRepetitions(t, source, nil)
+
+	expected := "%define NAME \"kernel\"\n%define len 6\n%define first \"ker\"\n%define c \"b\"\n%define n 3\n%undef n\n%define n 4\n%define C \"abcdkernel\"\ndd len, n\n%strlen(NAME)\n%strcat(\"x\", \"y\")"
+	if result != expected {
+		t.Errorf("expected the directives to be replaced with %%define, got:\n%s", result)
+	}
+}
+
+func TestHandleRepetitions_StringFunctionDirectiveErrors(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+		column  int
+	}{
+		{"%strlen", "%strlen requires a symbol name", 8},
+		{"%strlen L", "%strlen requires a string for 'L'", 0},
+		{"%strlen L 42", "%strlen expects a string, got '42'", 11},
+		{`%strlen L "a" 1`, "%strlen expects 1 argument(s), but got 2", 1},
+		{`%substr V "abc"`, "%substr expects 2 or 3 argument(s), but got 1", 1},
+		{`%substr V "abc" 0`, "%substr start must be at least 1, got 0", 1},
+		{`%substr V "abc" 1, x`, "invalid %substr argument 'x'", 20},
+		{"%strcat S", "%strcat requires a string for 'S'", 0},
+		{`%strcat S "a", 1`, "%strcat expects a string, got '1'", 16},
+	}
+
+	for _, tt := range tests {
+		result, _, errs := preProcessing.HandleRepetitions(tt.source, nil)
+		e := requireError(t, errs, tt.message)
+		if e.Line != 1 || e.Column != tt.column {
+			t.Errorf("%q: expected error at 1:%d, got %d:%d", tt.source, tt.column, e.Line, e.Column)
+		}
+		if containsSubstring(result, "%define") {
+			t.Errorf("%q: expected no definition, got %q", tt.source, result)
+		}
+		if e.Phase != preProcessing.PhaseStringDirectives {
+			t.Errorf("%q: expected the error in phase %q, got %q", tt.source, preProcessing.PhaseStringDirectives, e.Phase)
+		}
+	}
+}
+
+// mustHandleFunctions evaluates functions and fails the test on pre-processing errors.
+func mustHandleFunctions(t *testing.T, source string) string {
+	t.Helper()
+	result, errs := preProcessing.HandleFunctions(source)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected pre-processing errors: %v", errs)
+	}
+	return result
+}
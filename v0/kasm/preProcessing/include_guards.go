@@ -0,0 +1,70 @@
+package preProcessing
+
+import (
+	"strings"
+)
+
+// IncludeOnce returns true if the content of an included file protects itself
+// against repeated inclusion (FR-1.10.1), either with a %pragma once line or
+// with a classic include guard wrapping the whole file:
+//
+//	%ifndef IO_KASM
+//	%define IO_KASM
+//	…
+//	%endif
+//
+// Blank lines and comments are ignored, so the guard may be preceded by a
+// file header comment.
+func IncludeOnce(content string) bool {
+	lines := significantLines(content)
+	for _, fields := range lines {
+		if isPragmaOnce(fields) {
+			return true
+		}
+	}
+	return hasIncludeGuard(lines)
+}
+
+// isPragmaOnce returns true if fields are the fields of a %pragma once line.
+func isPragmaOnce(fields []string) bool {
+	return len(fields) == 2 && fields[0] == "%pragma" && fields[1] == "once"
+}
+
+// hasIncludeGuard returns true if lines — the fields of the significant lines
+// of a file — open with %ifndef NAME and %define NAME, and the block opened by
+// the %ifndef is closed by the last line.
+func hasIncludeGuard(lines [][]string) bool {
+	if len(lines) < 3 {
+		return false
+	}
+	first, second := lines[0], lines[1]
+	if len(first) != 2 || first[0] != "%ifndef" || len(second) < 2 || second[0] != "%define" || second[1] != first[1] {
+		return false
+	}
+
+	depth := 0
+	for index, fields := range lines {
+		switch fields[0] {
+		case "%if", "%ifdef", "%ifndef":
+			depth++
+		case "%endif":
+			depth--
+			if depth == 0 {
+				return index == len(lines)-1
+			}
+		}
+	}
+	return false
+}
+
+// significantLines returns the whitespace-separated fields of each line of
+// content that is not blank once its comment is removed.
+func significantLines(content string) [][]string {
+	lines := make([][]string, 0)
+	for _, line := range strings.Split(content, "\n") {
+		if fields := strings.Fields(stripComment(line)); len(fields) > 0 {
+			lines = append(lines, fields)
+		}
+	}
+	return lines
+}
@@ -0,0 +1,54 @@
+package preProcessing
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// IncludePathEnvironment is the environment variable holding additional
+// include directories, separated by the platform's list separator (FR-1.8.3).
+const IncludePathEnvironment = "KASM_INCLUDE"
+
+// IncludeSearchPath returns the ordered include search path: the given
+// directories (e.g. from -I flags) followed by the directories listed in
+// KASM_INCLUDE. Empty entries are skipped.
+func IncludeSearchPath(directories []string) []string {
+	searchPath := make([]string, 0, len(directories))
+	for _, directory := range append(directories, filepath.SplitList(os.Getenv(IncludePathEnvironment))...) {
+		if directory != "" {
+			searchPath = append(searchPath, directory)
+		}
+	}
+	return searchPath
+}
+
+// IncludeCandidates returns, in lookup order, the paths an %include of path
+// may refer to (FR-1.8.1). An absolute path is its only candidate. A relative
+// path is looked up in includingDir — the directory of the file containing
+// the directive — and then in each directory of searchPath. An empty
+// includingDir leaves the path relative to the process working directory.
+func IncludeCandidates(path, includingDir string, searchPath []string) []string {
+	if filepath.IsAbs(path) {
+		return []string{filepath.Clean(path)}
+	}
+
+	candidates := make([]string, 0, len(searchPath)+1)
+	candidates = append(candidates, filepath.Join(includingDir, path))
+	for _, directory := range searchPath {
+		candidates = append(candidates, filepath.Join(directory, path))
+	}
+	return candidates
+}
+
+// ResolveInclude returns the first candidate of IncludeCandidates that exists
+// and is not a directory (FR-1.8.2). If none does, the first candidate is
+// returned, so that reading it reports a meaningful error.
+func ResolveInclude(path, includingDir string, searchPath []string) string {
+	candidates := IncludeCandidates(path, includingDir, searchPath)
+	for _, candidate := range candidates {
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() {
+			return candidate
+		}
+	}
+	return candidates[0]
+}
@@ -3,146 +3,250 @@ package preProcessing
 import (
 	"fmt"
 	"os"
-	"regexp"
+	"path/filepath"
+	"slices"
 	"strings"
 )
 
-// Pre-compiled regex for %include directives (AR-6.3).
-var includeDirectiveRegex = regexp.MustCompile(`(?m)^\s*%include\s+"([^"]+)"\s*$`)
-
 // HandleIncludes processes %include directives in the source code,
-// replacing each with the content of the referenced file. It returns the
-// updated source code and a list of inclusions for error reporting and debugging.
+// replacing each with the content of the referenced file. Relative paths are
+// resolved against the process working directory for the top-level source.
+// It is HandleIncludesWithOptions with no options.
+func HandleIncludes(source string, alreadyIncluded map[string]bool) (string, []Inclusion, []PreProcessingError) {
+	return HandleIncludesWithOptions(source, IncludeOptions{}, alreadyIncluded)
+}
+
+// IncludeOptions configures HandleIncludesWithOptions.
+type IncludeOptions struct {
+	// FilePath is the file the source was read from, or empty if it was not
+	// read from a file.
+	FilePath string
+	// SearchPath lists the directories searched, in order, after the
+	// directory of the including file (FR-1.8).
+	SearchPath []string
+	// DefinedSymbols seeds the symbols defined at the start of the source,
+	// as for HandleConditionals (FR-1.9).
+	DefinedSymbols map[string]bool
+	// CommandLine holds the symbols defined and undefined on the command
+	// line (FR-10.2).
+	CommandLine *CommandLineSymbols
+}
+
+// HandleIncludesWithOptions processes %include directives in the source
+// code, replacing each with the content of the referenced file. It returns the
+// updated source code and a list of inclusions for error reporting and
+// debugging.
+//
+// Each path is resolved with ResolveInclude (FR-1.8): relative to the file
+// containing the directive, then through options.SearchPath in order. The
+// containing file of a directive in previously inlined content is taken from
+// the enclosing ; FILE: marker, otherwise it is options.FilePath. Inclusions
+// and markers carry the resolved path.
+//
+// Only directives in active conditional branches are followed (FR-1.9): the
+// source is fed to a ConditionalScanner seeded with options.DefinedSymbols
+// and options.CommandLine, and %include and %incbin directives on inactive
+// lines are left untouched for HandleConditionals to remove.
 //
 // Only .kasm files may be included; any other file extension is a pre-processing error.
+// Directives that cannot be inlined (wrong extension, unreadable file) are
+// reported as errors, stripped from the source and left out of the returned
+// inclusions, so that the caller can keep resolving the remaining includes.
 //
-// The alreadyIncluded set contains file paths that have been inlined by a
-// previous invocation (FR-1.7: Shared Dependency Deduplication). When a
-// %include directive references a path in this set, the directive line is
-// silently removed without reading or inlining the file content again. Pass
+// The alreadyIncluded set contains resolved file paths that have been inlined
+// by a previous invocation (FR-1.7: Shared Dependency Deduplication). When a
+// %include directive resolves to a guarded file (IncludeOnce) in this set,
+// or to one inlined earlier in the same invocation, the directive line is
+// silently removed without inlining the file content again. An unguarded
+// file is inlined at every directive, as NASM does, except at a directive
+// inside the file itself, which is reported as a circular inclusion. Pass
 // nil if no deduplication is needed.
 //
-// The function works in three passes:
-//  1. Collect all %include directives and their line numbers into the inclusions slice.
-//     Directives targeting paths in alreadyIncluded are collected separately for removal.
-//     Panics if a non-.kasm file is referenced.
-//  2. Deduplicate %include directives within this invocation (FR-1.7). The first
-//     occurrence of each path is kept; subsequent duplicates are silently stripped.
-//  3. Replace each new %include directive with the content of the referenced file,
-//     wrapped in ; FILE: and ; END FILE: comments for traceability. Only the first
-//     match is replaced. After inlining, any remaining %include directives for
-//     shared or duplicate paths are stripped from the source.
-func HandleIncludes(source string, alreadyIncluded map[string]bool) (string, []Inclusion) {
+// Each inlined file is wrapped in ; FILE: and ; END FILE: comments for
+// traceability. %pragma once lines are blanked (FR-1.10).
+//
+// The file path of each %incbin directive is resolved in the same way and
+// replaced by the resolved path, so that the binary is found regardless of
+// where the directive ends up after inlining (FR-1.8.4).
+func HandleIncludesWithOptions(source string, options IncludeOptions, alreadyIncluded map[string]bool) (string, []Inclusion, []PreProcessingError) {
 	// Early-exit: if the source is empty, skip all processing (AR-8.1).
 	if len(source) == 0 {
-		return source, nil
+		return source, nil, nil
 	}
 
-	// Early-exit: if the source does not contain %include, skip all processing (AR-8.2).
-	if !strings.Contains(source, "%include") {
-		return source, nil
+	// Early-exit: if the source does not contain %include, %incbin or
+	// %pragma, skip all processing (AR-8.2).
+	if !strings.Contains(source, "%include") && !strings.Contains(source, "%incbin") && !strings.Contains(source, "%pragma") {
+		return source, nil, nil
 	}
 
-	matches := includeDirectiveRegex.FindAllStringSubmatchIndex(source, -1)
+	errors := make([]PreProcessingError, 0)
+	inclusions := make([]Inclusion, 0)
+	seen := make(map[string]bool)
+
+	// files is the stack of files enclosing the current line, from the
+	// ; FILE: / ; END FILE: markers of previous invocations.
+	files := []string{options.FilePath}
+	scanner := ConditionalScannerNew(options.DefinedSymbols).WithCommandLine(options.CommandLine)
+	deferred := false
+
+	lines := strings.Split(source, "\n")
+	output := make([]string, 0, len(lines))
+	for index, line := range lines {
+		// FR-1.9.2: Once a file with nested includes has been inlined, the
+		// conditions that follow it depend on files not yet read, so the
+		// remaining lines are left for the next invocation.
+		if deferred {
+			output = append(output, line)
+			continue
+		}
 
-	// Pre-allocate with known capacity to avoid repeated slice growth
-	inclusions := make([]Inclusion, 0, len(matches))
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case strings.HasPrefix(trimmed, "; FILE: "):
+			files = append(files, strings.TrimPrefix(trimmed, "; FILE: "))
+		case strings.HasPrefix(trimmed, "; END FILE: ") && len(files) > 1:
+			files = files[:len(files)-1]
+		}
 
-	// FR-1.7: Collect paths that should be silently stripped (shared dependencies).
-	var sharedPaths []string
+		// FR-1.10.2: %pragma once has served its purpose once the file is
+		// inlined; the line is blanked.
+		if isPragmaOnce(strings.Fields(stripComment(line))) {
+			output = append(output, "")
+			continue
+		}
 
-	// Pass 1: collect all %include directives before modifying the source,
-	// so that line numbers remain accurate.
-	// Only .kasm files are accepted; including any other file type is a pre-processing error.
-	for _, matchIdx := range matches {
-		if len(matchIdx) < 4 {
+		// FR-1.9.1: Directives in inactive branches are not followed.
+		if !scanner.Active(line) {
+			output = append(output, line)
 			continue
 		}
 
-		matchStart := matchIdx[0]
-		lineNumber := strings.Count(source[:matchStart], "\n") + 1
+		// FR-1.8.4: Binaries are not inlined; only their path is resolved.
+		if start, end, ok := incbinPath(line); ok {
+			output = append(output, line[:start]+ResolveInclude(line[start:end], includingDirectory(files), options.SearchPath)+line[end:])
+			continue
+		}
 
-		includedFilePath := source[matchIdx[2]:matchIdx[3]]
+		includedFilePath, ok := includePath(line)
+		if !ok {
+			output = append(output, line)
+			continue
+		}
+		lineNumber := index + 1
 
 		// Validate that the included file has the .kasm extension
 		if !strings.HasSuffix(includedFilePath, ".kasm") {
-			message := fmt.Sprintf("pre-processing error: Included file '%s' at line %d must have a .kasm extension",
-				includedFilePath, lineNumber)
-			panic(message)
+			errors = append(errors, PreProcessingError{
+				Message: fmt.Sprintf("included file '%s' must have a .kasm extension", includedFilePath),
+				Line:    lineNumber,
+			})
+			continue
 		}
 
-		// FR-1.7.1 / FR-1.7.4: If the file has already been inlined by a
-		// previous invocation, record it for silent removal — do not add it
-		// to the inclusions list.
-		if alreadyIncluded != nil && alreadyIncluded[includedFilePath] {
-			sharedPaths = append(sharedPaths, includedFilePath)
+		resolvedPath := ResolveInclude(includedFilePath, includingDirectory(files), options.SearchPath)
+
+		includedContentBytes, err := os.ReadFile(resolvedPath)
+		if err != nil {
+			errors = append(errors, PreProcessingError{
+				Message: fmt.Sprintf("failed to read included file '%s': %v", resolvedPath, err),
+				Line:    lineNumber,
+			})
 			continue
 		}
 
+		// FR-1.7.1 / FR-1.10.2: A guarded file inlined by a previous
+		// invocation, or earlier in this one, is silently stripped. An
+		// unguarded file is inlined every time it is included.
+		guarded := IncludeOnce(string(includedContentBytes))
+		if guarded && (alreadyIncluded[resolvedPath] || seen[resolvedPath]) {
+			continue
+		}
+
+		// FR-1.6.1: An unguarded file included from within itself would be
+		// inlined forever.
+		if slices.Contains(files, resolvedPath) {
+			errors = append(errors, PreProcessingError{
+				Message: fmt.Sprintf("circular inclusion of '%s'", resolvedPath),
+				Line:    lineNumber,
+			})
+			continue
+		}
+		seen[resolvedPath] = true
+
 		inclusions = append(inclusions, Inclusion{
-			IncludedFilePath: includedFilePath,
+			IncludedFilePath: resolvedPath,
 			LineNumber:       lineNumber,
 		})
-	}
 
-	// Pass 2: deduplicate %include directives within this invocation.
-	// FR-1.7: A shared dependency may appear in multiple included files. The
-	// first occurrence is kept; subsequent duplicates are silently stripped.
-	seen := make(map[string]bool, len(inclusions))
-	deduplicated := make([]Inclusion, 0, len(inclusions))
-	for _, inclusion := range inclusions {
-		if seen[inclusion.IncludedFilePath] {
-			// FR-1.7.3: Duplicate within this invocation — will be stripped after inlining.
+		// Wrap the file content with file boundary comments for traceability.
+		includedContent := strings.TrimSpace(string(includedContentBytes))
+		output = append(output,
+			"; FILE: "+resolvedPath,
+			includedContent,
+			"; END FILE: "+resolvedPath,
+			"",
+		)
+
+		// The inlined lines precede the rest of the source, so their
+		// definitions apply to the conditionals that follow.
+		if strings.Contains(includedContent, "%include") {
+			deferred = true
 			continue
 		}
-		seen[inclusion.IncludedFilePath] = true
-		deduplicated = append(deduplicated, inclusion)
-	}
-	inclusions = deduplicated
-
-	// Pass 3: replace each %include directive with the file content,
-	// surrounded by ; FILE: / ; END FILE: comments.
-	// Process in reverse source order so that replacements at later positions
-	// do not shift earlier positions, and each directive is replaced at its
-	// original location rather than at a match injected by a previous inline.
-	for idx := len(inclusions) - 1; idx >= 0; idx-- {
-		inclusion := inclusions[idx]
-		includedContentBytes, err := os.ReadFile(inclusion.IncludedFilePath)
-		if err != nil {
-			message := fmt.Sprintf("pre-processing error: Failed to read included file '%s' at line %d: %v",
-				inclusion.IncludedFilePath, inclusion.LineNumber, err)
-			panic(message)
+		for _, includedLine := range strings.Split(includedContent, "\n") {
+			scanner.Active(includedLine)
 		}
+	}
 
-		// Wrap the file content with file boundary comments for traceability
-		includedContent := fmt.Sprintf("; FILE: %s\n%s\n; END FILE: %s\n",
-			inclusion.IncludedFilePath,
-			strings.TrimSpace(string(includedContentBytes)),
-			inclusion.IncludedFilePath,
-		)
+	return strings.Join(output, "\n"), inclusions, errors
+}
 
-		// Per-value regex: depends on the inclusion path, compiled once per path (AR-6.4).
-		includeDirectivePattern := regexp.MustCompile(`(?m)^\s*%include\s+"` + regexp.QuoteMeta(inclusion.IncludedFilePath) + `"\s*$`)
-		// Replace only the first match so that duplicates are not inlined twice.
-		loc := includeDirectivePattern.FindStringIndex(source)
-		if loc != nil {
-			source = source[:loc[0]] + includedContent + source[loc[1]:]
-		}
+// includingDirectory returns the directory of the innermost file in files,
+// the stack of files enclosing a line, or an empty string if that file is
+// unknown.
+func includingDirectory(files []string) string {
+	if including := files[len(files)-1]; including != "" {
+		return filepath.Dir(including)
+	}
+	return ""
+}
+
+// includePath returns the path of an %include directive line. ok is false
+// if line is not an %include directive whose only operand is a double-quoted
+// path; a comment may follow it.
+func includePath(line string) (string, bool) {
+	directive, rest, _ := splitDirective(line)
+	if directive != "include" || !separatesOperands(rest) {
+		return "", false
 	}
 
-	// FR-1.7.1: Strip remaining %include directives for shared dependencies.
-	// This removes: (a) directives for paths already inlined in a previous
-	// invocation (from the alreadyIncluded set), and (b) duplicate directives
-	// within this invocation whose first occurrence was already inlined above.
-	for _, sharedPath := range sharedPaths {
-		pattern := regexp.MustCompile(`(?m)^\s*%include\s+"` + regexp.QuoteMeta(sharedPath) + `"\s*\n?`)
-		source = pattern.ReplaceAllString(source, "")
+	operands := operandTokens(rest)
+	if len(operands) == 0 || !isQuotedPath(operands[0]) ||
+		len(operands) > 2 || (len(operands) == 2 && operands[1].kind != tokenComment) {
+		return "", false
 	}
-	// Also strip any remaining duplicates that were deduplicated in Pass 2.
-	for path := range seen {
-		pattern := regexp.MustCompile(`(?m)^\s*%include\s+"` + regexp.QuoteMeta(path) + `"\s*\n?`)
-		source = pattern.ReplaceAllString(source, "")
+	return operands[0].text[1 : len(operands[0].text)-1], true
+}
+
+// incbinPath returns the offsets in line of the path of an %incbin directive,
+// between its double quotes. ok is false if line is not an %incbin directive
+// whose first operand is a double-quoted path.
+func incbinPath(line string) (start int, end int, ok bool) {
+	directive, rest, restOffset := splitDirective(line)
+	if directive != "incbin" || !separatesOperands(rest) {
+		return 0, 0, false
 	}
 
-	return source, inclusions
+	path, ok := firstToken(rest)
+	if !ok || !isQuotedPath(path) {
+		return 0, 0, false
+	}
+	return restOffset + path.offset + 1, restOffset + path.end() - 1, true
+}
+
+// isQuotedPath returns true if t is a terminated, non-empty string literal
+// in double quotes.
+func isQuotedPath(t token) bool {
+	return t.kind == tokenString && len(t.text) > 2 && t.text[0] == '"' && t.text[len(t.text)-1] == '"'
 }
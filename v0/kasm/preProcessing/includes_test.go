@@ -19,7 +19,7 @@ func TestPreProcessingHandleIncludes_SingleInclude(t *testing.T) {
 	os.WriteFile(includePath, []byte("mov rax, 1\nmov rdi, 0"), 0644)
 
 	source := `%include "` + includePath + `"`
-	result, inclusions := preProcessing.HandleIncludes(source, nil)
+	result, inclusions := mustHandleIncludes(t, source, nil)
 
 	if len(inclusions) != 1 {
 		t.Fatalf("expected 1 inclusion, got %d", len(inclusions))
@@ -55,7 +55,7 @@ func TestPreProcessingHandleIncludes_MultipleIncludes(t *testing.T) {
 
 	source := `%include "` + path1 + `"
 %include "` + path2 + `"`
-	result, inclusions := preProcessing.HandleIncludes(source, nil)
+	result, inclusions := mustHandleIncludes(t, source, nil)
 
 	if len(inclusions) != 2 {
 		t.Fatalf("expected 2 inclusions, got %d", len(inclusions))
@@ -71,7 +71,7 @@ func TestPreProcessingHandleIncludes_MultipleIncludes(t *testing.T) {
 
 func TestPreProcessingHandleIncludes_NoIncludes(t *testing.T) {
 	source := `mov rax, 1`
-	result, inclusions := preProcessing.HandleIncludes(source, nil)
+	result, inclusions := mustHandleIncludes(t, source, nil)
 
 	if len(inclusions) != 0 {
 		t.Fatalf("expected 0 inclusions, got %d", len(inclusions))
@@ -82,36 +82,35 @@ func TestPreProcessingHandleIncludes_NoIncludes(t *testing.T) {
 	}
 }
 
-func TestPreProcessingHandleIncludes_NonKasmExtension_Panics(t *testing.T) {
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatal("expected panic for non-.kasm file")
-		}
-		msg, ok := r.(string)
-		if !ok {
-			t.Fatalf("expected string panic, got %T", r)
-		}
-		if !containsSubstring(msg, "must have a .kasm extension") {
-			t.Errorf("unexpected panic message: %s", msg)
-		}
-	}()
+func TestPreProcessingHandleIncludes_NonKasmExtension_ReportsError(t *testing.T) {
+	source := `mov rax, 1
+%include "module.asm"`
+	result, inclusions, errs := preProcessing.HandleIncludes(source, nil)
 
-	source := `%include "module.asm"`
-	preProcessing.HandleIncludes(source, nil)
+	e := requireError(t, errs, "must have a .kasm extension")
+	if e.Line != 2 {
+		t.Errorf("expected error at line 2, got %d", e.Line)
+	}
+	if len(inclusions) != 0 {
+		t.Errorf("expected 0 inclusions, got %d", len(inclusions))
+	}
+	if strings.Contains(result, "%include") {
+		t.Errorf("expected the invalid directive to be stripped, got:\n%s", result)
+	}
 }
 
 // TestPreProcessingHandleIncludes_DuplicateInclude_Deduplicated verifies
-// FR-1.7: duplicate %include directives within a single invocation are
-// silently deduplicated — the file is inlined once, the duplicate is stripped.
+// FR-1.7: duplicate %include directives for a guarded file within a single
+// invocation are silently deduplicated — the file is inlined once, the
+// duplicate is stripped.
 func TestPreProcessingHandleIncludes_DuplicateInclude_Deduplicated(t *testing.T) {
 	tmpDir := t.TempDir()
 	includePath := filepath.Join(tmpDir, "hulp.kasm")
-	os.WriteFile(includePath, []byte("nop"), 0644)
+	os.WriteFile(includePath, []byte("%pragma once\nnop"), 0644)
 
 	source := `%include "` + includePath + `"
 %include "` + includePath + `"`
-	result, inclusions := preProcessing.HandleIncludes(source, nil)
+	result, inclusions := mustHandleIncludes(t, source, nil)
 
 	// Only one inclusion should be recorded (the first occurrence).
 	if len(inclusions) != 1 {
@@ -130,23 +129,65 @@ func TestPreProcessingHandleIncludes_DuplicateInclude_Deduplicated(t *testing.T)
 	}
 }
 
-func TestPreProcessingHandleIncludes_FileNotFound_Panics(t *testing.T) {
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatal("expected panic for missing file")
-		}
-		msg, ok := r.(string)
-		if !ok {
-			t.Fatalf("expected string panic, got %T", r)
-		}
-		if !containsSubstring(msg, "Failed to read included file") {
-			t.Errorf("unexpected panic message: %s", msg)
-		}
-	}()
+// TestPreProcessingHandleIncludes_UnguardedInclude_Repeated verifies FR-1.7.7:
+// an unguarded file is inlined at every directive, and a directive inside
+// the file it includes is a circular inclusion.
+func TestPreProcessingHandleIncludes_UnguardedInclude_Repeated(t *testing.T) {
+	tmpDir := t.TempDir()
+	includePath := filepath.Join(tmpDir, "entry.kasm")
+	os.WriteFile(includePath, []byte("dq 0"), 0644)
+
+	source := `%include "` + includePath + `"
+%include "` + includePath + `"`
+	result, inclusions := mustHandleIncludes(t, source, map[string]bool{includePath: true})
 
+	if len(inclusions) != 2 || strings.Count(result, "dq 0") != 2 {
+		t.Errorf("expected the file to be inlined twice, got %d inclusions:\n%s", len(inclusions), result)
+	}
+
+	source = "; FILE: " + includePath + "\n" + `%include "` + includePath + `"` + "\n; END FILE: " + includePath
+	_, inclusions, errs := preProcessing.HandleIncludes(source, nil)
+	e := requireError(t, errs, "circular inclusion of '"+includePath+"'")
+	if e.Line != 2 || len(inclusions) != 0 {
+		t.Errorf("expected the error at line 2 and no inclusions, got %v and %d inclusions", e, len(inclusions))
+	}
+}
+
+func TestPreProcessingHandleIncludes_FileNotFound_ReportsError(t *testing.T) {
 	source := `%include "nonexistent.kasm"`
-	preProcessing.HandleIncludes(source, nil)
+	result, inclusions, errs := preProcessing.HandleIncludes(source, nil)
+
+	e := requireError(t, errs, "failed to read included file 'nonexistent.kasm'")
+	if e.Line != 1 {
+		t.Errorf("expected error at line 1, got %d", e.Line)
+	}
+	if len(inclusions) != 0 {
+		t.Errorf("expected 0 inclusions, got %d", len(inclusions))
+	}
+	if strings.Contains(result, "%include") {
+		t.Errorf("expected the unreadable directive to be stripped, got:\n%s", result)
+	}
+}
+
+func TestPreProcessingHandleIncludes_ReportsAllErrors(t *testing.T) {
+	tmpDir := t.TempDir()
+	includePath := filepath.Join(tmpDir, "ok.kasm")
+	os.WriteFile(includePath, []byte("nop"), 0644)
+
+	source := `%include "missing.kasm"
+%include "` + includePath + `"
+%include "module.asm"`
+	result, inclusions, errs := preProcessing.HandleIncludes(source, nil)
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(errs), errs)
+	}
+	if errs[0].Line != 1 || errs[1].Line != 3 {
+		t.Errorf("expected errors at lines 1 and 3, got %d and %d", errs[0].Line, errs[1].Line)
+	}
+	if len(inclusions) != 1 || !strings.Contains(result, "nop") {
+		t.Errorf("expected the valid include to be inlined, got %d inclusion(s):\n%s", len(inclusions), result)
+	}
 }
 
 func TestPreProcessingHandleIncludes_LineNumber(t *testing.T) {
@@ -157,7 +198,7 @@ func TestPreProcessingHandleIncludes_LineNumber(t *testing.T) {
 	source := `; line 1
 ; line 2
 %include "` + includePath + `"`
-	_, inclusions := preProcessing.HandleIncludes(source, nil)
+	_, inclusions := mustHandleIncludes(t, source, nil)
 
 	if len(inclusions) != 1 {
 		t.Fatalf("expected 1 inclusion, got %d", len(inclusions))
@@ -173,7 +214,7 @@ func TestPreProcessingHandleIncludes_TrimWhitespace(t *testing.T) {
 	os.WriteFile(includePath, []byte("\n  mov rax, 1\n\n"), 0644)
 
 	source := `%include "` + includePath + `"`
-	result, _ := preProcessing.HandleIncludes(source, nil)
+	result, _ := mustHandleIncludes(t, source, nil)
 
 	// Should not start with newline inside FILE block (trimmed)
 	if !containsSubstring(result, "; FILE: "+includePath+"\nmov rax, 1\n; END FILE:") {
@@ -191,12 +232,12 @@ func TestPreProcessingHandleIncludes_TrimWhitespace(t *testing.T) {
 func TestPreProcessingHandleIncludes_SharedDependency_Skipped(t *testing.T) {
 	tmpDir := t.TempDir()
 	sharedPath := filepath.Join(tmpDir, "shared.kasm")
-	os.WriteFile(sharedPath, []byte("mov rax, 42"), 0644)
+	os.WriteFile(sharedPath, []byte("%pragma once\nmov rax, 42"), 0644)
 
 	source := `%include "` + sharedPath + `"` + "\nmov rbx, 1"
 	alreadyIncluded := map[string]bool{sharedPath: true}
 
-	result, inclusions := preProcessing.HandleIncludes(source, alreadyIncluded)
+	result, inclusions := mustHandleIncludes(t, source, alreadyIncluded)
 
 	// FR-1.7.1: The directive should be stripped.
 	if strings.Contains(result, "%include") {
@@ -226,13 +267,13 @@ func TestPreProcessingHandleIncludes_SharedDependency_MixedWithNew(t *testing.T)
 	tmpDir := t.TempDir()
 	sharedPath := filepath.Join(tmpDir, "shared.kasm")
 	newPath := filepath.Join(tmpDir, "new.kasm")
-	os.WriteFile(sharedPath, []byte("mov rax, 42"), 0644)
+	os.WriteFile(sharedPath, []byte("%pragma once\nmov rax, 42"), 0644)
 	os.WriteFile(newPath, []byte("mov rcx, 99"), 0644)
 
 	source := `%include "` + sharedPath + `"` + "\n" + `%include "` + newPath + `"`
 	alreadyIncluded := map[string]bool{sharedPath: true}
 
-	result, inclusions := preProcessing.HandleIncludes(source, alreadyIncluded)
+	result, inclusions := mustHandleIncludes(t, source, alreadyIncluded)
 
 	// FR-1.7.2: The new file should be inlined normally.
 	if len(inclusions) != 1 {
@@ -265,7 +306,7 @@ func TestPreProcessingHandleIncludes_SharedDependency_NilSet(t *testing.T) {
 	os.WriteFile(path, []byte("nop"), 0644)
 
 	source := `%include "` + path + `"`
-	result, inclusions := preProcessing.HandleIncludes(source, nil)
+	result, inclusions := mustHandleIncludes(t, source, nil)
 
 	if len(inclusions) != 1 {
 		t.Fatalf("expected 1 inclusion, got %d", len(inclusions))
@@ -275,6 +316,113 @@ func TestPreProcessingHandleIncludes_SharedDependency_NilSet(t *testing.T) {
 	}
 }
 
+// ---------------------------------------------------------------------------
+// FR-1.8: Include Search Path
+// ---------------------------------------------------------------------------
+
+// TestPreProcessingHandleIncludes_RelativeToIncludingFile verifies FR-1.8.1:
+// relative paths resolve against the directory of the file containing the
+// directive, including files inlined by a previous invocation.
+func TestPreProcessingHandleIncludes_RelativeToIncludingFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, "lib"), 0755)
+	root := filepath.Join(tmpDir, "main.kasm")
+	io := filepath.Join(tmpDir, "lib", "io.kasm")
+	syscall := filepath.Join(tmpDir, "lib", "syscall.kasm")
+	os.WriteFile(io, []byte(`%include "syscall.kasm"`), 0644)
+	os.WriteFile(syscall, []byte("syscall"), 0644)
+
+	result, inclusions, errs := preProcessing.HandleIncludesWithOptions(`%include "lib/io.kasm"`, preProcessing.IncludeOptions{FilePath: root}, nil)
+	if len(errs) != 0 || len(inclusions) != 1 || inclusions[0].IncludedFilePath != io {
+		t.Fatalf("expected lib/io.kasm to resolve to '%s', got %v (errors: %v)", io, inclusions, errs)
+	}
+
+	result, inclusions, errs = preProcessing.HandleIncludesWithOptions(result, preProcessing.IncludeOptions{FilePath: root}, nil)
+	if len(errs) != 0 || len(inclusions) != 1 || inclusions[0].IncludedFilePath != syscall {
+		t.Fatalf("expected syscall.kasm to resolve next to io.kasm, got %v (errors: %v)", inclusions, errs)
+	}
+	if !strings.Contains(result, "; FILE: "+syscall+"\nsyscall\n; END FILE: "+syscall) {
+		t.Errorf("expected markers with the resolved path, got:\n%s", result)
+	}
+}
+
+// TestPreProcessingHandleIncludes_SearchPathOrder verifies FR-1.8.2: the
+// including file's directory is searched first, then the search path in
+// order.
+func TestPreProcessingHandleIncludes_SearchPathOrder(t *testing.T) {
+	tmpDir := t.TempDir()
+	first := filepath.Join(tmpDir, "first")
+	second := filepath.Join(tmpDir, "second")
+	os.MkdirAll(first, 0755)
+	os.MkdirAll(second, 0755)
+	os.WriteFile(filepath.Join(first, "util.kasm"), []byte("first"), 0644)
+	os.WriteFile(filepath.Join(second, "util.kasm"), []byte("second"), 0644)
+	os.WriteFile(filepath.Join(second, "other.kasm"), []byte("other"), 0644)
+	root := filepath.Join(tmpDir, "main.kasm")
+
+	source := "%include \"util.kasm\"\n%include \"other.kasm\""
+	result, _, errs := preProcessing.HandleIncludesWithOptions(source, preProcessing.IncludeOptions{FilePath: root, SearchPath: []string{first, second}}, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if !strings.Contains(result, "first") || strings.Contains(result, "\nsecond\n") || !strings.Contains(result, "other") {
+		t.Errorf("expected the first match in search path order, got:\n%s", result)
+	}
+
+	os.WriteFile(filepath.Join(tmpDir, "util.kasm"), []byte("local"), 0644)
+	result, _, _ = preProcessing.HandleIncludesWithOptions(source, preProcessing.IncludeOptions{FilePath: root, SearchPath: []string{first, second}}, nil)
+	if !strings.Contains(result, "local") {
+		t.Errorf("expected the including file's directory to take precedence, got:\n%s", result)
+	}
+}
+
+// TestPreProcessingHandleIncludes_NotOnSearchPath verifies that an include
+// found nowhere reports the path relative to the including file.
+func TestPreProcessingHandleIncludes_NotOnSearchPath(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "main.kasm")
+
+	_, _, errs := preProcessing.HandleIncludesWithOptions(`%include "missing.kasm"`, preProcessing.IncludeOptions{FilePath: root, SearchPath: []string{t.TempDir()}}, nil)
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, filepath.Join(tmpDir, "missing.kasm")) {
+		t.Errorf("expected an error for '%s', got %v", filepath.Join(tmpDir, "missing.kasm"), errs)
+	}
+}
+
+// TestPreProcessingHandleIncludes_ResolvesIncbinPaths verifies FR-1.8.4: the
+// path of an %incbin directive is replaced by its resolved path, keeping the
+// offset and length, and the binary is not inlined.
+func TestPreProcessingHandleIncludes_ResolvesIncbinPaths(t *testing.T) {
+	tmpDir := t.TempDir()
+	os.MkdirAll(filepath.Join(tmpDir, "lib"), 0755)
+	root := filepath.Join(tmpDir, "main.kasm")
+	font := filepath.Join(tmpDir, "lib", "font.bin")
+	os.WriteFile(filepath.Join(tmpDir, "lib", "fonts.kasm"), []byte(`%incbin "font.bin", 16, 32`), 0644)
+	os.WriteFile(font, []byte{0, 1, 2}, 0644)
+
+	result, _, errs := preProcessing.HandleIncludesWithOptions(`%include "lib/fonts.kasm"`, preProcessing.IncludeOptions{FilePath: root}, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	result, inclusions, errs := preProcessing.HandleIncludesWithOptions(result, preProcessing.IncludeOptions{FilePath: root}, nil)
+	if len(errs) != 0 || len(inclusions) != 0 {
+		t.Fatalf("expected no inclusions or errors, got %v and %v", inclusions, errs)
+	}
+	if !strings.Contains(result, `%incbin "`+font+`", 16, 32`) {
+		t.Errorf("expected the %%incbin path to be resolved, got:\n%s", result)
+	}
+}
+
+// TestIncludeSearchPath_Environment verifies FR-1.8.3: KASM_INCLUDE
+// directories follow the given directories.
+func TestIncludeSearchPath_Environment(t *testing.T) {
+	t.Setenv(preProcessing.IncludePathEnvironment, strings.Join([]string{"/env/a", "", "/env/b"}, string(os.PathListSeparator)))
+
+	searchPath := preProcessing.IncludeSearchPath([]string{"/flag"})
+	if strings.Join(searchPath, ",") != "/flag,/env/a,/env/b" {
+		t.Errorf("expected -I directories before KASM_INCLUDE, got %v", searchPath)
+	}
+}
+
 func BenchmarkPreProcessingHandleIncludes_NoIncludes(b *testing.B) {
 	source := "mov rax, 1\nmov rdi, 0\nsyscall\n"
 	b.ResetTimer()
@@ -358,3 +506,162 @@ func BenchmarkPreProcessingHandleIncludes_IncludeDeepInSource(b *testing.B) {
 		preProcessing.HandleIncludes(source, nil)
 	}
 }
+
+// mustHandleIncludes resolves includes and fails the test on pre-processing errors.
+func mustHandleIncludes(t *testing.T, source string, alreadyIncluded map[string]bool) (string, []preProcessing.Inclusion) {
+	t.Helper()
+	result, inclusions, errs := preProcessing.HandleIncludes(source, alreadyIncluded)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected pre-processing errors: %v", errs)
+	}
+	return result, inclusions
+}
+
+// ---------------------------------------------------------------------------
+// FR-1.9: Conditional Includes
+// ---------------------------------------------------------------------------
+
+// TestPreProcessingHandleIncludes_InactiveBranchNotFollowed verifies FR-1.9.1:
+// an %include in an inactive branch is neither read nor reported, and is left
+// in place for HandleConditionals.
+func TestPreProcessingHandleIncludes_InactiveBranchNotFollowed(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(filepath.Join(tmpDir, "x86.kasm"), []byte("x86"), 0644)
+
+	source := "%ifdef ARCH_RISCV\n%include \"riscv.kasm\"\n%else\n%include \"x86.kasm\"\n%endif"
+	result, inclusions, errs := preProcessing.HandleIncludesWithOptions(source, preProcessing.IncludeOptions{FilePath: root}, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if len(inclusions) != 1 || inclusions[0].IncludedFilePath != filepath.Join(tmpDir, "x86.kasm") {
+		t.Fatalf("expected only x86.kasm to be included, got %v", inclusions)
+	}
+	if !strings.Contains(result, `%include "riscv.kasm"`) {
+		t.Errorf("expected the inactive directive to be left in place, got:\n%s", result)
+	}
+}
+
+// TestPreProcessingHandleIncludes_DefinedSymbols verifies FR-1.9.1: the
+// defined symbols seed the conditional evaluation.
+func TestPreProcessingHandleIncludes_DefinedSymbols(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(filepath.Join(tmpDir, "riscv.kasm"), []byte("riscv"), 0644)
+
+	source := "%ifdef ARCH_RISCV\n%include \"riscv.kasm\"\n%else\n%include \"x86.kasm\"\n%endif"
+	options := preProcessing.IncludeOptions{FilePath: root, DefinedSymbols: map[string]bool{"ARCH_RISCV": true}}
+	_, inclusions, errs := preProcessing.HandleIncludesWithOptions(source, options, nil)
+	if len(errs) != 0 || len(inclusions) != 1 || inclusions[0].IncludedFilePath != filepath.Join(tmpDir, "riscv.kasm") {
+		t.Errorf("expected only riscv.kasm to be included, got %v (errors: %v)", inclusions, errs)
+	}
+}
+
+// TestPreProcessingHandleIncludes_DefineInIncludedFile verifies FR-1.9.2: a
+// %define in an included file, at any depth, decides the conditionals that
+// follow the %include.
+func TestPreProcessingHandleIncludes_DefineInIncludedFile(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(filepath.Join(tmpDir, "config.kasm"), []byte(`%include "arch.kasm"`), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "arch.kasm"), []byte("%define ARCH_RISCV"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "riscv.kasm"), []byte("riscv"), 0644)
+	os.WriteFile(filepath.Join(tmpDir, "generic.kasm"), []byte("generic"), 0644)
+
+	source := "%include \"config.kasm\"\n%ifdef ARCH_RISCV\n%include \"riscv.kasm\"\n%else\n%include \"generic.kasm\"\n%endif"
+	options := preProcessing.IncludeOptions{FilePath: root}
+	var included []string
+	for {
+		var inclusions []preProcessing.Inclusion
+		var errs []preProcessing.PreProcessingError
+		source, inclusions, errs = preProcessing.HandleIncludesWithOptions(source, options, nil)
+		if len(errs) != 0 {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		if len(inclusions) == 0 {
+			break
+		}
+		for _, inclusion := range inclusions {
+			included = append(included, filepath.Base(inclusion.IncludedFilePath))
+		}
+	}
+
+	if strings.Join(included, ",") != "config.kasm,arch.kasm,riscv.kasm" {
+		t.Errorf("expected config.kasm, arch.kasm and riscv.kasm to be included, got %v", included)
+	}
+}
+
+// TestPreProcessingHandleIncludes_UnevaluableConditionFollowed verifies
+// FR-1.9.3: a condition that cannot be evaluated before assembly, such as one
+// on an %assign variable, is assumed to hold.
+func TestPreProcessingHandleIncludes_UnevaluableConditionFollowed(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(filepath.Join(tmpDir, "table.kasm"), []byte("table"), 0644)
+
+	source := "%assign COUNT 2\n%if COUNT > 1\n%include \"table.kasm\"\n%endif"
+	_, inclusions, errs := preProcessing.HandleIncludesWithOptions(source, preProcessing.IncludeOptions{FilePath: root}, nil)
+	if len(errs) != 0 || len(inclusions) != 1 {
+		t.Errorf("expected table.kasm to be included, got %v (errors: %v)", inclusions, errs)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// FR-1.10: Include Guards
+// ---------------------------------------------------------------------------
+
+// TestIncludeOnce verifies FR-1.10.1: %pragma once and classic include
+// guards wrapping the whole file are recognised.
+func TestIncludeOnce(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    bool
+	}{
+		{"pragma once", "; io helpers\n%pragma once\nmov rax, 1", true},
+		{"guard", "; io helpers\n\n%ifndef IO_KASM\n%define IO_KASM 1 ; guard\n%ifdef DEBUG\nmov rax, 1\n%endif\n%endif ; IO_KASM\n", true},
+		{"no guard", "mov rax, 1", false},
+		{"mismatched guard name", "%ifndef IO_KASM\n%define IO\n%endif", false},
+		{"guard does not wrap file", "%ifndef IO_KASM\n%define IO_KASM\n%endif\nmov rax, 1", false},
+		{"unterminated guard", "%ifndef IO_KASM\n%define IO_KASM\nmov rax, 1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := preProcessing.IncludeOnce(tt.content); got != tt.want {
+				t.Errorf("IncludeOnce() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestPreProcessingHandleIncludes_PragmaOnceBlanked verifies FR-1.10.2:
+// %pragma once lines are blanked, in the source and in inlined files.
+func TestPreProcessingHandleIncludes_PragmaOnceBlanked(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(filepath.Join(tmpDir, "io.kasm"), []byte("%pragma once\nmov rax, 1"), 0644)
+
+	result, _, errs := preProcessing.HandleIncludesWithOptions("%pragma once\n%include \"io.kasm\"", preProcessing.IncludeOptions{FilePath: root}, nil)
+	result, _, _ = preProcessing.HandleIncludesWithOptions(result, preProcessing.IncludeOptions{FilePath: root}, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if strings.Contains(result, "%pragma") || !strings.HasPrefix(result, "\n; FILE: ") {
+		t.Errorf("expected %%pragma once lines to be blanked, got:\n%s", result)
+	}
+}
+
+func TestPreProcessingHandleIncludes_TrailingCommentAndCommentedDirective(t *testing.T) {
+	tmpDir := t.TempDir()
+	root := filepath.Join(tmpDir, "main.kasm")
+	os.WriteFile(filepath.Join(tmpDir, "io.kasm"), []byte("mov rax, 1"), 0644)
+
+	source := "%include \"io.kasm\" ; console output\n; %include \"missing.kasm\"\ndb \"%include 'missing.kasm'\""
+	result, inclusions, errs := preProcessing.HandleIncludesWithOptions(source, preProcessing.IncludeOptions{FilePath: root}, nil)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if len(inclusions) != 1 || !strings.Contains(result, "mov rax, 1") {
+		t.Errorf("expected only io.kasm to be included, got %v:\n%s", inclusions, result)
+	}
+}
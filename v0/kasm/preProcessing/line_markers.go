@@ -0,0 +1,145 @@
+package preProcessing
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// LineMarker is a %line directive (FR-12.2). It states that the line after
+// the directive is line Line of File, and that every following line is
+// Increment lines further on:
+//
+//	%line 12+1 "lib/io.kasm"    ; the next line is lib/io.kasm:12, then 13, …
+//	%line 40+0 "main.kasm"      ; the next lines all come from main.kasm:40
+//
+// The increment defaults to 1. File may be omitted, in which case the lines
+// keep the file they are in.
+type LineMarker struct {
+	// Directive - the 1-based line number of the directive.
+	Directive int
+	// Line - the original line number of the line after the directive.
+	Line int
+	// Increment - the original line distance between consecutive lines.
+	Increment int
+	// File - the original file, or "" for the file the directive is in.
+	File string
+}
+
+// String returns the marker as a %line directive.
+func (m LineMarker) String() string {
+	directive := fmt.Sprintf("%%line %d+%d", m.Line, m.Increment)
+	if m.File != "" {
+		directive += " " + quoteString(m.File)
+	}
+	return directive
+}
+
+// LineMarkers returns the %line directives in source, in order, together
+// with errors for the malformed ones, which are left out.
+func LineMarkers(source string) ([]LineMarker, []PreProcessingError) {
+	markers := make([]LineMarker, 0)
+	errors := make([]PreProcessingError, 0)
+
+	// Early-exit: no %line directives (AR-8.1).
+	if !strings.Contains(source, "%line") {
+		return markers, errors
+	}
+
+	for i, line := range strings.Split(source, "\n") {
+		rest, ok := splitLineDirective(line)
+		if !ok {
+			continue
+		}
+		marker, message := parseLineMarker(rest)
+		if message != "" {
+			errors = append(errors, PreProcessingError{Message: message, Line: i + 1})
+			continue
+		}
+		marker.Directive = i + 1
+		markers = append(markers, marker)
+	}
+	return markers, errors
+}
+
+// HandleLineMarkers blanks the %line directives in source, so that they do
+// not reach the lexer, and reports the malformed ones (FR-12.2.1). The
+// markers themselves are applied by the caller through ResolveLineMarker.
+// The transformation is line-preserving.
+func HandleLineMarkers(source string) (string, []PreProcessingError) {
+	markers, errors := LineMarkers(source)
+	if len(markers) == 0 && len(errors) == 0 {
+		return source, errors
+	}
+
+	lines := strings.Split(source, "\n")
+	for i, line := range lines {
+		if _, ok := splitLineDirective(line); ok {
+			lines[i] = ""
+		}
+	}
+	return strings.Join(lines, "\n"), errors
+}
+
+// ResolveLineMarker maps a 1-based line of the source markers were read from
+// to the original file and line stated by the last marker before it. ok is
+// false for lines before the first marker and for the marker lines
+// themselves; file is "" if that marker names no file.
+func ResolveLineMarker(markers []LineMarker, line int) (file string, original int, ok bool) {
+	for i := len(markers) - 1; i >= 0; i-- {
+		marker := markers[i]
+		if marker.Directive > line {
+			continue
+		}
+		if marker.Directive == line {
+			return "", 0, false
+		}
+		return marker.File, marker.Line + (line-marker.Directive-1)*marker.Increment, true
+	}
+	return "", 0, false
+}
+
+// splitLineDirective returns the operands of a %line directive line, without
+// its comment. ok is false if line is not a %line directive.
+func splitLineDirective(line string) (string, bool) {
+	directive, rest, _ := splitDirective(line)
+	if directive != "line" || !separatesOperands(rest) {
+		return "", false
+	}
+	return strings.TrimSpace(stripComment(rest)), true
+}
+
+// parseLineMarker parses the operands of a %line directive: LINE[+INCREMENT]
+// and an optional file name, quoted or not. It returns an error message if
+// they are malformed.
+func parseLineMarker(rest string) (LineMarker, string) {
+	const usage = "malformed %line directive: expected %line LINE[+INCREMENT] [FILE]"
+
+	position, file := rest, ""
+	if end := strings.IndexAny(rest, " \t"); end >= 0 {
+		position, file = rest[:end], rest[end+1:]
+	}
+	lineText, incrementText, hasIncrement := strings.Cut(position, "+")
+
+	line, err := strconv.Atoi(lineText)
+	if err != nil || line < 1 {
+		return LineMarker{}, usage
+	}
+	increment := 1
+	if hasIncrement {
+		increment, err = strconv.Atoi(incrementText)
+		if err != nil || increment < 0 {
+			return LineMarker{}, usage
+		}
+	}
+
+	file = strings.TrimSpace(file)
+	if strings.HasPrefix(file, `"`) {
+		if len(file) < 2 || !strings.HasSuffix(file, `"`) {
+			return LineMarker{}, usage
+		}
+		file = file[1 : len(file)-1]
+	}
+
+	return LineMarker{Line: line, Increment: increment, File: file}, ""
+}
@@ -0,0 +1,81 @@
+package preProcessing_test
+
+import (
+	"testing"
+
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+)
+
+// --- FR-12.2: %line markers ---
+
+func TestLineMarkers(t *testing.T) {
+	source := "nop\n%line 12 \"lib/io.kasm\"\nnop\n  %line 40+0 main.kasm ; expansion\n%line 7+2\n%linear\n%line\n%line 3+x\n%line 0"
+
+	markers, errs := preProcessing.LineMarkers(source)
+
+	expected := []preProcessing.LineMarker{
+		{Directive: 2, Line: 12, Increment: 1, File: "lib/io.kasm"},
+		{Directive: 4, Line: 40, Increment: 0, File: "main.kasm"},
+		{Directive: 5, Line: 7, Increment: 2},
+	}
+	if len(markers) != len(expected) {
+		t.Fatalf("expected %d markers, got %v", len(expected), markers)
+	}
+	for i, marker := range markers {
+		if marker != expected[i] {
+			t.Errorf("expected %+v, got %+v", expected[i], marker)
+		}
+	}
+
+	if len(errs) != 3 {
+		t.Fatalf("expected three malformed directives, got %v", errs)
+	}
+	for i, line := range []int{7, 8, 9} {
+		if errs[i].Line != line || errs[i].Message != "malformed %line directive: expected %line LINE[+INCREMENT] [FILE]" {
+			t.Errorf("unexpected error %q", errs[i].String())
+		}
+	}
+}
+
+func TestLineMarker_String(t *testing.T) {
+	if got := (preProcessing.LineMarker{Line: 12, Increment: 0, File: "lib/io.kasm"}).String(); got != `%line 12+0 "lib/io.kasm"` {
+		t.Errorf("unexpected directive %q", got)
+	}
+	if got := (preProcessing.LineMarker{Line: 3, Increment: 1}).String(); got != "%line 3+1" {
+		t.Errorf("unexpected directive %q", got)
+	}
+}
+
+func TestResolveLineMarker(t *testing.T) {
+	markers, _ := preProcessing.LineMarkers("nop\n%line 12 \"io.kasm\"\nnop\nnop\n%line 40+0\nnop\nnop")
+
+	tests := []struct {
+		line     int
+		file     string
+		original int
+		ok       bool
+	}{
+		{1, "", 0, false},
+		{2, "", 0, false},
+		{3, "io.kasm", 12, true},
+		{4, "io.kasm", 13, true},
+		{6, "", 40, true},
+		{7, "", 40, true},
+	}
+	for _, tt := range tests {
+		file, original, ok := preProcessing.ResolveLineMarker(markers, tt.line)
+		if file != tt.file || original != tt.original || ok != tt.ok {
+			t.Errorf("line %d: expected %q:%d (%v), got %q:%d (%v)", tt.line, tt.file, tt.original, tt.ok, file, original, ok)
+		}
+	}
+}
+
+func TestHandleLineMarkers_Blanks(t *testing.T) {
+	result, errs := preProcessing.HandleLineMarkers("%line 3 \"a.kasm\"\nnop\n%line 1+0\nret")
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if result != "\nnop\n\nret" {
+		t.Errorf("expected the directives to be blanked, got %q", result)
+	}
+}
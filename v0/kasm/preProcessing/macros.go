@@ -2,156 +2,696 @@ package preProcessing
 
 import (
 	"fmt"
-	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 )
 
-// Pre-compiled regexes for macro processing (AR-6.3).
-var (
-	// macroDetectRegex checks if any %macro directive exists in the source.
-	macroDetectRegex = regexp.MustCompile(`%macro\s+\w+\s*\d*`)
-	// macroDefRegex matches a %macro definition line and captures name + param count.
-	macroDefRegex = regexp.MustCompile(`(?m)^\s*%macro\s+(\w+)\s*(\d*)\s*$`)
-)
-
 // HasMacros returns true if the source contains at least one
 // %macro directive, false otherwise. Used as an early-exit check.
 func HasMacros(source string) bool {
-	return macroDetectRegex.MatchString(source)
+	if !strings.Contains(source, "%macro") {
+		return false
+	}
+	for _, line := range strings.Split(source, "\n") {
+		if _, _, ok := macroDefinition(line); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// macroBlock is a %macro … %endmacro definition. start and end are the
+// 0-based indices of its %macro and %endmacro lines; end is -1 if the
+// definition has no %endmacro.
+type macroBlock struct {
+	name  string
+	spec  string
+	start int
+	end   int
+}
+
+// scanMacroBlocks returns the %macro … %endmacro definitions in lines, in
+// order, in a single pass. A definition ends at the first %endmacro after
+// it, so %macro lines in its body do not start another definition. Only the
+// last definition can lack its %endmacro.
+func scanMacroBlocks(lines []string) []macroBlock {
+	blocks := make([]macroBlock, 0)
+	open := false
+	for i, line := range lines {
+		if !open {
+			if name, spec, ok := macroDefinition(line); ok {
+				blocks = append(blocks, macroBlock{name: name, spec: spec, start: i, end: -1})
+				open = true
+			}
+			continue
+		}
+		if directive, _, _ := splitDirective(line); directive == "endmacro" {
+			blocks[len(blocks)-1].end = i
+			open = false
+		}
+	}
+	return blocks
+}
+
+// macroDefinition returns the macro name and the parameter specification of
+// a %macro line. ok is false if line is not a %macro directive followed by a
+// name.
+func macroDefinition(line string) (name string, spec string, ok bool) {
+	directive, rest, _ := splitDirective(line)
+	if directive != "macro" || !separatesOperands(rest) {
+		return "", "", false
+	}
+
+	text := strings.TrimLeft(rest, " \t\r")
+	end := 0
+	for end < len(text) && isSymbolChar(text[end]) {
+		end++
+	}
+	if end == 0 {
+		return "", "", false
+	}
+	return text[:end], text[end:], true
 }
 
 // MacroTable extracts macro definitions from the source code and
 // returns a map of Macro structs indexed by their names. Returns an empty map
 // if no macros are found.
-func MacroTable(source string) map[string]Macro {
+//
+// The definition line declares the number of parameters, a range of
+// parameter counts, and default values for the optional parameters:
+//
+//	%macro exit 1            ; exactly one argument
+//	%macro log 1-*           ; one or more arguments
+//	%macro write 1-3 1, 0    ; one to three; %2 defaults to 1, %3 to 0
+//
+// Only definitions in active conditional branches are collected (FR-2.2.8),
+// so that a macro may be defined differently under %ifdef and %else. A
+// %macro without a matching %endmacro and a malformed parameter
+// specification are reported as errors; the macro is left out of the table.
+// A second definition of a macro with the same parameter counts is reported
+// as a duplicate, and the first is kept; a definition with other counts
+// replaces the previous one.
+func MacroTable(source string) (map[string]Macro, []PreProcessingError) {
+	return MacroTableWithCommandLine(source, nil)
+}
+
+// MacroTableWithCommandLine is MacroTable with the symbols defined and
+// undefined on the command line in effect when deciding which conditional
+// branches are active, as for HandleConditionalsWithCommandLine (FR-10.2).
+func MacroTableWithCommandLine(source string, commandLine *CommandLineSymbols) (map[string]Macro, []PreProcessingError) {
 	macroTable := make(map[string]Macro)
+	errors := make([]PreProcessingError, 0)
 	if !HasMacros(source) {
-		return macroTable
+		return macroTable, errors
 	}
 
-	matches := macroDefRegex.FindAllStringSubmatch(source, -1)
-	matchIndices := macroDefRegex.FindAllStringIndex(source, -1)
+	lines := strings.Split(source, "\n")
+	scanner := ConditionalScannerNew(nil).WithCommandLine(commandLine)
+	active := make([]bool, len(lines))
+	for i, line := range lines {
+		active[i] = scanner.Active(line)
+	}
+	definedAt := make(map[string]int)
 
-	for i, match := range matches {
-		macroName := match[1]
-		paramCount := 0
-		if len(match) > 2 && match[2] != "" {
-			paramCount = int(match[2][0] - '0')
+	for _, block := range scanMacroBlocks(lines) {
+		lineNumber := block.start + 1
+		if !active[block.start] {
+			continue
 		}
 
-		// Per-value regex: depends on the macro name, compiled once per macro (AR-6.4).
-		bodyRegex := regexp.MustCompile(`(?s)%macro\s+` + regexp.QuoteMeta(macroName) + `\s*\d*\s*(.*?)%endmacro`)
-		bodyMatch := bodyRegex.FindStringSubmatch(source)
-
 		// FR-2.2.6: A %macro without a matching %endmacro is a pre-processing error.
-		if bodyMatch == nil {
-			lineNumber := strings.Count(source[:matchIndices[i][0]], "\n") + 1
-			panic(fmt.Sprintf("pre-processing error: %%macro '%s' at line %d has no matching %%endmacro", macroName, lineNumber))
+		if block.end < 0 {
+			errors = append(errors, PreProcessingError{
+				Message: fmt.Sprintf("%%macro '%s' has no matching %%endmacro", block.name),
+				Line:    lineNumber,
+			})
+			continue
+		}
+
+		macro, message := parseMacroSpecification(block.name, block.spec)
+		if message != "" {
+			errors = append(errors, PreProcessingError{Message: message, Line: lineNumber})
+			continue
+		}
+
+		if previous, ok := macroTable[block.name]; ok &&
+			previous.MinArguments == macro.MinArguments && previous.MaxArguments == macro.MaxArguments {
+			errors = append(errors, PreProcessingError{
+				Message:  fmt.Sprintf("duplicate %%macro '%s' with %s", block.name, describeArgumentCount(macro)),
+				Line:     lineNumber,
+				Previous: definedAt[block.name],
+			})
+			continue
+		}
+
+		// The body keeps the line break of its last line.
+		if body := lines[block.start+1 : block.end]; len(body) > 0 {
+			macro.Body = strings.Join(body, "\n") + "\n"
 		}
+		macroTable[block.name] = macro
+		definedAt[block.name] = lineNumber
+	}
+
+	return macroTable, errors
+}
+
+// maxMacroParameters bounds the parameter counts of a %macro specification.
+// Placeholders are resolved by their index when a call is expanded, but the
+// declared parameters are named in Macro.Parameters, so an absurd count such
+// as `%macro m 1000000000` is rejected rather than allocated.
+const maxMacroParameters = 1024
+
+// parseMacroSpecification parses the parameter specification that follows
+// the macro name on a %macro line. It returns an error message instead of a
+// macro if the specification is malformed or a count exceeds
+// maxMacroParameters.
+func parseMacroSpecification(name string, spec string) (Macro, string) {
+	spec = strings.TrimSpace(stripComment(spec))
 
-		macroBody := bodyMatch[1]
+	macro := Macro{
+		Name:       name,
+		Parameters: make(map[string]MacroParameter),
+		Calls:      []MacroCall{},
+	}
 
-		parameters := make(map[string]MacroParameter)
-		for i := 1; i <= paramCount; i++ {
-			paramName := fmt.Sprintf("param%c", 'A'+i-1)
-			parameters[paramName] = MacroParameter{
-				Name: paramName,
+	if spec != "" {
+		// The parameter count or range: 2, 1-3 or 1-*.
+		count, rest := spec, ""
+		if end := strings.IndexAny(spec, " \t"); end >= 0 {
+			count, rest = spec[:end], spec[end:]
+		}
+		minText, maxText, ranged := strings.Cut(count, "-")
+		if !isDigits(minText) || (ranged && maxText != "*" && !isDigits(maxText)) {
+			return Macro{}, fmt.Sprintf("invalid parameter specification '%s' for macro '%s'", spec, name)
+		}
+
+		var message string
+		if macro.MinArguments, message = macroParameterCount(name, minText); message != "" {
+			return Macro{}, message
+		}
+		macro.MaxArguments = macro.MinArguments
+		switch {
+		case !ranged:
+		case maxText == "*":
+			macro.MaxArguments = -1
+		default:
+			if macro.MaxArguments, message = macroParameterCount(name, maxText); message != "" {
+				return Macro{}, message
+			}
+			if macro.MaxArguments < macro.MinArguments {
+				return Macro{}, fmt.Sprintf("invalid parameter range %s-%s for macro '%s'", minText, maxText, name)
 			}
 		}
 
-		macroTable[macroName] = Macro{
-			Name:       macroName,
-			Parameters: parameters,
-			Body:       macroBody,
-			Calls:      []MacroCall{},
+		if rest = strings.TrimSpace(rest); rest != "" {
+			macro.Defaults = splitMacroArguments(rest)
+			if macro.MaxArguments < 0 || len(macro.Defaults) > macro.MaxArguments-macro.MinArguments {
+				return Macro{}, fmt.Sprintf("too many default values for macro '%s'", name)
+			}
+		}
+	}
+
+	declared := macro.MaxArguments
+	if declared < 0 {
+		declared = macro.MinArguments
+	}
+	for i := 1; i <= declared; i++ {
+		paramName := macroParameterName(i)
+		macro.Parameters[paramName] = MacroParameter{
+			Name: paramName,
+		}
+	}
+
+	return macro, ""
+}
+
+// macroParameterCount converts a run of digits from the parameter
+// specification of macro name. It returns an error message instead of a count
+// if the count exceeds maxMacroParameters, or does not fit in an int.
+func macroParameterCount(name string, digits string) (int, string) {
+	count, err := strconv.Atoi(digits)
+	if err != nil || count > maxMacroParameters {
+		return 0, fmt.Sprintf("parameter count %s for macro '%s' exceeds the limit of %d", digits, name, maxMacroParameters)
+	}
+	return count, ""
+}
+
+// isDigits returns true if text is a non-empty run of decimal digits.
+func isDigits(text string) bool {
+	for i := 0; i < len(text); i++ {
+		if !isDigit(text[i]) {
+			return false
 		}
 	}
+	return text != ""
+}
 
-	return macroTable
+// macroParameterName returns the name of the i-th (1-based) parameter:
+// paramA … paramZ, paramAA, paramAB, …
+func macroParameterName(i int) string {
+	suffix := ""
+	for ; i > 0; i = (i - 1) / 26 {
+		suffix = string(rune('A'+(i-1)%26)) + suffix
+	}
+	return "param" + suffix
 }
 
 // CollectMacroCalls scans the source for invocations of each macro
 // in the provided table and appends found calls to Macro.Calls.
 // This function mutates macroTable in place — the caller's map is updated directly.
-func CollectMacroCalls(source string, macroTable map[string]Macro) {
-	for macroName, macro := range macroTable {
-		// Per-value regex: depends on the macro name, compiled once per macro (AR-6.4).
-		pattern := `(?m)^[^\S\n]*` + regexp.QuoteMeta(macroName) + `\s+(.+)$`
-		re := regexp.MustCompile(pattern)
-
-		matches := re.FindAllStringSubmatchIndex(source, -1)
-		for _, matchIdx := range matches {
-			if len(matchIdx) < 4 {
-				continue
-			}
+//
+// Arguments are split at commas outside brackets, parentheses, braces and
+// string literals; an argument wrapped in braces ({a, b}) is passed without
+// them. Missing optional arguments are filled in from the macro's defaults.
+// Calls with an argument count outside the macro's range are reported as
+// errors and are not collected. Lines inside %macro … %endmacro blocks are
+// not calls; calls in macro bodies are expanded by ReplaceMacroCalls.
+//
+// A call is a line whose first token is the name of a macro, standing alone
+// (FR-13.2): a label such as `name:` or a name inside a comment or string
+// literal is not a call. The source is scanned once, whatever the number of
+// macros, and errors are reported in source order.
+//
+// Calls in inactive conditional branches are neither collected nor checked,
+// as for the definitions collected by MacroTable (FR-2.3.7).
+func CollectMacroCalls(source string, macroTable map[string]Macro) []PreProcessingError {
+	return CollectMacroCallsWithCommandLine(source, macroTable, nil)
+}
+
+// CollectMacroCallsWithCommandLine is CollectMacroCalls with the symbols
+// defined and undefined on the command line in effect when deciding which
+// conditional branches are active, as for MacroTableWithCommandLine.
+func CollectMacroCallsWithCommandLine(source string, macroTable map[string]Macro, commandLine *CommandLineSymbols) []PreProcessingError {
+	errors := make([]PreProcessingError, 0)
+	if len(macroTable) == 0 {
+		return errors
+	}
 
-			matchStart := matchIdx[0]
-			lineNumber := strings.Count(source[:matchStart], "\n") + 1
+	lines := strings.Split(source, "\n")
+	definitions := macroDefinitionLines(lines)
+	scanner := ConditionalScannerNew(nil).WithCommandLine(commandLine)
+	for i, line := range lines {
+		if !scanner.Active(line) || definitions[i] {
+			continue
+		}
+		macroName, argStr, ok := splitInvocation(line)
+		if !ok {
+			continue
+		}
+		macro, ok := macroTable[macroName]
+		if !ok {
+			continue
+		}
 
-			argStr := source[matchIdx[2]:matchIdx[3]]
+		args, message := macroCallArguments(macro, argStr)
+		if message != "" {
+			errors = append(errors, PreProcessingError{Message: message, Line: i + 1})
+			continue
+		}
 
-			rawArgs := strings.Split(argStr, ",")
-			args := make([]string, 0, len(rawArgs))
-			for _, arg := range rawArgs {
-				trimmed := strings.TrimSpace(arg)
-				if trimmed != "" {
-					args = append(args, trimmed)
-				}
-			}
+		macro.Calls = append(macro.Calls, MacroCall{
+			Name:       macroName,
+			Arguments:  args,
+			LineNumber: i + 1,
+		})
+		macroTable[macroName] = macro
+	}
 
-			if len(args) != len(macro.Parameters) {
-				message := fmt.Sprintf("pre-processing error: Macro '%s' expects %d arguments, but got %d at line %d",
-					macroName, len(macro.Parameters), len(args), lineNumber)
-				panic(message)
-			}
+	return errors
+}
 
-			macro.Calls = append(macro.Calls, MacroCall{
-				Name:       macroName,
-				Arguments:  args,
-				LineNumber: lineNumber,
-			})
+// macroCallArguments splits the argument text of a call to macro and fills in
+// defaults. It returns an error message instead of the arguments if their
+// number is outside the macro's range.
+func macroCallArguments(macro Macro, argStr string) ([]string, string) {
+	args := splitMacroArguments(argStr)
+
+	if len(args) < macro.MinArguments || (macro.MaxArguments >= 0 && len(args) > macro.MaxArguments) {
+		return nil, fmt.Sprintf("macro '%s' expects %s, but got %d",
+			macro.Name, describeArgumentCount(macro), len(args))
+	}
+
+	// Defaults cover the optional parameters in order; parameters
+	// without one are left out, and %0 counts only those present.
+	if optional := len(args) - macro.MinArguments; optional < len(macro.Defaults) {
+		args = append(args, macro.Defaults[optional:]...)
+	}
+	return args, ""
+}
+
+// macroDefinitionLines reports, for each of lines, whether it is part of a
+// %macro … %endmacro definition, from the %macro line to the %endmacro line.
+// A definition without %endmacro is not.
+func macroDefinitionLines(lines []string) []bool {
+	definitions := make([]bool, len(lines))
+	for _, block := range scanMacroBlocks(lines) {
+		for i := block.start; i <= block.end; i++ {
+			definitions[i] = true
 		}
+	}
+	return definitions
+}
 
-		macroTable[macroName] = macro
+// describeArgumentCount describes the number of arguments macro accepts.
+func describeArgumentCount(macro Macro) string {
+	switch {
+	case macro.MaxArguments < 0:
+		return fmt.Sprintf("at least %d arguments", macro.MinArguments)
+	case macro.MaxArguments > macro.MinArguments:
+		return fmt.Sprintf("%d to %d arguments", macro.MinArguments, macro.MaxArguments)
+	default:
+		return fmt.Sprintf("%d arguments", macro.MinArguments)
+	}
+}
+
+// splitMacroArguments splits the argument text of a macro call. Commas inside
+// brackets, parentheses, braces and string literals do not split arguments,
+// and a ';' comment ends the list. Arguments are trimmed; one that is wrapped
+// in braces has them removed. Empty text yields no arguments.
+func splitMacroArguments(text string) []string {
+	args := make([]string, 0)
+	if strings.TrimSpace(stripComment(text)) == "" {
+		return args
+	}
+
+	depth := 0
+	start := 0
+	i := 0
+	for ; i < len(text); i++ {
+		ch := text[i]
+		switch {
+		case ch == '"' || ch == '\'':
+			i = skipQuoted(text, i) - 1
+		case ch == '(' || ch == '[' || ch == '{':
+			depth++
+		case (ch == ')' || ch == ']' || ch == '}') && depth > 0:
+			depth--
+		case ch == ',' && depth == 0:
+			args = append(args, trimMacroArgument(text[start:i]))
+			start = i + 1
+		case ch == ';':
+			args = append(args, trimMacroArgument(text[start:i]))
+			return args
+		}
+	}
+
+	return append(args, trimMacroArgument(text[start:i]))
+}
+
+// trimMacroArgument trims arg and removes enclosing braces.
+func trimMacroArgument(arg string) string {
+	arg = strings.TrimSpace(arg)
+	if len(arg) >= 2 && arg[0] == '{' && arg[len(arg)-1] == '}' {
+		arg = strings.TrimSpace(arg[1 : len(arg)-1])
 	}
+	return arg
+}
+
+// skipQuoted returns the offset just past the string literal starting at
+// offset start, which may be delimited by " or '. It returns len(text) if
+// the literal is not terminated.
+func skipQuoted(text string, start int) int {
+	if end := strings.IndexByte(text[start+1:], text[start]); end >= 0 {
+		return start + end + 2
+	}
+	return len(text)
+}
+
+// DefaultMacroExpansionDepth is the maximum nesting depth of macro expansion
+// used by ReplaceMacroCalls. A deeper chain of macros invoking macros is
+// reported as infinite recursion.
+const DefaultMacroExpansionDepth = 64
+
+// ReplaceMacroCalls replaces macro invocations in the source code with their
+// expanded bodies, allowing at most DefaultMacroExpansionDepth levels of
+// nested expansion. See ReplaceMacroCallsWithDepth.
+func ReplaceMacroCalls(source string, macroTable map[string]Macro) (string, []PreProcessingError) {
+	return ReplaceMacroCallsWithDepth(source, macroTable, DefaultMacroExpansionDepth)
 }
 
-// ReplaceMacroCalls replaces macro invocations in the source code
+// ReplaceMacroCallsWithDepth replaces macro invocations in the source code
 // with their expanded bodies based on the provided macro table. Placeholders
-// (%1, %2, …) are substituted with the call's arguments. Returns the
-// transformed source string.
-func ReplaceMacroCalls(source string, macroTable map[string]Macro) string {
+// are substituted with the call's arguments: %1 … %9, %{10} or %10 for later
+// arguments, and %0 for the number of arguments. A %rotate N line rotates the
+// arguments N places to the left (right if negative) for the rest of the
+// body. Macro-local labels (%%name) are renamed to a name that is unique to
+// the expansion (..@N.name). Returns the transformed source string.
+//
+// Each call replaces the line it was collected from, so source must be the
+// source passed to CollectMacroCalls. Calls are expanded in source order.
+// Macro bodies may invoke other macros: every expanded line is checked for a
+// call, which is expanded in place, until no calls remain. Expansion is
+// therefore deterministic, and expansion numbers follow the order in which
+// the expanded lines appear in the result.
+//
+// A chain of nested expansions deeper than maxDepth is reported as an error
+// naming the full chain, and the call that exceeds the limit is dropped.
+// Argument count errors in nested calls and invalid %rotate counts are
+// reported with the chain of enclosing macros. All errors are reported at the
+// line of the outermost call.
+func ReplaceMacroCallsWithDepth(source string, macroTable map[string]Macro, maxDepth int) (string, []PreProcessingError) {
+	result, _, errors := ReplaceMacroCallsWithOrigins(source, macroTable, maxDepth)
+	return result, errors
+}
+
+// ReplaceMacroCallsWithOrigins is ReplaceMacroCallsWithDepth that also
+// returns the origin of each line of the result: origins[n] is the 0-based
+// index of the line of source that line n was produced from. Every line of an
+// expansion maps to the line of its outermost call, so that an error or a
+// __LINE__ in a macro body points at the call site (FR-11.2.2).
+func ReplaceMacroCallsWithOrigins(source string, macroTable map[string]Macro, maxDepth int) (string, []int, []PreProcessingError) {
+	errors := make([]PreProcessingError, 0)
+
+	calls := make([]MacroCall, 0)
 	for _, macro := range macroTable {
-		for _, call := range macro.Calls {
-			expandedBody := macro.Body
+		calls = append(calls, macro.Calls...)
+	}
+	sort.Slice(calls, func(a, b int) bool { return calls[a].LineNumber < calls[b].LineNumber })
 
-			for i, arg := range call.Arguments {
-				placeholder := fmt.Sprintf("%%%d", i+1)
-				expandedBody = strings.ReplaceAll(expandedBody, placeholder, arg)
-			}
+	expander := &macroExpander{table: macroTable, maxDepth: maxDepth}
 
-			lines := strings.Split(expandedBody, "\n")
-			trimmedLines := make([]string, 0, len(lines))
-			for _, line := range lines {
-				trimmed := strings.TrimLeft(line, " \t")
-				if trimmed != "" {
-					trimmedLines = append(trimmedLines, trimmed)
-				}
-			}
-			expandedBody = strings.Join(trimmedLines, "\n")
+	lines := strings.Split(source, "\n")
+	expansions := make(map[int][]string, len(calls))
+	for _, call := range calls {
+		if call.LineNumber < 1 || call.LineNumber > len(lines) {
+			continue
+		}
 
-			expandedBody = fmt.Sprintf("\n; MACRO: %s\n%s\n", call.Name, expandedBody)
+		expander.messages = expander.messages[:0]
+		expander.aborted = false
+		expanded := expander.expand(call, nil)
 
-			// Per-value regex: depends on call name + arguments, compiled once per call (AR-6.4).
-			callPattern := `(?m)^[^\S\n]*` + regexp.QuoteMeta(call.Name) + `[^\S\n]+` + regexp.QuoteMeta(strings.Join(call.Arguments, ", ")) + `[^\S\n]*$`
-			source = regexp.MustCompile(callPattern).ReplaceAllString(source, expandedBody)
+		for _, message := range expander.messages {
+			errors = append(errors, PreProcessingError{Message: message, Line: call.LineNumber})
 		}
+		expansions[call.LineNumber-1] = append(append([]string{""}, expanded...), "")
 	}
 
 	// FR-2.5: Remove all %macro ... %endmacro definition blocks from the source
 	// after expansion.
-	macroBlockRegex := regexp.MustCompile(`(?ms)^\s*%macro\s+\w+\s*\d*\s*\n.*?%endmacro\s*$`)
-	source = macroBlockRegex.ReplaceAllString(source, "")
+	definitions := macroDefinitionLines(lines)
+
+	output := make([]string, 0, len(lines))
+	origins := make([]int, 0, len(lines))
+	for i, line := range lines {
+		if definitions[i] {
+			continue
+		}
+		expanded, ok := expansions[i]
+		if !ok {
+			expanded = []string{line}
+		}
+		for _, l := range expanded {
+			output = append(output, l)
+			origins = append(origins, i)
+		}
+	}
+
+	return strings.Join(output, "\n"), origins, errors
+}
+
+// macroExpander expands a macro call together with the calls in its body.
+// expansion numbers every expansion for macro-local labels and is shared by
+// all calls. aborted is set when the depth limit is exceeded, which stops the
+// remaining expansion of the outermost call: a recursive macro may invoke
+// itself more than once per level, so continuing would take exponential time.
+type macroExpander struct {
+	table     map[string]Macro
+	maxDepth  int
+	expansion int
+	aborted   bool
+	messages  []string
+}
+
+// expand returns the lines of the expansion of call, including the
+// traceability comment, with nested calls expanded. chain holds the names of
+// the enclosing expansions, outermost first.
+func (e *macroExpander) expand(call MacroCall, chain []string) []string {
+	chain = append(chain[:len(chain):len(chain)], call.Name)
+	if len(chain) > e.maxDepth {
+		e.messages = append(e.messages, fmt.Sprintf("macro expansion exceeds the depth limit of %d: %s",
+			e.maxDepth, strings.Join(chain, " → ")))
+		e.aborted = true
+		return nil
+	}
+
+	e.expansion++
+	body, messages := expandMacroCall(e.table[call.Name], call, e.expansion)
+	for _, message := range messages {
+		e.report(message, chain)
+	}
+
+	lines := make([]string, 0, len(body)+1)
+	lines = append(lines, "; MACRO: "+call.Name)
+	for _, line := range body {
+		if e.aborted {
+			break
+		}
+
+		nested, message, ok := e.nestedCall(line)
+		switch {
+		case !ok:
+			lines = append(lines, line)
+		case message != "":
+			e.messages = append(e.messages, fmt.Sprintf("%s (in expansion of %s)", message, strings.Join(chain, " → ")))
+		default:
+			lines = append(lines, e.expand(nested, chain)...)
+		}
+	}
+	return lines
+}
+
+// nestedCall returns the macro call on an expanded line. ok is false if the
+// line is not a call; a call with a wrong argument count is returned as an
+// error message.
+func (e *macroExpander) nestedCall(line string) (call MacroCall, message string, ok bool) {
+	name, argStr, ok := splitInvocation(line)
+	if !ok {
+		return MacroCall{}, "", false
+	}
+	macro, ok := e.table[name]
+	if !ok {
+		return MacroCall{}, "", false
+	}
+
+	args, message := macroCallArguments(macro, argStr)
+	return MacroCall{Name: macro.Name, Arguments: args}, message, true
+}
+
+// report records an error found while expanding the innermost macro of
+// chain. The message already names that macro, so the chain is only added
+// for nested expansions.
+func (e *macroExpander) report(message string, chain []string) {
+	if len(chain) > 1 {
+		message = fmt.Sprintf("%s (in expansion of %s)", message, strings.Join(chain, " → "))
+	}
+	e.messages = append(e.messages, message)
+}
+
+// expandMacroCall returns the lines of the expanded body of a single macro
+// call, without expanding nested calls, and any %rotate, %rep and %assign
+// errors. expansion is the unique number of this expansion and is used to
+// rename macro-local labels (FR-2.4.7).
+//
+// %rep blocks in the body are unrolled here rather than by HandleRepetitions,
+// so that a %rotate inside the block rotates the arguments once per
+// repetition (FR-2.4.10).
+func expandMacroCall(macro Macro, call MacroCall, expansion int) ([]string, []string) {
+	messages := make([]string, 0)
+	args := append([]string(nil), call.Arguments...)
+
+	body := strings.Split(macro.Body, "\n")
+	for i, line := range body {
+		body[i] = renameLocalLabels(line, expansion)
+	}
+
+	r := newRepeater(body, nil, fmt.Sprintf(" in macro '%s'", macro.Name))
+	r.prepare = func(line string, _ int) (string, bool) {
+		trimmed := strings.TrimLeft(substituteMacroArguments(line, args), " \t")
+
+		directive, rest, _ := splitDirective(trimmed)
+		if directive != "rotate" || !separatesOperands(rest) {
+			return trimmed, true
+		}
+		if !r.conditions.active() {
+			return "", false
+		}
+		count, err := evaluateExpression(strings.TrimSpace(stripComment(rest)), r.conditions.definitions, r.conditions.defined)
+		if err != nil {
+			messages = append(messages, fmt.Sprintf("invalid %%rotate count in macro '%s': %s", macro.Name, err.message))
+			return "", false
+		}
+		args = rotateArguments(args, count)
+		return "", false
+	}
+	r.run(0, len(r.lines))
 
-	return source
+	for _, e := range r.errors {
+		messages = append(messages, e.Message)
+	}
+
+	trimmedLines := make([]string, 0, len(r.output))
+	for _, line := range r.output {
+		if strings.TrimSpace(line) != "" {
+			trimmedLines = append(trimmedLines, line)
+		}
+	}
+
+	return trimmedLines, messages
+}
+
+// renameLocalLabels renames the macro-local labels (%%name) in line to
+// ..@N.name, where N is the expansion number (FR-2.4.7). Labels inside string
+// literals and comments are left as they are.
+func renameLocalLabels(line string, expansion int) string {
+	if !strings.Contains(line, "%%") {
+		return line
+	}
+
+	prefix := "..@" + strconv.Itoa(expansion) + "."
+	return rewriteTokens(line, func(t token) (string, bool) {
+		if t.kind != tokenLocal {
+			return "", false
+		}
+		return prefix + t.text[2:], true
+	})
+}
+
+// substituteMacroArguments replaces the parameter references %0, %N and %{N}
+// in line. A reference to a parameter that was not passed expands to
+// nothing. References inside string literals and comments are left as they
+// are, and %1 never matches the start of %10: each reference is a single
+// token.
+func substituteMacroArguments(line string, args []string) string {
+	if !strings.Contains(line, "%") {
+		return line
+	}
+
+	return rewriteTokens(line, func(t token) (string, bool) {
+		if t.kind != tokenParameter {
+			return "", false
+		}
+		index, _ := strconv.Atoi(strings.Trim(t.text[1:], "{}"))
+		switch {
+		case index == 0:
+			return strconv.Itoa(len(args)), true
+		case index <= len(args):
+			return args[index-1], true
+		}
+		return "", true
+	})
+}
+
+// rotateArguments rotates args count places to the left, or to the right if
+// count is negative.
+func rotateArguments(args []string, count int64) []string {
+	if len(args) == 0 {
+		return args
+	}
+	n := int(count % int64(len(args)))
+	if n < 0 {
+		n += len(args)
+	}
+	return append(args[n:len(args):len(args)], args[:n]...)
 }
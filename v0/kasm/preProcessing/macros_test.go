@@ -50,7 +50,7 @@ func TestPreProcessingMacroTable_SingleMacro(t *testing.T) {
     mov rax, %1
     mov rdi, %2
 %endmacro`
-	table := preProcessing.MacroTable(source)
+	table := mustMacroTable(t, source)
 
 	if len(table) != 1 {
 		t.Fatalf("expected 1 macro, got %d", len(table))
@@ -83,7 +83,7 @@ func TestPreProcessingMacroTable_MultipleMacros(t *testing.T) {
     mov rbx, %2
     mov rcx, %3
 %endmacro`
-	table := preProcessing.MacroTable(source)
+	table := mustMacroTable(t, source)
 
 	if len(table) != 2 {
 		t.Fatalf("expected 2 macros, got %d", len(table))
@@ -106,7 +106,7 @@ func TestPreProcessingMacroTable_MultipleMacros(t *testing.T) {
 
 func TestPreProcessingMacroTable_NoMacros(t *testing.T) {
 	source := `mov rax, 1`
-	table := preProcessing.MacroTable(source)
+	table := mustMacroTable(t, source)
 
 	if len(table) != 0 {
 		t.Errorf("expected 0 macros, got %d", len(table))
@@ -119,7 +119,7 @@ func TestPreProcessingMacroTable_ParameterNaming(t *testing.T) {
     mov rbx, %2
     mov rcx, %3
 %endmacro`
-	table := preProcessing.MacroTable(source)
+	table := mustMacroTable(t, source)
 	macro := table["test_macro"]
 
 	expectedParams := []string{"paramA", "paramB", "paramC"}
@@ -139,8 +139,8 @@ func TestPreProcessingCollectMacroCalls_SingleCall(t *testing.T) {
 %endmacro
 my_macro 1, 2`
 
-	table := preProcessing.MacroTable(source)
-	preProcessing.CollectMacroCalls(source, table)
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
 
 	macro := table["my_macro"]
 	if len(macro.Calls) != 1 {
@@ -167,8 +167,8 @@ func TestPreProcessingCollectMacroCalls_MultipleCalls(t *testing.T) {
 my_macro 1, 2
 my_macro 3, 4`
 
-	table := preProcessing.MacroTable(source)
-	preProcessing.CollectMacroCalls(source, table)
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
 
 	macro := table["my_macro"]
 	if len(macro.Calls) != 2 {
@@ -176,30 +176,45 @@ my_macro 3, 4`
 	}
 }
 
-func TestPreProcessingCollectMacroCalls_WrongArgCount_Panics(t *testing.T) {
+func TestPreProcessingCollectMacroCalls_WrongArgCount_ReportsError(t *testing.T) {
 	source := `%macro my_macro 2
     mov rax, %1
     mov rdi, %2
 %endmacro
 my_macro 1`
 
-	table := preProcessing.MacroTable(source)
+	table := mustMacroTable(t, source)
+	errs := preProcessing.CollectMacroCalls(source, table)
 
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatal("expected panic for wrong argument count")
-		}
-		msg, ok := r.(string)
-		if !ok {
-			t.Fatalf("expected string panic, got %T", r)
-		}
-		if !containsSubstring(msg, "expects 2 arguments, but got 1") {
-			t.Errorf("unexpected panic message: %s", msg)
-		}
-	}()
+	e := requireError(t, errs, "expects 2 arguments, but got 1")
+	if e.Line != 5 {
+		t.Errorf("expected error at line 5, got %d", e.Line)
+	}
+	if len(table["my_macro"].Calls) != 0 {
+		t.Errorf("expected the invalid call not to be collected, got %d call(s)", len(table["my_macro"].Calls))
+	}
+}
 
-	preProcessing.CollectMacroCalls(source, table)
+func TestPreProcessingCollectMacroCalls_ReportsAllErrors(t *testing.T) {
+	source := `%macro one 1
+    mov rax, %1
+%endmacro
+%macro two 2
+    mov rax, %1
+%endmacro
+one 1, 2
+two 1
+one 3`
+
+	table := mustMacroTable(t, source)
+	errs := preProcessing.CollectMacroCalls(source, table)
+
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 errors, got %d: %v", len(errs), errs)
+	}
+	if len(table["one"].Calls) != 1 {
+		t.Errorf("expected the valid call to be collected, got %d call(s)", len(table["one"].Calls))
+	}
 }
 
 func TestPreProcessingCollectMacroCalls_LineNumber(t *testing.T) {
@@ -209,8 +224,8 @@ func TestPreProcessingCollectMacroCalls_LineNumber(t *testing.T) {
 ; comment line
 my_macro 42`
 
-	table := preProcessing.MacroTable(source)
-	preProcessing.CollectMacroCalls(source, table)
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
 
 	macro := table["my_macro"]
 	if len(macro.Calls) != 1 {
@@ -230,9 +245,9 @@ func TestPreProcessingReplaceMacroCalls_BasicExpansion(t *testing.T) {
 %endmacro
 my_macro 1, 2`
 
-	table := preProcessing.MacroTable(source)
-	preProcessing.CollectMacroCalls(source, table)
-	result := preProcessing.ReplaceMacroCalls(source, table)
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
 
 	if containsSubstring(result, "my_macro 1, 2") {
 		t.Error("expected macro call to be replaced")
@@ -254,9 +269,9 @@ func TestPreProcessingReplaceMacroCalls_StripsIndentation(t *testing.T) {
 %endmacro
 my_macro 42`
 
-	table := preProcessing.MacroTable(source)
-	preProcessing.CollectMacroCalls(source, table)
-	result := preProcessing.ReplaceMacroCalls(source, table)
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
 
 	if containsSubstring(result, "    mov rax, 42") {
 		t.Error("expected leading indentation to be stripped from expanded body")
@@ -266,29 +281,479 @@ my_macro 42`
 	}
 }
 
+// --- FR-2.4.7: Macro-local labels ---
+
+func TestPreProcessingReplaceMacroCalls_LocalLabelsAreUniquePerExpansion(t *testing.T) {
+	source := `%macro spin 1
+    mov rcx, %1
+%%loop:
+    jmp %%loop
+%endmacro
+spin 10
+spin 20`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
+
+	if containsSubstring(result, "%%") {
+		t.Errorf("expected all local labels to be renamed, got:\n%s", result)
+	}
+	for _, expected := range []string{"..@1.loop:\njmp ..@1.loop", "..@2.loop:\njmp ..@2.loop"} {
+		if !containsSubstring(result, expected) {
+			t.Errorf("expected %q in expanded output, got:\n%s", expected, result)
+		}
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_IdenticalCallsGetDistinctLabels(t *testing.T) {
+	source := `%macro spin 1
+%%loop:
+    jmp %%loop
+%endmacro
+spin 10
+spin 10`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
+
+	if strings.Count(result, "; MACRO: spin") != 2 {
+		t.Fatalf("expected two expansions, got:\n%s", result)
+	}
+	if !containsSubstring(result, "..@1.loop:") || !containsSubstring(result, "..@2.loop:") {
+		t.Errorf("expected each identical call to get its own labels, got:\n%s", result)
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_LocalLabelsAreNumberedInSourceOrder(t *testing.T) {
+	source := `%macro b_macro 1
+%%b:
+%endmacro
+%macro a_macro 1
+%%a:
+%endmacro
+b_macro 1
+a_macro 1`
+
+	for i := 0; i < 10; i++ {
+		table := mustMacroTable(t, source)
+		mustCollectMacroCalls(t, source, table)
+		result := mustReplaceMacroCalls(t, source, table)
+
+		if !containsSubstring(result, "..@1.b:") || !containsSubstring(result, "..@2.a:") {
+			t.Fatalf("expected expansions to be numbered in source order, got:\n%s", result)
+		}
+	}
+}
+
 // --- FR-2.2.6: %macro without %endmacro ---
 
-func TestPreProcessingMacroTable_NoEndmacro_Panics(t *testing.T) {
-	source := `%macro my_macro 1
+func TestPreProcessingMacroTable_NoEndmacro_ReportsError(t *testing.T) {
+	source := `mov rax, 0
+%macro my_macro 1
     mov rax, %1
 `
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatal("expected panic for macro without matching endmacro")
+	table, errs := preProcessing.MacroTable(source)
+
+	e := requireError(t, errs, "'my_macro' has no matching %endmacro")
+	if e.Line != 2 {
+		t.Errorf("expected error at line 2, got %d", e.Line)
+	}
+	if _, exists := table["my_macro"]; exists {
+		t.Error("expected the unterminated macro to be left out of the table")
+	}
+}
+
+// --- FR-2.2.7: Parameter specifications ---
+
+func TestPreProcessingMacroTable_MoreThanNineParameters(t *testing.T) {
+	source := `%macro many 12
+    mov rax, %{12}
+%endmacro`
+	table := mustMacroTable(t, source)
+
+	macro := table["many"]
+	if macro.MinArguments != 12 || macro.MaxArguments != 12 || len(macro.Parameters) != 12 {
+		t.Errorf("expected 12 parameters, got %d-%d (%d declared)", macro.MinArguments, macro.MaxArguments, len(macro.Parameters))
+	}
+}
+
+func TestPreProcessingMacroTable_ParameterNamesBeyondZ(t *testing.T) {
+	table := mustMacroTable(t, "%macro wide 28\n%endmacro")
+
+	for _, name := range []string{"paramA", "paramZ", "paramAA", "paramAB"} {
+		if _, ok := table["wide"].Parameters[name]; !ok {
+			t.Errorf("expected parameter '%s' to be present", name)
+		}
+	}
+}
+
+func TestPreProcessingMacroTable_RangeAndDefaults(t *testing.T) {
+	source := `%macro syscall3 1-4 0, 0 ; number, then up to three arguments
+%endmacro
+%macro log 1-*
+%endmacro
+%macro nothing
+%endmacro`
+	table := mustMacroTable(t, source)
+
+	syscall3 := table["syscall3"]
+	if syscall3.MinArguments != 1 || syscall3.MaxArguments != 4 {
+		t.Errorf("expected syscall3 to take 1-4 arguments, got %d-%d", syscall3.MinArguments, syscall3.MaxArguments)
+	}
+	if len(syscall3.Defaults) != 2 || syscall3.Defaults[0] != "0" || syscall3.Defaults[1] != "0" {
+		t.Errorf("expected defaults [0 0], got %v", syscall3.Defaults)
+	}
+	if table["log"].MinArguments != 1 || table["log"].MaxArguments != -1 {
+		t.Errorf("expected log to be variadic, got %d-%d", table["log"].MinArguments, table["log"].MaxArguments)
+	}
+	if table["nothing"].MaxArguments != 0 || len(table["nothing"].Parameters) != 0 {
+		t.Errorf("expected nothing to take no arguments, got %d", table["nothing"].MaxArguments)
+	}
+}
+
+func TestPreProcessingMacroTable_InvalidSpecification_ReportsError(t *testing.T) {
+	source := `%macro a x
+%endmacro
+%macro b 3-1
+%endmacro
+%macro c 1-2 7, 8
+%endmacro
+%macro d 1-* 7
+%endmacro`
+	table, errs := preProcessing.MacroTable(source)
+
+	expected := []struct {
+		message string
+		line    int
+	}{
+		{"invalid parameter specification 'x' for macro 'a'", 1},
+		{"invalid parameter range 3-1 for macro 'b'", 3},
+		{"too many default values for macro 'c'", 5},
+		{"too many default values for macro 'd'", 7},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, e := range expected {
+		if errs[i].Message != e.message || errs[i].Line != e.line {
+			t.Errorf("expected %q at line %d, got %v", e.message, e.line, errs[i])
+		}
+	}
+	if len(table) != 0 {
+		t.Errorf("expected invalid macros to be left out of the table, got %d", len(table))
+	}
+}
+
+// --- FR-2.2.8: Conditional definitions ---
+
+func TestPreProcessingMacroTable_OnlyActiveBranches(t *testing.T) {
+	source := `%ifdef DEBUG
+%macro log 1
+    call debug_log
+%endmacro
+%else
+%macro log 1
+    nop
+%endmacro
+%endif`
+
+	table := mustMacroTable(t, source)
+	if body := table["log"].Body; body != "    nop\n" {
+		t.Errorf("expected the %%else definition without DEBUG, got %q", body)
+	}
+
+	commandLine, err := preProcessing.CommandLineSymbolsNew([]string{"DEBUG"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	table, errs := preProcessing.MacroTableWithCommandLine(source, commandLine)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if body := table["log"].Body; body != "    call debug_log\n" {
+		t.Errorf("expected the %%ifdef definition with -D DEBUG, got %q", body)
+	}
+}
+
+func TestPreProcessingMacroTable_Redefinition(t *testing.T) {
+	source := `%macro push2 2
+    push %1
+%endmacro
+%macro push2 2
+    push %2
+%endmacro
+%macro zero 0
+    xor rax, rax
+%endmacro
+%macro zero 1
+    xor %1, %1
+%endmacro`
+	table, errs := preProcessing.MacroTable(source)
+
+	if len(errs) != 1 || errs[0].Message != "duplicate %macro 'push2' with 2 arguments" || errs[0].Line != 4 || errs[0].Previous != 1 {
+		t.Fatalf("expected a duplicate error at line 4, got %v", errs)
+	}
+	if table["push2"].Body != "    push %1\n" {
+		t.Errorf("expected the first definition to be kept, got %q", table["push2"].Body)
+	}
+	if table["zero"].MinArguments != 1 {
+		t.Errorf("expected a definition with other counts to replace the previous one, got %d", table["zero"].MinArguments)
+	}
+}
+
+func TestPreProcessingMacroTable_HugeParameterCount_ReportsError(t *testing.T) {
+	source := `%macro m 1000000000
+%endmacro
+%macro n 1-99999999999999999999
+%endmacro
+%macro o 1024
+%endmacro`
+	table, errs := preProcessing.MacroTable(source)
+
+	expected := []struct {
+		message string
+		line    int
+	}{
+		{"parameter count 1000000000 for macro 'm' exceeds the limit of 1024", 1},
+		{"parameter count 99999999999999999999 for macro 'n' exceeds the limit of 1024", 3},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d errors, got %d: %v", len(expected), len(errs), errs)
+	}
+	for i, e := range expected {
+		if errs[i].Message != e.message || errs[i].Line != e.line {
+			t.Errorf("expected %q at line %d, got %v", e.message, e.line, errs[i])
 		}
-		msg, ok := r.(string)
-		if !ok {
-			t.Fatal("expected panic message to be a string")
+	}
+	if _, ok := table["o"]; !ok || len(table) != 1 {
+		t.Errorf("expected only 'o' in the table, got %v", table)
+	}
+}
+
+// --- FR-2.3.6: Argument splitting and counts ---
+
+func TestPreProcessingCollectMacroCalls_SplitsRespectingBracketsAndStrings(t *testing.T) {
+	source := `%macro four 4
+%endmacro
+four [rbx + rcx*8], "a, b", 'c,d', {1, 2} ; not, an, argument`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+
+	calls := table["four"].Calls
+	if len(calls) != 1 {
+		t.Fatalf("expected 1 call, got %d", len(calls))
+	}
+	expected := []string{"[rbx + rcx*8]", `"a, b"`, "'c,d'", "1, 2"}
+	for i, arg := range expected {
+		if calls[0].Arguments[i] != arg {
+			t.Errorf("argument %d: expected %q, got %q", i+1, arg, calls[0].Arguments[i])
 		}
-		if !containsSubstring(msg, "my_macro") {
-			t.Errorf("expected panic message to contain macro name, got: %s", msg)
+	}
+}
+
+func TestPreProcessingCollectMacroCalls_WithoutArguments(t *testing.T) {
+	source := `%macro save
+    mov rax, 1
+%endmacro
+save
+save:
+saved`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+
+	calls := table["save"].Calls
+	if len(calls) != 1 || calls[0].LineNumber != 4 {
+		t.Errorf("expected a single call at line 4, got %v", calls)
+	}
+}
+
+func TestPreProcessingCollectMacroCalls_AppliesDefaults(t *testing.T) {
+	source := `%macro syscall3 1-4 0, 0
+%endmacro
+syscall3 60
+syscall3 1, 1, msg
+syscall3 1, 2, 3, 4`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+
+	calls := table["syscall3"].Calls
+	expected := [][]string{{"60", "0", "0"}, {"1", "1", "msg"}, {"1", "2", "3", "4"}}
+	if len(calls) != len(expected) {
+		t.Fatalf("expected %d calls, got %d", len(expected), len(calls))
+	}
+	for i, args := range expected {
+		if strings.Join(calls[i].Arguments, "|") != strings.Join(args, "|") {
+			t.Errorf("call %d: expected arguments %v, got %v", i+1, args, calls[i].Arguments)
 		}
-		if !containsSubstring(msg, "endmacro") {
-			t.Errorf("expected panic message to mention endmacro, got: %s", msg)
+	}
+}
+
+func TestPreProcessingCollectMacroCalls_RangeViolations_ReportErrors(t *testing.T) {
+	source := `%macro ranged 2-3
+%endmacro
+%macro log 1-*
+%endmacro
+ranged 1
+ranged 1, 2, 3, 4
+log
+log a, b, c, d, e`
+
+	table := mustMacroTable(t, source)
+	errs := preProcessing.CollectMacroCalls(source, table)
+
+	for _, message := range []string{
+		"macro 'ranged' expects 2 to 3 arguments, but got 1",
+		"macro 'ranged' expects 2 to 3 arguments, but got 4",
+		"macro 'log' expects at least 1 arguments, but got 0",
+	} {
+		requireError(t, errs, message)
+	}
+	if len(errs) != 3 {
+		t.Errorf("expected 3 errors, got %d: %v", len(errs), errs)
+	}
+	if len(table["log"].Calls) != 1 || len(table["log"].Calls[0].Arguments) != 5 {
+		t.Errorf("expected the variadic call to be collected with 5 arguments, got %v", table["log"].Calls)
+	}
+}
+
+// --- FR-2.4.9: Parameter references and %rotate ---
+
+func TestPreProcessingReplaceMacroCalls_ArgumentCountAndBracedReferences(t *testing.T) {
+	source := `%macro many 1-*
+    mov rax, %0
+    mov rbx, %{10}
+    mov rcx, %10
+    mov rdx, %1
+    mov rsi, %{11}
+%endmacro
+many a, b, c, d, e, f, g, h, i, j`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
+
+	for _, expected := range []string{"mov rax, 10", "mov rbx, j", "mov rcx, j", "mov rdx, a\n", "mov rsi, \n"} {
+		if !containsSubstring(result, expected) {
+			t.Errorf("expected %q in expanded output, got:\n%s", expected, result)
 		}
-	}()
-	preProcessing.MacroTable(source)
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_Rotate(t *testing.T) {
+	source := `%macro push3 3
+    push %1
+%rotate 1
+    push %1
+    %rotate -2
+    push %1
+%endmacro
+push3 rax, rbx, rcx`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
+
+	if !containsSubstring(result, "push rax\npush rbx\npush rcx") {
+		t.Errorf("expected arguments to rotate between lines, got:\n%s", result)
+	}
+	if containsSubstring(result, "%rotate") {
+		t.Errorf("expected %%rotate lines to be removed, got:\n%s", result)
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_InvalidRotate_ReportsError(t *testing.T) {
+	source := `%macro bad 1
+%rotate x
+%endmacro
+mov rax, 1
+bad 1`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	_, errs := preProcessing.ReplaceMacroCalls(source, table)
+
+	e := requireError(t, errs, "invalid %rotate count in macro 'bad'")
+	if e.Line != 5 {
+		t.Errorf("expected error at the call on line 5, got %d", e.Line)
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_ArgumentSpacingIsIrrelevant(t *testing.T) {
+	source := `%macro pair 2
+    mov %1, %2
+%endmacro
+pair rax,1
+    pair   rbx ,   2`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
+
+	if !containsSubstring(result, "mov rax, 1") || !containsSubstring(result, "mov rbx, 2") {
+		t.Errorf("expected both calls to be expanded, got:\n%s", result)
+	}
+}
+
+// --- FR-2.4.10: %rep and %assign in macro bodies ---
+
+func TestPreProcessingReplaceMacroCalls_RepWithRotate(t *testing.T) {
+	source := `%macro pushall 1-*
+%rep %0
+    push %1
+%rotate 1
+%endrep
+%endmacro
+pushall rax, rbx, rcx`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
+
+	if !containsSubstring(result, "; MACRO: pushall\npush rax\npush rbx\npush rcx\n") {
+		t.Errorf("expected one push per argument, got:\n%s", result)
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_AssignInBody(t *testing.T) {
+	source := `%macro table 1
+%assign n 0
+%rep %1
+dq n * 8
+%assign n n+1
+%endrep
+%endmacro
+table 3`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
+
+	if !containsSubstring(result, "%define n 0\ndq 0 * 8\n%undef n\n%define n 1\ndq 1 * 8\n%undef n\n%define n 2\ndq 2 * 8\n") {
+		t.Errorf("expected the assigned values to be substituted per repetition, got:\n%s", result)
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_RepError(t *testing.T) {
+	source := `%macro bad 1
+%rep %1
+nop
+%endrep
+%endmacro
+bad -2`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	_, errs := preProcessing.ReplaceMacroCalls(source, table)
+
+	e := requireError(t, errs, "%rep count in macro 'bad' must not be negative, got -2")
+	if e.Line != 6 {
+		t.Errorf("expected error at the call on line 6, got %d", e.Line)
+	}
 }
 
 // --- FR-2.5: Macro definition removal ---
@@ -299,9 +764,9 @@ func TestPreProcessingReplaceMacroCalls_RemovesDefinitionBlock(t *testing.T) {
 %endmacro
 my_macro 42`
 
-	table := preProcessing.MacroTable(source)
-	preProcessing.CollectMacroCalls(source, table)
-	result := preProcessing.ReplaceMacroCalls(source, table)
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
 
 	if containsSubstring(result, "%macro") {
 		t.Error("expected macro definition to be removed from output")
@@ -320,9 +785,9 @@ func TestPreProcessingReplaceMacroCalls_RemovesUnusedDefinition(t *testing.T) {
 %endmacro
 mov rbx, 1`
 
-	table := preProcessing.MacroTable(source)
-	preProcessing.CollectMacroCalls(source, table)
-	result := preProcessing.ReplaceMacroCalls(source, table)
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
 
 	if containsSubstring(result, "%macro") {
 		t.Error("expected unused macro definition to be removed (FR-2.5.3)")
@@ -345,9 +810,9 @@ func TestPreProcessingReplaceMacroCalls_RemovesMultipleDefinitions(t *testing.T)
 mac_a 1
 mac_b 2`
 
-	table := preProcessing.MacroTable(source)
-	preProcessing.CollectMacroCalls(source, table)
-	result := preProcessing.ReplaceMacroCalls(source, table)
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
 
 	if containsSubstring(result, "%macro") {
 		t.Error("expected all macro definitions to be removed")
@@ -360,6 +825,326 @@ mac_b 2`
 	}
 }
 
+// --- FR-2.6: Nested expansion ---
+
+func TestPreProcessingReplaceMacroCalls_NestedCalls(t *testing.T) {
+	source := `%macro outer 2
+    inner %1
+    inner %2
+%endmacro
+%macro inner 1
+    mov rax, %1
+%endmacro
+outer 1, 2`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
+
+	expected := "; MACRO: outer\n; MACRO: inner\nmov rax, 1\n; MACRO: inner\nmov rax, 2\n"
+	if !containsSubstring(result, expected) {
+		t.Errorf("expected nested expansion %q, got:\n%s", expected, result)
+	}
+}
+
+func TestPreProcessingCollectMacroCalls_IgnoresCallsInDefinitions(t *testing.T) {
+	source := `%macro outer 0
+    inner 1
+%endmacro
+%macro inner 1
+%endmacro
+inner 2`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+
+	calls := table["inner"].Calls
+	if len(calls) != 1 || calls[0].LineNumber != 6 {
+		t.Errorf("expected only the call at line 6 to be collected, got %v", calls)
+	}
+}
+
+func TestPreProcessingCollectMacroCalls_IgnoresCallsInInactiveBranches(t *testing.T) {
+	source := `%macro m 1
+    mov rax, %1
+%endmacro
+%ifdef NOPE
+m 1, 2
+%else
+m 3
+%endif`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+
+	calls := table["m"].Calls
+	if len(calls) != 1 || calls[0].LineNumber != 7 {
+		t.Errorf("expected only the call at line 7 to be collected, got %v", calls)
+	}
+}
+
+func TestPreProcessingCollectMacroCallsWithCommandLine_ChecksCallsInActiveBranches(t *testing.T) {
+	source := `%macro m 1
+%endmacro
+%ifdef DEBUG
+m 1, 2
+%endif`
+
+	commandLine, err := preProcessing.CommandLineSymbolsNew([]string{"DEBUG"}, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	table := mustMacroTable(t, source)
+	errs := preProcessing.CollectMacroCallsWithCommandLine(source, table, commandLine)
+
+	e := requireError(t, errs, "macro 'm' expects 1 arguments, but got 2")
+	if e.Line != 4 {
+		t.Errorf("expected error at line 4, got %d", e.Line)
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_NestedIsDeterministic(t *testing.T) {
+	source := `%macro a 0
+    b
+    c
+%endmacro
+%macro b 0
+%%b:
+    d
+%endmacro
+%macro c 0
+%%c:
+    d
+%endmacro
+%macro d 0
+%%d:
+%endmacro
+a
+d`
+
+	var first string
+	for i := 0; i < 20; i++ {
+		table := mustMacroTable(t, source)
+		mustCollectMacroCalls(t, source, table)
+		result := mustReplaceMacroCalls(t, source, table)
+		if i == 0 {
+			first = result
+			continue
+		}
+		if result != first {
+			t.Fatalf("expected identical output on every run, got:\n%s\nand:\n%s", first, result)
+		}
+	}
+
+	for _, label := range []string{"..@2.b:", "..@3.d:", "..@4.c:", "..@5.d:", "..@6.d:"} {
+		if !containsSubstring(first, label) {
+			t.Errorf("expected %q in expanded output, got:\n%s", label, first)
+		}
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_Recursion_ReportsChain(t *testing.T) {
+	source := `%macro ping 0
+    pong
+%endmacro
+%macro pong 0
+    ping
+%endmacro
+mov rax, 1
+ping`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	_, errs := preProcessing.ReplaceMacroCallsWithDepth(source, table, 3)
+
+	if len(errs) != 1 {
+		t.Fatalf("expected 1 error, got %d: %v", len(errs), errs)
+	}
+	expected := "macro expansion exceeds the depth limit of 3: ping → pong → ping → pong"
+	if errs[0].Message != expected || errs[0].Line != 8 {
+		t.Errorf("expected %q at line 8, got %v", expected, errs[0])
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_DefaultDepthLimit(t *testing.T) {
+	source := `%macro forever 1
+    forever %1
+%endmacro
+forever 1`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	_, errs := preProcessing.ReplaceMacroCalls(source, table)
+
+	e := requireError(t, errs, fmt.Sprintf("depth limit of %d", preProcessing.DefaultMacroExpansionDepth))
+	if strings.Count(e.Message, "forever") != preProcessing.DefaultMacroExpansionDepth+1 {
+		t.Errorf("expected the full chain in the error, got: %s", e.Message)
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_BranchingRecursionStops(t *testing.T) {
+	source := `%macro twice 0
+    twice
+    twice
+%endmacro
+twice`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	_, errs := preProcessing.ReplaceMacroCalls(source, table)
+
+	if len(errs) != 1 {
+		t.Errorf("expected expansion to stop at the first error, got %d error(s)", len(errs))
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_NestedArgumentCount_ReportsChain(t *testing.T) {
+	source := `%macro outer 0
+    middle
+%endmacro
+%macro middle 0
+    inner 1, 2
+%endmacro
+%macro inner 1
+%endmacro
+outer`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	_, errs := preProcessing.ReplaceMacroCalls(source, table)
+
+	e := requireError(t, errs, "macro 'inner' expects 1 arguments, but got 2 (in expansion of outer → middle)")
+	if e.Line != 9 {
+		t.Errorf("expected error at the outermost call on line 9, got %d", e.Line)
+	}
+}
+
+// --- FR-11.2.2: Expansion origins ---
+
+func TestPreProcessingReplaceMacroCallsWithOrigins(t *testing.T) {
+	source := `%macro outer 0
+    inner
+%endmacro
+%macro inner 0
+    nop
+%endmacro
+start:
+outer`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result, origins, errs := preProcessing.ReplaceMacroCallsWithOrigins(source, table, preProcessing.DefaultMacroExpansionDepth)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	lines := strings.Split(result, "\n")
+	expectedLines := []string{"start:", "", "; MACRO: outer", "; MACRO: inner", "nop", ""}
+	expectedOrigins := []int{6, 7, 7, 7, 7, 7}
+	if strings.Join(lines, "|") != strings.Join(expectedLines, "|") {
+		t.Fatalf("expected lines %q, got %q", expectedLines, lines)
+	}
+	for i, origin := range origins {
+		if origin != expectedOrigins[i] {
+			t.Errorf("expected line %d to originate from line %d, got %d", i, expectedOrigins[i], origin)
+		}
+	}
+}
+
+// --- FR-13: Token-based recognition ---
+
+func TestPreProcessingReplaceMacroCalls_StringsAndCommentsAreNotSubstituted(t *testing.T) {
+	source := `%macro show 1
+%%label: db "%1 at %%label", 0 ; prints %1
+    mov rax, %1
+%endmacro
+show 5`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
+
+	for _, expected := range []string{`..@1.label: db "%1 at %%label", 0 ; prints %1`, "mov rax, 5"} {
+		if !containsSubstring(result, expected) {
+			t.Errorf("expected %q in expanded output, got:\n%s", expected, result)
+		}
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_TenthParameterIsNotFirst(t *testing.T) {
+	source := `%macro ten 10
+    dq %10, %1, %{1}0
+%endmacro
+ten a, b, c, d, e, f, g, h, i, j`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
+
+	if !containsSubstring(result, "dq j, a, a0") {
+		t.Errorf("expected 'dq j, a, a0' in expanded output, got:\n%s", result)
+	}
+}
+
+func TestPreProcessingCollectMacroCalls_OnlyStandaloneNamesAreCalls(t *testing.T) {
+	source := `%macro exit 0
+    nop
+%endmacro
+; exit
+db "exit"
+exit:
+exit.code equ 1
+exit ; leave`
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+
+	calls := table["exit"].Calls
+	if len(calls) != 1 || calls[0].LineNumber != 8 {
+		t.Fatalf("expected a single call on line 8, got %v", calls)
+	}
+}
+
+func TestPreProcessingCollectMacroCalls_ErrorsInSourceOrder(t *testing.T) {
+	source := `%macro b 1
+%endmacro
+%macro a 1
+%endmacro
+b
+a`
+
+	table := mustMacroTable(t, source)
+	errs := preProcessing.CollectMacroCalls(source, table)
+
+	if len(errs) != 2 || errs[0].Line != 5 || errs[1].Line != 6 {
+		t.Fatalf("expected errors on lines 5 and 6, got %v", errs)
+	}
+}
+
+func TestPreProcessingReplaceMacroCalls_LargeSource(t *testing.T) {
+	const macros, calls = 2000, 20000
+
+	var sb strings.Builder
+	for i := 0; i < macros; i++ {
+		sb.WriteString(fmt.Sprintf("%%macro mac_%d 1\n    mov rax, %%1\n%%endmacro\n", i))
+	}
+	for i := 0; i < calls; i++ {
+		sb.WriteString(fmt.Sprintf("mac_%d %d\n", i%macros, i))
+	}
+	source := sb.String()
+
+	table := mustMacroTable(t, source)
+	mustCollectMacroCalls(t, source, table)
+	result := mustReplaceMacroCalls(t, source, table)
+
+	if count := strings.Count(result, "; MACRO: "); count != calls {
+		t.Fatalf("expected %d expansions, got %d", calls, count)
+	}
+	if !containsSubstring(result, fmt.Sprintf("mov rax, %d", calls-1)) {
+		t.Errorf("expected the last call to be expanded")
+	}
+}
+
 // --- helpers ---
 
 func containsSubstring(s, substr string) bool {
@@ -476,11 +1261,11 @@ func BenchmarkPreProcessingCollectMacroCalls_NoCalls(b *testing.B) {
     mov rdi, %2
 %endmacro
 mov rax, 1`
-	table := preProcessing.MacroTable(source)
+	table, _ := preProcessing.MacroTable(source)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		// Reset calls each iteration by rebuilding the table
-		t2 := preProcessing.MacroTable(source)
+		t2, _ := preProcessing.MacroTable(source)
 		preProcessing.CollectMacroCalls(source, t2)
 		_ = t2
 	}
@@ -495,7 +1280,7 @@ func BenchmarkPreProcessingCollectMacroCalls_SingleCall(b *testing.B) {
 my_macro 1, 2`
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		table := preProcessing.MacroTable(source)
+		table, _ := preProcessing.MacroTable(source)
 		preProcessing.CollectMacroCalls(source, table)
 	}
 }
@@ -509,7 +1294,7 @@ func BenchmarkPreProcessingCollectMacroCalls_ManyCalls(b *testing.B) {
 	source := sb.String()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		table := preProcessing.MacroTable(source)
+		table, _ := preProcessing.MacroTable(source)
 		preProcessing.CollectMacroCalls(source, table)
 	}
 }
@@ -527,7 +1312,23 @@ func BenchmarkPreProcessingCollectMacroCalls_MultipleMacros(b *testing.B) {
 	source := sb.String()
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
-		table := preProcessing.MacroTable(source)
+		table, _ := preProcessing.MacroTable(source)
+		preProcessing.CollectMacroCalls(source, table)
+	}
+}
+
+func BenchmarkPreProcessingCollectMacroCalls_LargeSource(b *testing.B) {
+	var sb strings.Builder
+	for i := 0; i < 500; i++ {
+		sb.WriteString(fmt.Sprintf("%%macro mac_%d 1\n    mov rax, %%1\n%%endmacro\n", i))
+	}
+	for i := 0; i < 5000; i++ {
+		sb.WriteString(fmt.Sprintf("mac_%d %d\n", i%500, i))
+	}
+	source := sb.String()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		table, _ := preProcessing.MacroTable(source)
 		preProcessing.CollectMacroCalls(source, table)
 	}
 }
@@ -540,7 +1341,7 @@ func BenchmarkPreProcessingReplaceMacroCalls_SingleCall(b *testing.B) {
     mov rdi, %2
 %endmacro
 my_macro 1, 2`
-	table := preProcessing.MacroTable(source)
+	table, _ := preProcessing.MacroTable(source)
 	preProcessing.CollectMacroCalls(source, table)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -555,7 +1356,7 @@ func BenchmarkPreProcessingReplaceMacroCalls_ManyCalls(b *testing.B) {
 		sb.WriteString(fmt.Sprintf("my_macro %d, %d\n", i, i+1))
 	}
 	source := sb.String()
-	table := preProcessing.MacroTable(source)
+	table, _ := preProcessing.MacroTable(source)
 	preProcessing.CollectMacroCalls(source, table)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -571,7 +1372,7 @@ func BenchmarkPreProcessingReplaceMacroCalls_LargeBody(b *testing.B) {
 	}
 	body.WriteString("%endmacro\nbig_macro rax, rbx\n")
 	source := body.String()
-	table := preProcessing.MacroTable(source)
+	table, _ := preProcessing.MacroTable(source)
 	preProcessing.CollectMacroCalls(source, table)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
@@ -588,10 +1389,51 @@ func BenchmarkPreProcessingReplaceMacroCalls_MultipleMacros(b *testing.B) {
 		sb.WriteString(fmt.Sprintf("mac_%d %d\n", i, i*10))
 	}
 	source := sb.String()
-	table := preProcessing.MacroTable(source)
+	table, _ := preProcessing.MacroTable(source)
 	preProcessing.CollectMacroCalls(source, table)
 	b.ResetTimer()
 	for i := 0; i < b.N; i++ {
 		preProcessing.ReplaceMacroCalls(source, table)
 	}
 }
+
+// requireError asserts that errs contains an error whose message contains
+// substr and returns it.
+func requireError(t *testing.T, errs []preProcessing.PreProcessingError, substr string) preProcessing.PreProcessingError {
+	t.Helper()
+	for _, e := range errs {
+		if containsSubstring(e.Message, substr) {
+			return e
+		}
+	}
+	t.Fatalf("expected an error containing %q, got: %v", substr, errs)
+	return preProcessing.PreProcessingError{}
+}
+
+// mustMacroTable builds the macro table and fails the test on pre-processing errors.
+func mustMacroTable(t *testing.T, source string) map[string]preProcessing.Macro {
+	t.Helper()
+	table, errs := preProcessing.MacroTable(source)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected pre-processing errors: %v", errs)
+	}
+	return table
+}
+
+// mustCollectMacroCalls collects macro calls and fails the test on pre-processing errors.
+func mustCollectMacroCalls(t *testing.T, source string, table map[string]preProcessing.Macro) {
+	t.Helper()
+	if errs := preProcessing.CollectMacroCalls(source, table); len(errs) != 0 {
+		t.Fatalf("unexpected pre-processing errors: %v", errs)
+	}
+}
+
+// mustReplaceMacroCalls expands macro calls and fails the test on pre-processing errors.
+func mustReplaceMacroCalls(t *testing.T, source string, table map[string]preProcessing.Macro) string {
+	t.Helper()
+	result, errs := preProcessing.ReplaceMacroCalls(source, table)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected pre-processing errors: %v", errs)
+	}
+	return result
+}
@@ -0,0 +1,491 @@
+package preProcessing
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// MaxRepetitions is the largest count a single %rep block accepts. It guards
+// against a mistyped count producing an unbounded amount of source.
+const MaxRepetitions = 1 << 20
+
+// repetitionDirectives lists the directives handled by the repetition engine,
+// without the leading '%' (FR-8.1, FR-14.6).
+var repetitionDirectives = map[string]bool{
+	"assign": true, "rep": true, "endrep": true, "exitrep": true,
+	"strlen": true, "substr": true, "strcat": true,
+}
+
+// stringDirectives lists the string functions that also have a directive
+// form, which binds the result to a name (FR-14.6).
+var stringDirectives = map[string]bool{"strlen": true, "substr": true, "strcat": true}
+
+// HandleRepetitions unrolls %rep blocks and evaluates %assign variables
+// (FR-8):
+//
+//	%assign i 0
+//	%rep 256
+//	    isr_stub i              ; i is replaced with 0, 1, … 255
+//	%assign i i+1
+//	%endrep
+//
+// A %rep block is repeated the number of times given by its count, a constant
+// expression (FR-4.7); %exitrep in an active branch ends the innermost block
+// early. %assign evaluates its expression when the directive is reached and
+// binds the result to a name, which may be reassigned at any time. Each
+// assigned name is replaced with its current value wherever it appears as a
+// whole word, except in directives other than %if and %elif.
+//
+// The source is evaluated top to bottom with the same conditional and
+// %define tracking as HandleConditionals, so %assign, %rep and %exitrep are
+// ignored in inactive branches and their expressions may use the values of
+// %define symbols. definedSymbols seeds the set of defined symbols. The
+// conditional directives themselves are left in place for
+// HandleConditionals. %macro … %endmacro blocks are copied unchanged: %rep
+// and %assign in a macro body are evaluated when the macro is expanded
+// (FR-2.4.10).
+//
+// The directive forms of %strlen, %substr and %strcat (FR-14.6) bind a name
+// like %assign does:
+//
+//	%strlen len "kernel"        ; len is 6
+//	%substr first "kernel" 1,3  ; first is "ker"
+//	%strcat entry "isr_", "14"  ; entry is "isr_14"
+//
+// The %rep, %endrep and %exitrep lines are removed. Each %assign and string
+// directive is replaced with a %define of its value, preceded by an %undef
+// if the name was already defined, so that the conditional and define phases see the name as defined
+// from that line on. The result is returned together with the
+// origin of each of its lines: origins[n] is the 0-based index of the line of
+// source that line n was produced from, so every repetition of a line maps
+// back to the same template line.
+//
+// Malformed directives, unbalanced blocks and invalid counts are reported as
+// errors; an error inside a repeated block is reported once.
+func HandleRepetitions(source string, definedSymbols map[string]bool) (string, []int, []PreProcessingError) {
+	return HandleRepetitionsWithCommandLine(source, definedSymbols, nil)
+}
+
+// HandleRepetitionsWithCommandLine is HandleRepetitions with the symbols
+// defined and undefined on the command line in effect from the first line,
+// as for HandleConditionalsWithCommandLine (FR-10.2).
+func HandleRepetitionsWithCommandLine(source string, definedSymbols map[string]bool, commandLine *CommandLineSymbols) (string, []int, []PreProcessingError) {
+	lines := strings.Split(source, "\n")
+
+	// Early-exit: no repetition directives (AR-8.1, AR-8.2).
+	if len(source) == 0 || (!strings.Contains(source, "%rep") &&
+		!strings.Contains(source, "%endrep") &&
+		!strings.Contains(source, "%exitrep") &&
+		!strings.Contains(source, "%assign") &&
+		!strings.Contains(source, "%strlen") &&
+		!strings.Contains(source, "%substr") &&
+		!strings.Contains(source, "%strcat")) {
+		origins := make([]int, len(lines))
+		for i := range origins {
+			origins[i] = i
+		}
+		return source, origins, make([]PreProcessingError, 0)
+	}
+
+	r := newRepeater(lines, definedSymbols, "")
+	r.conditions.seedCommandLine(commandLine)
+
+	// Macro definitions are copied as they are.
+	r.verbatim = macroDefinitionLines(lines)
+
+	r.run(0, len(lines))
+
+	return strings.Join(r.output, "\n"), r.origins, r.errors
+}
+
+// repeater evaluates %assign, %rep, %endrep and %exitrep directives over a
+// list of template lines. It is shared by HandleRepetitions, where the
+// template is the source, and by macro expansion, where it is a macro body.
+//
+// conditions tracks which lines are active and which symbols are defined; its
+// own errors are discarded, since HandleConditionals reports them. prepare,
+// if set, is applied to every template line each time it is reached and may
+// consume it by returning false; macro expansion uses it to substitute the
+// current arguments and to handle %rotate.
+type repeater struct {
+	lines      []string
+	verbatim   []bool
+	prepare    func(line string, index int) (string, bool)
+	conditions *conditionalEvaluator
+	assigned   map[string]string // Current values of %assign variables
+	context    string            // Appended to directive names in error messages, e.g. " in macro 'x'"
+	depth      int               // Number of enclosing %rep blocks
+	exitLine   int               // Template index of the %exitrep that ended the innermost block
+	output     []string
+	origins    []int
+	errors     []PreProcessingError
+	reported   map[PreProcessingError]bool
+	phase      string // Phase of the errors reported, see PreProcessingError
+}
+
+// newRepeater returns a repeater for the template lines, with definedSymbols
+// as the initially defined symbols.
+func newRepeater(lines []string, definedSymbols map[string]bool, context string) *repeater {
+	r := &repeater{
+		lines:      lines,
+		verbatim:   make([]bool, len(lines)),
+		conditions: newConditionalEvaluator(definedSymbols),
+		assigned:   make(map[string]string),
+		context:    context,
+		output:     make([]string, 0, len(lines)),
+		origins:    make([]int, 0, len(lines)),
+		errors:     make([]PreProcessingError, 0),
+		reported:   make(map[PreProcessingError]bool),
+	}
+	return r
+}
+
+// run processes the template lines [from, to) once. It returns false if an
+// %exitrep ended the innermost enclosing %rep block.
+func (r *repeater) run(from, to int) bool {
+	for i := from; i < to; i++ {
+		if r.verbatim[i] {
+			r.emit(r.lines[i], i)
+			continue
+		}
+
+		line := r.lines[i]
+		if r.prepare != nil {
+			var ok bool
+			if line, ok = r.prepare(line, i); !ok {
+				continue
+			}
+		}
+
+		directive, rest, restOffset := splitRepetitionDirective(line)
+		switch directive {
+		case "assign":
+			if !r.conditions.active() {
+				continue
+			}
+			// The directive is left behind as a %define of the value, so
+			// that later phases see the name as defined (FR-8.3.3). A
+			// previous definition is ended first, as a redefinition.
+			name, redefined, ok := r.assign(rest, restOffset, i)
+			if redefined {
+				r.emit("%undef "+name, i)
+			}
+			if ok {
+				r.emit(fmt.Sprintf("%%define %s %s", name, r.assigned[name]), i)
+			}
+		case "strlen", "substr", "strcat":
+			if !r.conditions.active() {
+				continue
+			}
+			name, redefined, ok := r.stringFunction(directive, rest, restOffset, i)
+			if redefined {
+				r.emit("%undef "+name, i)
+			}
+			if ok {
+				r.emit(fmt.Sprintf("%%define %s %s", name, r.conditions.definitions[name].body), i)
+			}
+		case "rep":
+			end := r.matchingEndrep(i, to)
+			if r.conditions.active() {
+				r.repeat(i+1, end, r.count(rest, restOffset, i))
+			}
+			i = end
+		case "endrep":
+			r.report(fmt.Sprintf("%%endrep without matching %%rep%s", r.context), i, 0)
+		case "exitrep":
+			if !r.conditions.active() {
+				continue
+			}
+			if r.depth == 0 {
+				r.report(fmt.Sprintf("%%exitrep outside of %%rep%s", r.context), i, 0)
+				continue
+			}
+			r.exitLine = i
+			return false
+		default:
+			line = r.substitute(line)
+			r.track(line, i)
+			r.emit(line, i)
+		}
+	}
+	return true
+}
+
+// repeat runs the template lines [from, to) count times, or until an
+// %exitrep. Conditional blocks left open by %exitrep are closed with an
+// %endif line, so that the output stays balanced.
+func (r *repeater) repeat(from, to int, count int64) {
+	r.depth++
+	defer func() { r.depth-- }()
+
+	for n := int64(0); n < count; n++ {
+		open := len(r.conditions.stack)
+		if r.run(from, to) {
+			continue
+		}
+		for len(r.conditions.stack) > open {
+			r.conditions.stack = r.conditions.stack[:len(r.conditions.stack)-1]
+			r.emit("%endif", r.exitLine)
+		}
+		return
+	}
+}
+
+// matchingEndrep returns the template index of the %endrep that closes the
+// %rep at index start, or to if there is none.
+func (r *repeater) matchingEndrep(start, to int) int {
+	nesting := 0
+	for i := start + 1; i < to; i++ {
+		if r.verbatim[i] {
+			continue
+		}
+		switch directive, _, _ := splitRepetitionDirective(r.lines[i]); directive {
+		case "rep":
+			nesting++
+		case "endrep":
+			if nesting == 0 {
+				return i
+			}
+			nesting--
+		}
+	}
+	r.report(fmt.Sprintf("%%rep%s has no matching %%endrep", r.context), start, 0)
+	return to
+}
+
+// count evaluates the count of a %rep directive. An invalid count is reported
+// and evaluates to zero.
+func (r *repeater) count(rest string, restOffset, index int) int64 {
+	text, offset := r.operand(rest, restOffset)
+	if text == "" {
+		r.report(fmt.Sprintf("%%rep%s requires a count", r.context), index, 0)
+		return 0
+	}
+
+	value, err := evaluateExpression(text, r.conditions.definitions, r.conditions.defined)
+	switch {
+	case err != nil:
+		r.report(fmt.Sprintf("invalid %%rep count%s: %s", r.context, err.message), index, offset+err.offset+1)
+		return 0
+	case value < 0:
+		r.report(fmt.Sprintf("%%rep count%s must not be negative, got %d", r.context, value), index, offset+1)
+		return 0
+	case value > MaxRepetitions:
+		r.report(fmt.Sprintf("%%rep count%s %d exceeds the limit of %d", r.context, value, MaxRepetitions), index, offset+1)
+		return 0
+	}
+	return value
+}
+
+// assign evaluates an %assign directive and binds the result to its name,
+// which it returns. redefined is true if the name was already defined; ok is
+// false if the directive is malformed.
+func (r *repeater) assign(rest string, restOffset, index int) (name string, redefined, ok bool) {
+	name, expression, offset, ok := r.symbolOperand("assign", rest, restOffset, index)
+	if !ok {
+		return "", false, false
+	}
+	if expression == "" {
+		r.report(fmt.Sprintf("%%assign%s requires an expression for '%s'", r.context, name), index, 0)
+		return "", false, false
+	}
+
+	value, err := evaluateExpression(expression, r.conditions.definitions, r.conditions.defined)
+	if err != nil {
+		r.report(fmt.Sprintf("invalid %%assign value for '%s'%s: %s", name, r.context, err.message), index, offset+err.offset+1)
+		return "", false, false
+	}
+
+	_, redefined = r.conditions.definitions[name]
+	r.assigned[name] = strconv.FormatInt(value, 10)
+	r.conditions.definitions[name] = definition{name: name, body: r.assigned[name], lineNumber: index + 1}
+	r.conditions.defined[name] = true
+	return name, redefined, true
+}
+
+// stringFunction evaluates the directive form of %strlen, %substr or %strcat,
+// named by directive, and binds the result to its name, which it returns
+// (FR-14.6). The string comes first; the start and length of %substr and the
+// further strings of %strcat follow it. Without a length, %substr selects a
+// single character, as in NASM. redefined and ok are as for assign. Errors
+// are reported in PhaseStringDirectives.
+func (r *repeater) stringFunction(directive, rest string, restOffset, index int) (name string, redefined, ok bool) {
+	r.phase = PhaseStringDirectives
+	defer func() { r.phase = "" }()
+
+	name, text, offset, ok := r.symbolOperand(directive, rest, restOffset, index)
+	if !ok {
+		return "", false, false
+	}
+	if text == "" {
+		r.report(fmt.Sprintf("%%%s%s requires a string for '%s'", directive, r.context, name), index, 0)
+		return "", false, false
+	}
+
+	str := nextToken(text, 0)
+	args := append([]functionArgument{{text: str.text, offset: offset}}, directiveArguments(text[str.end():], offset+str.end())...)
+	if directive == "substr" && len(args) == 2 {
+		args = append(args, functionArgument{text: "1", offset: offset + len(text)})
+	}
+	fn := token{kind: tokenDirective, text: "%" + directive, offset: restOffset - len(directive) - 1}
+	expander := &functionExpander{definitions: r.conditions.definitions, defined: r.conditions.defined, active: make(map[string]bool)}
+	value, ok := expander.call(fn, text, args)
+	for _, err := range expander.errors {
+		r.report(err.message, index, err.offset+1)
+	}
+	if !ok {
+		return "", false, false
+	}
+
+	// A string value is not substituted like an %assign value.
+	delete(r.assigned, name)
+	_, redefined = r.conditions.definitions[name]
+	r.conditions.definitions[name] = definition{name: name, body: value, lineNumber: index + 1}
+	r.conditions.defined[name] = true
+	return name, redefined, true
+}
+
+// directiveArguments splits text, the arguments of a directive that follow
+// its string, at the commas outside parentheses and brackets. offset is the
+// offset of text in the line. A comma before the first argument is skipped.
+func directiveArguments(text string, offset int) []functionArgument {
+	trimmed := strings.TrimLeft(text, " \t")
+	trimmed = strings.TrimPrefix(trimmed, ",")
+	offset += len(text) - len(trimmed)
+	if strings.TrimSpace(trimmed) == "" {
+		return nil
+	}
+
+	args := make([]functionArgument, 0, 2)
+	argument := func(from, to int) functionArgument {
+		text := strings.TrimLeft(trimmed[from:to], " \t")
+		return functionArgument{text: text, offset: offset + to - len(text)}
+	}
+	depth := 0
+	start := 0
+	for i := 0; i < len(trimmed); {
+		t := nextToken(trimmed, i)
+		i = t.end()
+		switch t.text {
+		case "(", "[":
+			depth++
+		case ")", "]":
+			depth--
+		case ",":
+			if depth == 0 {
+				args = append(args, argument(start, t.offset))
+				start = i
+			}
+		}
+	}
+	return append(args, argument(start, len(trimmed)))
+}
+
+// symbolOperand splits the operand of a directive that binds a name, such as
+// %assign, into the name and the text after it, whose 0-based offset in the
+// line it returns. A missing or invalid name is reported; ok is then false.
+func (r *repeater) symbolOperand(directive, rest string, restOffset, index int) (name, text string, offset int, ok bool) {
+	operand, offset := r.operand(rest, restOffset)
+	nameEnd := 0
+	for nameEnd < len(operand) && isSymbolChar(operand[nameEnd]) {
+		nameEnd++
+	}
+	name = operand[:nameEnd]
+	if !isSymbolName(name) || (nameEnd < len(operand) && operand[nameEnd] != ' ' && operand[nameEnd] != '\t') {
+		r.report(fmt.Sprintf("%%%s%s requires a symbol name", directive, r.context), index, offset+1)
+		return "", "", 0, false
+	}
+
+	text = strings.TrimLeft(operand[nameEnd:], " \t")
+	return name, text, offset + len(operand) - len(text), true
+}
+
+// operand returns the operand of a directive without its comment and
+// surrounding whitespace, together with its 0-based offset in the line.
+func (r *repeater) operand(rest string, restOffset int) (string, int) {
+	text := stripComment(rest)
+	trimmed := strings.TrimLeft(text, " \t")
+	return strings.TrimRight(trimmed, " \t\r"), restOffset + len(text) - len(trimmed)
+}
+
+// substitute replaces the %assign variables in line with their current
+// values. Directives are left untouched, except for the expressions of %if
+// and %elif, where the operand of defined is kept as a name.
+func (r *repeater) substitute(line string) string {
+	if len(r.assigned) == 0 {
+		return line
+	}
+
+	start := 0
+	if trimmed := strings.TrimLeft(line, " \t"); strings.HasPrefix(trimmed, "%") && len(trimmed) > 1 && isWordStart(trimmed[1]) {
+		directive, _, restOffset := splitConditionalDirective(line)
+		if directive != "if" && directive != "elif" {
+			return line
+		}
+		start = restOffset
+	}
+
+	keepNext := false
+	return line[:start] + rewriteTokens(line[start:], func(t token) (string, bool) {
+		switch {
+		case t.kind == tokenWord:
+			value, ok := r.assigned[t.text]
+			ok = ok && !keepNext
+			keepNext = t.text == "defined"
+			return value, ok
+		case t.kind != tokenSpace && t.text != "(":
+			keepNext = false
+		}
+		return "", false
+	})
+}
+
+// track feeds an output line to the conditional evaluator, so that later
+// directives know whether they are in an active branch and which symbols are
+// defined.
+func (r *repeater) track(line string, index int) {
+	if directive, rest, restOffset := splitConditionalDirective(line); directive != "" {
+		r.conditions.handleDirective(directive, rest, restOffset, index+1)
+		return
+	}
+	if r.conditions.active() {
+		r.conditions.trackDefinition(line, index+1)
+	}
+}
+
+// emit appends a line to the output.
+func (r *repeater) emit(line string, index int) {
+	r.output = append(r.output, line)
+	r.origins = append(r.origins, index)
+}
+
+// report records an error at the template line index, once.
+func (r *repeater) report(message string, index, column int) {
+	e := PreProcessingError{Message: message, Line: index + 1, Column: column, Phase: r.phase}
+	if r.reported[e] {
+		return
+	}
+	r.reported[e] = true
+
+	i := sort.Search(len(r.errors), func(n int) bool { return r.errors[n].Line > e.Line })
+	r.errors = append(r.errors, PreProcessingError{})
+	copy(r.errors[i+1:], r.errors[i:])
+	r.errors[i] = e
+}
+
+// splitRepetitionDirective returns the directive name, the remainder of the
+// line and the 0-based offset of the remainder if line is a repetition
+// directive, or empty strings otherwise.
+func splitRepetitionDirective(line string) (string, string, int) {
+	directive, rest, offset := splitDirective(line)
+	if !repetitionDirectives[directive] {
+		return "", "", 0
+	}
+	// %strlen(, %substr( and %strcat( are function calls (FR-14.1).
+	if stringDirectives[directive] && rest != "" && rest[0] != ' ' && rest[0] != '\t' {
+		return "", "", 0
+	}
+	return directive, rest, offset
+}
@@ -0,0 +1,264 @@
+package preProcessing_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+)
+
+// --- FR-8.2: %rep ---
+
+func TestHandleRepetitions_RepeatsBody(t *testing.T) {
+	source := `start:
+%rep 3
+    nop
+%endrep
+ret`
+	result, origins := mustHandleRepetitions(t, source, nil)
+
+	if result != "start:\n    nop\n    nop\n    nop\nret" {
+		t.Errorf("expected the body three times, got:\n%s", result)
+	}
+	if !reflect.DeepEqual(origins, []int{0, 2, 2, 2, 4}) {
+		t.Errorf("expected repeated lines to map to their template line, got %v", origins)
+	}
+}
+
+func TestHandleRepetitions_NestedBlocks(t *testing.T) {
+	source := `%rep 2
+a
+%rep 3
+b
+%endrep
+%endrep`
+	result, _ := mustHandleRepetitions(t, source, nil)
+
+	if result != "a\nb\nb\nb\na\nb\nb\nb" {
+		t.Errorf("expected nested blocks to multiply, got:\n%s", result)
+	}
+}
+
+func TestHandleRepetitions_CountExpression(t *testing.T) {
+	source := `%define ENTRIES 4
+%rep ENTRIES / 2 ; half
+x
+%endrep`
+	result, _ := mustHandleRepetitions(t, source, nil)
+
+	if strings.Count(result, "x") != 2 {
+		t.Errorf("expected 2 repetitions, got:\n%s", result)
+	}
+}
+
+func TestHandleRepetitions_ZeroCount(t *testing.T) {
+	source := "%rep 0\nx\n%endrep\ny"
+	result, origins := mustHandleRepetitions(t, source, nil)
+
+	if result != "y" || !reflect.DeepEqual(origins, []int{3}) {
+		t.Errorf("expected an empty block, got %q with origins %v", result, origins)
+	}
+}
+
+// --- FR-8.3: %assign ---
+
+func TestHandleRepetitions_AssignCounter(t *testing.T) {
+	source := `%assign i 0
+%rep 3
+db i ; i
+%assign i i+1
+%endrep
+dd i`
+	result, _ := mustHandleRepetitions(t, source, nil)
+
+	expected := "%define i 0\ndb 0 ; i\n%undef i\n%define i 1\ndb 1 ; i\n%undef i\n%define i 2\ndb 2 ; i\n%undef i\n%define i 3\ndd 3"
+	if result != expected {
+		t.Errorf("expected the assigned values to be substituted, got:\n%s", result)
+	}
+}
+
+func TestHandleRepetitions_AssignLeavesStringsAndDirectives(t *testing.T) {
+	source := `%assign n 2
+msg: db "n"
+%define n_copy n
+%ifdef n
+%if n == 2 && defined(n)
+n.field:
+%endif
+%endif`
+	result, _ := mustHandleRepetitions(t, source, nil)
+
+	expected := "%define n 2\nmsg: db \"n\"\n%define n_copy n\n%ifdef n\n%if 2 == 2 && defined(n)\nn.field:\n%endif\n%endif"
+	if result != expected {
+		t.Errorf("expected only words outside strings and directives to be replaced, got:\n%s", result)
+	}
+}
+
+func TestHandleRepetitions_AssignLeavesSingleQuotedStrings(t *testing.T) {
+	source := `%assign i 5
+db 'i', ';', i ; i`
+	result, _ := mustHandleRepetitions(t, source, nil)
+
+	expected := "%define i 5\ndb 'i', ';', 5 ; i"
+	if result != expected {
+		t.Errorf("expected %q, got %q", expected, result)
+	}
+}
+
+func TestHandleRepetitions_AssignInIf(t *testing.T) {
+	source := `%assign level 3
+%if level > 2
+deep
+%endif`
+	result, _ := mustHandleRepetitions(t, source, nil)
+	result = mustHandleConditionals(t, result, nil)
+
+	if !containsSubstring(result, "deep") {
+		t.Errorf("expected the %%if to see the assigned value, got:\n%s", result)
+	}
+}
+
+func TestHandleRepetitions_InactiveBranchesAreIgnored(t *testing.T) {
+	source := `%assign n 1
+%ifdef DEBUG
+%assign n 2
+%rep 100
+x
+%endrep
+%endif
+db n`
+	result, _ := mustHandleRepetitions(t, source, nil)
+
+	if containsSubstring(result, "x") || !containsSubstring(result, "db 1") {
+		t.Errorf("expected directives in inactive branches to be ignored, got:\n%s", result)
+	}
+
+	result, _ = mustHandleRepetitions(t, source, map[string]bool{"DEBUG": true})
+	if strings.Count(result, "x") != 100 || !containsSubstring(result, "db 2") {
+		t.Errorf("expected directives in active branches to apply, got:\n%s", result)
+	}
+}
+
+// --- FR-8.4: %exitrep ---
+
+func TestHandleRepetitions_Exitrep(t *testing.T) {
+	source := `%assign i 0
+%rep 10
+%if i == 3
+%exitrep
+%endif
+db i
+%assign i i+1
+%endrep
+done`
+	result, _ := mustHandleRepetitions(t, source, nil)
+	result = mustHandleConditionals(t, result, nil)
+
+	lines := make([]string, 0)
+	for _, line := range strings.Split(result, "\n") {
+		if line != "" && !strings.HasPrefix(line, "%define") && !strings.HasPrefix(line, "%undef") {
+			lines = append(lines, line)
+		}
+	}
+	if !reflect.DeepEqual(lines, []string{"db 0", "db 1", "db 2", "done"}) {
+		t.Errorf("expected %%exitrep to stop after 3 repetitions, got %v", lines)
+	}
+}
+
+func TestHandleRepetitions_ExitrepEndsInnermostBlock(t *testing.T) {
+	source := `%rep 2
+%rep 5
+b
+%exitrep
+%endrep
+a
+%endrep`
+	result, _ := mustHandleRepetitions(t, source, nil)
+
+	if result != "b\na\nb\na" {
+		t.Errorf("expected only the inner block to end, got:\n%s", result)
+	}
+}
+
+// --- FR-8.5: Macro bodies ---
+
+func TestHandleRepetitions_MacroBodiesAreCopied(t *testing.T) {
+	source := `%macro m 0
+%rep 2
+x
+%endrep
+%endmacro
+%rep 2
+m
+%endrep`
+	result, _ := mustHandleRepetitions(t, source, nil)
+
+	if result != "%macro m 0\n%rep 2\nx\n%endrep\n%endmacro\nm\nm" {
+		t.Errorf("expected the macro body to be left for macro expansion, got:\n%s", result)
+	}
+}
+
+// --- FR-8.6: Errors ---
+
+func TestHandleRepetitions_Errors(t *testing.T) {
+	tests := []struct {
+		source  string
+		message string
+		line    int
+		column  int
+	}{
+		{"%rep\n%endrep", "%rep requires a count", 1, 0},
+		{"%rep 1 +\n%endrep", "invalid %rep count: expected a value but found 'end of expression'", 1, 9},
+		{"%rep -1\n%endrep", "%rep count must not be negative, got -1", 1, 6},
+		{"%rep 0x7fffffff\n%endrep", "%rep count 2147483647 exceeds the limit of 1048576", 1, 6},
+		{"x\n%rep 2\nx", "%rep has no matching %endrep", 2, 0},
+		{"x\n%endrep", "%endrep without matching %rep", 2, 0},
+		{"%exitrep", "%exitrep outside of %rep", 1, 0},
+		{"%assign", "%assign requires a symbol name", 1, 8},
+		{"%assign 1x 2", "%assign requires a symbol name", 1, 9},
+		{"%assign x", "%assign requires an expression for 'x'", 1, 0},
+		{"%assign x 1 / 0", "invalid %assign value for 'x': division by zero", 1, 13},
+	}
+
+	for _, tt := range tests {
+		_, _, errs := preProcessing.HandleRepetitions(tt.source, nil)
+		e := requireError(t, errs, tt.message)
+		if e.Line != tt.line || e.Column != tt.column {
+			t.Errorf("%q: expected error at %d:%d, got %d:%d", tt.source, tt.line, tt.column, e.Line, e.Column)
+		}
+	}
+}
+
+func TestHandleRepetitions_ErrorInBlockIsReportedOnce(t *testing.T) {
+	source := `%rep 3
+%assign x y
+%endrep`
+	_, _, errs := preProcessing.HandleRepetitions(source, nil)
+
+	if len(errs) != 1 || errs[0].Line != 2 {
+		t.Errorf("expected a single error at line 2, got %v", errs)
+	}
+}
+
+func TestHandleRepetitions_NoDirectives(t *testing.T) {
+	source := "mov rax, 1\n%define rep 2"
+	result, origins := mustHandleRepetitions(t, source, nil)
+
+	if result != source || !reflect.DeepEqual(origins, []int{0, 1}) {
+		t.Errorf("expected the source unchanged, got %q with origins %v", result, origins)
+	}
+}
+
+// mustHandleRepetitions unrolls repetitions and fails the test on pre-processing errors.
+func mustHandleRepetitions(t *testing.T, source string, symbols map[string]bool) (string, []int) {
+	t.Helper()
+	result, origins, errs := preProcessing.HandleRepetitions(source, symbols)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected pre-processing errors: %v", errs)
+	}
+	if len(origins) != strings.Count(result, "\n")+1 {
+		t.Fatalf("expected one origin per line, got %d for:\n%s", len(origins), result)
+	}
+	return result, origins
+}
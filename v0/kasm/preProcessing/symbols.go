@@ -2,70 +2,94 @@ package preProcessing
 
 import (
 	"fmt"
-	"regexp"
 	"strings"
 )
 
-// Pre-compiled regex for %define directives (AR-6.3).
-var defineDirectiveRegex = regexp.MustCompile(`(?m)^\s*%define\s+(\w+)\s*$`)
-
 // CreateSymbolTable scans the source code for %define directives and builds
 // a symbol table mapping each defined symbol name to true.
 // Macro names from the provided macro table are also added as defined symbols.
 // It returns the symbol table for use in conditional assembly processing.
 //
+// A symbol counts as defined if it is defined anywhere in the source,
+// whatever its value (FR-3.1.4). %undef only ends a definition for the
+// purposes of duplicate detection, so that a symbol may be redefined after it
+// was undefined.
+//
 // Only valid identifier names are accepted as symbols; any malformed %define directive
 // is a pre-processing error.
 //
-// The function works in three passes:
-//  1. Collect all %define directives and their line numbers, validating each symbol name.
-//     Panics if a symbol name is empty or not a valid identifier.
-//  2. Detect duplicate %define directives and panic if any are found.
+// The function works in four passes:
+//  1. Collect all %define and %undef directives and their line numbers. A
+//     directive without a symbol name is skipped; HandleDefines reports it.
+//  2. Detect duplicate %define directives (without an %undef in between) and report
+//     each one as an error; the first definition is kept.
 //  3. Add all macro names from the macro table as defined symbols.
+//  4. Apply the command-line symbols (CreateSymbolTableWithCommandLine only).
 //     Returns the completed symbol table.
-func CreateSymbolTable(source string, macroTable map[string]Macro) map[string]bool {
-	// Early-exit: if no %define directives exist, skip regex processing (AR-8.2).
-	hasDefines := strings.Contains(source, "%define")
+func CreateSymbolTable(source string, macroTable map[string]Macro) (map[string]bool, []PreProcessingError) {
+	return CreateSymbolTableWithCommandLine(source, macroTable, nil)
+}
 
-	var matches [][]int
-	if hasDefines {
-		matches = defineDirectiveRegex.FindAllStringSubmatchIndex(source, -1)
-	}
+// CreateSymbolTableWithCommandLine is CreateSymbolTable with the symbols
+// defined and undefined on the command line (FR-10.2). Symbols defined with
+// -D are in the table and symbols undefined with -U are not, whatever the
+// source and the macro table say. %define directives for them are skipped
+// and never reported as duplicates; HandleDefines warns about them.
+func CreateSymbolTableWithCommandLine(source string, macroTable map[string]Macro, commandLine *CommandLineSymbols) (map[string]bool, []PreProcessingError) {
+	errors := make([]PreProcessingError, 0)
 
 	type symbolEntry struct {
+		directive  string
 		name       string
 		lineNumber int
 	}
 
-	entries := make([]symbolEntry, 0, len(matches))
+	entries := make([]symbolEntry, 0)
 
-	// Pass 1: collect all %define directives.
-	for _, matchIdx := range matches {
-		if len(matchIdx) < 4 {
-			continue
-		}
+	// Pass 1: collect all %define and %undef directives. Early-exit: if no
+	// %define directives exist, the source is not scanned (AR-8.2).
+	if strings.Contains(source, "%define") {
+		for i, line := range strings.Split(source, "\n") {
+			directive, rest := splitDefineDirective(line)
+			if directive == "" {
+				continue
+			}
 
-		matchStart := matchIdx[0]
-		lineNumber := strings.Count(source[:matchStart], "\n") + 1
-		symbolName := source[matchIdx[2]:matchIdx[3]]
+			text := strings.TrimLeft(rest, " \t\r")
+			end := 0
+			for end < len(text) && isSymbolChar(text[end]) {
+				end++
+			}
+			if end == 0 {
+				continue
+			}
 
-		if symbolName == "" {
-			panic(fmt.Sprintf("pre-processing error: Empty symbol name in %%define at line %d", lineNumber))
+			entries = append(entries, symbolEntry{
+				directive:  directive,
+				name:       text[:end],
+				lineNumber: i + 1,
+			})
 		}
-
-		entries = append(entries, symbolEntry{
-			name:       symbolName,
-			lineNumber: lineNumber,
-		})
 	}
 
 	// Pass 2: detect duplicates and build the symbol table.
 	seen := make(map[string]int, len(entries))
 	symbolTable := make(map[string]bool, len(entries)+len(macroTable))
 	for _, entry := range entries {
+		if commandLine.overrides(entry.name) != "" {
+			continue
+		}
+		if entry.directive == "undef" {
+			delete(seen, entry.name)
+			continue
+		}
 		if firstLine, exists := seen[entry.name]; exists {
-			panic(fmt.Sprintf("pre-processing error: Duplicate %%define for symbol '%s' at line %d (first defined at line %d)",
-				entry.name, entry.lineNumber, firstLine))
+			errors = append(errors, PreProcessingError{
+				Message:  fmt.Sprintf("duplicate %%define for symbol '%s'", entry.name),
+				Line:     entry.lineNumber,
+				Previous: firstLine,
+			})
+			continue
 		}
 		seen[entry.name] = entry.lineNumber
 		symbolTable[entry.name] = true
@@ -76,5 +100,15 @@ func CreateSymbolTable(source string, macroTable map[string]Macro) map[string]bo
 		symbolTable[macroName] = true
 	}
 
-	return symbolTable
+	// Pass 4: apply the command line, which takes precedence.
+	for _, name := range commandLine.Defined() {
+		symbolTable[name] = true
+	}
+	if commandLine != nil {
+		for name := range commandLine.undefined {
+			delete(symbolTable, name)
+		}
+	}
+
+	return symbolTable, errors
 }
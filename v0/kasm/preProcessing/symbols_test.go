@@ -12,7 +12,7 @@ import (
 
 func TestPreProcessingCreateSymbolTable_SingleDefine(t *testing.T) {
 	source := `%define DEBUG`
-	symbols := preProcessing.CreateSymbolTable(source, nil)
+	symbols := mustCreateSymbolTable(t, source, nil)
 
 	if !symbols["DEBUG"] {
 		t.Error("expected 'DEBUG' to be defined")
@@ -26,7 +26,7 @@ func TestPreProcessingCreateSymbolTable_MultipleDefines(t *testing.T) {
 	source := `%define DEBUG
 %define VERBOSE
 %define TRACE`
-	symbols := preProcessing.CreateSymbolTable(source, nil)
+	symbols := mustCreateSymbolTable(t, source, nil)
 
 	if len(symbols) != 3 {
 		t.Fatalf("expected 3 symbols, got %d", len(symbols))
@@ -41,7 +41,7 @@ func TestPreProcessingCreateSymbolTable_MultipleDefines(t *testing.T) {
 
 func TestPreProcessingCreateSymbolTable_NoDefines(t *testing.T) {
 	source := `mov rax, 1`
-	symbols := preProcessing.CreateSymbolTable(source, nil)
+	symbols := mustCreateSymbolTable(t, source, nil)
 
 	if len(symbols) != 0 {
 		t.Errorf("expected 0 symbols, got %d", len(symbols))
@@ -49,55 +49,55 @@ func TestPreProcessingCreateSymbolTable_NoDefines(t *testing.T) {
 }
 
 func TestPreProcessingCreateSymbolTable_EmptySource(t *testing.T) {
-	symbols := preProcessing.CreateSymbolTable("", nil)
+	symbols := mustCreateSymbolTable(t, "", nil)
 
 	if len(symbols) != 0 {
 		t.Errorf("expected 0 symbols, got %d", len(symbols))
 	}
 }
 
-func TestPreProcessingCreateSymbolTable_DuplicateDefine_Panics(t *testing.T) {
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatal("expected panic for duplicate define")
-		}
-		msg, ok := r.(string)
-		if !ok {
-			t.Fatalf("expected string panic, got %T", r)
-		}
-		if !containsSubstring(msg, "Duplicate %define") {
-			t.Errorf("unexpected panic message: %s", msg)
-		}
-	}()
-
+func TestPreProcessingCreateSymbolTable_DuplicateDefine_ReportsError(t *testing.T) {
 	source := `%define DEBUG
 %define DEBUG`
-	preProcessing.CreateSymbolTable(source, nil)
+	symbols, errs := preProcessing.CreateSymbolTable(source, nil)
+
+	requireError(t, errs, "duplicate %define for symbol 'DEBUG'")
+	if !symbols["DEBUG"] {
+		t.Error("expected the first definition to be kept")
+	}
 }
 
 func TestPreProcessingCreateSymbolTable_DuplicateDefine_ReportsLineNumbers(t *testing.T) {
-	defer func() {
-		r := recover()
-		if r == nil {
-			t.Fatal("expected panic for duplicate define")
-		}
-		msg, ok := r.(string)
-		if !ok {
-			t.Fatalf("expected string panic, got %T", r)
-		}
-		if !containsSubstring(msg, "at line 3") {
-			t.Errorf("expected duplicate at line 3, got: %s", msg)
-		}
-		if !containsSubstring(msg, "first defined at line 1") {
-			t.Errorf("expected first defined at line 1, got: %s", msg)
-		}
-	}()
-
 	source := `%define DEBUG
 ; comment
 %define DEBUG`
-	preProcessing.CreateSymbolTable(source, nil)
+	_, errs := preProcessing.CreateSymbolTable(source, nil)
+
+	e := requireError(t, errs, "duplicate %define")
+	if e.Line != 3 {
+		t.Errorf("expected duplicate at line 3, got %d", e.Line)
+	}
+	if e.Previous != 1 {
+		t.Errorf("expected first defined at line 1, got %d", e.Previous)
+	}
+	if !containsSubstring(e.String(), "first defined at line 1") {
+		t.Errorf("expected the string form to mention the first definition, got: %s", e.String())
+	}
+}
+
+func TestPreProcessingCreateSymbolTable_DuplicateDefine_AfterBlankLines(t *testing.T) {
+	source := `mov rax, 1
+
+%define DEBUG
+
+
+%define DEBUG`
+	_, errs := preProcessing.CreateSymbolTable(source, nil)
+
+	e := requireError(t, errs, "duplicate %define")
+	if e.Line != 6 || e.Previous != 3 {
+		t.Errorf("expected duplicate at line 6 first defined at line 3, got %d and %d", e.Line, e.Previous)
+	}
 }
 
 // --- PreProcessingCreateSymbolTable: macros as symbols ---
@@ -109,7 +109,7 @@ func TestPreProcessingCreateSymbolTable_MacrosAddedAsSymbols(t *testing.T) {
 			Name: "my_macro",
 		},
 	}
-	symbols := preProcessing.CreateSymbolTable(source, macroTable)
+	symbols := mustCreateSymbolTable(t, source, macroTable)
 
 	if !symbols["my_macro"] {
 		t.Error("expected macro 'my_macro' to be in symbol table")
@@ -123,7 +123,7 @@ func TestPreProcessingCreateSymbolTable_DefinesAndMacrosCombined(t *testing.T) {
 			Name: "my_macro",
 		},
 	}
-	symbols := preProcessing.CreateSymbolTable(source, macroTable)
+	symbols := mustCreateSymbolTable(t, source, macroTable)
 
 	if len(symbols) != 2 {
 		t.Fatalf("expected 2 symbols, got %d", len(symbols))
@@ -138,7 +138,7 @@ func TestPreProcessingCreateSymbolTable_DefinesAndMacrosCombined(t *testing.T) {
 
 func TestPreProcessingCreateSymbolTable_NilMacroTable(t *testing.T) {
 	source := `%define FOO`
-	symbols := preProcessing.CreateSymbolTable(source, nil)
+	symbols := mustCreateSymbolTable(t, source, nil)
 
 	if len(symbols) != 1 {
 		t.Fatalf("expected 1 symbol, got %d", len(symbols))
@@ -155,7 +155,7 @@ func TestPreProcessingCreateSymbolTable_MultipleMacros(t *testing.T) {
 		"macro_b": {Name: "macro_b"},
 		"macro_c": {Name: "macro_c"},
 	}
-	symbols := preProcessing.CreateSymbolTable(source, macroTable)
+	symbols := mustCreateSymbolTable(t, source, macroTable)
 
 	if len(symbols) != 3 {
 		t.Fatalf("expected 3 symbols, got %d", len(symbols))
@@ -171,7 +171,7 @@ func TestPreProcessingCreateSymbolTable_MultipleMacros(t *testing.T) {
 
 func TestPreProcessingCreateSymbolTable_LeadingWhitespace(t *testing.T) {
 	source := `   %define DEBUG`
-	symbols := preProcessing.CreateSymbolTable(source, nil)
+	symbols := mustCreateSymbolTable(t, source, nil)
 
 	if !symbols["DEBUG"] {
 		t.Error("expected 'DEBUG' to be defined despite leading whitespace")
@@ -180,7 +180,7 @@ func TestPreProcessingCreateSymbolTable_LeadingWhitespace(t *testing.T) {
 
 func TestPreProcessingCreateSymbolTable_TabIndent(t *testing.T) {
 	source := "\t%define DEBUG"
-	symbols := preProcessing.CreateSymbolTable(source, nil)
+	symbols := mustCreateSymbolTable(t, source, nil)
 
 	if !symbols["DEBUG"] {
 		t.Error("expected 'DEBUG' to be defined despite tab indent")
@@ -192,7 +192,7 @@ func TestPreProcessingCreateSymbolTable_TabIndent(t *testing.T) {
 func TestPreProcessingCreateSymbolTable_IgnoresComments(t *testing.T) {
 	source := `; %define NOT_A_SYMBOL
 %define REAL_SYMBOL`
-	symbols := preProcessing.CreateSymbolTable(source, nil)
+	symbols := mustCreateSymbolTable(t, source, nil)
 
 	if len(symbols) != 1 {
 		t.Fatalf("expected 1 symbol, got %d", len(symbols))
@@ -210,7 +210,7 @@ func TestPreProcessingCreateSymbolTable_IgnoresInlineMacroDirectives(t *testing.
     mov rax, %1
 %endmacro
 %define ENABLED`
-	symbols := preProcessing.CreateSymbolTable(source, nil)
+	symbols := mustCreateSymbolTable(t, source, nil)
 
 	if !symbols["ENABLED"] {
 		t.Error("expected 'ENABLED' to be defined")
@@ -317,3 +317,13 @@ func BenchmarkPreProcessingCreateSymbolTable_ManyDefinesWithMacros(b *testing.B)
 		preProcessing.CreateSymbolTable(source, macroTable)
 	}
 }
+
+// mustCreateSymbolTable builds the symbol table and fails the test on pre-processing errors.
+func mustCreateSymbolTable(t *testing.T, source string, macroTable map[string]preProcessing.Macro) map[string]bool {
+	t.Helper()
+	symbols, errs := preProcessing.CreateSymbolTable(source, macroTable)
+	if len(errs) != 0 {
+		t.Fatalf("unexpected pre-processing errors: %v", errs)
+	}
+	return symbols
+}
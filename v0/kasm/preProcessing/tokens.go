@@ -0,0 +1,203 @@
+package preProcessing
+
+import "strings"
+
+// This file holds the pre-processor's tokeniser (FR-13). Directives, macro
+// calls, macro parameters and macro-local labels are recognised on its
+// tokens, so that text inside string literals and comments is never taken
+// for one of them, and every line is scanned once.
+//
+// Tokens follow the lexical rules of the kasm lexer, but keep the exact text
+// of the line, whitespace and comments included, so that a line can be
+// rewritten token by token. The lexer itself is not used: it drops
+// whitespace and comments, needs an architecture profile to classify words,
+// and records its diagnostics in a debug context, which the pre-processor
+// must not import (AR-2.2).
+
+// tokenKind classifies a pre-processor token.
+type tokenKind int
+
+const (
+	tokenSpace     tokenKind = iota // A run of spaces, tabs and carriage returns.
+	tokenWord                       // A word of the lexer's word characters: name, point.x, ..@1.loop.
+	tokenNumber                     // A digit followed by word characters: 42, 0x2A, 10h.
+	tokenString                     // A "…" or '…' literal, possibly unterminated.
+	tokenComment                    // A ';' comment, up to the end of the line.
+	tokenDirective                  // A '%' followed by a word: %macro, %if.
+	tokenParameter                  // A macro parameter reference: %0, %N or %{N}.
+	tokenLocal                      // A macro-local label reference: %%name.
+	tokenPaste                      // The token pasting operator: %+.
+	tokenPunct                      // Any other single character.
+)
+
+// token is a token of a single line. text is the exact source text of the
+// token and offset its byte offset in the line.
+type token struct {
+	kind   tokenKind
+	text   string
+	offset int
+}
+
+// end returns the offset just past the token.
+func (t token) end() int {
+	return t.offset + len(t.text)
+}
+
+// nextToken returns the token of line starting at offset, which must be less
+// than len(line).
+func nextToken(line string, offset int) token {
+	ch := line[offset]
+	end := offset + 1
+	kind := tokenPunct
+
+	switch {
+	case ch == ' ' || ch == '\t' || ch == '\r':
+		kind = tokenSpace
+		for end < len(line) && (line[end] == ' ' || line[end] == '\t' || line[end] == '\r') {
+			end++
+		}
+	case ch == ';':
+		kind, end = tokenComment, len(line)
+	case ch == '"' || ch == '\'':
+		kind, end = tokenString, skipQuoted(line, offset)
+	case isDigit(ch):
+		kind, end = tokenNumber, scanWord(line, end)
+	case isWordStart(ch):
+		kind, end = tokenWord, scanWord(line, end)
+	case ch == '%':
+		kind, end = scanPercent(line, offset)
+	}
+
+	return token{kind: kind, text: line[offset:end], offset: offset}
+}
+
+// scanPercent returns the kind and end offset of the token starting with the
+// '%' at offset. A '%' that starts no directive, parameter, macro-local label
+// or token pasting operator is punctuation.
+func scanPercent(line string, offset int) (tokenKind, int) {
+	next := offset + 1
+	switch {
+	case next >= len(line):
+	case line[next] == '%':
+		end := next + 1
+		for end < len(line) && isSymbolChar(line[end]) {
+			end++
+		}
+		if end > next+1 {
+			return tokenLocal, end
+		}
+	case isDigit(line[next]):
+		end := next + 1
+		for end < len(line) && isDigit(line[end]) {
+			end++
+		}
+		return tokenParameter, end
+	case line[next] == '{':
+		end := next + 1
+		for end < len(line) && isDigit(line[end]) {
+			end++
+		}
+		if end > next+1 && end < len(line) && line[end] == '}' {
+			return tokenParameter, end + 1
+		}
+	case line[next] == '+':
+		return tokenPaste, next + 1
+	case isWordStart(line[next]):
+		return tokenDirective, scanWord(line, next)
+	}
+	return tokenPunct, next
+}
+
+// tokenizeLine returns the tokens of line, in order. Their texts concatenate
+// to line.
+func tokenizeLine(line string) []token {
+	tokens := make([]token, 0, 8)
+	for offset := 0; offset < len(line); {
+		t := nextToken(line, offset)
+		tokens = append(tokens, t)
+		offset = t.end()
+	}
+	return tokens
+}
+
+// firstToken returns the first token of line that is not whitespace. ok is
+// false if the line is blank.
+func firstToken(line string) (t token, ok bool) {
+	if line == "" {
+		return token{}, false
+	}
+	if t = nextToken(line, 0); t.kind != tokenSpace {
+		return t, true
+	}
+	if t.end() == len(line) {
+		return token{}, false
+	}
+	return nextToken(line, t.end()), true
+}
+
+// operandTokens returns the tokens of text other than whitespace.
+func operandTokens(text string) []token {
+	tokens := make([]token, 0, 4)
+	for _, t := range tokenizeLine(text) {
+		if t.kind != tokenSpace {
+			tokens = append(tokens, t)
+		}
+	}
+	return tokens
+}
+
+// splitDirective returns the name of the directive that starts line, without
+// its '%', the remainder of the line after the name and the offset of that
+// remainder in line. The name is empty if line does not start with a
+// directive.
+func splitDirective(line string) (name string, rest string, restOffset int) {
+	t, ok := firstToken(line)
+	if !ok || t.kind != tokenDirective {
+		return "", "", 0
+	}
+	return t.text[1:], line[t.end():], t.end()
+}
+
+// splitInvocation returns the word that starts line and the remainder of the
+// line after it, if the word stands alone: it is followed by whitespace, a
+// comment or the end of the line. This is the shape of a macro call. ok is
+// false for any other line, including labels (name:) and dotted operands.
+func splitInvocation(line string) (name string, rest string, ok bool) {
+	t, ok := firstToken(line)
+	if !ok || t.kind != tokenWord {
+		return "", "", false
+	}
+	if end := t.end(); end < len(line) {
+		if next := nextToken(line, end); next.kind != tokenSpace && next.kind != tokenComment {
+			return "", "", false
+		}
+	}
+	return t.text, line[t.end():], true
+}
+
+// rewriteTokens returns line with every token for which replace returns ok
+// replaced by its replacement. Tokens inside string literals and comments
+// are single tokens, so replace never sees text inside them.
+func rewriteTokens(line string, replace func(t token) (replacement string, ok bool)) string {
+	var sb strings.Builder
+	written := 0
+	for offset := 0; offset < len(line); {
+		t := nextToken(line, offset)
+		offset = t.end()
+		replacement, ok := replace(t)
+		if !ok {
+			continue
+		}
+		if written == 0 {
+			sb.Grow(len(line))
+		}
+		sb.WriteString(line[written:t.offset])
+		sb.WriteString(replacement)
+		written = offset
+	}
+	if written == 0 {
+		return line
+	}
+	sb.WriteString(line[written:])
+	return sb.String()
+}
@@ -0,0 +1,56 @@
+package preProcessing_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/keurnel/assembler/v0/kasm"
+	"github.com/keurnel/assembler/v0/kasm/preProcessing"
+	"github.com/keurnel/assembler/v0/kasm/profile"
+)
+
+// --- FR-13.1: Tokenisation ---
+
+// TestTokenizer_MatchesLexer verifies FR-13.1: the tokeniser and the lexer
+// find the same string literals, at the same offsets, and the lexer emits no
+// token after the point where the tokeniser finds a comment.
+func TestTokenizer_MatchesLexer(t *testing.T) {
+	corpus := []string{
+		`db "a;b" ; c`,
+		`db 'a;b' ; c`,
+		`db "it's" ; it's`,
+		`db 'say "hi"', 0`,
+		`db "", ''`,
+		`db "a""b"`,
+		`db "unterminated ; x`,
+		`db 'unterminated ; x`,
+		`db "`,
+		`; "not a string"`,
+		`mov rax, 1 ;; "twice"`,
+		`mov rax, 1;no space`,
+		`%define S "a;b" ; c`,
+		`db "%1", '%%x' ; %1`,
+		"msg: db \"tab\tinside\"",
+		`db ";", ';', "'", '"'`,
+	}
+
+	for _, line := range corpus {
+		literals, offsets, comment := preProcessing.LineStrings(line)
+
+		lexerLiterals := make([]string, 0)
+		lexerOffsets := make([]int, 0)
+		for _, tok := range kasm.LexerNew(line, profile.NewX8664Profile()).Start() {
+			if tok.Type == kasm.TokenString {
+				lexerLiterals = append(lexerLiterals, tok.Literal)
+				lexerOffsets = append(lexerOffsets, tok.Column-1)
+			}
+			if comment >= 0 && tok.Column-1 >= comment {
+				t.Errorf("%q: the lexer emitted %q inside the comment at offset %d", line, tok.Literal, comment)
+			}
+		}
+
+		if !slices.Equal(literals, lexerLiterals) || !slices.Equal(offsets, lexerOffsets) {
+			t.Errorf("%q: expected the strings %q at %v, the lexer found %q at %v", line, literals, offsets, lexerLiterals, lexerOffsets)
+		}
+	}
+}
@@ -8,7 +8,7 @@ type MacroParameter struct {
 // MacroCall represents a single invocation of a macro in the source code.
 type MacroCall struct {
 	Name       string   // Name of the macro being called
-	Arguments  []string // Arguments passed to the macro call, in the order they are provided
+	Arguments  []string // Arguments passed to the macro call, in the order they are provided, followed by defaults for omitted optional parameters
 	LineNumber int      // Line number in the source code where the macro call occurs (for error reporting and debugging)
 }
 
@@ -16,8 +16,13 @@ type MacroCall struct {
 type Macro struct {
 	Name       string                    // Name of the macro
 	Parameters map[string]MacroParameter // Parameters of the macro, indexed by their names
-	Body       string                    // Body of the macro, which may contain the code to be expanded when the macro is invoked
-	Calls      []MacroCall               // Calls to this macro found in the source code
+	// MinArguments and MaxArguments bound the number of arguments a call may pass (%macro name 1-3). MaxArguments
+	// is -1 for a variadic macro (%macro name 1-*).
+	MinArguments int
+	MaxArguments int
+	Defaults     []string    // Default values for the optional parameters MinArguments+1, MinArguments+2, …
+	Body         string      // Body of the macro, which may contain the code to be expanded when the macro is invoked
+	Calls        []MacroCall // Calls to this macro found in the source code
 }
 
 // Inclusion represents a single %include directive found in the source code.
@@ -26,27 +31,24 @@ type Inclusion struct {
 	LineNumber       int    // Line number in the source code where the inclusion occurs (for error reporting and debugging)
 }
 
-// conditionalBlock represents a single conditional assembly block found in the source code, including its directive
-// type, associated symbol, and the positions of its components for error reporting and debugging.
-type conditionalBlock struct {
-	ifDirective string
-	symbol      string
-	ifStart     int
-	ifEnd       int
-	elseStart   int
-	elseEnd     int
-	endifStart  int
-	endifEnd    int
-	lineNumber  int
+// conditionalFrame represents an open conditional block on the stack used by HandleConditionals. A block is
+// active while its current branch is retained; taken records whether any branch has been retained so far, so
+// that later %elif and %else branches are skipped.
+type conditionalFrame struct {
+	directive    string // Opening directive: "if", "ifdef" or "ifndef"
+	line         int    // Line of the opening directive
+	parentActive bool   // Whether the enclosing block is active
+	active       bool
+	taken        bool
+	elseLine     int // Line of the %else directive, or 0
 }
 
-// stackEntry represents an entry in the stack used to track nested conditional directives during pre-processing.
-type stackEntry struct {
-	directive  string
-	symbol     string
-	start      int
-	end        int
-	lineNumber int
-	elseStart  int
-	elseEnd    int
+// definition represents a single %define directive: a name, the replacement text it expands to, and, for
+// parameterised defines (%define NAME(a, b) body), the names of its parameters.
+type definition struct {
+	name          string
+	hasParameters bool     // Whether the define takes an argument list, even an empty one
+	parameters    []string // Parameter names, in declaration order
+	body          string   // Replacement text; empty for flag-only defines
+	lineNumber    int
 }
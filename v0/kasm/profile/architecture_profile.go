@@ -26,6 +26,12 @@ type ArchitectureProfile interface {
 func defaultKeywords() map[string]bool {
 	return map[string]bool{
 		"namespace": true,
+		// Structure definitions and instances.
+		"struc": true, "endstruc": true,
+		"istruc": true, "at": true, "iend": true,
+		// Reservation and data keywords used inside them.
+		"resb": true, "resw": true, "resd": true, "resq": true,
+		"db": true, "dw": true, "dd": true, "dq": true,
 	}
 }
 
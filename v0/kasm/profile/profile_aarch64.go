@@ -0,0 +1,69 @@
+package profile
+
+import "fmt"
+
+func init() {
+	Register("aarch64", NewAArch64Profile)
+}
+
+// NewAArch64Profile returns an ArchitectureProfile populated with the AArch64
+// general-purpose registers (x0–x30, w0–w30, sp, wsp, xzr, wzr and the
+// fp/lr aliases), the AArch64 instruction set, and the default keyword set.
+// Like the other profiles, it is fully assembled at construction time and
+// immutable afterwards.
+func NewAArch64Profile() ArchitectureProfile {
+	return &staticProfile{
+		registers:    aarch64Registers(),
+		instructions: aarch64Instructions(),
+		keywords:     defaultKeywords(),
+	}
+}
+
+// aarch64Registers returns the 64-bit (x) and 32-bit (w) views of the
+// general-purpose registers together with the stack pointer, the zero
+// registers, and the frame pointer / link register aliases of x29 / x30.
+func aarch64Registers() map[string]bool {
+	registers := map[string]bool{
+		"sp": true, "wsp": true, "xzr": true, "wzr": true,
+		"fp": true, "lr": true,
+	}
+	for i := 0; i <= 30; i++ {
+		registers[fmt.Sprintf("x%d", i)] = true
+		registers[fmt.Sprintf("w%d", i)] = true
+	}
+	return registers
+}
+
+// aarch64Instructions returns the AArch64 mnemonic set (lower-case). These
+// mnemonics must match those provided by v0/architecture/arm/aarch64.
+func aarch64Instructions() map[string]bool {
+	instructions := map[string]bool{
+		// Data processing
+		"add": true, "adds": true, "sub": true, "subs": true,
+		"cmp": true, "cmn": true, "neg": true,
+		"and": true, "ands": true, "orr": true, "eor": true,
+		"bic": true, "orn": true, "tst": true, "mvn": true,
+		"mov": true, "movz": true, "movn": true, "movk": true,
+		"lsl": true, "lsr": true, "asr": true,
+		"mul": true, "madd": true, "msub": true, "udiv": true, "sdiv": true,
+		"adr": true, "adrp": true,
+		// Load and store
+		"ldr": true, "str": true, "ldrb": true, "strb": true,
+		"ldrh": true, "strh": true, "ldrsw": true,
+		"ldp": true, "stp": true,
+		// Branch
+		"b": true, "bl": true, "cbz": true, "cbnz": true,
+		"br": true, "blr": true, "ret": true,
+		// System
+		"svc": true, "brk": true, "nop": true,
+		// Custom
+		"use": true,
+	}
+	for _, cond := range []string{
+		"eq", "ne", "cs", "hs", "cc", "lo", "mi", "pl",
+		"vs", "vc", "hi", "ls", "ge", "lt", "gt", "le", "al",
+	} {
+		instructions["b."+cond] = true
+	}
+	return instructions
+}
@@ -0,0 +1,76 @@
+package profile
+
+import "fmt"
+
+func init() {
+	Register("rv64", NewRV64Profile)
+}
+
+// NewRV64Profile returns an ArchitectureProfile populated with the RISC-V
+// RV64 register set (numeric and ABI names), the RV64I/M/A instruction set,
+// and the default keyword set. Like the x86_64 profile, it is fully assembled
+// at construction time and immutable afterwards.
+func NewRV64Profile() ArchitectureProfile {
+	return &staticProfile{
+		registers:    rv64Registers(),
+		instructions: rv64Instructions(),
+		keywords:     defaultKeywords(),
+	}
+}
+
+// rv64Registers returns the integer register file under both its numeric
+// names (x0–x31) and its ABI names (lower-case). `fp` is accepted as an alias
+// of `s0`.
+func rv64Registers() map[string]bool {
+	registers := map[string]bool{
+		"zero": true, "ra": true, "sp": true, "gp": true, "tp": true, "fp": true,
+		"t0": true, "t1": true, "t2": true, "t3": true, "t4": true, "t5": true, "t6": true,
+		"s0": true, "s1": true, "s2": true, "s3": true, "s4": true, "s5": true,
+		"s6": true, "s7": true, "s8": true, "s9": true, "s10": true, "s11": true,
+		"a0": true, "a1": true, "a2": true, "a3": true,
+		"a4": true, "a5": true, "a6": true, "a7": true,
+	}
+	for i := 0; i < 32; i++ {
+		registers[fmt.Sprintf("x%d", i)] = true
+	}
+	return registers
+}
+
+// rv64Instructions returns the RV64I/M/A mnemonic set (lower-case). These
+// mnemonics must match those provided by v0/architecture/riscv/rv64.
+func rv64Instructions() map[string]bool {
+	return map[string]bool{
+		// Integer computational
+		"add": true, "sub": true, "sll": true, "slt": true, "sltu": true,
+		"xor": true, "srl": true, "sra": true, "or": true, "and": true,
+		"addw": true, "subw": true, "sllw": true, "srlw": true, "sraw": true,
+		"addi": true, "slti": true, "sltiu": true, "xori": true, "ori": true, "andi": true,
+		"slli": true, "srli": true, "srai": true,
+		"addiw": true, "slliw": true, "srliw": true, "sraiw": true,
+		"lui": true, "auipc": true,
+		"nop": true, "mv": true, "li": true, "not": true,
+		// Load and store
+		"lb": true, "lh": true, "lw": true, "ld": true,
+		"lbu": true, "lhu": true, "lwu": true,
+		"sb": true, "sh": true, "sw": true, "sd": true,
+		// Control flow
+		"beq": true, "bne": true, "blt": true, "bge": true, "bltu": true, "bgeu": true,
+		"beqz": true, "bnez": true,
+		"jal": true, "jalr": true, "j": true, "call": true, "ret": true,
+		// Multiply and divide
+		"mul": true, "mulh": true, "mulhsu": true, "mulhu": true,
+		"div": true, "divu": true, "rem": true, "remu": true,
+		"mulw": true, "divw": true, "divuw": true, "remw": true, "remuw": true,
+		// Atomic
+		"lr.w": true, "sc.w": true, "amoswap.w": true, "amoadd.w": true, "amoxor.w": true,
+		"amoand.w": true, "amoor.w": true, "amomin.w": true, "amomax.w": true,
+		"amominu.w": true, "amomaxu.w": true,
+		"lr.d": true, "sc.d": true, "amoswap.d": true, "amoadd.d": true, "amoxor.d": true,
+		"amoand.d": true, "amoor.d": true, "amomin.d": true, "amomax.d": true,
+		"amominu.d": true, "amomaxu.d": true,
+		// System
+		"ecall": true, "ebreak": true, "fence": true,
+		// Custom
+		"use": true,
+	}
+}
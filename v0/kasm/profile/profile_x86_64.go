@@ -14,6 +14,10 @@ func (p *staticProfile) Registers() map[string]bool    { return p.registers }
 func (p *staticProfile) Instructions() map[string]bool { return p.instructions }
 func (p *staticProfile) Keywords() map[string]bool     { return p.keywords }
 
+func init() {
+	Register("x86_64", NewX8664Profile)
+}
+
 // NewX8664Profile returns an ArchitectureProfile populated with the x86_64
 // register set, instruction set, and the default keyword set. Because all
 // three sets are assembled at construction time, the returned profile is
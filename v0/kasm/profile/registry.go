@@ -0,0 +1,31 @@
+package profile
+
+import "fmt"
+
+// profiles maps lower-case canonical architecture names (see
+// architecture.Register) to the constructor of their lexer vocabulary.
+var profiles = make(map[string]func() ArchitectureProfile)
+
+// Register adds the profile constructor for the canonical architecture name.
+// Profile files call it from their init function. Names are matched
+// case-insensitively; registering a name twice is a programming error and
+// panics.
+func Register(name string, constructor func() ArchitectureProfile) {
+	if name == "" || constructor == nil {
+		panic("profile: Register requires a name and a constructor")
+	}
+
+	key := toLower(name)
+	if _, exists := profiles[key]; exists {
+		panic(fmt.Sprintf("profile: '%s' is already registered", name))
+	}
+	profiles[key] = constructor
+}
+
+// Lookup returns the profile constructor registered for the canonical
+// architecture name. Aliases are resolved by the architecture registry, not
+// here.
+func Lookup(name string) (func() ArchitectureProfile, bool) {
+	constructor, exists := profiles[toLower(name)]
+	return constructor, exists
+}
@@ -28,6 +28,14 @@ type namespaceDecl struct {
 	Column int
 }
 
+// strucDecl tracks where a structure was declared and its computed layout.
+type strucDecl struct {
+	Name   string
+	Layout strucLayout
+	Line   int
+	Column int
+}
+
 // useDecl tracks where a module was imported.
 type useDecl struct {
 	Name   string
@@ -49,6 +57,19 @@ type LineMapper interface {
 	Origin(lineNumber int) int
 }
 
+// originLine translates a 1-based pre-processed line number to its 1-based
+// original source line number using m. Returns the input unchanged when m is
+// nil or the line was inserted during pre-processing.
+func originLine(m LineMapper, line int) int {
+	if m == nil {
+		return line
+	}
+	if orig := m.Origin(line - 1); orig >= 0 {
+		return orig + 1
+	}
+	return line
+}
+
 // Analyser validates a *ast.Program AST against the rules of the .kasm language
 // and the target architecture. It detects errors that are syntactically legal
 // but semantically invalid. If an Analyser value exists, it is guaranteed to
@@ -59,6 +80,8 @@ type Analyser struct {
 	labels       map[string]labelDecl
 	namespaces   map[string]namespaceDecl
 	modules      map[string]useDecl
+	strucs       map[string]strucDecl
+	strucSymbols map[string]int64 // NAME.field and NAME_size of every structure.
 	errors       []SemanticError
 	debugCtx     *debugcontext.DebugContext
 	lineMapper   LineMapper
@@ -81,6 +104,8 @@ func AnalyserNew(program *ast.Program, instructions map[string]architecture.Inst
 		labels:       make(map[string]labelDecl),
 		namespaces:   make(map[string]namespaceDecl),
 		modules:      make(map[string]useDecl),
+		strucs:       make(map[string]strucDecl),
+		strucSymbols: make(map[string]int64),
 		errors:       make([]SemanticError, 0),
 	}
 }
@@ -158,8 +183,8 @@ func (a *Analyser) Analyse() []SemanticError {
 // Pass 1: Collection
 // ---------------------------------------------------------------------------
 
-// collect gathers all label, namespace, and use declarations into lookup
-// tables. Duplicate declarations are recorded as errors immediately.
+// collect gathers all label, namespace, use and structure declarations into
+// lookup tables. Duplicate declarations are recorded as errors immediately.
 func (a *Analyser) collect() {
 	for _, stmt := range a.program.Statements {
 		switch s := stmt.(type) {
@@ -169,22 +194,27 @@ func (a *Analyser) collect() {
 			a.collectNamespace(s)
 		case *ast.UseStmt:
 			a.collectUse(s)
+		case *ast.StrucStmt:
+			a.collectStruc(s)
 		}
 	}
+
+	layouts := make(map[string]strucLayout, len(a.strucs))
+	for name, decl := range a.strucs {
+		layouts[name] = decl.Layout
+	}
+	a.strucSymbols = strucSymbols(layouts)
+
+	// A label shadows a structure symbol of the same name.
+	for name := range a.labels {
+		delete(a.strucSymbols, name)
+	}
 }
 
 // mapLine translates a 1-based pre-processed line number to its 1-based
-// original source line number using the attached LineMapper. Returns the
-// input unchanged when no mapper is set or the line was inserted during
-// pre-processing.
+// original source line number using the attached LineMapper.
 func (a *Analyser) mapLine(line int) int {
-	if a.lineMapper == nil {
-		return line
-	}
-	if orig := a.lineMapper.Origin(line - 1); orig >= 0 {
-		return orig + 1
-	}
-	return line
+	return originLine(a.lineMapper, line)
 }
 
 // collectLabel adds a label to the label table or records a duplicate error.
@@ -223,6 +253,32 @@ func (a *Analyser) collectUse(s *ast.UseStmt) {
 	a.modules[s.ModuleName] = useDecl{Name: s.ModuleName, Line: s.Line, Column: s.Column}
 }
 
+// collectStruc adds a structure to the table or records a duplicate error.
+// Duplicate field names within the structure are recorded as well (FR-10.1).
+func (a *Analyser) collectStruc(s *ast.StrucStmt) {
+	if prev, exists := a.strucs[s.Name]; exists {
+		a.addError(
+			fmt.Sprintf("duplicate structure '%s', previously declared at %d:%d", s.Name, a.mapLine(prev.Line), prev.Column),
+			s.Line, s.Column,
+		)
+		return
+	}
+
+	seen := make(map[string]*ast.StrucField, len(s.Fields))
+	for _, f := range s.Fields {
+		if prev, exists := seen[f.Name]; exists {
+			a.addError(
+				fmt.Sprintf("duplicate field '%s' in structure '%s', previously declared at %d:%d", f.Name, s.Name, a.mapLine(prev.Line), prev.Column),
+				f.Line, f.Column,
+			)
+			continue
+		}
+		seen[f.Name] = f
+	}
+
+	a.strucs[s.Name] = strucDecl{Name: s.Name, Layout: layoutStruc(s), Line: s.Line, Column: s.Column}
+}
+
 // ---------------------------------------------------------------------------
 // Pass 2: Validation
 // ---------------------------------------------------------------------------
@@ -241,6 +297,12 @@ func (a *Analyser) validate() {
 			a.validateUse(s)
 		case *ast.DirectiveStmt:
 			a.validateDirective(s)
+		case *ast.IncbinStmt:
+			a.validateIncbin(s)
+		case *ast.StrucStmt:
+			a.validateStruc(s)
+		case *ast.IstrucStmt:
+			a.validateIstruc(s)
 		}
 	}
 }
@@ -287,6 +349,10 @@ func (a *Analyser) validateOperands(s *ast.InstructionStmt) {
 			a.validateMemoryOperand(o)
 		case *ast.IdentifierOperand:
 			a.validateIdentifierReference(o)
+		case *ast.RelocationOperand:
+			a.validateRelocation(o)
+		case *ast.LiteralOperand:
+			a.validateLiteral(o)
 		}
 	}
 }
@@ -296,7 +362,7 @@ func (a *Analyser) validateOperands(s *ast.InstructionStmt) {
 func (a *Analyser) validateVariantMatch(s *ast.InstructionStmt, instr *architecture.Instruction) {
 	operandTypes := make([]string, len(s.Operands))
 	for i, op := range s.Operands {
-		operandTypes[i] = operandSemanticType(op)
+		operandTypes[i] = a.operandType(op)
 	}
 
 	// Try exact match first.
@@ -328,6 +394,17 @@ func (a *Analyser) validateVariantMatch(s *ast.InstructionStmt, instr *architect
 	}
 }
 
+// operandType maps an operand to its semantic type string. A structure
+// symbol is a constant and matches immediate slots (FR-10.5).
+func (a *Analyser) operandType(op ast.Operand) string {
+	if id, ok := op.(*ast.IdentifierOperand); ok {
+		if _, exists := a.strucSymbols[id.Name]; exists {
+			return "immediate"
+		}
+	}
+	return operandSemanticType(op)
+}
+
 // operandSemanticType maps an AST ast.Operand node to its semantic type string.
 func operandSemanticType(op ast.Operand) string {
 	switch op.(type) {
@@ -341,6 +418,11 @@ func operandSemanticType(op ast.Operand) string {
 		return "identifier"
 	case *ast.StringOperand:
 		return "string"
+	case *ast.RelocationOperand:
+		// A relocation resolves to a numeric value and matches immediate slots.
+		return "immediate"
+	case *ast.LiteralOperand:
+		return "literal"
 	default:
 		return "unknown"
 	}
@@ -422,6 +504,9 @@ func (a *Analyser) validateIdentifierReference(o *ast.IdentifierOperand) {
 	if _, exists := a.labels[o.Name]; exists {
 		return // Resolved to a label.
 	}
+	if _, exists := a.strucSymbols[o.Name]; exists {
+		return // Resolved to a structure offset or size (FR-10.5).
+	}
 	// Check if it matches a namespace-qualified pattern (future extension).
 	// For now, record an undefined reference error.
 	a.addError(
@@ -430,6 +515,35 @@ func (a *Analyser) validateIdentifierReference(o *ast.IdentifierOperand) {
 	)
 }
 
+// validateRelocation checks that a relocation function is supported and that
+// its symbol refers to a declared label.
+func (a *Analyser) validateRelocation(o *ast.RelocationOperand) {
+	if !relocationFunctions["%"+o.Function] {
+		a.addError(
+			fmt.Sprintf("unknown relocation function '%%%s'", o.Function),
+			o.Line, o.Column,
+		)
+		return
+	}
+	if _, exists := a.labels[o.Symbol]; !exists {
+		a.addError(
+			fmt.Sprintf("undefined reference to '%s'", o.Symbol),
+			o.Line, o.Column,
+		)
+	}
+}
+
+// validateLiteral validates the value of a literal pool reference: an
+// immediate must be well-formed and a label must be declared.
+func (a *Analyser) validateLiteral(o *ast.LiteralOperand) {
+	switch v := o.Value.(type) {
+	case *ast.ImmediateOperand:
+		a.validateImmediate(v)
+	case *ast.IdentifierOperand:
+		a.validateIdentifierReference(v)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Namespace validation (FR-5)
 // ---------------------------------------------------------------------------
@@ -473,6 +587,113 @@ func (a *Analyser) validateDirective(s *ast.DirectiveStmt) {
 	)
 }
 
+// validateIncbin checks that the offset and length of an %incbin directive
+// are valid, non-negative immediates (FR-7.3).
+func (a *Analyser) validateIncbin(s *ast.IncbinStmt) {
+	if s.Path == "" {
+		a.addError("%incbin file path must not be empty", s.Line, s.Column)
+	}
+	for _, o := range []*ast.ImmediateOperand{s.Offset, s.Length} {
+		if o == nil {
+			continue
+		}
+		if strings.HasPrefix(o.Value, "-") {
+			a.addError(
+				fmt.Sprintf("%%incbin offset and length must not be negative, got '%s'", o.Value),
+				o.Line, o.Column,
+			)
+			continue
+		}
+		a.validateImmediate(o)
+	}
+}
+
+// ---------------------------------------------------------------------------
+// Structure validation (FR-10)
+// ---------------------------------------------------------------------------
+
+// validateStruc checks that every field of a structure reserves a valid,
+// non-negative number of units (FR-10.2).
+func (a *Analyser) validateStruc(s *ast.StrucStmt) {
+	for _, f := range s.Fields {
+		if strings.HasPrefix(f.Count.Value, "-") {
+			a.addError(
+				fmt.Sprintf("structure field count must not be negative, got '%s'", f.Count.Value),
+				f.Count.Line, f.Count.Column,
+			)
+			continue
+		}
+		a.validateImmediate(f.Count)
+	}
+}
+
+// validateIstruc checks an initialised structure instance against the layout
+// of its structure (FR-10.3): every `at` line names a field of that
+// structure, the fields are initialised in order without overlapping, the
+// values fit their unit width and nothing runs past the end of the
+// structure.
+func (a *Analyser) validateIstruc(s *ast.IstrucStmt) {
+	decl, exists := a.strucs[s.Name]
+	if !exists {
+		a.addError(
+			fmt.Sprintf("unknown structure '%s'", s.Name),
+			s.Line, s.Column,
+		)
+		return
+	}
+
+	end := int64(0) // End of the previous at line's values.
+	for _, v := range s.Values {
+		field, ok := decl.Layout.field(v.Field)
+		if !ok {
+			a.addError(
+				fmt.Sprintf("'%s' is not a field of structure '%s'", v.Field, s.Name),
+				v.Line, v.Column,
+			)
+			continue
+		}
+		if field.offset < end {
+			a.addError(
+				fmt.Sprintf("field '%s' is initialised out of order or overlaps the previous value", v.Field),
+				v.Line, v.Column,
+			)
+		}
+
+		size := int64(0)
+		for _, op := range v.Operands {
+			data, err := atOperandBytes(dataSizes[v.Data], op)
+			if err != nil {
+				a.addError(err.Error(), op.OperandLine(), op.OperandColumn())
+				continue
+			}
+			size += int64(len(data))
+		}
+		if field.offset+size > decl.Layout.size {
+			a.addError(
+				fmt.Sprintf("values for field '%s' overrun structure '%s' of %d byte(s)", v.Field, s.Name, decl.Layout.size),
+				v.Line, v.Column,
+			)
+		}
+		end = field.offset + size
+	}
+}
+
+// validateStrucReference checks that an identifier of the shape NAME.field,
+// where NAME is a declared structure, names one of its fields (FR-9.5).
+// Other identifiers are left alone.
+func (a *Analyser) validateStrucReference(tok Token) {
+	owner := strucOwner(tok.Literal)
+	if _, exists := a.strucs[owner]; !exists {
+		return
+	}
+	if _, exists := a.strucSymbols[tok.Literal]; !exists {
+		a.addError(
+			fmt.Sprintf("unknown field '%s' in structure '%s'", tok.Literal[len(owner)+1:], owner),
+			tok.Line, tok.Column,
+		)
+	}
+}
+
 // ---------------------------------------------------------------------------
 // Immediate value validation (FR-8)
 // ---------------------------------------------------------------------------
@@ -485,9 +706,20 @@ func (a *Analyser) validateImmediate(o *ast.ImmediateOperand) {
 		return
 	}
 
+	// A leading '-' marks a negative literal; the digits that follow are
+	// validated like any other literal.
+	digits := strings.TrimPrefix(val, "-")
+	if digits == "" {
+		a.addError(
+			fmt.Sprintf("invalid immediate value '%s'", val),
+			o.Line, o.Column,
+		)
+		return
+	}
+
 	// Hex literal: 0x or 0X prefix.
-	if len(val) >= 2 && val[0] == '0' && (val[1] == 'x' || val[1] == 'X') {
-		hexPart := val[2:]
+	if len(digits) >= 2 && digits[0] == '0' && (digits[1] == 'x' || digits[1] == 'X') {
+		hexPart := digits[2:]
 		if len(hexPart) == 0 {
 			a.addError(
 				fmt.Sprintf("invalid immediate value '%s'", val),
@@ -508,7 +740,7 @@ func (a *Analyser) validateImmediate(o *ast.ImmediateOperand) {
 	}
 
 	// Decimal literal.
-	for _, ch := range val {
+	for _, ch := range digits {
 		if ch < '0' || ch > '9' {
 			a.addError(
 				fmt.Sprintf("invalid immediate value '%s'", val),
@@ -548,6 +780,10 @@ func (a *Analyser) validateMemoryOperand(o *ast.MemoryOperand) {
 	// FR-9.4: Validate operators.
 	for _, comp := range o.Components {
 		tok := comp.Token
+		// FR-9.5: A NAME.field displacement must name a field of NAME.
+		if tok.Type == TokenIdentifier {
+			a.validateStrucReference(tok)
+		}
 		if tok.Type == TokenIdentifier && len(tok.Literal) == 1 {
 			ch := tok.Literal[0]
 			if ch == '+' || ch == '-' {
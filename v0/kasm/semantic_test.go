@@ -431,6 +431,100 @@ func TestAnalyse_UnrecognisedDirective(t *testing.T) {
 	requireErrorContains(t, errors, 0, "unrecognised directive '%foobar'")
 }
 
+func TestAnalyse_Incbin(t *testing.T) {
+	program := &ast.Program{
+		Statements: []ast.Statement{
+			&ast.IncbinStmt{
+				Path:   "font.bin",
+				Offset: &ast.ImmediateOperand{Value: "0x10", Line: 1, Column: 20},
+				Length: &ast.ImmediateOperand{Value: "8", Line: 1, Column: 26},
+				Line:   1,
+				Column: 1,
+			},
+		},
+	}
+	errors := kasm.AnalyserNew(program, minimalInstructions()).Analyse()
+	requireSemanticErrorCount(t, errors, 0)
+}
+
+func TestAnalyse_IncbinNegativeOffset(t *testing.T) {
+	program := &ast.Program{
+		Statements: []ast.Statement{
+			&ast.IncbinStmt{
+				Path:   "font.bin",
+				Offset: &ast.ImmediateOperand{Value: "-1", Line: 1, Column: 20},
+				Line:   1,
+				Column: 1,
+			},
+		},
+	}
+	errors := kasm.AnalyserNew(program, minimalInstructions()).Analyse()
+	requireSemanticErrorCount(t, errors, 1)
+	requireErrorContains(t, errors, 0, "must not be negative, got '-1'")
+}
+
+// ---------------------------------------------------------------------------
+// FR-10: Structure validation
+// ---------------------------------------------------------------------------
+
+// analyseRV64 runs lexer → parser → analyser for RV64 source.
+func analyseRV64(t *testing.T, source string) []kasm.SemanticError {
+	t.Helper()
+	tokens := kasm.LexerNew(source, profile.NewRV64Profile()).Start()
+	program, parseErrors := kasm.ParserNew(tokens).Parse()
+	if len(parseErrors) != 0 {
+		t.Fatalf("unexpected parse errors: %v", parseErrors)
+	}
+	return kasm.AnalyserNew(program, rv64InstrTable()).Analyse()
+}
+
+func TestAnalyse_Struc(t *testing.T) {
+	errors := analyseRV64(t, `struc task
+    .pid:   resd 1
+    .name:  resb 8
+endstruc
+    ld a0, [a1 + task.name]
+    lw a0, [a1 + task_size]
+    li a0, task_size
+    addi a0, a0, task.name
+    istruc task
+        at task.pid,  dd 0xFFFFFFFF
+        at task.name, db "idle", 0
+    iend`)
+	requireNoSemanticErrors(t, errors)
+}
+
+func TestAnalyse_StrucErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		source  string
+		message string
+	}{
+		{"duplicate structure", "struc a\nendstruc\nstruc a\nendstruc", "duplicate structure 'a', previously declared at 1:1"},
+		{"duplicate field", "struc a\n.x: resb 1\n.x: resb 1\nendstruc", "duplicate field 'x' in structure 'a', previously declared at 2:1"},
+		{"negative count", "struc a\n.x: resb -1\nendstruc", "structure field count must not be negative, got '-1'"},
+		{"unknown field reference", "struc a\n.x: resb 1\nendstruc\nlb a0, [a1 + a.y]", "unknown field 'y' in structure 'a'"},
+		{"symbol as jump target", "struc a\n.x: resb 1\nendstruc\nj a_size", "no variant of 'j' accepts operands (immediate)"},
+		{"unknown structure", "istruc b\niend", "unknown structure 'b'"},
+		{"foreign field", "struc a\n.x: resb 1\nendstruc\nistruc a\nat b.x, db 1\niend", "'b.x' is not a field of structure 'a'"},
+		{"out of order", "struc a\n.x: resb 1\n.y: resb 1\nendstruc\nistruc a\nat a.y, db 1\nat a.x, db 1\niend", "field 'a.x' is initialised out of order or overlaps the previous value"},
+		{"overlap", "struc a\n.x: resb 1\n.y: resb 1\nendstruc\nistruc a\nat a.x, dw 1\nat a.y, db 1\niend", "field 'a.y' is initialised out of order or overlaps the previous value"},
+		{"overrun", "struc a\n.x: resb 2\nendstruc\nistruc a\nat a.x, db \"abc\"\niend", "values for field 'a.x' overrun structure 'a' of 2 byte(s)"},
+		{"value too large", "struc a\n.x: resw 1\nendstruc\nistruc a\nat a.x, dw 0x10000\niend", "value '0x10000' does not fit in 2 byte(s)"},
+		{"value too small", "struc a\n.x: resb 1\nendstruc\nistruc a\nat a.x, db -129\niend", "value '-129' does not fit in 1 byte(s)"},
+		{"string with dd", "struc a\n.x: resd 1\nendstruc\nistruc a\nat a.x, dd \"ab\"\niend", "string values are only allowed with db"},
+		{"register value", "struc a\n.x: resd 1\nendstruc\nistruc a\nat a.x, dd a0\niend", "expected immediate or string value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			errors := analyseRV64(t, tt.source)
+			requireSemanticErrorCount(t, errors, 1)
+			requireErrorContains(t, errors, 0, tt.message)
+		})
+	}
+}
+
 // ---------------------------------------------------------------------------
 // FR-8: Immediate value validation
 // ---------------------------------------------------------------------------
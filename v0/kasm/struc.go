@@ -0,0 +1,138 @@
+package kasm
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/keurnel/assembler/v0/kasm/ast"
+)
+
+// reservationSizes maps each reservation keyword of a structure field to the
+// size in bytes of the unit it reserves.
+var reservationSizes = map[string]int64{
+	"resb": 1,
+	"resw": 2,
+	"resd": 4,
+	"resq": 8,
+}
+
+// dataSizes maps each data keyword of an `at` line to the size in bytes of
+// every value it emits.
+var dataSizes = map[string]int64{
+	"db": 1,
+	"dw": 2,
+	"dd": 4,
+	"dq": 8,
+}
+
+// strucLayout is the computed layout of a `struc … endstruc` block: the
+// offset and size of every field, in declaration order, and the total size.
+type strucLayout struct {
+	name   string
+	size   int64
+	fields []strucFieldLayout
+}
+
+// strucFieldLayout is a single field of a strucLayout. symbol is the full
+// offset symbol, NAME.field.
+type strucFieldLayout struct {
+	symbol string
+	offset int64
+	size   int64
+}
+
+// layoutStruc computes the layout of s. Fields are laid out back to back,
+// without padding. A count that is not a valid non-negative immediate
+// reserves nothing; the semantic analyser reports it.
+func layoutStruc(s *ast.StrucStmt) strucLayout {
+	layout := strucLayout{name: s.Name, fields: make([]strucFieldLayout, 0, len(s.Fields))}
+	for _, f := range s.Fields {
+		size := int64(0)
+		if count, err := parseImmediateValue(f.Count.Value); err == nil && count > 0 {
+			size = count * reservationSizes[f.Reserve]
+		}
+		layout.fields = append(layout.fields, strucFieldLayout{
+			symbol: strucFieldSymbol(s.Name, f.Name),
+			offset: layout.size,
+			size:   size,
+		})
+		layout.size += size
+	}
+	return layout
+}
+
+// field returns the field of l with the given offset symbol.
+func (l strucLayout) field(symbol string) (strucFieldLayout, bool) {
+	for _, f := range l.fields {
+		if f.symbol == symbol {
+			return f, true
+		}
+	}
+	return strucFieldLayout{}, false
+}
+
+// strucFieldSymbol returns the offset symbol of field in the structure name.
+func strucFieldSymbol(name, field string) string {
+	return name + "." + field
+}
+
+// strucSizeSymbol returns the size symbol of the structure name.
+func strucSizeSymbol(name string) string {
+	return name + "_size"
+}
+
+// strucOwner returns the structure name of a NAME.field symbol, or "" if
+// symbol is not of that shape.
+func strucOwner(symbol string) string {
+	dot := strings.LastIndexByte(symbol, '.')
+	if dot <= 0 {
+		return ""
+	}
+	return symbol[:dot]
+}
+
+// strucSymbols returns the value of every symbol defined by the structures
+// of layouts: NAME.field is the offset of the field and NAME_size the size
+// of the structure.
+func strucSymbols(layouts map[string]strucLayout) map[string]int64 {
+	symbols := make(map[string]int64)
+	for _, layout := range layouts {
+		for _, f := range layout.fields {
+			symbols[f.symbol] = f.offset
+		}
+		symbols[strucSizeSymbol(layout.name)] = layout.size
+	}
+	return symbols
+}
+
+// atOperandBytes returns the little-endian bytes a single value of an `at`
+// line emits with the given unit width. Immediates must fit the width,
+// either as a signed or as an unsigned value. Strings are only allowed with
+// db and emit their bytes as written.
+func atOperandBytes(width int64, op ast.Operand) ([]byte, error) {
+	switch o := op.(type) {
+	case *ast.ImmediateOperand:
+		value, err := parseImmediateValue(o.Value)
+		if err != nil {
+			return nil, err
+		}
+		if width < 8 {
+			bits := uint(width * 8)
+			if value < -(int64(1)<<(bits-1)) || value >= int64(1)<<bits {
+				return nil, fmt.Errorf("value '%s' does not fit in %d byte(s)", o.Value, width)
+			}
+		}
+		data := make([]byte, width)
+		for i := range data {
+			data[i] = byte(uint64(value) >> (8 * uint(i)))
+		}
+		return data, nil
+	case *ast.StringOperand:
+		if width != 1 {
+			return nil, fmt.Errorf("string values are only allowed with db")
+		}
+		return []byte(o.Value), nil
+	default:
+		return nil, fmt.Errorf("expected immediate or string value")
+	}
+}
@@ -0,0 +1,5 @@
+package kasm
+
+// Version is the version of the assembler. It is reported by the CLI's
+// --version flag and predefined as __KASM_VERSION__ for the source.
+const Version = "0.1.0"